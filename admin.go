@@ -0,0 +1,138 @@
+package xrpc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// gcPauses is the number of latest GC pauses in RuntimeStats.
+const gcPauses = 16
+
+// WithDebugEndpoints adds net/http/pprof and runtime stats to the admin
+// handler, for requests carrying "Authorization: Bearer token". They stay
+// off with an empty token.
+func WithDebugEndpoints(token string) ServerOption {
+	return func(s *Server) {
+		s.debugToken = token
+	}
+}
+
+// WithAdminRoute mounts h on the admin handler at pattern, as with
+// http.ServeMux, for the endpoints of packages built on the server.
+func WithAdminRoute(pattern string, h http.Handler) ServerOption {
+	return func(s *Server) {
+		if s.adminRoutes == nil {
+			s.adminRoutes = make(map[string]http.Handler)
+		}
+		s.adminRoutes[pattern] = h
+	}
+}
+
+// RuntimeStats are the runtime figures of the process.
+type RuntimeStats struct {
+	Goroutines   int
+	HeapAlloc    uint64 // bytes of allocated heap objects
+	HeapInuse    uint64
+	HeapSys      uint64
+	NumGC        uint32
+	PauseTotal   time.Duration
+	RecentPauses []time.Duration // latest GC pauses, newest first
+}
+
+// ReadRuntimeStats returns the runtime stats of the process. It stops the
+// world briefly, like runtime.ReadMemStats.
+func ReadRuntimeStats() RuntimeStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	stats := RuntimeStats{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  ms.HeapAlloc,
+		HeapInuse:  ms.HeapInuse,
+		HeapSys:    ms.HeapSys,
+		NumGC:      ms.NumGC,
+		PauseTotal: time.Duration(ms.PauseTotalNs),
+	}
+	for i := uint32(0); i < ms.NumGC && i < gcPauses; i++ {
+		// PauseNs is a ring, the latest pause at (NumGC+255)%256
+		stats.RecentPauses = append(stats.RecentPauses, time.Duration(ms.PauseNs[(ms.NumGC-1-i)%uint32(len(ms.PauseNs))]))
+	}
+	return stats
+}
+
+// AdminHandler returns an HTTP handler exposing server internals. Mount it
+// on a private listener:
+//
+//	/requests    recent requests, see WithRequestTrace
+//	/channelz    listeners and connections of this server
+//	/methods     registered methods, see Describe
+//	/servicemap  signatures of the methods, see ExportServiceMap
+//	/openrpc     OpenRPC document, titled by the title and version queries
+//	/metrics     calls by method and code, SLO burn rates, see WriteOpenMetrics
+//
+// With WithDebugEndpoints, also:
+//
+//	/debug/pprof/  profiles of net/http/pprof
+//	/runtime       goroutines, heap and GC pauses, see ReadRuntimeStats
+//
+// and the routes of WithAdminRoute.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/requests", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, s.RecentRequests())
+	})
+	mux.HandleFunc("/channelz", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, s.Channelz())
+	})
+	mux.HandleFunc("/methods", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, s.Describe())
+	})
+	mux.HandleFunc("/servicemap", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, s.ExportServiceMap())
+	})
+	mux.HandleFunc("/openrpc", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		writeJSON(w, s.OpenRPC(OpenRPCInfo{Title: q.Get("title"), Version: q.Get("version")}))
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		_ = s.WriteOpenMetrics(w)
+	})
+	if s.debugToken != "" {
+		mux.Handle("/debug/pprof/", s.debugAuth(http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", s.debugAuth(http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", s.debugAuth(http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", s.debugAuth(http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", s.debugAuth(http.HandlerFunc(pprof.Trace)))
+		mux.Handle("/runtime", s.debugAuth(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeJSON(w, ReadRuntimeStats())
+		})))
+	}
+	for pattern, h := range s.adminRoutes {
+		mux.Handle(pattern, h)
+	}
+	return mux
+}
+
+// debugAuth lets through the requests carrying the debug token.
+func (s *Server) debugAuth(h http.Handler) http.Handler {
+	want := []byte("Bearer " + s.debugToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
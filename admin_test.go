@@ -0,0 +1,60 @@
+package xrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_AdminDebugEndpoints(t *testing.T) {
+	get := func(h http.Handler, path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	h := NewServerWithCodec(nil).AdminHandler()
+	assert.Equal(t, http.StatusNotFound, get(h, "/runtime", "").Code)
+	assert.Equal(t, http.StatusNotFound, get(h, "/debug/pprof/", "").Code)
+
+	h = NewServerWithCodec(nil, WithDebugEndpoints("secret")).AdminHandler()
+	assert.Equal(t, http.StatusUnauthorized, get(h, "/runtime", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(h, "/debug/pprof/", "Bearer wrong").Code)
+	assert.Equal(t, http.StatusOK, get(h, "/methods", "").Code)
+
+	runtime.GC()
+	w := get(h, "/runtime", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats RuntimeStats
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.True(t, stats.Goroutines > 0)
+	assert.True(t, stats.HeapAlloc > 0)
+	assert.True(t, stats.NumGC > 0)
+	assert.NotEmpty(t, stats.RecentPauses)
+
+	w = get(h, "/debug/pprof/goroutine?debug=1", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+}
+
+func TestServer_AdminRoute(t *testing.T) {
+	h := NewServerWithCodec(nil, WithAdminRoute("/extra", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("extra"))
+	}))).AdminHandler()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/extra", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "extra", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/methods", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
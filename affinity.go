@@ -0,0 +1,79 @@
+package xrpc
+
+import (
+	"sync"
+	"time"
+)
+
+// affinity pins sessions to servers.
+type affinity struct {
+	key string
+	ttl time.Duration
+
+	mu        sync.Mutex
+	pins      map[string]pin
+	lastSweep time.Time
+}
+
+type pin struct {
+	addr    string
+	expires time.Time
+}
+
+// SetAffinity makes calls carrying the metadata key stick to the server
+// first chosen for its value, the session, while the session keeps calling
+// within ttl. A session moves to another server when its server is removed
+// or its connection fails. An empty key turns affinity off.
+func (m *MultiClient) SetAffinity(key string, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key == "" {
+		m.affinity = nil
+		return
+	}
+	m.affinity = &affinity{key: key, ttl: ttl, pins: make(map[string]pin)}
+}
+
+// get returns the server of session, if it's pinned to one which is still
+// known.
+func (a *affinity) get(session string, known func(addr string) bool) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	p, ok := a.pins[session]
+	if !ok || now.After(p.expires) || !known(p.addr) {
+		delete(a.pins, session)
+		return "", false
+	}
+	p.expires = now.Add(a.ttl)
+	a.pins[session] = p
+	return p.addr, true
+}
+
+func (a *affinity) set(session, addr string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(a.lastSweep) > a.ttl {
+		for s, p := range a.pins {
+			if now.After(p.expires) {
+				delete(a.pins, s)
+			}
+		}
+		a.lastSweep = now
+	}
+	a.pins[session] = pin{addr: addr, expires: now.Add(a.ttl)}
+}
+
+// unpin drops the pin of session to addr, e.g. after its connection failed.
+func (a *affinity) unpin(session, addr string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p, ok := a.pins[session]; ok && p.addr == addr {
+		delete(a.pins, session)
+	}
+}
@@ -0,0 +1,72 @@
+package xrpc
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// WarningKey is the response metadata key of the warning sent back by
+// deprecated methods.
+const WarningKey = "xrpc-warning"
+
+type deprecation struct {
+	warning string
+	calls   uint64
+}
+
+// Alias makes calls to alias ("Int.Add") go to target ("Int.Sum").
+func (s *Server) Alias(alias, target string) error {
+	if _, _, err := parseFromRPCMethod(alias); err != nil {
+		return err
+	}
+	if _, _, err := parseFromRPCMethod(target); err != nil {
+		return err
+	}
+	if _, dup := s.aliases.LoadOrStore(alias, target); dup {
+		return errors.New("rpc: alias already defined: " + alias)
+	}
+	return nil
+}
+
+// Deprecate marks a method or an alias as deprecated. Calls still succeed,
+// but their response carries the warning under WarningKey and are counted,
+// see DeprecatedCalls.
+func (s *Server) Deprecate(method, warning string) {
+	if warning == "" {
+		warning = method + " is deprecated"
+	}
+	s.deprecated.Store(method, &deprecation{warning: warning})
+}
+
+// DeprecatedCalls returns the number of calls to each deprecated method.
+func (s *Server) DeprecatedCalls() map[string]uint64 {
+	calls := make(map[string]uint64)
+	s.deprecated.Range(func(key, value interface{}) bool {
+		calls[key.(string)] = atomic.LoadUint64(&value.(*deprecation).calls)
+		return true
+	})
+	return calls
+}
+
+// resolveAlias returns the method an alias stands for.
+func (s *Server) resolveAlias(method string) string {
+	if target, ok := s.aliases.Load(method); ok {
+		return target.(string)
+	}
+	return method
+}
+
+// warnDeprecated adds the deprecation warning of method, or of the
+// method its alias resolved to, to resp.
+func (s *Server) warnDeprecated(resp Response, methods ...string) {
+	for _, method := range methods {
+		d, ok := s.deprecated.Load(method)
+		if !ok {
+			continue
+		}
+		dep := d.(*deprecation)
+		atomic.AddUint64(&dep.calls, 1)
+		resp.SetMetadata(resp.GetMetadata().Join(Metadata{WarningKey: dep.warning}))
+		return
+	}
+}
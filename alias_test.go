@@ -0,0 +1,35 @@
+package xrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_AliasDeprecate(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.Alias("Int.Add", "Int.Sum"))
+	assert.NotNil(t, s.Alias("Int.Add", "Int.Sum"))
+	assert.NotNil(t, s.Alias("Add", "Int.Sum"))
+	s.Deprecate("Int.Add", "use Int.Sum")
+
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	var md Metadata
+	assert.Nil(t, c.Call("Int.Add", &Args{A: 1, B: 2}, &sum, WithResponseMetadata(&md)))
+	assert.Equal(t, 3, sum)
+	assert.Equal(t, "use Int.Sum", md.Get(WarningKey))
+
+	md = nil
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, WithResponseMetadata(&md)))
+	assert.Empty(t, md)
+
+	s.Deprecate("Int.Sum", "")
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, WithResponseMetadata(&md)))
+	assert.Equal(t, "Int.Sum is deprecated", md.Get(WarningKey))
+
+	assert.Equal(t, map[string]uint64{"Int.Add": 1, "Int.Sum": 1}, s.DeprecatedCalls())
+}
@@ -0,0 +1,193 @@
+package xrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// An HTTP call may carry binary attachments in a multipart body: the first
+// part is the request, as the codec encodes it, and each other part an
+// attachment, referenced from the params by AttachmentRef of its content
+// id. This spares uploads the bloat of base64 in JSON.
+
+// Attachment is a binary part of a multipart call.
+type Attachment struct {
+	ContentID   string
+	ContentType string // defaults to application/octet-stream
+	Filename    string
+	Data        []byte
+}
+
+type (
+	attachmentsKey         struct{}
+	outgoingAttachmentsKey struct{}
+)
+
+// WithAttachments accepts multipart HTTP requests of up to maxSize bytes,
+// whose attachments the handlers get with GetAttachment.
+func WithAttachments(maxSize int64) ServerOption {
+	return func(s *Server) {
+		s.maxAttachments = maxSize
+	}
+}
+
+// WithAttachment sends a as an attachment of the call, which must go to an
+// http:// or https:// address. Repeated options add attachments.
+func WithAttachment(a Attachment) CallOption {
+	return func(o *callOptions) {
+		o.attached = append(o.attached, a)
+	}
+}
+
+// AttachmentRef is the reference to the attachment of content id cid, to
+// be sent in the params.
+func AttachmentRef(cid string) string {
+	return "cid:" + cid
+}
+
+// GetAttachment returns the attachment of the request being handled that
+// ref, as returned by AttachmentRef, or its bare content id refers to.
+func GetAttachment(ctx context.Context, ref string) (*Attachment, bool) {
+	attachments, _ := ctx.Value(attachmentsKey{}).(map[string]*Attachment)
+	a, ok := attachments[strings.TrimPrefix(ref, "cid:")]
+	return a, ok
+}
+
+// isMultipart reports whether contentType is that of a multipart body.
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(mediaType, "multipart/")
+}
+
+// readMultipart reads the requests and attachments of the multipart body
+// of req.
+func (s *Server) readMultipart(req *http.Request) ([]Request, map[string]*Attachment, *Error) {
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, nil, &Error{ErrCode: InvalidRequest, ErrMsg: "rpc: multipart request without boundary"}
+	}
+	body := &io.LimitedReader{R: req.Body, N: s.maxAttachments + 1}
+	mr := multipart.NewReader(body, params["boundary"])
+	tooLarge := func() *Error {
+		return &Error{ErrCode: InvalidRequest, ErrMsg: fmt.Sprintf("rpc: multipart request larger than %d bytes", s.maxAttachments)}
+	}
+
+	var (
+		reqs        []Request
+		attachments = make(map[string]*Attachment)
+	)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if body.N <= 0 {
+				return nil, nil, tooLarge()
+			}
+			return nil, nil, &Error{ErrCode: ParseErr, ErrMsg: err.Error()}
+		}
+		data, err := ioutil.ReadAll(part)
+		if body.N <= 0 {
+			return nil, nil, tooLarge()
+		}
+		if err != nil {
+			return nil, nil, &Error{ErrCode: ParseErr, ErrMsg: err.Error()}
+		}
+
+		if reqs == nil {
+			if !s.acceptsContentType(part.Header.Get("Content-Type")) {
+				return nil, nil, &Error{ErrCode: InvalidRequest, ErrMsg: "unsupported content type of the request part, want " + s.codec.ContentType()}
+			}
+			if reqs, err = s.codec.ReadRequest(data); err != nil {
+				return nil, nil, &Error{ErrCode: ParseErr, ErrMsg: err.Error()}
+			}
+			continue
+		}
+
+		cid := strings.Trim(part.Header.Get("Content-Id"), "<>")
+		if cid == "" {
+			cid = part.FormName()
+		}
+		if cid == "" {
+			return nil, nil, &Error{ErrCode: InvalidRequest, ErrMsg: "rpc: attachment without content id"}
+		}
+		if _, dup := attachments[cid]; dup {
+			return nil, nil, &Error{ErrCode: InvalidRequest, ErrMsg: fmt.Sprintf("rpc: duplicate attachment %q", cid)}
+		}
+		contentType := part.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		attachments[cid] = &Attachment{ContentID: cid, ContentType: contentType, Filename: part.FileName(), Data: data}
+	}
+	if reqs == nil {
+		return nil, nil, &Error{ErrCode: InvalidRequest, ErrMsg: "rpc: multipart request without a request part"}
+	}
+	return reqs, attachments, nil
+}
+
+func withAttachments(ctx context.Context, attachments map[string]*Attachment) context.Context {
+	return context.WithValue(ctx, attachmentsKey{}, attachments)
+}
+
+func withOutgoingAttachments(ctx context.Context, attachments []Attachment) context.Context {
+	return context.WithValue(ctx, outgoingAttachmentsKey{}, attachments)
+}
+
+// encodeMultipart returns the multipart body carrying the encoded request
+// body and the attachments, and its content type.
+func encodeMultipart(body []byte, contentType string, attachments []Attachment) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="request"`)
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = w.Write(body); err != nil {
+		return nil, "", err
+	}
+
+	for _, a := range attachments {
+		if a.ContentID == "" {
+			return nil, "", errors.New("rpc: attachment without content id")
+		}
+		h := make(textproto.MIMEHeader)
+		disposition := map[string]string{"name": a.ContentID}
+		if a.Filename != "" {
+			disposition["filename"] = a.Filename
+		}
+		h.Set("Content-Disposition", mime.FormatMediaType("form-data", disposition))
+		h.Set("Content-Id", "<"+a.ContentID+">")
+		if a.ContentType != "" {
+			h.Set("Content-Type", a.ContentType)
+		} else {
+			h.Set("Content-Type", "application/octet-stream")
+		}
+		if w, err = mw.CreatePart(h); err != nil {
+			return nil, "", err
+		}
+		if _, err = w.Write(a.Data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
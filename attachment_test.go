@@ -0,0 +1,70 @@
+package xrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Files struct{}
+
+type FileInfo struct {
+	Name, Type string
+	Size       int
+}
+
+func (f *Files) Stat(ctx context.Context, ref *string) (*FileInfo, error) {
+	a, ok := GetAttachment(ctx, *ref)
+	if !ok {
+		return nil, errors.New("no attachment " + *ref)
+	}
+	return &FileInfo{Name: a.Filename, Type: a.ContentType, Size: len(a.Data)}, nil
+}
+
+func TestAttachments(t *testing.T) {
+	s := NewServerWithCodec(nil, WithAttachments(1<<10))
+	assert.Nil(t, s.Register(new(Files)))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := NewClientWithCodec(nil, srv.URL)
+	defer c.Close()
+
+	var info FileInfo
+	photo := Attachment{ContentID: "photo", ContentType: "image/png", Filename: "me.png", Data: []byte{0x89, 'P', 'N', 'G', 0}}
+	assert.Nil(t, c.CallContext(context.Background(), "Files.Stat", AttachmentRef("photo"), &info, WithAttachment(photo)))
+	assert.Equal(t, FileInfo{Name: "me.png", Type: "image/png", Size: 5}, info)
+
+	err := c.CallContext(context.Background(), "Files.Stat", AttachmentRef("other"), &info, WithAttachment(photo))
+	assert.Contains(t, err.Error(), "no attachment cid:other")
+
+	big := Attachment{ContentID: "big", Data: bytes.Repeat([]byte{1}, 2<<10)}
+	err = c.CallContext(context.Background(), "Files.Stat", AttachmentRef("big"), &info, WithAttachment(big))
+	assert.Contains(t, err.Error(), "multipart request larger than 1024 bytes")
+
+	err = c.CallContext(context.Background(), "Files.Stat", AttachmentRef("x"), &info, WithAttachment(Attachment{}))
+	assert.EqualError(t, err, "rpc: attachment without content id")
+
+	tcp := NewClientWithCodec(nil, "127.0.0.1:1")
+	defer tcp.Close()
+	err = tcp.CallContext(context.Background(), "Files.Stat", AttachmentRef("photo"), &info, WithAttachment(photo))
+	assert.EqualError(t, err, "rpc: attachments need an http:// or https:// address")
+}
+
+func TestAttachments_offByDefault(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Files)))
+
+	body, contentType, err := encodeMultipart([]byte("request"), "", nil)
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
@@ -0,0 +1,205 @@
+package xrpc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAuditTag       = "audit"
+	defaultAuditQueue     = 1024
+	defaultAuditBatch     = 100
+	defaultAuditFlushTime = time.Second
+)
+
+// AuditRecord describes a call of an audited method.
+type AuditRecord struct {
+	Time       time.Time     `json:"time"`
+	Method     string        `json:"method"`
+	Principal  string        `json:"principal,omitempty"`
+	Tenant     string        `json:"tenant,omitempty"`
+	Peer       string        `json:"peer,omitempty"`
+	ParamsHash string        `json:"params_hash"` // hex SHA-256 of the encoded params
+	Code       Code          `json:"code"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// AuditStore persists audit records, e.g. to a file, a database or a
+// message queue. WriteAudit is called from a single goroutine.
+type AuditStore interface {
+	WriteAudit(records []AuditRecord) error
+}
+
+type AuditConfig struct {
+	// Tag selects the audited methods, see WithMethodTags. Defaults to
+	// "audit".
+	Tag string
+	// QueueSize bounds the records waiting to be written; records are
+	// dropped rather than slowing calls down. Defaults to 1024.
+	QueueSize int
+	// BatchSize bounds the records per write. Defaults to 100.
+	BatchSize int
+	// FlushInterval bounds how long records wait for a full batch.
+	// Defaults to 1s.
+	FlushInterval time.Duration
+	// Logger logs failed writes. Defaults to log.Default().
+	Logger *log.Logger
+}
+
+// Auditor writes audit records in the background, in batches. Close it to
+// flush the pending records.
+type Auditor struct {
+	store   AuditStore
+	cfg     AuditConfig
+	dropped uint64
+	done    chan struct{}
+
+	mu      sync.RWMutex // guards closed and sending on records
+	closed  bool
+	records chan AuditRecord
+}
+
+func NewAuditor(store AuditStore, cfg AuditConfig) *Auditor {
+	if cfg.Tag == "" {
+		cfg.Tag = defaultAuditTag
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultAuditQueue
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultAuditBatch
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultAuditFlushTime
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	a := &Auditor{
+		store:   store,
+		cfg:     cfg,
+		records: make(chan AuditRecord, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// WithAuditor audits the calls of the methods tagged with the tag of a.
+func WithAuditor(a *Auditor) ServerOption {
+	return func(s *Server) {
+		s.auditor = a
+	}
+}
+
+// Dropped returns the number of records dropped because the queue was full.
+func (a *Auditor) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Close writes the pending records and stops the auditor. Records of later
+// calls are dropped.
+func (a *Auditor) Close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.records)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+func (a *Auditor) audits(mType *methodType) bool {
+	return hasTag(mType, a.cfg.Tag)
+}
+
+func (a *Auditor) add(rec AuditRecord) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		atomic.AddUint64(&a.dropped, 1)
+		return
+	}
+	select {
+	case a.records <- rec:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+	}
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditRecord, 0, a.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.store.WriteAudit(batch); err != nil {
+			a.cfg.Logger.Printf("rpc: could not write %d audit records: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case rec, ok := <-a.records:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, rec); len(batch) >= a.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func paramsHash(params []byte) string {
+	sum := sha256.Sum256(params)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileAuditStore appends audit records to a file as JSON lines.
+type FileAuditStore struct {
+	f *os.File
+}
+
+func NewFileAuditStore(path string) (*FileAuditStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditStore{f: f}, nil
+}
+
+func (s *FileAuditStore) WriteAudit(records []AuditRecord) error {
+	w := bufio.NewWriter(s.f)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *FileAuditStore) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,84 @@
+package xrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memAuditStore struct {
+	mu      sync.Mutex
+	batches [][]AuditRecord
+}
+
+func (m *memAuditStore) WriteAudit(records []AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.batches = append(m.batches, append([]AuditRecord(nil), records...))
+	return nil
+}
+
+func TestAuditor(t *testing.T) {
+	store := new(memAuditStore)
+	auditor := NewAuditor(store, AuditConfig{BatchSize: 2, FlushInterval: time.Hour})
+	s := NewServerWithCodec(nil, WithAuditor(auditor), WithInterceptors(auth))
+	assert.Nil(t, s.Register(new(Shop), WithMethodTags("Buy", "audit")))
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	md := WithMetadata(Metadata{"token": "alice", "tenant": "acme"})
+	item := "apple"
+	var ok bool
+	var sum int
+	assert.NotNil(t, c.Call("Shop.Buy", &item, &ok, md))
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, md))
+	assert.NotNil(t, c.Call("Shop.Buy", &item, &ok))
+	assert.NotNil(t, c.Call("Shop.Buy", &item, &ok, md))
+	auditor.Close()
+
+	assert.Len(t, store.batches, 2)
+	assert.Len(t, store.batches[0], 2)
+	first := store.batches[0][0]
+	assert.Equal(t, "Shop.Buy", first.Method)
+	assert.Equal(t, "alice", first.Principal)
+	assert.Equal(t, "acme", first.Tenant)
+	assert.Equal(t, outOfStock, first.Code)
+	assert.Contains(t, first.Error, "apple is out of stock")
+	assert.Len(t, first.ParamsHash, 64)
+
+	// rejected by the auth interceptor, before the method ran
+	assert.Equal(t, "", store.batches[0][1].Principal)
+	assert.Equal(t, InvalidRequest, store.batches[0][1].Code)
+
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, md))
+	assert.NotNil(t, c.Call("Shop.Buy", &item, &ok, md))
+	assert.Equal(t, uint64(1), auditor.Dropped())
+}
+
+func TestFileAuditStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	store, err := NewFileAuditStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, store.WriteAudit([]AuditRecord{{Method: "A.B"}, {Method: "A.C"}}))
+	assert.Nil(t, store.Close())
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	var methods []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &rec))
+		methods = append(methods, rec.Method)
+	}
+	assert.Equal(t, []string{"A.B", "A.C"}, methods)
+}
@@ -0,0 +1,24 @@
+package xrpc
+
+// BenchService answers the calls of the xrpc-bench load generator, to
+// baseline codecs and transports:
+//
+//	s.Register(new(xrpc.BenchService))
+type BenchService struct{}
+
+// BenchPayload is the payload of the calls to BenchService.
+type BenchPayload struct {
+	Data []byte
+}
+
+// Echo replies with args.
+func (b *BenchService) Echo(args *BenchPayload, reply *BenchPayload) error {
+	*reply = *args
+	return nil
+}
+
+// Discard replies with the size of args only, to load one direction.
+func (b *BenchService) Discard(args *BenchPayload, reply *int) error {
+	*reply = len(args.Data)
+	return nil
+}
@@ -0,0 +1,41 @@
+package xrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchService(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(BenchService)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	args := &BenchPayload{Data: []byte("payload")}
+	var echo BenchPayload
+	assert.Nil(t, c.Call("BenchService.Echo", args, &echo))
+	assert.Equal(t, args.Data, echo.Data)
+
+	var n int
+	assert.Nil(t, c.Call("BenchService.Discard", args, &n))
+	assert.Equal(t, 7, n)
+}
+
+func BenchmarkBenchService_Echo(b *testing.B) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(BenchService))
+	c := NewClientWithCodec(nil, startServer(b, s))
+	defer c.Close()
+
+	args := &BenchPayload{Data: make([]byte, 128)}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var reply BenchPayload
+		for pb.Next() {
+			if err := c.Call("BenchService.Echo", args, &reply); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
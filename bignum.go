@@ -0,0 +1,225 @@
+package xrpc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalScale bounds the exponents of decoded decimals, which would
+// otherwise let a peer make the server allocate a huge number.
+const maxDecimalScale = 1 << 12
+
+// BigInt is a big.Int which JSON encodes as a decimal string, since peers
+// decoding JSON numbers into doubles round those beyond 2^53, and gob
+// encodes natively. Decoding JSON accepts strings and bare numbers.
+type BigInt struct {
+	big.Int
+}
+
+// NewBigInt returns a BigInt of the value of x.
+func NewBigInt(x *big.Int) BigInt {
+	var b BigInt
+	b.Set(x)
+	return b
+}
+
+func (b BigInt) String() string {
+	return b.Int.String()
+}
+
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Int.String())
+}
+
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	s, ok, err := unquoteNumber(data)
+	if err != nil || !ok {
+		return err
+	}
+	if _, ok := b.SetString(s, 10); !ok {
+		return fmt.Errorf("rpc: invalid BigInt %q", s)
+	}
+	return nil
+}
+
+func (b BigInt) GobEncode() ([]byte, error) {
+	return b.Int.GobEncode()
+}
+
+func (b *BigInt) GobDecode(data []byte) error {
+	return b.Int.GobDecode(data)
+}
+
+// Decimal is an exact decimal number, unscaled × 10^-scale, for amounts
+// of money and the like. It JSON encodes as a string, e.g. "-12.340",
+// and gob encodes natively. Decoding JSON accepts strings and bare
+// numbers, in exponent notation too.
+type Decimal struct {
+	unscaled big.Int
+	scale    int32 // digits after the point, never negative
+}
+
+// NewDecimal returns the decimal unscaled × 10^-scale.
+func NewDecimal(unscaled *big.Int, scale int32) Decimal {
+	var d Decimal
+	d.unscaled.Set(unscaled)
+	d.scale = scale
+	d.normalize()
+	return d
+}
+
+// ParseDecimal parses s, e.g. "12.5", "-0.001" or "1.5e3".
+func ParseDecimal(s string) (Decimal, error) {
+	var d Decimal
+	if err := d.parse(s); err != nil {
+		return Decimal{}, err
+	}
+	return d, nil
+}
+
+func (d *Decimal) parse(s string) error {
+	invalid := fmt.Errorf("rpc: invalid Decimal %q", s)
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.ParseInt(s[i+1:], 10, 32); err != nil {
+			return invalid
+		}
+		mantissa = s[:i]
+	}
+	intPart, frac := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, frac = mantissa[:i], mantissa[i+1:]
+	}
+	digits := strings.TrimLeft(intPart, "+-")
+	if len(intPart)-len(digits) > 1 || digits+frac == "" || !isDigits(digits) || !isDigits(frac) {
+		return invalid
+	}
+	scale := int64(len(frac)) - exp
+	if scale > maxDecimalScale || scale < -maxDecimalScale {
+		return fmt.Errorf("rpc: exponent of Decimal %q out of range", s)
+	}
+	if _, ok := d.unscaled.SetString(intPart+frac, 10); !ok {
+		return invalid
+	}
+	d.scale = int32(scale)
+	d.normalize()
+	return nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize makes the scale non-negative.
+func (d *Decimal) normalize() {
+	if d.scale >= 0 {
+		return
+	}
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-d.scale)), nil)
+	d.unscaled.Mul(&d.unscaled, pow)
+	d.scale = 0
+}
+
+// Unscaled returns the unscaled value of d.
+func (d Decimal) Unscaled() *big.Int {
+	return new(big.Int).Set(&d.unscaled)
+}
+
+// Scale returns the number of digits of d after the point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Rat returns the value of d.
+func (d Decimal) Rat() *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(&d.unscaled, denom)
+}
+
+// Cmp compares the values of d and e, whatever their scales.
+func (d Decimal) Cmp(e Decimal) int {
+	return d.Rat().Cmp(e.Rat())
+}
+
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(&d.unscaled).String()
+	sign := ""
+	if d.unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s, ok, err := unquoteNumber(data)
+	if err != nil || !ok {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d Decimal) GobEncode() ([]byte, error) {
+	unscaled, err := d.unscaled.GobEncode()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4, 4+len(unscaled))
+	binary.BigEndian.PutUint32(b, uint32(d.scale))
+	return append(b, unscaled...), nil
+}
+
+func (d *Decimal) GobDecode(b []byte) error {
+	if len(b) < 4 {
+		return errors.New("rpc: Decimal gob too short")
+	}
+	scale := int32(binary.BigEndian.Uint32(b))
+	if scale < 0 {
+		return errors.New("rpc: Decimal gob of negative scale")
+	}
+	if err := d.unscaled.GobDecode(b[4:]); err != nil {
+		return err
+	}
+	d.scale = scale
+	return nil
+}
+
+// unquoteNumber returns the number JSON encoded in data as a string or a
+// bare number; ok is false for null, which leaves the value as is.
+func unquoteNumber(data []byte) (s string, ok bool, err error) {
+	if string(data) == "null" {
+		return "", false, nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		if err = json.Unmarshal(data, &s); err != nil {
+			return "", false, err
+		}
+		return s, true, nil
+	}
+	var n json.Number
+	if err = json.Unmarshal(data, &n); err != nil {
+		return "", false, errors.New("rpc: want a number or a string of one")
+	}
+	return n.String(), true, nil
+}
@@ -0,0 +1,78 @@
+package xrpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Payment struct {
+	Wei    BigInt  `json:"wei"`
+	Amount Decimal `json:"amount"`
+}
+
+func TestBigInt_JSON(t *testing.T) {
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	b, err := json.Marshal(NewBigInt(n))
+	assert.Nil(t, err)
+	assert.Equal(t, `"123456789012345678901234567890"`, string(b))
+
+	var got BigInt
+	assert.Nil(t, json.Unmarshal(b, &got))
+	assert.Equal(t, 0, got.Cmp(n))
+	assert.Nil(t, json.Unmarshal([]byte(`-9007199254740993`), &got))
+	assert.Equal(t, "-9007199254740993", got.String())
+
+	assert.NotNil(t, json.Unmarshal([]byte(`"1.5"`), &got))
+	assert.NotNil(t, json.Unmarshal([]byte(`true`), &got))
+}
+
+func TestDecimal_Parse(t *testing.T) {
+	for in, want := range map[string]string{
+		"12.340":  "12.340",
+		"-0.001":  "-0.001",
+		".5":      "0.5",
+		"+7":      "7",
+		"1.5e3":   "1500",
+		"15e-4":   "0.0015",
+		"-2.50E1": "-25.0",
+	} {
+		d, err := ParseDecimal(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, want, d.String(), in)
+	}
+	for _, in := range []string{"", ".", "1.2.3", "--1", "1e", "0x10", "1e99999"} {
+		_, err := ParseDecimal(in)
+		assert.NotNil(t, err, in)
+	}
+
+	a, _ := ParseDecimal("1.50")
+	b, _ := ParseDecimal("1.5")
+	assert.Equal(t, 0, a.Cmp(b))
+	assert.Equal(t, int32(2), a.Scale())
+	assert.Equal(t, big.NewInt(150), a.Unscaled())
+	assert.Equal(t, "-1.23", NewDecimal(big.NewInt(-123), 2).String())
+}
+
+func TestBigNum_codecs(t *testing.T) {
+	wei, _ := new(big.Int).SetString("1000000000000000000001", 10)
+	amount, _ := ParseDecimal("19.99")
+	in := Payment{Wei: NewBigInt(wei), Amount: amount}
+
+	b, err := json.Marshal(in)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"wei":"1000000000000000000001","amount":"19.99"}`, string(b))
+	var out Payment
+	assert.Nil(t, json.Unmarshal([]byte(`{"wei":1000000000000000000001,"amount":19.99}`), &out))
+	assert.Equal(t, in, out)
+
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(in))
+	out = Payment{}
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(&out))
+	assert.Equal(t, in, out)
+}
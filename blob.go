@@ -0,0 +1,355 @@
+package xrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The protocol has no streaming frames, so blobs are moved as a sequence
+// of calls to the blob service, each carrying a chunk. The server pipes the
+// chunks to the handler as they arrive, so neither side holds the whole
+// blob.
+const (
+	blobService     = "XrpcBlob"
+	blobChunkSize   = 64 << 10
+	blobIdleTimeout = time.Minute
+	blobAttempts    = 3 // sends of a chunk before Upload gives up
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// BlobHandler consumes a blob uploaded under its name. The result, which
+// must not be nil, is the reply of the upload.
+type BlobHandler func(ctx context.Context, r io.Reader) (interface{}, error)
+
+// BlobChunk is a piece of an upload.
+type BlobChunk struct {
+	ID     string // of the upload, chosen by the client
+	Name   string // of the handler
+	Offset int64
+	Data   []byte
+	Sum    uint32 // CRC-32C of Data
+	Last   bool
+
+	Size      int64  // of the blob, -1 if unknown; declared by the first chunk
+	ObjectSum uint32 // CRC-32C of the blob, sent with the last chunk
+}
+
+// TransferError reports a blob corrupted or cut short in transfer, at the
+// offset where it was detected.
+type TransferError struct {
+	Offset int64
+	Reason string
+}
+
+const (
+	transferErrPrefix = "rpc: transfer failed at offset "
+	chunkSumMismatch  = "chunk checksum mismatch"
+)
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("%s%d: %s", transferErrPrefix, e.Offset, e.Reason)
+}
+
+func (e *TransferError) rpcError() *Error {
+	return &Error{ErrCode: TransferErr, ErrMsg: e.Error()}
+}
+
+// asTransferError restores the TransferError sent as the remote error err.
+func asTransferError(err error) error {
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrCode != TransferErr ||
+		!strings.HasPrefix(rpcErr.ErrMsg, transferErrPrefix) {
+		return err
+	}
+	msg := strings.TrimPrefix(rpcErr.ErrMsg, transferErrPrefix)
+	i := strings.Index(msg, ": ")
+	if i < 0 {
+		return err
+	}
+	offset, perr := strconv.ParseInt(msg[:i], 10, 64)
+	if perr != nil {
+		return err
+	}
+	return &TransferError{Offset: offset, Reason: msg[i+2:]}
+}
+
+// BlobAck acknowledges the chunks received so far.
+type BlobAck struct {
+	Offset int64
+}
+
+// HandleBlob makes h consume the blobs uploaded under name with
+// Client.Upload.
+func (s *Server) HandleBlob(name string, h BlobHandler) error {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	if s.blobs == nil {
+		b := &blobs{handlers: make(map[string]BlobHandler), uploads: make(map[string]*upload)}
+		if err := s.registerInternal(blobService, b); err != nil {
+			return err
+		}
+		s.blobs = b
+	}
+
+	s.blobs.mu.Lock()
+	defer s.blobs.mu.Unlock()
+	if _, dup := s.blobs.handlers[name]; dup {
+		return fmt.Errorf("rpc: blob handler already defined: %s", name)
+	}
+	s.blobs.handlers[name] = h
+	return nil
+}
+
+// registerInternal registers a service of the package under name.
+func (s *Server) registerInternal(name string, data interface{}) error {
+	srv := &service{
+		name:         name,
+		typ:          reflect.TypeOf(data),
+		val:          reflect.ValueOf(data),
+		registeredAt: caller(),
+		internal:     true,
+	}
+	srv.method = suitableMethods(srv.typ)
+	if i, dup := s.m.LoadOrStore(name, srv); dup {
+		return fmt.Errorf("rpc: service already defined: %s, by %s", name, i.(*service).origin())
+	}
+	return nil
+}
+
+// blobs is the blob service.
+type blobs struct {
+	mu       sync.Mutex
+	handlers map[string]BlobHandler
+	uploads  map[string]*upload
+}
+
+type upload struct {
+	mu       sync.Mutex // serializes the chunks
+	offset   int64
+	size     int64 // declared, -1 if unknown
+	sum      uint32
+	w        *io.PipeWriter
+	result   chan blobResult
+	cancel   context.CancelFunc
+	lastSeen int64 // unix nanoseconds, atomic
+}
+
+type blobResult struct {
+	reply interface{}
+	err   error
+}
+
+// Chunk passes a chunk to the handler. It replies with a BlobAck, or with
+// the result of the handler to the last chunk.
+func (b *blobs) Chunk(ctx context.Context, chunk *BlobChunk) (interface{}, error) {
+	if crc32Sum(chunk.Data) != chunk.Sum {
+		return nil, (&TransferError{Offset: chunk.Offset, Reason: chunkSumMismatch}).rpcError()
+	}
+	u, err := b.upload(ctx, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	atomic.StoreInt64(&u.lastSeen, time.Now().UnixNano())
+	end := chunk.Offset + int64(len(chunk.Data))
+	if end == u.offset && len(chunk.Data) > 0 && !chunk.Last {
+		// sent again after the ack got lost
+		return &BlobAck{Offset: u.offset}, nil
+	}
+	if chunk.Offset != u.offset {
+		return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: fmt.Sprintf("rpc: chunk at offset %d, expected %d", chunk.Offset, u.offset)}
+	}
+	if u.size >= 0 && end > u.size {
+		return nil, b.abort(chunk.ID, u, &TransferError{Offset: u.size, Reason: fmt.Sprintf("blob longer than the declared %d bytes", u.size)})
+	}
+	if _, err := u.w.Write(chunk.Data); err != nil {
+		if !errors.Is(err, io.ErrClosedPipe) {
+			return nil, err
+		}
+		// the handler returned early, the rest is dropped unless it failed
+		res := <-u.result
+		if res.err != nil {
+			b.remove(chunk.ID)
+			return nil, res.err
+		}
+		u.result <- res
+	}
+	u.offset = end
+	u.sum = crc32.Update(u.sum, crcTable, chunk.Data)
+	if !chunk.Last {
+		return &BlobAck{Offset: u.offset}, nil
+	}
+
+	if u.size >= 0 && end != u.size {
+		return nil, b.abort(chunk.ID, u, &TransferError{Offset: end, Reason: fmt.Sprintf("blob shorter than the declared %d bytes", u.size)})
+	}
+	if u.sum != chunk.ObjectSum {
+		return nil, b.abort(chunk.ID, u, &TransferError{Offset: 0, Reason: "blob checksum mismatch"})
+	}
+	_ = u.w.Close()
+	res := <-u.result
+	b.remove(chunk.ID)
+	return res.reply, res.err
+}
+
+// upload returns the upload of chunk, starting its handler on the first
+// chunk.
+func (b *blobs) upload(ctx context.Context, chunk *BlobChunk) (*upload, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if u := b.uploads[chunk.ID]; u != nil {
+		return u, nil
+	}
+	if chunk.Offset != 0 {
+		return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: "rpc: unknown upload " + chunk.ID}
+	}
+	h := b.handlers[chunk.Name]
+	if h == nil {
+		return nil, &Error{ErrCode: MethodNotFound, ErrMsg: "rpc: can't find blob handler " + chunk.Name}
+	}
+	b.expire()
+
+	r, w := io.Pipe()
+	hctx, cancel := context.WithCancel(withMetadata(context.Background(), MetadataFromContext(ctx)))
+	u := &upload{size: chunk.Size, w: w, result: make(chan blobResult, 1), cancel: cancel, lastSeen: time.Now().UnixNano()}
+	go func() {
+		reply, err := h(hctx, r)
+		// unblock writes if the handler stopped reading
+		_ = r.CloseWithError(io.ErrClosedPipe)
+		u.result <- blobResult{reply: reply, err: err}
+	}()
+	b.uploads[chunk.ID] = u
+	return u, nil
+}
+
+// expire aborts the uploads abandoned by their clients.
+func (b *blobs) expire() {
+	for id, u := range b.uploads {
+		if time.Since(time.Unix(0, atomic.LoadInt64(&u.lastSeen))) > blobIdleTimeout {
+			u.cancel()
+			_ = u.w.CloseWithError(errors.New("rpc: upload abandoned"))
+			delete(b.uploads, id)
+		}
+	}
+}
+
+// abort makes the handler of upload u fail with err, rather than see the
+// end of a broken blob.
+func (b *blobs) abort(id string, u *upload, err *TransferError) error {
+	_ = u.w.CloseWithError(err)
+	<-u.result
+	b.remove(id)
+	return err.rpcError()
+}
+
+func (b *blobs) remove(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if u := b.uploads[id]; u != nil {
+		u.cancel()
+		delete(b.uploads, id)
+	}
+}
+
+// Upload sends the content of r to the blob handler name of the server and
+// stores its result in reply. Chunks lost to a broken connection are sent
+// again, but the last one isn't since the handler may have completed.
+func (c *Client) Upload(ctx context.Context, name string, r io.Reader, reply interface{}) error {
+	id, err := newUploadID()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, blobChunkSize)
+	size := blobSize(r)
+	var (
+		offset int64
+		sum    uint32
+	)
+	for {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+		chunk := &BlobChunk{ID: id, Name: name, Offset: offset, Data: buf[:n], Sum: crc32Sum(buf[:n]), Last: last, Size: size}
+		sum = crc32.Update(sum, crcTable, chunk.Data)
+
+		if last {
+			chunk.ObjectSum = sum
+			err := c.CallContext(ctx, blobService+".Chunk", chunk, reply, WithRetryDisabled())
+			return asTransferError(err)
+		}
+		if offset, err = c.sendChunk(ctx, chunk); err != nil {
+			return asTransferError(err)
+		}
+	}
+}
+
+// blobSize returns the length of r if it tells it, or -1.
+func blobSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (os.FileInfo, error) }:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			if seeker, ok := r.(io.Seeker); ok {
+				if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+					return fi.Size() - pos
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// sendChunk sends chunk, again if the connection breaks or it got
+// corrupted, and returns the offset of the next chunk. The server
+// acknowledges chunks it got already, so the upload resumes where it was.
+func (c *Client) sendChunk(ctx context.Context, chunk *BlobChunk) (int64, error) {
+	var err error
+	for attempt := 0; attempt < blobAttempts; attempt++ {
+		var ack BlobAck
+		err = c.CallContext(ctx, blobService+".Chunk", chunk, &ack, WithRetryDisabled())
+		if err == nil {
+			return ack.Offset, nil
+		}
+		te, corrupted := asTransferError(err).(*TransferError)
+		corrupted = corrupted && te.Reason == chunkSumMismatch
+		if !retryable(err) && !corrupted || ctx.Err() != nil {
+			return 0, err
+		}
+	}
+	return 0, err
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func crc32Sum(data []byte) uint32 {
+	return crc32.Checksum(data, crcTable)
+}
@@ -0,0 +1,156 @@
+package xrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Upload(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.HandleBlob("sha256", func(ctx context.Context, r io.Reader) (interface{}, error) {
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}))
+	assert.NotNil(t, s.HandleBlob("sha256", nil))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	for _, size := range []int{0, 10, blobChunkSize, 3*blobChunkSize + 7} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		sum := sha256.Sum256(data)
+
+		var got string
+		assert.Nil(t, c.Upload(context.Background(), "sha256", bytes.NewReader(data), &got))
+		assert.Equal(t, hex.EncodeToString(sum[:]), got)
+	}
+	assert.Empty(t, s.blobs.uploads)
+
+	err := c.Upload(context.Background(), "md5", bytes.NewReader(nil), new(string))
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+
+	err = c.Upload(context.Background(), "sha256", lyingReader{bytes.NewReader(make([]byte, 100))}, new(string))
+	var te *TransferError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, int64(50), te.Offset)
+}
+
+// lyingReader declares half its length.
+type lyingReader struct {
+	*bytes.Reader
+}
+
+func (r lyingReader) Len() int {
+	return r.Reader.Len() / 2
+}
+
+func TestClient_UploadHandlerFails(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.HandleBlob("head", func(ctx context.Context, r io.Reader) (interface{}, error) {
+		if _, err := io.ReadFull(r, make([]byte, 10)); err != nil {
+			return nil, err
+		}
+		return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: "too large"}
+	}))
+	assert.Nil(t, s.HandleBlob("discard", func(ctx context.Context, r io.Reader) (interface{}, error) {
+		n, err := io.Copy(ioutil.Discard, io.LimitReader(r, 10))
+		return n, err
+	}))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	data := make([]byte, 4*blobChunkSize)
+	err := c.Upload(context.Background(), "head", bytes.NewReader(data), new(string))
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+
+	// the rest of the blob is dropped if the handler succeeds
+	var n int64
+	assert.Nil(t, c.Upload(context.Background(), "discard", bytes.NewReader(data), &n))
+	assert.Equal(t, int64(10), n)
+	assert.Empty(t, s.blobs.uploads)
+}
+
+func TestBlobs_Chunk(t *testing.T) {
+	b := &blobs{handlers: map[string]BlobHandler{
+		"len": func(ctx context.Context, r io.Reader) (interface{}, error) {
+			data, err := ioutil.ReadAll(r)
+			return len(data), err
+		},
+	}, uploads: make(map[string]*upload)}
+	ctx := context.Background()
+	chunk := func(offset int64, data string, last bool) *BlobChunk {
+		return &BlobChunk{ID: "1", Name: "len", Offset: offset, Data: []byte(data), Sum: crc32Sum([]byte(data)), Last: last, Size: -1}
+	}
+
+	ack, err := b.Chunk(ctx, chunk(0, "abc", false))
+	assert.Nil(t, err)
+	assert.Equal(t, &BlobAck{Offset: 3}, ack)
+	// sent again
+	ack, err = b.Chunk(ctx, chunk(0, "abc", false))
+	assert.Nil(t, err)
+	assert.Equal(t, &BlobAck{Offset: 3}, ack)
+
+	_, err = b.Chunk(ctx, chunk(5, "de", false))
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+
+	last := chunk(3, "de", true)
+	last.ObjectSum = crc32Sum([]byte("abcde"))
+	n, err := b.Chunk(ctx, last)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+}
+
+func TestBlobs_ChunkTransferErrors(t *testing.T) {
+	var handlerErr error
+	b := &blobs{handlers: map[string]BlobHandler{
+		"len": func(ctx context.Context, r io.Reader) (interface{}, error) {
+			data, err := ioutil.ReadAll(r)
+			handlerErr = err
+			return len(data), err
+		},
+	}, uploads: make(map[string]*upload)}
+	ctx := context.Background()
+	send := func(id string, offset, size int64, data string, last bool, objectSum string) error {
+		_, err := b.Chunk(ctx, &BlobChunk{ID: id, Name: "len", Offset: offset, Data: []byte(data),
+			Sum: crc32Sum([]byte(data)), Last: last, Size: size, ObjectSum: crc32Sum([]byte(objectSum))})
+		return err
+	}
+	transferErr := func(err error) *TransferError {
+		te, _ := asTransferError(err).(*TransferError)
+		return te
+	}
+
+	bad := &BlobChunk{ID: "0", Name: "len", Offset: 0, Data: []byte("abc"), Size: -1}
+	_, err := b.Chunk(ctx, bad)
+	assert.Equal(t, &TransferError{Offset: 0, Reason: chunkSumMismatch}, transferErr(err))
+
+	assert.Nil(t, send("1", 0, 4, "abc", false, ""))
+	err = send("1", 3, 4, "de", true, "abcde")
+	assert.Equal(t, &TransferError{Offset: 4, Reason: "blob longer than the declared 4 bytes"}, transferErr(err))
+	assert.Error(t, handlerErr)
+
+	assert.Nil(t, send("2", 0, 6, "abc", false, ""))
+	err = send("2", 3, 6, "de", true, "abcde")
+	assert.Equal(t, &TransferError{Offset: 5, Reason: "blob shorter than the declared 6 bytes"}, transferErr(err))
+	assert.Error(t, handlerErr)
+
+	assert.Nil(t, send("3", 0, 5, "abc", false, ""))
+	err = send("3", 3, 5, "de", true, "abcdf")
+	assert.Equal(t, &TransferError{Offset: 0, Reason: "blob checksum mismatch"}, transferErr(err))
+	assert.Error(t, handlerErr)
+	assert.Empty(t, b.uploads)
+
+	var rpcErr *Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, TransferErr, rpcErr.ErrCode)
+}
@@ -0,0 +1,124 @@
+package xrpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferSizes(t *testing.T) {
+	s := NewServerWithCodec(nil, WithBufferSizes(64, 64))
+	assert.Nil(t, s.Register(new(Echo)))
+	c := NewClientWithCodec(nil, startServer(t, s), WithClientBufferSizes(64, 64))
+	defer c.Close()
+
+	args := strings.Repeat("a", 10000)
+	var reply string
+	assert.Nil(t, c.Call("Echo.Say", &args, &reply))
+	assert.Equal(t, args, reply)
+
+	assert.Equal(t, defaultBufSize, bufSize(0))
+	assert.Equal(t, 128, bufSize(128))
+}
+
+func BenchmarkBufferSizes(b *testing.B) {
+	for _, payload := range []int{64, 256 << 10} {
+		for _, size := range []int{defaultBufSize, 64 << 10} {
+			b.Run(fmt.Sprintf("payload=%d/buf=%d", payload, size), func(b *testing.B) {
+				s := NewServerWithCodec(nil, WithBufferSizes(size, size), WithLogger(log.New(io.Discard, "", 0)))
+				_ = s.Register(new(Echo))
+				c := NewClientWithCodec(nil, startServer(b, s), WithClientBufferSizes(size, size))
+				defer c.Close()
+
+				args := strings.Repeat("a", payload)
+				var reply string
+				b.SetBytes(int64(payload))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := c.Call("Echo.Say", &args, &reply); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+type countingConn struct {
+	net.Conn
+	writes *int32
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(c.writes, 1)
+	return c.Conn.Write(b)
+}
+
+type countingListener struct {
+	net.Listener
+	writes int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{Conn: conn, writes: &l.writes}, nil
+}
+
+func TestFlushInterval(t *testing.T) {
+	pipeline := func(opts ...ServerOption) int32 {
+		s := NewServerWithCodec(nil, opts...)
+		assert.Nil(t, s.Register(new(Int)))
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cl := &countingListener{Listener: l}
+		go func() { _ = s.Serve(cl) }()
+		defer l.Close()
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		codec := NewGobCodec()
+		wr := bufio.NewWriter(conn)
+		for i := 0; i < 3; i++ {
+			reqs := []Request{codec.NewRequest("Int.Sum", &Args{A: i, B: 1})}
+			p := proto.New()
+			p.Body, err = codec.EncodeRequests(&reqs)
+			assert.Nil(t, err)
+			assert.Nil(t, p.WriteTCP(wr))
+		}
+		assert.Nil(t, wr.Flush())
+
+		rr := bufio.NewReader(conn)
+		for i := 0; i < 3; i++ {
+			p := proto.New()
+			assert.Nil(t, p.ReadTCP(rr))
+			resps, err := codec.ReadResponse(p.Body)
+			assert.Nil(t, err)
+			var sum int
+			assert.Nil(t, resps[0].DecodeInto(&sum))
+			assert.Equal(t, i+1, sum)
+		}
+		return atomic.LoadInt32(&cl.writes)
+	}
+
+	assert.Equal(t, int32(3), pipeline())
+	assert.Equal(t, int32(1), pipeline(WithFlushInterval(time.Second)))
+}
@@ -0,0 +1,48 @@
+package xrpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Bytes is binary data which JSON encodes as a base64 string and gob as
+// raw bytes, so args and replies can declare binary fields the same way
+// whatever the codec. Decoding JSON accepts the standard and URL-safe
+// alphabets, padded or not, as sent by clients in other languages.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*b = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("rpc: Bytes want a base64 string")
+	}
+	s = strings.TrimRight(s, "=")
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	decoded, err := enc.DecodeString(s)
+	if err != nil {
+		return errors.New("rpc: Bytes want a base64 string: " + err.Error())
+	}
+	*b = decoded
+	return nil
+}
+
+// String returns b in standard base64.
+func (b Bytes) String() string {
+	return base64.StdEncoding.EncodeToString(b)
+}
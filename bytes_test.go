@@ -0,0 +1,48 @@
+package xrpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Blob struct {
+	Name string
+	Data Bytes `json:"data"`
+}
+
+func TestBytes_JSON(t *testing.T) {
+	b, err := json.Marshal(Blob{Name: "a", Data: Bytes{0xfb, 0xff, 0x01}})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Name":"a","data":"+/8B"}`, string(b))
+
+	b, _ = json.Marshal(Blob{})
+	assert.Equal(t, `{"Name":"","data":null}`, string(b))
+
+	var got Bytes
+	assert.Nil(t, json.Unmarshal([]byte(`"+/8B"`), &got))
+	assert.Equal(t, Bytes{0xfb, 0xff, 0x01}, got)
+	assert.Nil(t, json.Unmarshal([]byte(`"-_8"`), &got))
+	assert.Equal(t, Bytes{0xfb, 0xff}, got)
+	assert.Nil(t, json.Unmarshal([]byte(`"+/8="`), &got))
+	assert.Equal(t, Bytes{0xfb, 0xff}, got)
+	assert.Nil(t, json.Unmarshal([]byte(`null`), &got))
+	assert.Nil(t, got)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`"not base64!"`), &got))
+	assert.NotNil(t, json.Unmarshal([]byte(`[1, 2]`), &got))
+}
+
+func TestBytes_gob(t *testing.T) {
+	var buf bytes.Buffer
+	in := Blob{Name: "a", Data: Bytes{0, 1, 2, 0xff}}
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(in))
+	assert.True(t, bytes.Contains(buf.Bytes(), []byte{0, 1, 2, 0xff}))
+
+	var out Blob
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(&out))
+	assert.Equal(t, in, out)
+}
@@ -0,0 +1,111 @@
+package xrpc
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	key     string
+	resp    Response
+	expires time.Time
+}
+
+// responseCache is an LRU of successful responses with a fixed TTL.
+type responseCache struct {
+	methods    map[string]bool
+	ttl        time.Duration
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int, methods []string) *responseCache {
+	return &responseCache{
+		methods:    methodSet(methods),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (rc *responseCache) match(method string) bool {
+	return rc.methods == nil || rc.methods[method]
+}
+
+func (rc *responseCache) get(key string) (Response, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		rc.removeElement(elem)
+		return nil, false
+	}
+	rc.ll.MoveToFront(elem)
+	return entry.resp, true
+}
+
+func (rc *responseCache) add(key string, resp Response) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	expires := time.Now().Add(rc.ttl)
+	if elem, ok := rc.items[key]; ok {
+		rc.ll.MoveToFront(elem)
+		entry := elem.Value.(*cacheEntry)
+		entry.resp, entry.expires = resp, expires
+		return
+	}
+	rc.items[key] = rc.ll.PushFront(&cacheEntry{key: key, resp: resp, expires: expires})
+	if rc.maxEntries > 0 && rc.ll.Len() > rc.maxEntries {
+		rc.removeElement(rc.ll.Back())
+	}
+}
+
+func (rc *responseCache) remove(key string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if elem, ok := rc.items[key]; ok {
+		rc.removeElement(elem)
+	}
+}
+
+func (rc *responseCache) purge() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.ll.Init()
+	rc.items = make(map[string]*list.Element)
+}
+
+func (rc *responseCache) removeElement(elem *list.Element) {
+	rc.ll.Remove(elem)
+	delete(rc.items, elem.Value.(*cacheEntry).key)
+}
+
+// InvalidateCache drops the cached response of method called with args.
+func (c *Client) InvalidateCache(method string, args interface{}) {
+	if c.cache == nil {
+		return
+	}
+	if req := c.codec.NewRequest(method, args); req != nil {
+		c.cache.remove(requestKey(req))
+	}
+}
+
+// PurgeCache drops every cached response.
+func (c *Client) PurgeCache() {
+	if c.cache != nil {
+		c.cache.purge()
+	}
+}
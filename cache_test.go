@@ -0,0 +1,57 @@
+package xrpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Cache(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Counter))
+	c := NewClientWithCodec(nil, startServer(t, s), WithCache(time.Minute, 0, "Counter.Get"))
+	defer c.Close()
+
+	var (
+		d     time.Duration
+		reply int32
+	)
+	assert.Nil(t, c.Call("Counter.Get", &d, &reply))
+	assert.Equal(t, int32(1), reply)
+	assert.Nil(t, c.Call("Counter.Get", &d, &reply))
+	assert.Equal(t, int32(1), reply)
+
+	d2 := time.Nanosecond
+	assert.Nil(t, c.Call("Counter.Get", &d2, &reply))
+	assert.Equal(t, int32(2), reply)
+
+	c.InvalidateCache("Counter.Get", &d)
+	assert.Nil(t, c.Call("Counter.Get", &d, &reply))
+	assert.Equal(t, int32(3), reply)
+
+	c.PurgeCache()
+	assert.Nil(t, c.Call("Counter.Get", &d2, &reply))
+	assert.Equal(t, int32(4), reply)
+}
+
+func TestResponseCache(t *testing.T) {
+	rc := newResponseCache(time.Minute, 2, nil)
+	r1, r2, r3 := &defaultResponse{Id: "1"}, &defaultResponse{Id: "2"}, &defaultResponse{Id: "3"}
+
+	rc.add("1", r1)
+	rc.add("2", r2)
+	_, _ = rc.get("1")
+	rc.add("3", r3)
+
+	_, ok := rc.get("2")
+	assert.False(t, ok)
+	resp, ok := rc.get("1")
+	assert.True(t, ok)
+	assert.Equal(t, r1, resp)
+
+	rc.ttl = -time.Second
+	rc.add("1", r1)
+	_, ok = rc.get("1")
+	assert.False(t, ok)
+}
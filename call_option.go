@@ -0,0 +1,60 @@
+package xrpc
+
+import "time"
+
+type callOptions struct {
+	timeout  time.Duration
+	md       Metadata
+	noRetry  bool
+	target   string
+	respMd   *Metadata
+	envelope *ReplyEnvelope
+	attached []Attachment
+}
+
+type CallOption func(*callOptions)
+
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCallTimeout bounds the call, in addition to the context deadline.
+func WithCallTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithMetadata sends md along with the request. Repeated options merge.
+func WithMetadata(md Metadata) CallOption {
+	return func(o *callOptions) {
+		o.md = o.md.Join(md)
+	}
+}
+
+// WithRetryDisabled turns off the retries configured by WithRetry.
+func WithRetryDisabled() CallOption {
+	return func(o *callOptions) {
+		o.noRetry = true
+	}
+}
+
+// WithTarget sends a MultiClient call to addr instead of the next server
+// in turn. Plain clients ignore it.
+func WithTarget(addr string) CallOption {
+	return func(o *callOptions) {
+		o.target = addr
+	}
+}
+
+// WithResponseMetadata stores the metadata of the response in md, e.g.
+// the warning of a deprecated method.
+func WithResponseMetadata(md *Metadata) CallOption {
+	return func(o *callOptions) {
+		o.respMd = md
+	}
+}
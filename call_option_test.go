@@ -0,0 +1,140 @@
+package xrpc
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_WithCallTimeout(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Slow))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	d := time.Second
+	err := c.Call("Slow.Sleep", &d, new(int), WithCallTimeout(20*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrTimeout))
+}
+
+func TestClient_WithMetadata(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	codec := NewGobCodec()
+	got := make(chan Metadata, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		p := proto.New()
+		if err := p.ReadTCP(bufio.NewReader(conn)); err != nil {
+			return
+		}
+		reqs, _ := codec.ReadRequest(p.Body)
+		got <- reqs[0].GetMetadata()
+	}()
+
+	c := NewClientWithCodec(codec, l.Addr().String())
+	defer c.Close()
+	_ = c.Call("Int.Sum", &Args{}, new(int),
+		WithMetadata(Metadata{"tenant": "a", "trace": "1"}),
+		WithMetadata(Metadata{"tenant": "b"}))
+	assert.Equal(t, Metadata{"tenant": "b", "trace": "1"}, <-got)
+}
+
+func TestClient_WithRetry(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	dropped := make(chan struct{})
+	go func() {
+		// drop the first connection, serve the next ones
+		if conn, err := l.Accept(); err == nil {
+			_ = conn.Close()
+		}
+		close(dropped)
+		_ = s.serve(l)
+	}()
+
+	c := NewClientWithCodec(nil, l.Addr().String(), WithRetry(1))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+	<-dropped
+}
+
+func TestClient_WithRetryDisabled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	c := NewClientWithCodec(nil, l.Addr().String(), WithRetry(3))
+	defer c.Close()
+	err = c.Call("Int.Sum", &Args{}, new(int), WithRetryDisabled())
+	assert.True(t, errors.Is(err, ErrConnClosed))
+}
+
+func TestMultiClient_Call(t *testing.T) {
+	s1 := NewServerWithCodec(nil)
+	_ = s1.Register(new(Int))
+	s2 := NewServerWithCodec(nil)
+	addr1, addr2 := startServer(t, s1), startServer(t, s2)
+
+	m := NewMultiClientWithCodec(nil, addr1, addr2)
+	defer m.Close()
+
+	var sum int
+	assert.Nil(t, m.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, WithTarget(addr1)))
+	assert.Equal(t, 3, sum)
+	assert.True(t, errors.Is(m.Call("Int.Sum", &Args{}, &sum, WithTarget(addr2)), ErrMethodNotFound))
+	assert.NotNil(t, m.Call("Int.Sum", &Args{}, &sum, WithTarget("unknown:1")))
+
+	failed := 0
+	for i := 0; i < 4; i++ {
+		if m.Call("Int.Sum", &Args{}, &sum) != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 2, failed)
+
+	assert.NotNil(t, NewMultiClientWithCodec(nil).Call("Int.Sum", &Args{}, &sum))
+}
+
+func TestRequestKey(t *testing.T) {
+	codec := NewGobCodec()
+	r1 := codec.NewRequest("Int.Sum", &Args{A: 1})
+	r2 := codec.NewRequest("Int.Sum", &Args{A: 1})
+	assert.Equal(t, requestKey(r1), requestKey(r2))
+
+	r2.SetMetadata(Metadata{"tenant": "a"})
+	assert.NotEqual(t, requestKey(r1), requestKey(r2))
+}
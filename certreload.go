@@ -0,0 +1,106 @@
+package xrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate from files and reloads it when they
+// change. Plug GetCertificate into a tls.Config; connections established
+// before a reload keep their certificate.
+type CertReloader struct {
+	mu       sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Reload loads the key pair again if either file changed since the last
+// load. On error the current certificate stays in use.
+func (r *CertReloader) Reload() error {
+	r.mu.RLock()
+	certFile, keyFile, loaded := r.certFile, r.keyFile, r.modTime
+	r.mu.RUnlock()
+
+	modTime, err := latestModTime(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	if !modTime.After(loaded) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.certFile == certFile && r.keyFile == keyFile {
+		r.cert, r.modTime = &cert, modTime
+	}
+	return nil
+}
+
+// setFiles points the reloader at other files and loads them.
+func (r *CertReloader) setFiles(certFile, keyFile string) error {
+	r.mu.Lock()
+	if r.certFile != certFile || r.keyFile != keyFile {
+		r.certFile, r.keyFile, r.modTime = certFile, keyFile, time.Time{}
+	}
+	r.mu.Unlock()
+
+	return r.Reload()
+}
+
+// Watch calls Reload every interval until ctx is done.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("rpc: reload certificate err=%v", err)
+			}
+		}
+	}
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,119 @@
+package xrpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a self-signed key pair for name into dir, stamped with
+// modTime, and returns the file paths.
+func writeCert(t *testing.T, dir, name string, modTime time.Time) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	} {
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCert(t, dir, "v1", now.Add(-time.Minute))
+
+	r, err := NewCertReloader(certFile, keyFile)
+	assert.Nil(t, err)
+	cert, _ := r.GetCertificate(nil)
+	assert.Equal(t, "v1", commonName(t, cert))
+
+	writeCert(t, dir, "v2", now)
+	assert.Nil(t, r.Reload())
+	cert, _ = r.GetCertificate(nil)
+	assert.Equal(t, "v2", commonName(t, cert))
+
+	_ = os.Remove(keyFile)
+	assert.NotNil(t, r.Reload())
+	cert, _ = r.GetCertificate(nil)
+	assert.Equal(t, "v2", commonName(t, cert))
+
+	_, err = NewCertReloader(certFile, keyFile)
+	assert.NotNil(t, err)
+}
+
+func TestServer_reloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "v1", time.Now())
+	other := filepath.Join(dir, "other")
+	_ = os.Mkdir(other, 0700)
+	otherCert, otherKey := writeCert(t, other, "v2", time.Now())
+
+	path := filepath.Join(dir, "server.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"tls": {"cert_file": "` + certFile + `", "key_file": "` + keyFile + `"}}`)
+
+	s, err := NewServerFromConfig(path)
+	assert.Nil(t, err)
+	cert, _ := s.tlsConfig.GetCertificate(nil)
+	assert.Equal(t, "v1", commonName(t, cert))
+
+	write(`{"tls": {"cert_file": "` + otherCert + `", "key_file": "` + otherKey + `"},
+		"limits": {"accept_min_backoff": "1ms", "accept_max_backoff": "3ms"}}`)
+	assert.Nil(t, s.reloadConfig())
+	cert, _ = s.tlsConfig.GetCertificate(nil)
+	assert.Equal(t, "v2", commonName(t, cert))
+	min, max := s.acceptBackoff()
+	assert.Equal(t, time.Millisecond, min)
+	assert.Equal(t, 3*time.Millisecond, max)
+
+	assert.NotNil(t, NewServerWithCodec(nil).reloadConfig())
+}
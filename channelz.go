@@ -0,0 +1,186 @@
+package xrpc
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChannelzServer is a snapshot of a serving server.
+type ChannelzServer struct {
+	ID          int64              `json:"id"`
+	Listeners   []ChannelzListener `json:"listeners"`
+	Connections []ChannelzConn     `json:"connections"`
+}
+
+type ChannelzListener struct {
+	ID       int64     `json:"id"`
+	Protocol string    `json:"protocol"`
+	Addr     string    `json:"addr"`
+	Since    time.Time `json:"since"`
+}
+
+// ChannelzConn describes a TCP connection. Byte counters cover frame
+// bodies.
+type ChannelzConn struct {
+	ID        int64     `json:"id"`
+	Remote    string    `json:"remote"`
+	Local     string    `json:"local"`
+	Since     time.Time `json:"since"`
+	FramesIn  uint64    `json:"frames_in"`
+	FramesOut uint64    `json:"frames_out"`
+	BytesIn   uint64    `json:"bytes_in"`
+	BytesOut  uint64    `json:"bytes_out"`
+	Requests  uint64    `json:"requests"`
+	Errors    uint64    `json:"errors"`
+}
+
+var (
+	channelzID      int64
+	channelzMu      sync.RWMutex
+	channelzServers = make(map[int64]*Server)
+)
+
+// openConns counts the TCP connections of clients and servers not closed
+// yet.
+var openConns int64
+
+// OpenConns returns the number of TCP connections opened by the clients and
+// servers of the package and not closed yet, for leak checks.
+func OpenConns() int {
+	return int(atomic.LoadInt64(&openConns))
+}
+
+func nextChannelzID() int64 {
+	return atomic.AddInt64(&channelzID, 1)
+}
+
+// Channelz returns a snapshot of every server with an open listener.
+func Channelz() []ChannelzServer {
+	channelzMu.RLock()
+	servers := make([]*Server, 0, len(channelzServers))
+	for _, s := range channelzServers {
+		servers = append(servers, s)
+	}
+	channelzMu.RUnlock()
+
+	out := make([]ChannelzServer, 0, len(servers))
+	for _, s := range servers {
+		out = append(out, s.Channelz())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+type connCounters struct {
+	framesIn  uint64
+	framesOut uint64
+	bytesIn   uint64
+	bytesOut  uint64
+	requests  uint64
+	errors    uint64
+	info      ChannelzConn
+}
+
+func (cc *connCounters) received(bodyLen, reqs int) {
+	atomic.AddUint64(&cc.framesIn, 1)
+	atomic.AddUint64(&cc.bytesIn, uint64(bodyLen))
+	atomic.AddUint64(&cc.requests, uint64(reqs))
+}
+
+func (cc *connCounters) sent(bodyLen int, resps []Response) {
+	atomic.AddUint64(&cc.framesOut, 1)
+	atomic.AddUint64(&cc.bytesOut, uint64(bodyLen))
+	for _, resp := range resps {
+		if resp == nil || resp.GetErrCode() != Success {
+			atomic.AddUint64(&cc.errors, 1)
+		}
+	}
+}
+
+// serverz tracks the listeners and connections of a server.
+type serverz struct {
+	mu        sync.Mutex
+	listeners map[int64]ChannelzListener
+	conns     map[int64]*connCounters
+}
+
+func (s *Server) trackListener(protocol string, l net.Listener) (untrack func()) {
+	id := nextChannelzID()
+	s.cz.mu.Lock()
+	if s.cz.listeners == nil {
+		s.cz.listeners = make(map[int64]ChannelzListener)
+	}
+	s.cz.listeners[id] = ChannelzListener{ID: id, Protocol: protocol, Addr: l.Addr().String(), Since: time.Now()}
+	s.cz.mu.Unlock()
+
+	channelzMu.Lock()
+	channelzServers[s.id] = s
+	channelzMu.Unlock()
+
+	return func() {
+		s.cz.mu.Lock()
+		delete(s.cz.listeners, id)
+		last := len(s.cz.listeners) == 0
+		s.cz.mu.Unlock()
+
+		if last {
+			channelzMu.Lock()
+			delete(channelzServers, s.id)
+			channelzMu.Unlock()
+		}
+	}
+}
+
+func (s *Server) trackConn(conn net.Conn) (cc *connCounters, untrack func()) {
+	id := nextChannelzID()
+	cc = &connCounters{info: ChannelzConn{
+		ID:     id,
+		Remote: conn.RemoteAddr().String(),
+		Local:  conn.LocalAddr().String(),
+		Since:  time.Now(),
+	}}
+
+	s.cz.mu.Lock()
+	if s.cz.conns == nil {
+		s.cz.conns = make(map[int64]*connCounters)
+	}
+	s.cz.conns[id] = cc
+	s.cz.mu.Unlock()
+
+	return cc, func() {
+		s.cz.mu.Lock()
+		delete(s.cz.conns, id)
+		s.cz.mu.Unlock()
+	}
+}
+
+// Channelz returns a snapshot of the listeners and TCP connections of s.
+func (s *Server) Channelz() ChannelzServer {
+	s.cz.mu.Lock()
+	defer s.cz.mu.Unlock()
+
+	out := ChannelzServer{
+		ID:          s.id,
+		Listeners:   make([]ChannelzListener, 0, len(s.cz.listeners)),
+		Connections: make([]ChannelzConn, 0, len(s.cz.conns)),
+	}
+	for _, l := range s.cz.listeners {
+		out.Listeners = append(out.Listeners, l)
+	}
+	for _, cc := range s.cz.conns {
+		conn := cc.info
+		conn.FramesIn = atomic.LoadUint64(&cc.framesIn)
+		conn.FramesOut = atomic.LoadUint64(&cc.framesOut)
+		conn.BytesIn = atomic.LoadUint64(&cc.bytesIn)
+		conn.BytesOut = atomic.LoadUint64(&cc.bytesOut)
+		conn.Requests = atomic.LoadUint64(&cc.requests)
+		conn.Errors = atomic.LoadUint64(&cc.errors)
+		out.Connections = append(out.Connections, conn)
+	}
+	sort.Slice(out.Listeners, func(i, j int) bool { return out.Listeners[i].ID < out.Listeners[j].ID })
+	sort.Slice(out.Connections, func(i, j int) bool { return out.Connections[i].ID < out.Connections[j].ID })
+	return out
+}
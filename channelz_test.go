@@ -0,0 +1,74 @@
+package xrpc
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func findServer(id int64) *ChannelzServer {
+	for _, cs := range Channelz() {
+		if cs.ID == id {
+			return &cs
+		}
+	}
+	return nil
+}
+
+func TestChannelz(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	assert.Nil(t, findServer(s.id))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = s.serve(l)
+		close(done)
+	}()
+
+	c := NewClientWithCodec(nil, l.Addr().String())
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.NotNil(t, c.Call("Int.Sub", &Args{A: 1, B: 2}, &sum))
+
+	cs := findServer(s.id)
+	if assert.NotNil(t, cs) {
+		assert.Len(t, cs.Listeners, 1)
+		assert.Equal(t, "tcp", cs.Listeners[0].Protocol)
+		assert.Equal(t, l.Addr().String(), cs.Listeners[0].Addr)
+	}
+
+	conns := s.Channelz().Connections
+	if assert.Len(t, conns, 1) {
+		assert.Equal(t, uint64(2), conns[0].FramesIn)
+		assert.Equal(t, uint64(2), conns[0].FramesOut)
+		assert.Equal(t, uint64(2), conns[0].Requests)
+		assert.Equal(t, uint64(1), conns[0].Errors)
+		assert.True(t, conns[0].BytesIn > 0)
+		assert.True(t, conns[0].BytesOut > 0)
+	}
+
+	w := httptest.NewRecorder()
+	s.AdminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/channelz", nil))
+	var dumped ChannelzServer
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &dumped))
+	assert.Equal(t, s.id, dumped.ID)
+	assert.Len(t, dumped.Connections, 1)
+
+	c.Close()
+	assert.Eventually(t, func() bool {
+		return len(s.Channelz().Connections) == 0
+	}, time.Second, time.Millisecond)
+
+	_ = l.Close()
+	<-done
+	assert.Nil(t, findServer(s.id))
+}
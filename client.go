@@ -5,23 +5,58 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/dabao-zhao/xrpc/proto"
 )
 
-func NewClientWithCodec(codec ClientCodec, tcpAddr string) *Client {
+func NewClientWithCodec(codec ClientCodec, tcpAddr string, opts ...ClientOption) *Client {
 	if codec == nil {
 		codec = NewGobCodec()
 	}
 
-	return &Client{
+	c := &Client{
 		tcpAddr: tcpAddr,
 		codec:   codec,
+		timeout: defaultCallTimeout,
+		dialer:  &net.Dialer{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.mirror != nil {
+		c.mirror.init(c)
+	}
+	if isHTTPAddr(tcpAddr) && c.httpClient == nil {
+		c.httpClient = c.newHTTPClient()
+	}
+	if c.outbox != nil {
+		c.outbox.start(c)
+	}
+	if c.heartbeat != nil && !isHTTPAddr(tcpAddr) {
+		go c.heartbeatLoop()
+	}
+	return c
+}
+
+// Dialer opens client connections. *net.Dialer and most proxy dialers
+// satisfy it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f DialFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
 }
 
 type Client struct {
@@ -29,21 +64,187 @@ type Client struct {
 
 	codec ClientCodec
 
+	flight *flightGroup
+	cache  *responseCache
+	stats  callStats
+	mirror *mirror
+	outbox *outbox
+	queue  *callQueue
+	limits *rateLimiter
+	faults *FaultInjector
+
+	heartbeat    *heartbeat
+	interceptors []ClientInterceptor
+	protoVersion uint16 // of the frames sent, 0 for proto.Ver1
+	schema       *schemaCheck
+
+	validate     ResponseValidator
+	codes        *CodeTranslator
+	events       ConnEvents
+	connected    bool // a connection was established before
+	frameHook    proto.FrameHook
+	readBufSize  int
+	writeBufSize int
+
+	timeout    time.Duration // used when the call context has no deadline
+	dialer     Dialer
+	maxRetries int
+
+	dialTimeout   time.Duration
+	eyeballsDelay time.Duration
+	lookupHost    func(ctx context.Context, host string) ([]string, error) // for tests
+
+	httpClient *http.Client // for http:// and https:// addresses
+
+	mu      sync.Mutex // guards tcpConn and serializes round trips on it
 	tcpConn net.Conn
+	conn    connWatch
+}
+
+func (c *Client) Call(method string, args, reply interface{}, opts ...CallOption) error {
+	return c.CallContext(context.Background(), method, args, reply, opts...)
 }
 
-func (c *Client) Call(method string, args, reply interface{}) error {
+// CallContext is like Call, but the round trip is bounded by the deadline
+// of ctx and aborted when ctx is canceled.
+func (c *Client) CallContext(ctx context.Context, method string, args, reply interface{}, opts ...CallOption) error {
+	if len(c.interceptors) > 0 {
+		return chainClientInterceptors(c.interceptors, c.invoke)(ctx, method, args, reply, opts...)
+	}
+	return c.invoke(ctx, method, args, reply, opts...)
+}
+
+func (c *Client) invoke(ctx context.Context, method string, args, reply interface{}, opts ...CallOption) error {
+	o := newCallOptions(opts)
+	resp, err := c.call(ctx, method, args, o)
+	if o.respMd != nil && resp != nil {
+		*o.respMd = resp.GetMetadata()
+	}
+	if err != nil {
+		return err
+	}
+	if o.envelope != nil {
+		return decodeEnvelope(resp, o.envelope, reply)
+	}
+	return resp.DecodeInto(reply)
+}
+
+// call sends a single request and returns its response, or the remote
+// error it carries.
+func (c *Client) call(ctx context.Context, method string, args interface{}, o callOptions) (resp Response, err error) {
+	start := time.Now()
+	defer func() {
+		c.stats.record(method, time.Since(start), err)
+	}()
+
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	if len(o.attached) > 0 {
+		if !isHTTPAddr(c.tcpAddr) {
+			return nil, errors.New("rpc: attachments need an http:// or https:// address")
+		}
+		ctx = withOutgoingAttachments(ctx, o.attached)
+	}
+
 	req := c.codec.NewRequest(method, args)
+	if req == nil {
+		return nil, errors.New("rpc: could not encode request " + method)
+	}
+	if len(o.md) > 0 {
+		req.SetMetadata(o.md)
+	}
+
+	var key string
+	cached := c.cache != nil && c.cache.match(method) && len(o.attached) == 0 // keyed by params only
+	if cached {
+		key = requestKey(req)
+		var ok bool
+		if resp, ok = c.cache.get(key); ok {
+			return resp, nil
+		}
+	}
+
+	if c.mirror != nil {
+		c.mirror.send(req)
+	}
+
+	if c.flight != nil && c.flight.match(method) && len(o.attached) == 0 {
+		resp, err = c.flight.do(ctx, requestKey(req), func(ctx context.Context) (Response, error) {
+			return c.send(ctx, req, o)
+		})
+		if err != nil && err == ctx.Err() {
+			err = c.ctxErr(err)
+		}
+	} else {
+		resp, err = c.send(ctx, req, o)
+	}
+
+	if cached && err == nil {
+		c.cache.add(key, resp)
+	}
+	return resp, err
+}
+
+// send does the round trip of req, retrying on connection failures.
+func (c *Client) send(ctx context.Context, req Request, o callOptions) (Response, error) {
+	for attempt := 0; ; attempt++ {
+		var resp Response
+		err := c.pace(ctx, req.GetMethod())
+		if err == nil {
+			err = c.injectFault(ctx, req.GetMethod())
+		}
+		if err == nil {
+			resp, err = c.roundTrip(ctx, req)
+		}
+		if err == nil || o.noRetry || attempt >= c.maxRetries || !retryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+	}
+}
+
+func (c *Client) injectFault(ctx context.Context, method string) error {
+	if c.faults == nil {
+		return nil
+	}
+	if err := c.faults.inject(ctx, method, true); err != nil {
+		return c.ctxErr(err)
+	}
+	return nil
+}
+
+func (c *Client) pace(ctx context.Context, method string) error {
+	if c.limits == nil {
+		return nil
+	}
+	if err := c.limits.wait(ctx, method); err != nil {
+		return c.ctxErr(err)
+	}
+	return nil
+}
+
+func retryable(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, ErrConnClosed) || errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (c *Client) roundTrip(ctx context.Context, req Request) (Response, error) {
 	resps := make([]Response, 0)
-	if err := c.callTcp([]Request{req}, &resps); err != nil {
-		return err
+	if err := c.callTcp(ctx, []Request{req}, &resps); err != nil {
+		return nil, err
 	}
 
 	resp := resps[0]
-	if err := c.codec.ReadResponseBody(resp.GetReply(), reply); err != nil {
-		return err
+	if err := responseError(resp); err != nil {
+		if c.codes != nil {
+			err = translateError(c.codes, err)
+		}
+		return resp, err
 	}
-	return nil
+	return resp, nil
 }
 
 func (c *Client) CallBatch(reqs []Request, reply interface{}) error {
@@ -56,13 +257,21 @@ func (c *Client) CallBatch(reqs []Request, reply interface{}) error {
 		return errors.New("multi reply should be array or slice pointer")
 	}
 
+	if id, dup := duplicateId(reqs); dup {
+		return fmt.Errorf("rpc: duplicate request id %q in batch", id)
+	}
+
 	resps := make([]Response, len(reqs))
-	if err := c.callTcp(reqs, &resps); err != nil {
+	if err := c.callTcp(context.Background(), reqs, &resps); err != nil {
 		return err
 	}
 	var results []interface{}
-	for _, resp := range resps {
-		results = append(results, resp.GetResult())
+	for _, resp := range matchResponses(reqs, resps) {
+		var result interface{}
+		if resp != nil {
+			result = resp.GetResult()
+		}
+		results = append(results, result)
 	}
 
 	respBody, err := c.codec.EncodeResponses(results)
@@ -76,67 +285,256 @@ func (c *Client) CallBatch(reqs []Request, reply interface{}) error {
 	return nil
 }
 
-func (c *Client) callTcp(reqs []Request, resps *[]Response) (err error) {
-	if err = c.valid(); err != nil {
+// matchResponses orders resps like reqs by id, since servers may answer a
+// batch in any order. Responses matching no request, e.g. errors the server
+// could not attribute, fill the remaining positions in order.
+func matchResponses(reqs []Request, resps []Response) []Response {
+	byId := make(map[string]Response, len(resps))
+	for _, resp := range resps {
+		if r, ok := resp.(IdentifiedResponse); ok && r.GetReqId() != "" {
+			byId[r.GetReqId()] = resp
+		}
+	}
+	if len(byId) == 0 {
+		return resps
+	}
+
+	out := make([]Response, len(reqs))
+	matched := make(map[Response]bool, len(reqs))
+	for i, req := range reqs {
+		if resp, ok := byId[req.GetId()]; ok && req.GetId() != "" {
+			out[i] = resp
+			matched[resp] = true
+		}
+	}
+	var rest []Response
+	for _, resp := range resps {
+		if !matched[resp] {
+			rest = append(rest, resp)
+		}
+	}
+	for i := range out {
+		if out[i] == nil && len(rest) > 0 {
+			out[i], rest = rest[0], rest[1:]
+		}
+	}
+	return out
+}
+
+func (c *Client) callTcp(ctx context.Context, reqs []Request, resps *[]Response) (err error) {
+	if c.queue != nil {
+		if err = c.queue.acquire(ctx); err != nil {
+			return c.ctxErr(err)
+		}
+		defer c.queue.release()
+	}
+	if isHTTPAddr(c.tcpAddr) {
+		return c.callHTTP(ctx, reqs, resps)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err = ctx.Err(); err != nil {
+		return c.ctxErr(err)
+	}
+	if err = c.valid(ctx); err != nil {
 		return err
 	}
+	if c.heartbeat != nil {
+		c.heartbeat.used()
+	}
 
 	var (
-		wr    = bufio.NewWriter(c.tcpConn)
-		rr    = bufio.NewReader(c.tcpConn)
+		conn  = c.tcpConn
+		wr    = bufio.NewWriterSize(conn, bufSize(c.writeBufSize))
+		rr    = bufio.NewReaderSize(conn, bufSize(c.readBufSize))
 		pSend = proto.New()
 		pRec  = proto.New()
 	)
+	pSend.Hook = c.frameHook
+	pRec.Hook = c.frameHook
+	if c.protoVersion != 0 {
+		pSend.Ver = c.protoVersion
+	}
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	select {
-	case <-timeoutCtx.Done():
-		return errors.New("timeout")
-	default:
-		if pSend.Body, err = c.codec.EncodeRequests(&reqs); err != nil {
-			return err
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return c.connErr(ctx, err)
+	}
+	// interrupt blocked reads and writes once ctx is canceled, unless the
+	// call ended: the conn may serve the next one by then
+	var (
+		inFlight sync.Mutex
+		done     = make(chan struct{})
+	)
+	defer func() {
+		inFlight.Lock()
+		close(done)
+		inFlight.Unlock()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			inFlight.Lock()
+			defer inFlight.Unlock()
+			select {
+			case <-done:
+			default:
+				_ = conn.SetDeadline(time.Unix(1, 0))
+			}
+		case <-done:
 		}
+	}()
 
-		if err := pSend.WriteTCP(wr); err != nil {
-			return err
-		}
-		_ = wr.Flush()
+	if pSend.Body, err = c.codec.EncodeRequests(&reqs); err != nil {
+		return err
+	}
 
-		if err := pRec.ReadTCP(rr); err != nil {
-			return err
-		}
+	if err := pSend.WriteTCP(wr); err != nil {
+		return c.connErr(ctx, err)
+	}
+	if err := wr.Flush(); err != nil {
+		return c.connErr(ctx, err)
+	}
 
-		*resps, err = c.codec.ReadResponse(pRec.Body)
-		if err != nil {
+	if err := pRec.ReadTCP(rr); err != nil {
+		return c.connErr(ctx, err)
+	}
+	if err := closeFrameErr(pRec); err != nil {
+		c.close(err)
+		return err
+	}
+
+	if c.validate != nil {
+		if err = c.validate(reqs, pRec.Body); err != nil {
 			return err
 		}
 	}
+	*resps, err = c.codec.ReadResponse(pRec.Body)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (c *Client) Close() {
+	if c.outbox != nil {
+		c.outbox.stop()
+	}
+	if c.heartbeat != nil {
+		c.heartbeat.close()
+	}
+	if c.mirror != nil {
+		c.mirror.client.Close()
+	}
+	if isHTTPAddr(c.tcpAddr) {
+		c.httpClient.CloseIdleConnections()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.close(nil)
+}
+
+// close drops the connection, which reason broke if not nil.
+func (c *Client) close(reason error) {
 	if c.tcpConn == nil {
 		return
 	}
 	if err := c.tcpConn.Close(); err != nil {
 		log.Printf("could not close c.tcpConn, err=%v", err)
 	}
+	c.tcpConn = nil
+	atomic.AddInt64(&openConns, -1)
+	c.conn.set(Idle)
+	c.closed(reason)
 }
 
-func (c *Client) valid() error {
+// connErr converts I/O errors into ErrTimeout, ErrConnClosed or the error
+// of ctx. The connection is dropped in those cases, since a frame may have
+// been cut short, and the next call dials again.
+func (c *Client) connErr(ctx context.Context, err error) error {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		abortConn(c.tcpConn)
+		c.close(err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return c.ctxErr(ctxErr)
+		}
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) &&
+		!errors.Is(err, net.ErrClosed) && !errors.Is(err, syscall.ECONNRESET) &&
+		!errors.Is(err, syscall.EPIPE) {
+		return err
+	}
+	c.close(err)
+	return fmt.Errorf("%w: %v", ErrConnClosed, err)
+}
+
+func (c *Client) ctxErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	return err
+}
+
+// responseError converts the error carried by resp into an *Error so
+// callers can inspect it with errors.Is and errors.As.
+func responseError(resp Response) error {
+	err := resp.Error()
+	if err == nil {
+		return nil
+	}
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return &Error{ErrCode: resp.GetErrCode(), ErrMsg: err.Error()}
+}
+
+// translateError returns a copy of the remote error err with its internal
+// code.
+func translateError(t *CodeTranslator, err error) error {
+	rpcErr := err.(*Error)
+	out := *rpcErr
+	out.ErrCode = t.FromWire(rpcErr.ErrCode)
+	return &out
+}
+
+func (c *Client) valid(ctx context.Context) error {
 	if c.codec == nil {
 		return errors.New("client has an empty codec")
 	}
 
 	if c.tcpConn == nil {
-		conn, err := net.Dial("tcp", c.tcpAddr)
+		c.conn.set(Connecting)
+		network, addr := splitNetwork(c.tcpAddr)
+		start := time.Now()
+		conn, err := c.dial(ctx, network, addr)
 		if err != nil {
-			return fmt.Errorf("net.Dial tcp get err: %v", err)
+			c.conn.set(TransientFailure)
+			c.dialFailed(err)
+			return fmt.Errorf("dial tcp get err: %w", err)
+		}
+		if c.schema != nil {
+			if err = c.hello(conn); err != nil {
+				_ = conn.Close()
+				c.conn.set(TransientFailure)
+				c.dialFailed(err)
+				return err
+			}
 		}
 		c.tcpConn = conn
+		atomic.AddInt64(&openConns, 1)
+		c.conn.set(Ready)
+		c.dialed(time.Since(start))
 	}
 	return nil
 }
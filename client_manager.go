@@ -0,0 +1,148 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ClientManager caches one MultiClient per target and codec, see
+// NewMultiClientForTarget, and closes those unused for a while. It is safe
+// for concurrent use.
+type ClientManager struct {
+	idleTimeout     time.Duration
+	resolveInterval time.Duration
+
+	mu      sync.Mutex
+	clients map[managedKey]*managedClient
+	closed  bool
+	stop    chan struct{}
+}
+
+type managedKey struct {
+	target string
+	codec  string
+}
+
+type managedClient struct {
+	client   *MultiClient
+	cancel   context.CancelFunc // stops re-resolving the target
+	lastUsed time.Time
+}
+
+// NewClientManager closes clients not returned by Get for idleTimeout (0
+// keeps them until Close) and re-resolves targets every resolveInterval
+// (0 resolves them once).
+func NewClientManager(idleTimeout, resolveInterval time.Duration) *ClientManager {
+	m := &ClientManager{
+		idleTimeout:     idleTimeout,
+		resolveInterval: resolveInterval,
+		clients:         make(map[managedKey]*managedClient),
+		stop:            make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go m.evictLoop()
+	}
+	return m
+}
+
+// Get returns the client of target using the codec registered as codec.
+// Get it for each use rather than keeping it: a client evicted as idle is
+// closed.
+func (m *ClientManager) Get(ctx context.Context, target, codec string) (*MultiClient, error) {
+	key := managedKey{target: target, codec: codec}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errors.New("rpc: client manager closed")
+	}
+	if mc, ok := m.clients[key]; ok {
+		mc.lastUsed = time.Now()
+		m.mu.Unlock()
+		return mc.client, nil
+	}
+	m.mu.Unlock()
+
+	// resolve without holding the lock, then keep the first client stored
+	c, err := NewCodec(codec)
+	if err != nil {
+		return nil, err
+	}
+	watchCtx, cancel := context.WithCancel(context.Background())
+	client, err := newMultiClientForTarget(ctx, watchCtx, c, target, m.resolveInterval)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		cancel()
+		client.Close()
+		return nil, errors.New("rpc: client manager closed")
+	}
+	if mc, ok := m.clients[key]; ok {
+		cancel()
+		client.Close()
+		mc.lastUsed = time.Now()
+		return mc.client, nil
+	}
+	m.clients[key] = &managedClient{client: client, cancel: cancel, lastUsed: time.Now()}
+	return client, nil
+}
+
+func (m *ClientManager) evictLoop() {
+	ticker := time.NewTicker(m.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.evictIdle(now)
+		}
+	}
+}
+
+func (m *ClientManager) evictIdle(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, mc := range m.clients {
+		if now.Sub(mc.lastUsed) >= m.idleTimeout {
+			mc.cancel()
+			mc.client.Close()
+			delete(m.clients, key)
+		}
+	}
+}
+
+// Len returns the number of cached clients.
+func (m *ClientManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.clients)
+}
+
+// Close closes every client. Later calls to Get fail.
+func (m *ClientManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+	m.closed = true
+	close(m.stop)
+	for key, mc := range m.clients {
+		mc.cancel()
+		mc.client.Close()
+		delete(m.clients, key)
+	}
+}
@@ -0,0 +1,65 @@
+package xrpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientManager(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	addr := startServer(t, s)
+
+	m := NewClientManager(0, 0)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	clients := make([]*MultiClient, 8)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := m.Get(ctx, addr, "gob")
+			assert.Nil(t, err)
+			clients[i] = c
+		}(i)
+	}
+	wg.Wait()
+	for _, c := range clients {
+		assert.True(t, c == clients[0])
+	}
+	assert.Equal(t, 1, m.Len())
+
+	var sum int
+	assert.Nil(t, clients[0].Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+
+	_, err := m.Get(ctx, addr, "nope")
+	assert.NotNil(t, err)
+	_, err = m.Get(ctx, "nope://x", "gob")
+	assert.NotNil(t, err)
+
+	m.Close()
+	m.Close()
+	assert.Equal(t, 0, m.Len())
+	_, err = m.Get(ctx, addr, "gob")
+	assert.NotNil(t, err)
+}
+
+func TestClientManager_EvictIdle(t *testing.T) {
+	m := NewClientManager(20*time.Millisecond, 0)
+	defer m.Close()
+
+	a, err := m.Get(context.Background(), "static://a:1", "gob")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return m.Len() == 0 }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, a.Addrs())
+
+	b, err := m.Get(context.Background(), "static://a:1", "gob")
+	assert.Nil(t, err)
+	assert.False(t, a == b)
+}
@@ -0,0 +1,152 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startServer(t testing.TB, s *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.serve(l) }()
+	t.Cleanup(func() { _ = l.Close() })
+	return l.Addr().String()
+}
+
+func TestClient_Call(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+}
+
+func TestClient_CallRemoteErr(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	err := c.Call("Int.Sub", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+	assert.False(t, errors.Is(err, ErrInternal))
+
+	var rpcErr *Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, MethodNotFound, rpcErr.ErrCode)
+	assert.Equal(t, "rpc: can't find method Int.Sub, did you mean Int.Sum?", rpcErr.ErrMsg)
+}
+
+func TestClient_CallConnClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	c := NewClientWithCodec(nil, l.Addr().String())
+	defer c.Close()
+
+	var sum int
+	err = c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrConnClosed))
+	assert.Nil(t, c.tcpConn)
+}
+
+func TestClient_CallContextTimeout(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Slow))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr)
+	defer c.Close()
+
+	d := time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.CallContext(ctx, "Slow.Sleep", &d, new(int))
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.Nil(t, c.tcpConn)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err = c.CallContext(ctx, "Slow.Sleep", &d, new(int))
+	assert.Equal(t, context.Canceled, err)
+
+	c2 := NewClientWithCodec(nil, addr, WithTimeout(20*time.Millisecond))
+	defer c2.Close()
+	err = c2.Call("Slow.Sleep", &d, new(int))
+	assert.True(t, errors.Is(err, ErrTimeout))
+
+	d = 0
+	assert.Nil(t, c2.Call("Slow.Sleep", &d, new(int)))
+}
+
+func TestClient_WithDialer(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	addr := startServer(t, s)
+
+	var dialed string
+	c := NewClientWithCodec(nil, "int.service:1", WithDialer(DialFunc(func(ctx context.Context, network, target string) (net.Conn, error) {
+		dialed = target
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	})))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+	assert.Equal(t, "int.service:1", dialed)
+}
+
+func TestMatchResponses(t *testing.T) {
+	reqs := []Request{&defaultRequest{Id: "a"}, &defaultRequest{Id: "b"}, &defaultRequest{Id: "c"}}
+	a, b, c := &defaultResponse{Id: "a"}, &defaultResponse{Id: "b"}, &defaultResponse{Id: "c"}
+	assert.Equal(t, []Response{a, b, c}, matchResponses(reqs, []Response{c, a, b}))
+
+	// an error the server could not attribute takes the free position
+	unknown := &defaultResponse{ErrCode: InvalidRequest}
+	assert.Equal(t, []Response{a, unknown, c}, matchResponses(reqs, []Response{c, unknown, a}))
+
+	// without ids, by position
+	anon := []Response{&defaultResponse{}, &defaultResponse{}}
+	assert.Equal(t, anon, matchResponses([]Request{&defaultRequest{}, &defaultRequest{}}, anon))
+}
+
+func TestClient_CancelAfterCallKeepsConn(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Slow))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr)
+	defer c.Close()
+
+	// each call's context ends as the next one starts on the same conn
+	var d time.Duration
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := c.CallContext(ctx, "Slow.Sleep", &d, new(int))
+		cancel()
+		if !assert.Nil(t, err, "call %d", i) {
+			break
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Command xrpc-bench baselines the library on your hardware, calling
+// xrpc.BenchService under load:
+//
+//	xrpc-bench serve -codec gob -tcp :9000 -http :9001  serve BenchService
+//	xrpc-bench run -codec gob -c 16 -size 128 -d 10s ADDR
+//
+// ADDR is host:port for TCP or an http(s) URL. Run reports the throughput
+// and the latency percentiles of the calls.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+	_ "github.com/dabao-zhao/xrpc/jsonrpc"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "serve":
+		serve(os.Args[2:])
+	case "run":
+		run(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: xrpc-bench serve [-codec gob] [-tcp addr] [-http addr] | run [-codec gob] [-c n] [-conns n] [-size bytes] [-d duration] [-method Echo] addr")
+	os.Exit(2)
+}
+
+func newCodec(name string) xrpc.Codec {
+	codec, err := xrpc.NewCodec(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return codec
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	codecName := fs.String("codec", "gob", "codec: gob, json or json-interop")
+	tcpAddr := fs.String("tcp", ":9000", "TCP address, empty to disable")
+	httpAddr := fs.String("http", ":9001", "HTTP address, empty to disable")
+	_ = fs.Parse(args)
+
+	s := xrpc.NewServerWithCodec(newCodec(*codecName))
+	if err := s.Register(new(xrpc.BenchService)); err != nil {
+		log.Fatal(err)
+	}
+	errCh := make(chan error, 2)
+	if *tcpAddr != "" {
+		go func() { errCh <- s.ServeTCP(*tcpAddr) }()
+	}
+	if *httpAddr != "" {
+		go func() { errCh <- http.ListenAndServe(*httpAddr, s) }()
+	}
+	log.Fatal(<-errCh)
+}
+
+func run(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	codecName := fs.String("codec", "gob", "codec: gob, json or json-interop")
+	concurrency := fs.Int("c", 16, "concurrent callers")
+	conns := fs.Int("conns", 1, "clients the callers share")
+	size := fs.Int("size", 128, "payload size in bytes")
+	duration := fs.Duration("d", 10*time.Second, "duration of the run")
+	method := fs.String("method", "Echo", "method of BenchService: Echo or Discard")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *concurrency < 1 || *conns < 1 {
+		usage()
+	}
+
+	clients := make([]*xrpc.Client, *conns)
+	for i := range clients {
+		clients[i] = xrpc.NewClientWithCodec(newCodec(*codecName), fs.Arg(0))
+		defer clients[i].Close()
+	}
+	payload := &xrpc.BenchPayload{Data: make([]byte, *size)}
+	name := "BenchService." + *method
+
+	// one call first, so setup errors don't drown in the results
+	var reply xrpc.RawMessage // not decoded, to measure the library only
+	if err := clients[0].Call(name, payload, &reply); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	results := make([]result, *concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func(r *result, c *xrpc.Client) {
+			defer wg.Done()
+			r.call(ctx, c, name, payload)
+		}(&results[i], clients[i%len(clients)])
+	}
+	wg.Wait()
+	report(time.Since(start), *size, results)
+}
+
+type result struct {
+	latencies []time.Duration
+	errors    int
+	lastErr   error
+}
+
+func (r *result) call(ctx context.Context, c *xrpc.Client, method string, payload *xrpc.BenchPayload) {
+	var reply xrpc.RawMessage // not decoded, to measure the library only
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := c.Call(method, payload, &reply)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.errors++
+			r.lastErr = err
+			continue
+		}
+		r.latencies = append(r.latencies, time.Since(start))
+	}
+}
+
+func report(elapsed time.Duration, size int, results []result) {
+	var (
+		all     []time.Duration
+		errors  int
+		lastErr error
+	)
+	for _, r := range results {
+		all = append(all, r.latencies...)
+		errors += r.errors
+		if r.lastErr != nil {
+			lastErr = r.lastErr
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	secs := elapsed.Seconds()
+	fmt.Printf("calls      %d in %s, %d errors\n", len(all), elapsed.Round(time.Millisecond), errors)
+	fmt.Printf("throughput %.0f calls/s, %.2f MB/s\n", float64(len(all))/secs, float64(len(all)*size)/secs/1e6)
+	if len(all) > 0 {
+		fmt.Printf("latency    p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+			percentile(all, 0.5), percentile(all, 0.9), percentile(all, 0.99), percentile(all, 0.999), all[len(all)-1])
+	}
+	if lastErr != nil {
+		fmt.Printf("last error %v\n", lastErr)
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
@@ -0,0 +1,77 @@
+// Command xrpc-conformance helps checking implementations of the protocol
+// in other languages:
+//
+//	xrpc-conformance serve -tcp :9000 -http :9001  serve the cases to a client
+//	xrpc-conformance check ADDR                    check the server at ADDR
+//	xrpc-conformance cases                         print the cases
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/dabao-zhao/xrpc/conformance"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "serve":
+		serve(os.Args[2:])
+	case "check":
+		check(os.Args[2:])
+	case "cases":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(conformance.Cases())
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: xrpc-conformance serve [-tcp addr] [-http addr] | check addr | cases")
+	os.Exit(2)
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	tcpAddr := fs.String("tcp", ":9000", "TCP address, empty to disable")
+	httpAddr := fs.String("http", ":9001", "HTTP address, empty to disable")
+	_ = fs.Parse(args)
+
+	s := conformance.NewServer()
+	errCh := make(chan error, 2)
+	if *tcpAddr != "" {
+		go func() { errCh <- s.ServeTCP(*tcpAddr) }()
+	}
+	if *httpAddr != "" {
+		go func() { errCh <- http.ListenAndServe(*httpAddr, s) }()
+	}
+	log.Fatal(<-errCh)
+}
+
+func check(args []string) {
+	if len(args) != 1 {
+		usage()
+	}
+	failed := 0
+	for _, r := range conformance.Check(context.Background(), args[0]) {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", r.Case, r.Err)
+		} else {
+			fmt.Printf("ok   %s\n", r.Case)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
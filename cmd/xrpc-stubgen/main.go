@@ -0,0 +1,59 @@
+// Command xrpc-stubgen generates a TypeScript or Python client from the
+// methods of a server, as served by the /methods route of its admin
+// handler:
+//
+//	xrpc-stubgen -lang typescript http://localhost:8081/methods > client.ts
+//	xrpc-stubgen -lang python methods.json > client.py
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/dabao-zhao/xrpc/stubgen"
+)
+
+func main() {
+	lang := flag.String("lang", "typescript", "language of the client: typescript or python")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: xrpc-stubgen [-lang typescript|python] URL|FILE")
+		os.Exit(2)
+	}
+
+	r, err := open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	var methods []xrpc.MethodDescription
+	if err := json.NewDecoder(r).Decode(&methods); err != nil {
+		log.Fatal(err)
+	}
+	if err := stubgen.Generate(os.Stdout, *lang, methods); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func open(src string) (io.ReadCloser, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := http.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", src, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return os.Open(src)
+}
@@ -0,0 +1,108 @@
+// Command xrpc-tap prints the frames of a capture recorded with
+// xrpctest.Tap:
+//
+//	xrpc-tap [-codec gob] capture.tap
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dabao-zhao/xrpc"
+	_ "github.com/dabao-zhao/xrpc/jsonrpc"
+	"github.com/dabao-zhao/xrpc/proto"
+	"github.com/dabao-zhao/xrpc/xrpctest"
+)
+
+func main() {
+	codecName := flag.String("codec", "gob", "codec of the bodies: gob, json or json-interop")
+	raw := flag.Bool("raw", false, "print bodies without decoding them")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: xrpc-tap [-codec gob] [-raw] capture.tap")
+		os.Exit(2)
+	}
+
+	codec, err := xrpc.NewCodec(*codecName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	frames, err := xrpctest.ReadTap(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, tf := range frames {
+		p := tf.Frame
+		arrow := "<-"
+		if tf.Dir == proto.Write {
+			arrow = "->"
+		}
+		fmt.Printf("%s conn %d %s op=%d ver=%d seq=%d ext=%d body=%d\n",
+			tf.Time.Format("15:04:05.000000"), tf.Conn, arrow, p.Op, p.Ver, p.Seq, len(p.Ext), len(p.Body))
+		if *raw || !printBody(codec, p) {
+			printBytes("  ", p.Body)
+		}
+	}
+}
+
+// printBody prints the requests or responses of p, if codec decodes them.
+// Servers don't set the op of responses, so both are tried.
+func printBody(codec xrpc.Codec, p *proto.Proto) bool {
+	if p.Op != proto.OpResponse && printRequests(codec, p.Body) {
+		return true
+	}
+	return printResponses(codec, p.Body)
+}
+
+func printRequests(codec xrpc.Codec, body []byte) bool {
+	reqs, err := codec.ReadRequest(body)
+	if err != nil {
+		return false
+	}
+	for _, req := range reqs {
+		fmt.Printf("  request %s id=%q md=%v\n", req.GetMethod(), req.GetId(), req.GetMetadata())
+		printBytes("    ", req.GetParams())
+	}
+	return true
+}
+
+func printResponses(codec xrpc.Codec, body []byte) bool {
+	resps, err := codec.ReadResponse(body)
+	if err != nil {
+		return false
+	}
+	for _, resp := range resps {
+		fmt.Printf("  response code=%v md=%v\n", resp.GetErrCode(), resp.GetMetadata())
+		if err := resp.Error(); err != nil {
+			fmt.Printf("    %v\n", err)
+		} else {
+			printBytes("    ", resp.GetReply())
+		}
+	}
+	return true
+}
+
+// printBytes prints text as is, binary data as a hex dump.
+func printBytes(indent string, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	if utf8.Valid(b) {
+		fmt.Printf("%s%s\n", indent, b)
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(hex.Dump(b), "\n"), "\n") {
+		fmt.Printf("%s%s\n", indent, line)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
 )
 
 var (
@@ -18,31 +19,61 @@ type Request interface {
 	GetMethod() string
 	GetParams() []byte
 	GetId() string
+	GetMetadata() Metadata
+	SetMetadata(md Metadata)
 }
 
+// MalformedRequest stands for an element of a batch which could not be
+// parsed. Codecs return it from ReadRequest, so that the server answers
+// the element with an InvalidRequest error at its position rather than
+// failing the whole batch.
+type MalformedRequest struct {
+	Id  string // if it could be read
+	Err error
+}
+
+func (r *MalformedRequest) GetMethod() string       { return "" }
+func (r *MalformedRequest) GetParams() []byte       { return nil }
+func (r *MalformedRequest) GetId() string           { return r.Id }
+func (r *MalformedRequest) GetMetadata() Metadata   { return nil }
+func (r *MalformedRequest) SetMetadata(md Metadata) {}
+
 type Response interface {
 	Error() error
-	GetErrCode() int
+	GetErrCode() Code
 	GetReply() []byte
 	GetResult() interface{}
+	DecodeInto(out interface{}) error
 	SetReqId(id string)
+	GetMetadata() Metadata
+	SetMetadata(md Metadata)
 }
 
 type defaultRequest struct {
 	Method string
 	Args   []byte
 	Id     string
+	Meta   Metadata
 }
 
-func (d *defaultRequest) GetMethod() string { return d.Method }
-func (d *defaultRequest) GetParams() []byte { return d.Args }
-func (d *defaultRequest) GetId() string     { return d.Id }
+func (d *defaultRequest) GetMethod() string       { return d.Method }
+func (d *defaultRequest) GetParams() []byte       { return d.Args }
+func (d *defaultRequest) GetId() string           { return d.Id }
+func (d *defaultRequest) GetMetadata() Metadata   { return d.Meta }
+func (d *defaultRequest) SetMetadata(md Metadata) { d.Meta = md }
+
+// IdentifiedResponse is a response telling the id of its request, which
+// CallBatch matches responses to requests by.
+type IdentifiedResponse interface {
+	GetReqId() string
+}
 
 type defaultResponse struct {
 	Reply   []byte
 	Err     string
-	ErrCode int
+	ErrCode Code
 	Id      string
+	Meta    Metadata
 }
 
 func (d *defaultResponse) Error() error {
@@ -52,10 +83,21 @@ func (d *defaultResponse) Error() error {
 	return errors.New(d.Err)
 }
 
-func (d *defaultResponse) GetReply() []byte       { return d.Reply }
-func (d *defaultResponse) GetResult() interface{} { return nil }
-func (d *defaultResponse) GetErrCode() int        { return d.ErrCode }
-func (d *defaultResponse) SetReqId(id string)     { d.Id = id }
+func (d *defaultResponse) GetReply() []byte        { return d.Reply }
+func (d *defaultResponse) GetResult() interface{}  { return nil }
+func (d *defaultResponse) GetErrCode() Code        { return d.ErrCode }
+func (d *defaultResponse) SetReqId(id string)      { d.Id = id }
+func (d *defaultResponse) GetReqId() string        { return d.Id }
+func (d *defaultResponse) GetMetadata() Metadata   { return d.Meta }
+func (d *defaultResponse) SetMetadata(md Metadata) { d.Meta = md }
+func (d *defaultResponse) DecodeInto(out interface{}) error {
+	return (&gobCodec{}).Decode(d.Reply, out)
+}
+func (d *defaultResponse) Reset() { *d = defaultResponse{} }
+
+var defaultResponsePool = sync.Pool{
+	New: func() interface{} { return new(defaultResponse) },
+}
 
 var (
 	_ Codec = &gobCodec{}
@@ -69,6 +111,25 @@ func init() {
 	gob.Register(&defaultResponse{})
 }
 
+// RawMessage is an already encoded value which codecs pass through as is,
+// e.g. params forwarded by a proxy which doesn't know their type.
+type RawMessage []byte
+
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return m, nil
+}
+
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+// Positional passes the params of a method taking several args, in order.
+type Positional []interface{}
+
 type Codec interface {
 	ServerCodec
 	ClientCodec
@@ -78,9 +139,19 @@ type ServerCodec interface {
 	ReadRequest(data []byte) ([]Request, error)
 	ReadRequestBody(reqBody []byte, data interface{}) error
 	NewResponse(data interface{}) Response
-	ErrResponse(errCode int, err error) Response
+	ErrResponse(errCode Code, err error) Response
 	EncodeResponses(v interface{}) ([]byte, error)
 	Send(w http.ResponseWriter, statusCode int, b []byte) error
+	// ContentType is the media type of the encoded requests and responses
+	// over HTTP.
+	ContentType() string
+}
+
+// ResponseReleaser is implemented by codecs which reuse responses. The
+// server releases the responses of a TCP frame once they are sent, so they
+// must not be kept beyond that.
+type ResponseReleaser interface {
+	ReleaseResponse(resp Response)
 }
 
 type ClientCodec interface {
@@ -91,6 +162,33 @@ type ClientCodec interface {
 	EncodeResponses(v interface{}) ([]byte, error)
 }
 
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]func() Codec{
+		"gob": NewGobCodec,
+	}
+)
+
+// RegisterCodec makes a codec available by name, e.g. in config files.
+func RegisterCodec(name string, newCodec func() Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[name] = newCodec
+}
+
+// NewCodec returns a new codec registered under name.
+func NewCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	newCodec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("rpc: unknown codec %q", name)
+	}
+	return newCodec(), nil
+}
+
 func NewGobCodec() Codec {
 	codec := &gobCodec{}
 	return codec
@@ -100,6 +198,22 @@ type gobCodec struct {
 }
 
 func (g *gobCodec) Encode(argv interface{}) ([]byte, error) {
+	if raw, ok := argv.(RawMessage); ok {
+		return raw, nil
+	}
+	// each param is encoded on its own, so the server decodes them one by
+	// one into the arg types
+	if params, ok := argv.(Positional); ok {
+		parts := make([][]byte, len(params))
+		for i, param := range params {
+			part, err := g.Encode(param)
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = part
+		}
+		argv = parts
+	}
 	buf := bytes.NewBuffer(nil)
 	enc := gob.NewEncoder(buf)
 
@@ -111,6 +225,10 @@ func (g *gobCodec) Encode(argv interface{}) ([]byte, error) {
 }
 
 func (g *gobCodec) Decode(data []byte, out interface{}) error {
+	if raw, ok := out.(*RawMessage); ok {
+		*raw = append((*raw)[:0], data...)
+		return nil
+	}
 	buf := bytes.NewBuffer(data)
 	dec := gob.NewDecoder(buf)
 
@@ -127,23 +245,34 @@ func (g *gobCodec) NewResponse(data interface{}) Response {
 		log.Printf("[NewResponse] could not encode reply=%v, err=%v", data, err)
 		return nil
 	}
-	resp := &defaultResponse{
-		Reply:   reply,
-		Err:     "",
-		ErrCode: Success,
-	}
+	resp := defaultResponsePool.Get().(*defaultResponse)
+	resp.Reply = reply
+	resp.ErrCode = Success
 
 	return resp
 }
 
-func (g *gobCodec) ErrResponse(errCode int, err error) Response {
-	resp := &defaultResponse{
-		Err:     err.Error(),
-		ErrCode: errCode,
+func (g *gobCodec) ErrResponse(errCode Code, err error) Response {
+	errMsg := CodeMessage(errCode)
+	if rpcErr, ok := err.(*Error); ok {
+		errMsg = rpcErr.ErrMsg
+	} else if err != nil {
+		errMsg = err.Error()
 	}
+
+	resp := defaultResponsePool.Get().(*defaultResponse)
+	resp.Err = errMsg
+	resp.ErrCode = errCode
 	return resp
 }
 
+func (g *gobCodec) ReleaseResponse(resp Response) {
+	if d, ok := resp.(*defaultResponse); ok {
+		d.Reset()
+		defaultResponsePool.Put(d)
+	}
+}
+
 func (g *gobCodec) NewRequest(method string, data interface{}) Request {
 	args, err := g.Encode(data)
 	if err != nil {
@@ -171,7 +300,12 @@ func (g *gobCodec) ReadRequest(data []byte) ([]Request, error) {
 func (g *gobCodec) ReadResponse(data []byte) ([]Response, error) {
 	resps := make([]Response, 0)
 	if err := g.Decode(data, &resps); err != nil {
-		return nil, fmt.Errorf("could not decode response: %v", err)
+		// single responses are sent bare over HTTP
+		resp := new(defaultResponse)
+		if g.Decode(data, resp) != nil {
+			return nil, fmt.Errorf("could not decode response: %v", err)
+		}
+		return []Response{resp}, nil
 	}
 	return resps, nil
 }
@@ -192,8 +326,10 @@ func (g *gobCodec) EncodeResponses(v interface{}) ([]byte, error) {
 	return g.Encode(v)
 }
 
+func (g *gobCodec) ContentType() string { return "application/x-gob" }
+
 func (g *gobCodec) Send(w http.ResponseWriter, statusCode int, b []byte) error {
-	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Type", g.ContentType())
 	w.WriteHeader(statusCode)
 	_, err := w.Write(b)
 	return err
@@ -145,6 +145,19 @@ func TestGobCodec_ReadResponseBody(t *testing.T) {
 	assert.Equal(t, &data, out)
 }
 
+func TestGobResponse_DecodeInto(t *testing.T) {
+	codec := NewGobCodec()
+
+	data := &Args{A: 1, B: 2}
+	resp := codec.NewResponse(data)
+
+	out := new(Args)
+	assert.Nil(t, resp.DecodeInto(out))
+	assert.Equal(t, data, out)
+
+	assert.NotNil(t, resp.DecodeInto(new(string)))
+}
+
 func TestGobCodec_EncodeRequests(t *testing.T) {
 	codec := NewGobCodec()
 	data := "1"
@@ -168,7 +181,7 @@ func TestGobCodec_EncodeResponses(t *testing.T) {
 func TestGobCodec_ErrResponse(t *testing.T) {
 	codec := NewGobCodec()
 
-	errCode := 1
+	errCode := Code(1)
 	err := errors.New("1")
 	resp := codec.ErrResponse(errCode, err)
 
@@ -176,6 +189,46 @@ func TestGobCodec_ErrResponse(t *testing.T) {
 	assert.Equal(t, errCode, resp.GetErrCode())
 }
 
+func TestGobCodec_ErrResponseNilErr(t *testing.T) {
+	codec := NewGobCodec()
+
+	resp := codec.ErrResponse(MethodNotFound, nil)
+	assert.Equal(t, errors.New(CodeMessage(MethodNotFound)), resp.Error())
+	assert.Equal(t, MethodNotFound, resp.GetErrCode())
+}
+
 func TestGobCodec_Send(t *testing.T) {
 
 }
+
+func TestGobCodec_RawMessage(t *testing.T) {
+	codec := NewGobCodec().(*gobCodec)
+	encoded, err := codec.Encode(&Args{A: 1, B: 2})
+	assert.Nil(t, err)
+
+	req := codec.NewRequest("Int.Sum", RawMessage(encoded))
+	assert.Equal(t, encoded, req.GetParams())
+
+	var raw RawMessage
+	assert.Nil(t, codec.ReadRequestBody(req.GetParams(), &raw))
+	assert.Equal(t, RawMessage(encoded), raw)
+
+	var args Args
+	assert.Nil(t, codec.NewResponse(raw).DecodeInto(&args))
+	assert.Equal(t, Args{A: 1, B: 2}, args)
+}
+
+func TestGobCodec_ReleaseResponse(t *testing.T) {
+	codec := NewGobCodec().(*gobCodec)
+
+	resp := codec.ErrResponse(InternalErr, errors.New("boom"))
+	resp.SetReqId("1")
+	resp.SetMetadata(Metadata{"a": "b"})
+	codec.ReleaseResponse(resp)
+	assert.Equal(t, &defaultResponse{}, resp)
+
+	resp = codec.NewResponse(1)
+	assert.Equal(t, Success, resp.GetErrCode())
+	assert.Empty(t, resp.GetMetadata())
+	assert.Nil(t, resp.Error())
+}
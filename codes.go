@@ -0,0 +1,85 @@
+package xrpc
+
+import "sync"
+
+// CodeTranslator maps internal error codes onto the codes sent on the wire
+// and back, so the taxonomy of an application stays independent of the
+// JSON-RPC numbering, e.g. internal failures spread over the server error
+// range -32000 to -32099.
+type CodeTranslator struct {
+	mu       sync.RWMutex
+	toWire   map[Code]wireCode
+	fromWire map[Code]Code
+}
+
+type wireCode struct {
+	code Code
+	data interface{}
+}
+
+func NewCodeTranslator() *CodeTranslator {
+	return &CodeTranslator{
+		toWire:   make(map[Code]wireCode),
+		fromWire: make(map[Code]Code),
+	}
+}
+
+// Map sends errors of the internal code with the wire code, adding data to
+// those which carry none. Clients translate the wire code back to internal
+// if it is mapped once.
+func (t *CodeTranslator) Map(internal, wire Code, data interface{}) *CodeTranslator {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.toWire[internal] = wireCode{code: wire, data: data}
+	if _, dup := t.fromWire[wire]; dup {
+		// ambiguous, leave it as is
+		t.fromWire[wire] = wire
+	} else {
+		t.fromWire[wire] = internal
+	}
+	return t
+}
+
+// ToWire returns err as sent on the wire. err itself is left untouched.
+func (t *CodeTranslator) ToWire(err *Error) *Error {
+	t.mu.RLock()
+	w, ok := t.toWire[err.ErrCode]
+	t.mu.RUnlock()
+	if !ok {
+		return err
+	}
+	out := *err
+	out.ErrCode = w.code
+	if out.Data == nil {
+		out.Data = w.data
+	}
+	return &out
+}
+
+// FromWire returns the internal code of a wire code.
+func (t *CodeTranslator) FromWire(code Code) Code {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if internal, ok := t.fromWire[code]; ok {
+		return internal
+	}
+	return code
+}
+
+// WithCodeTranslator translates the codes of the errors returned by
+// handlers before they are sent.
+func WithCodeTranslator(t *CodeTranslator) ServerOption {
+	return func(s *Server) {
+		s.codes = t
+	}
+}
+
+// WithClientCodeTranslator translates the codes of remote errors back to
+// internal codes.
+func WithClientCodeTranslator(t *CodeTranslator) ClientOption {
+	return func(c *Client) {
+		c.codes = t
+	}
+}
@@ -0,0 +1,67 @@
+package xrpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const outOfStock Code = 1001
+
+type Shop struct{}
+
+func (s *Shop) Buy(args *string, reply *bool) error {
+	if *args == "boom" {
+		return errors.New("boom")
+	}
+	return &Error{ErrCode: outOfStock, ErrMsg: *args + " is out of stock"}
+}
+
+func TestCodeTranslator(t *testing.T) {
+	codes := NewCodeTranslator().
+		Map(outOfStock, -32001, map[string]string{"reason": "out_of_stock"}).
+		Map(InternalErr, -32000, nil)
+
+	wire := codes.ToWire(&Error{ErrCode: outOfStock, ErrMsg: "x"})
+	assert.Equal(t, &Error{ErrCode: -32001, ErrMsg: "x", Data: map[string]string{"reason": "out_of_stock"}}, wire)
+	assert.Equal(t, &Error{ErrCode: InvalidRequest}, codes.ToWire(&Error{ErrCode: InvalidRequest}))
+	assert.Equal(t, outOfStock, codes.FromWire(-32001))
+	assert.Equal(t, Code(-32050), codes.FromWire(-32050))
+
+	codes.Map(Code(1002), -32001, nil)
+	assert.Equal(t, Code(-32001), codes.FromWire(-32001))
+}
+
+func TestCodeTranslator_Call(t *testing.T) {
+	codes := NewCodeTranslator().
+		Map(outOfStock, -32001, nil).
+		Map(InternalErr, -32000, nil)
+	s := NewServerWithCodec(nil, WithCodeTranslator(codes))
+	assert.Nil(t, s.Register(new(Shop)))
+	addr := startServer(t, s)
+
+	var ok bool
+	item := "apple"
+	var rpcErr *Error
+
+	raw := NewClientWithCodec(nil, addr)
+	defer raw.Close()
+	err := raw.Call("Shop.Buy", &item, &ok)
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, Code(-32001), rpcErr.ErrCode)
+
+	c := NewClientWithCodec(nil, addr, WithClientCodeTranslator(codes))
+	defer c.Close()
+	err = c.Call("Shop.Buy", &item, &ok)
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, outOfStock, rpcErr.ErrCode)
+	assert.Equal(t, "apple is out of stock", rpcErr.ErrMsg)
+
+	item = "boom"
+	err = raw.Call("Shop.Buy", &item, &ok)
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, Code(-32000), rpcErr.ErrCode)
+	err = c.Call("Shop.Buy", &item, &ok)
+	assert.True(t, errors.Is(err, ErrInternal))
+}
@@ -0,0 +1,93 @@
+package xrpc
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithCompression gzips HTTP responses of at least minSize bytes for
+// clients sending Accept-Encoding: gzip.
+func WithCompression(minSize int) ServerOption {
+	return func(s *Server) {
+		s.gzipMinSize = minSize
+	}
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		name, params := enc, ""
+		if i := strings.Index(enc, ";"); i >= 0 {
+			name, params = enc[:i], enc[i+1:]
+		}
+		if name = strings.TrimSpace(name); name != "gzip" && name != "*" {
+			continue
+		}
+		if q := strings.TrimSpace(params); strings.HasPrefix(q, "q=") {
+			if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter decides on the first Write whether to compress, since
+// codecs write the whole response at once.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	started bool
+	gz      *gzip.Writer
+}
+
+func newGzipResponseWriter(w http.ResponseWriter, minSize int) *gzipResponseWriter {
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &gzipResponseWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		if len(b) >= w.minSize {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Del("Content-Length")
+			w.gz = gzipWriters.Get().(*gzip.Writer)
+			w.gz.Reset(w.ResponseWriter)
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Close flushes the compressed stream, or the status if nothing was
+// written.
+func (w *gzipResponseWriter) Close() error {
+	if !w.started {
+		w.started = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	gzipWriters.Put(w.gz)
+	w.gz = nil
+	return err
+}
@@ -0,0 +1,78 @@
+package xrpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                      false,
+		"gzip":                  true,
+		"deflate, gzip;q=0.5":   true,
+		"gzip;q=0":              false,
+		"gzip; q=0.0, br":       false,
+		"*":                     true,
+		"identity, deflate, br": false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		assert.Equal(t, want, acceptsGzip(req), header)
+	}
+}
+
+type Echo struct{}
+
+func (e *Echo) Say(args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+func TestServer_ServeHTTPCompression(t *testing.T) {
+	s := NewServerWithCodec(nil, WithCompression(512))
+	_ = s.Register(new(Echo))
+	codec := NewGobCodec()
+
+	post := func(msg string, acceptGzip bool) *httptest.ResponseRecorder {
+		reqs := []Request{codec.NewRequest("Echo.Say", &msg)}
+		body, err := codec.EncodeRequests(&reqs)
+		assert.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if acceptGzip {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	big := strings.Repeat("x", 4096)
+	w := post(big, true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	zr, err := gzip.NewReader(w.Body)
+	assert.Nil(t, err)
+	b, err := io.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.True(t, len(b) > 4096 && w.Body.Len() < 1024)
+	var resp defaultResponse
+	assert.Nil(t, codec.(*gobCodec).Decode(b, &resp))
+	var reply string
+	assert.Nil(t, resp.DecodeInto(&reply))
+	assert.Equal(t, big, reply)
+
+	w = post("small", true)
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+
+	w = post(big, false)
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.True(t, w.Body.Len() > 4096)
+}
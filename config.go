@@ -0,0 +1,291 @@
+package xrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a server. Durations are strings like "5ms".
+type Config struct {
+	Codec      string           `json:"codec" yaml:"codec"`
+	Listeners  []ListenerConfig `json:"listeners" yaml:"listeners"`
+	TLS        *TLSConfig       `json:"tls" yaml:"tls"`
+	Limits     LimitsConfig     `json:"limits" yaml:"limits"`
+	Middleware MiddlewareConfig `json:"middleware" yaml:"middleware"`
+	Log        LogConfig        `json:"log" yaml:"log"`
+}
+
+type ListenerConfig struct {
+	Protocol string `json:"protocol" yaml:"protocol"` // "tcp", "unix" or "http"
+	Addr     string `json:"addr" yaml:"addr"`
+}
+
+type TLSConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+}
+
+// LimitsConfig sets the limits of the server; zero values keep the
+// defaults, see the options of the same names.
+type LimitsConfig struct {
+	AcceptMinBackoff string `json:"accept_min_backoff" yaml:"accept_min_backoff"`
+	AcceptMaxBackoff string `json:"accept_max_backoff" yaml:"accept_max_backoff"`
+	MaxBatchSize     int    `json:"max_batch_size" yaml:"max_batch_size"`
+	BatchWorkers     int    `json:"batch_workers" yaml:"batch_workers"`
+	MaxMethodLength  int    `json:"max_method_length" yaml:"max_method_length"`
+	StuckTimeout     string `json:"stuck_timeout" yaml:"stuck_timeout"` // see WithReadTimeouts
+	IdleTimeout      string `json:"idle_timeout" yaml:"idle_timeout"`
+	ReadBufferSize   int    `json:"read_buffer_size" yaml:"read_buffer_size"`
+	WriteBufferSize  int    `json:"write_buffer_size" yaml:"write_buffer_size"`
+}
+
+// MiddlewareConfig turns on the built-in features wrapping the handling of
+// requests; all are off by default.
+type MiddlewareConfig struct {
+	RequestTrace           int    `json:"request_trace" yaml:"request_trace"`                   // requests kept, see WithRequestTrace
+	SlowRequestThreshold   string `json:"slow_request_threshold" yaml:"slow_request_threshold"` // see WithSlowRequestLog
+	CompressionMinSize     int    `json:"compression_min_size" yaml:"compression_min_size"`     // see WithCompression
+	CaseInsensitiveMethods bool   `json:"case_insensitive_methods" yaml:"case_insensitive_methods"`
+	Envelope               bool   `json:"envelope" yaml:"envelope"`
+}
+
+type LogConfig struct {
+	Output string `json:"output" yaml:"output"` // "stderr", "stdout", "discard" or a file path
+	Prefix string `json:"prefix" yaml:"prefix"`
+}
+
+// LoadConfig reads a YAML (.yaml, .yml) or JSON config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(Config)
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		err = json.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rpc: could not parse config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// NewServerFromConfig builds a server from the config file at path. opts
+// are applied after the config. Register services, then call Run.
+func NewServerFromConfig(path string, opts ...ServerOption) (*Server, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	cfgOpts, err := cfg.serverOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.Codec
+	if name == "" {
+		name = "gob"
+	}
+	codec, err := NewCodec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewServerWithCodec(codec, append(cfgOpts, opts...)...)
+	s.listeners = cfg.Listeners
+	s.configPath = path
+	return s, nil
+}
+
+func (cfg *Config) serverOptions() ([]ServerOption, error) {
+	var opts []ServerOption
+
+	for _, l := range cfg.Listeners {
+		if l.Protocol != "tcp" && l.Protocol != "unix" && l.Protocol != "http" {
+			return nil, fmt.Errorf("rpc: unknown listener protocol %q", l.Protocol)
+		}
+	}
+
+	if cfg.TLS != nil {
+		reloader, err := NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, func(s *Server) {
+			s.tlsConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+			s.certReloader = reloader
+		})
+	}
+
+	min, max, err := cfg.Limits.acceptBackoff()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithAcceptBackoff(min, max))
+
+	limits, err := cfg.Limits.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, limits...)
+	middleware, err := cfg.Middleware.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, middleware...)
+
+	if cfg.Log.Output != "" || cfg.Log.Prefix != "" {
+		var out io.Writer
+		switch cfg.Log.Output {
+		case "", "stderr":
+			out = os.Stderr
+		case "stdout":
+			out = os.Stdout
+		case "discard":
+			out = io.Discard
+		default:
+			f, err := os.OpenFile(cfg.Log.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			out = f
+		}
+		opts = append(opts, WithLogger(log.New(out, cfg.Log.Prefix, log.LstdFlags)))
+	}
+
+	return opts, nil
+}
+
+func (l LimitsConfig) acceptBackoff() (min, max time.Duration, err error) {
+	if min, err = parseDuration(l.AcceptMinBackoff); err != nil {
+		return
+	}
+	if max, err = parseDuration(l.AcceptMaxBackoff); err != nil {
+		return
+	}
+	if min == 0 {
+		min = defaultAcceptMinBackoff
+	}
+	if max == 0 {
+		max = defaultAcceptMaxBackoff
+	}
+	return
+}
+
+func (l LimitsConfig) options() ([]ServerOption, error) {
+	opts := []ServerOption{
+		WithMaxBatchSize(l.MaxBatchSize),
+		WithBatchWorkers(l.BatchWorkers),
+		WithMaxMethodLength(l.MaxMethodLength),
+		WithBufferSizes(l.ReadBufferSize, l.WriteBufferSize),
+	}
+	stuck, err := parseDuration(l.StuckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	idle, err := parseDuration(l.IdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, WithReadTimeouts(stuck, idle)), nil
+}
+
+func (m MiddlewareConfig) options() ([]ServerOption, error) {
+	var opts []ServerOption
+	if m.RequestTrace > 0 {
+		opts = append(opts, WithRequestTrace(m.RequestTrace))
+	}
+	threshold, err := parseDuration(m.SlowRequestThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if threshold > 0 {
+		opts = append(opts, WithSlowRequestLog(SlowLogConfig{Threshold: threshold}))
+	}
+	if m.CompressionMinSize > 0 {
+		opts = append(opts, WithCompression(m.CompressionMinSize))
+	}
+	if m.CaseInsensitiveMethods {
+		opts = append(opts, WithCaseInsensitiveMethods())
+	}
+	if m.Envelope {
+		opts = append(opts, WithEnvelope())
+	}
+	return opts, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("rpc: invalid duration %q in config", s)
+	}
+	return d, nil
+}
+
+// WatchConfig re-reads the config file every interval until ctx is done and
+// applies the settings that can change on a live server: accept limits and
+// the TLS certificate, which is also reloaded when its files change.
+// Listeners, codec, logging, the other limits and the middleware need a
+// restart.
+func (s *Server) WatchConfig(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reloadConfig(); err != nil {
+				s.logger.Printf("rpc: reload config err=%v", err)
+			}
+		}
+	}
+}
+
+func (s *Server) reloadConfig() error {
+	if s.configPath == "" {
+		return errors.New("rpc: server was not built from a config file")
+	}
+	cfg, err := LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+
+	min, max, err := cfg.Limits.acceptBackoff()
+	if err != nil {
+		return err
+	}
+	s.setAcceptBackoff(min, max)
+
+	if s.certReloader != nil && cfg.TLS != nil {
+		return s.certReloader.setFiles(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	}
+	return nil
+}
+
+// Run serves every listener of the server until one fails, and returns
+// its error. See Start to stop the server.
+func (s *Server) Run() error {
+	if err := s.Start(context.Background()); err != nil {
+		return err
+	}
+	return <-s.Err()
+}
@@ -0,0 +1,102 @@
+package xrpc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewServerFromConfig(t *testing.T) {
+	RegisterCodec("test-gob", NewGobCodec)
+
+	path := writeConfig(t, "server.yaml", `
+codec: test-gob
+listeners:
+  - protocol: tcp
+    addr: 127.0.0.1:0
+  - protocol: http
+    addr: 127.0.0.1:0
+limits:
+  accept_min_backoff: 1ms
+  max_batch_size: 50
+  batch_workers: 8
+  max_method_length: 64
+  stuck_timeout: 2s
+  idle_timeout: 1m
+  read_buffer_size: 8192
+middleware:
+  request_trace: 10
+  slow_request_threshold: 100ms
+  compression_min_size: 1024
+  case_insensitive_methods: true
+  envelope: true
+log:
+  output: discard
+  prefix: "[xrpc] "
+`)
+	s, err := NewServerFromConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &gobCodec{}, s.codec)
+	assert.Equal(t, []ListenerConfig{{"tcp", "127.0.0.1:0"}, {"http", "127.0.0.1:0"}}, s.listeners)
+	assert.Equal(t, time.Millisecond, s.acceptMinBackoff)
+	assert.Equal(t, defaultAcceptMaxBackoff, s.acceptMaxBackoff)
+	assert.Equal(t, "[xrpc] ", s.logger.Prefix())
+	assert.Equal(t, 50, s.maxBatchSize)
+	assert.Equal(t, 8, s.batchWorkers)
+	assert.Equal(t, 64, s.maxMethodLen)
+	assert.Equal(t, 2*time.Second, s.stuckTimeout)
+	assert.Equal(t, time.Minute, s.idleTimeout)
+	assert.Equal(t, 8192, s.readBufSize)
+	assert.Equal(t, 0, s.writeBufSize)
+	assert.NotNil(t, s.trace)
+	assert.NotNil(t, s.slowLog)
+	assert.Equal(t, 1024, s.gzipMinSize)
+	assert.NotNil(t, s.normalizeName)
+	assert.True(t, s.envelope)
+
+	path = writeConfig(t, "server.json", `{"listeners": [{"protocol": "tcp", "addr": "127.0.0.1:0"}]}`)
+	s, err = NewServerFromConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &gobCodec{}, s.codec)
+	assert.Len(t, s.listeners, 1)
+	assert.Nil(t, s.trace)
+	assert.False(t, s.envelope)
+}
+
+func TestNewServerFromConfigErr(t *testing.T) {
+	for _, content := range []string{
+		`{"codec": "unknown"}`,
+		`{"listeners": [{"protocol": "udp"}]}`,
+		`{"limits": {"accept_max_backoff": "1"}}`,
+		`{"limits": {"idle_timeout": "soon"}}`,
+		`{"middleware": {"slow_request_threshold": "1"}}`,
+		`{"tls": {"cert_file": "missing.pem", "key_file": "missing.key"}}`,
+		`{`,
+	} {
+		_, err := NewServerFromConfig(writeConfig(t, "server.json", content))
+		assert.NotNil(t, err, content)
+	}
+
+	_, err := NewServerFromConfig("missing.json")
+	assert.NotNil(t, err)
+}
+
+func TestServer_Run(t *testing.T) {
+	path := writeConfig(t, "server.json", `{"listeners": [{"protocol": "tcp", "addr": "256.0.0.1:0"}]}`)
+	s, err := NewServerFromConfig(path)
+	assert.Nil(t, err)
+	assert.NotNil(t, s.Run())
+
+	assert.NotNil(t, NewServerWithCodec(nil).Run())
+}
@@ -0,0 +1,221 @@
+// Package conformance checks that implementations of the protocol in other
+// languages interoperate with this one, using the JSON codec.
+//
+// Authors of clients run theirs against NewServer, e.g. with the
+// xrpc-conformance tool, sending the requests of Cases and expecting their
+// responses. Authors of servers serve the Conformance methods and run Check
+// against them.
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/dabao-zhao/xrpc/jsonrpc"
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+// Conformance is the service the cases call.
+type Conformance struct{}
+
+// Echo replies with its params.
+func (c *Conformance) Echo(params *xrpc.RawMessage, reply *xrpc.RawMessage) error {
+	*reply = *params
+	return nil
+}
+
+// FailArgs is the error Fail returns.
+type FailArgs struct {
+	Code    xrpc.Code `json:"code"`
+	Message string    `json:"message"`
+}
+
+// Fail returns the error of args.
+func (c *Conformance) Fail(args *FailArgs, reply *bool) error {
+	return &xrpc.Error{ErrCode: args.Code, ErrMsg: args.Message}
+}
+
+// Sum replies with the sum of its params.
+func (c *Conformance) Sum(args *[]int, reply *int) error {
+	for _, n := range *args {
+		*reply += n
+	}
+	return nil
+}
+
+// Meta replies with the value of the request metadata key.
+func (c *Conformance) Meta(ctx context.Context, key *string, reply *string) error {
+	*reply = xrpc.MetadataFromContext(ctx).Get(*key)
+	return nil
+}
+
+// NewServer returns a server of the Conformance service.
+func NewServer() *xrpc.Server {
+	s := xrpc.NewServerWithCodec(jsonrpc.NewJSONCodec())
+	_ = s.Register(new(Conformance))
+	return s
+}
+
+// Case is a request and the responses it gets. A request given as a JSON
+// string is sent as is, e.g. to send malformed JSON. A "*" in the response
+// matches any value, e.g. messages left to implementations. Responses to a
+// single request may be an array of one, as over TCP, or the bare object,
+// as over HTTP.
+type Case struct {
+	Name     string          `json:"name"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Body returns the request body to send.
+func (c Case) Body() []byte {
+	var raw string
+	if err := json.Unmarshal(c.Request, &raw); err == nil {
+		return []byte(raw)
+	}
+	return c.Request
+}
+
+//go:embed cases.json
+var casesJSON []byte
+
+// Cases returns the conformance cases.
+func Cases() []Case {
+	var cases []Case
+	if err := json.Unmarshal(casesJSON, &cases); err != nil {
+		panic(err)
+	}
+	return cases
+}
+
+// Result is the outcome of a case, Err is nil if it passed.
+type Result struct {
+	Case string
+	Err  error
+}
+
+// Check runs the cases against the server at addr, a TCP address or an
+// http:// URL.
+func Check(ctx context.Context, addr string) []Result {
+	var results []Result
+	for _, c := range Cases() {
+		resp, err := roundTrip(ctx, addr, c.Body())
+		if err == nil {
+			err = Match(c.Response, resp)
+		}
+		results = append(results, Result{Case: c.Name, Err: err})
+	}
+	return results
+}
+
+func roundTrip(ctx context.Context, addr string, body []byte) ([]byte, error) {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return io.ReadAll(resp.Body)
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	wr := bufio.NewWriter(conn)
+	p := proto.New()
+	p.Body = body
+	if err := p.WriteTCP(wr); err != nil {
+		return nil, err
+	}
+	if err := wr.Flush(); err != nil {
+		return nil, err
+	}
+	if err := p.ReadTCP(bufio.NewReader(conn)); err != nil {
+		return nil, err
+	}
+	return p.Body, nil
+}
+
+// Match reports how the response got differs from the expected one.
+func Match(expected, got []byte) error {
+	var want, have interface{}
+	if err := unmarshal(expected, &want); err != nil {
+		return fmt.Errorf("conformance: bad expectation: %v", err)
+	}
+	if err := unmarshal(got, &have); err != nil {
+		return fmt.Errorf("conformance: response is not JSON: %v: %s", err, got)
+	}
+	if m, ok := want.(map[string]interface{}); ok {
+		want = []interface{}{m}
+	}
+	if m, ok := have.(map[string]interface{}); ok {
+		have = []interface{}{m}
+	}
+	if !match(want, have) {
+		return fmt.Errorf("conformance: got %s, want %s", got, expected)
+	}
+	return nil
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func match(want, have interface{}) bool {
+	switch w := want.(type) {
+	case string:
+		if w == "*" {
+			return true
+		}
+	case map[string]interface{}:
+		h, ok := have.(map[string]interface{})
+		if !ok || len(h) != len(w) {
+			return false
+		}
+		for k, v := range w {
+			hv, ok := h[k]
+			if !ok || !match(v, hv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		h, ok := have.([]interface{})
+		if !ok || len(h) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !match(w[i], h[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(want, have)
+}
@@ -0,0 +1,40 @@
+package conformance
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	s := NewServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+	h := httptest.NewServer(s)
+	defer h.Close()
+
+	for _, addr := range []string{l.Addr().String(), h.URL} {
+		results := Check(context.Background(), addr)
+		assert.Len(t, results, len(Cases()))
+		for _, r := range results {
+			assert.Nil(t, r.Err, "%s over %s", r.Case, addr)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	assert.Nil(t, Match([]byte(`{"id":"1","result":1}`), []byte(`[{"result":1,"id":"1"}]`)))
+	assert.Nil(t, Match([]byte(`{"error":{"code":1,"message":"*"}}`), []byte(`{"error":{"code":1,"message":"boom"}}`)))
+
+	assert.Error(t, Match([]byte(`{"id":"1","result":1}`), []byte(`{"id":"1","result":1.0}`)))
+	assert.Error(t, Match([]byte(`{"id":"1","result":1}`), []byte(`{"id":"1","result":1,"extra":true}`)))
+	assert.Error(t, Match([]byte(`[{"id":"1"},{"id":"2"}]`), []byte(`[{"id":"2"},{"id":"1"}]`)))
+	assert.Error(t, Match([]byte(`{"id":"1"}`), []byte(`not json`)))
+}
@@ -0,0 +1,141 @@
+package xrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConnState is the connectivity state of a client.
+type ConnState int
+
+const (
+	// Idle clients have no connection and dial on the next call.
+	Idle ConnState = iota
+	Connecting
+	Ready
+	// TransientFailure follows a failed dial; the next call dials again.
+	TransientFailure
+)
+
+var connStateNames = map[ConnState]string{
+	Idle:             "Idle",
+	Connecting:       "Connecting",
+	Ready:            "Ready",
+	TransientFailure: "TransientFailure",
+}
+
+func (s ConnState) String() string {
+	if name, ok := connStateNames[s]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+const (
+	waitForReadyMinBackoff = 10 * time.Millisecond
+	waitForReadyMaxBackoff = time.Second
+)
+
+// connWatch holds the state of a client and its subscribers.
+type connWatch struct {
+	mu    sync.Mutex
+	state ConnState
+	subs  map[chan ConnState]struct{}
+}
+
+func (w *connWatch) get() ConnState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.state
+}
+
+// set records state and notifies the subscribers whose buffer has room.
+func (w *connWatch) set(state ConnState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state == state {
+		return
+	}
+	w.state = state
+	for ch := range w.subs {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// State returns the connectivity state of the client.
+func (c *Client) State() ConnState {
+	return c.conn.get()
+}
+
+// SubscribeState returns a channel receiving the states the client goes
+// through. Changes are dropped while the buffer of 16 states is full.
+// Cancel ends the subscription and closes the channel.
+func (c *Client) SubscribeState() (states <-chan ConnState, cancel func()) {
+	ch := make(chan ConnState, 16)
+	c.conn.mu.Lock()
+	if c.conn.subs == nil {
+		c.conn.subs = make(map[chan ConnState]struct{})
+	}
+	c.conn.subs[ch] = struct{}{}
+	c.conn.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.conn.mu.Lock()
+			delete(c.conn.subs, ch)
+			c.conn.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// connect dials unless the client is connected. Clients posting over HTTP
+// have nothing to dial.
+func (c *Client) connect(ctx context.Context) error {
+	if isHTTPAddr(c.tcpAddr) {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.valid(ctx)
+}
+
+// WaitForReady dials until the client is Ready or ctx is done.
+func (c *Client) WaitForReady(ctx context.Context) error {
+	backoff := waitForReadyMinBackoff
+	for {
+		err := c.connect(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return c.ctxErr(ctx.Err())
+		}
+		if backoff *= 2; backoff > waitForReadyMaxBackoff {
+			backoff = waitForReadyMaxBackoff
+		}
+	}
+}
+
+// Dial is NewClientWithCodec connecting eagerly, so an unreachable
+// address fails here rather than on the first call.
+func Dial(ctx context.Context, codec ClientCodec, tcpAddr string, opts ...ClientOption) (*Client, error) {
+	c := NewClientWithCodec(codec, tcpAddr, opts...)
+	if err := c.connect(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
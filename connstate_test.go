@@ -0,0 +1,104 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drain(ch <-chan ConnState) []ConnState {
+	var states []ConnState
+	for {
+		select {
+		case s := <-ch:
+			states = append(states, s)
+		default:
+			return states
+		}
+	}
+}
+
+func TestClient_ConnState(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	states, cancel := c.SubscribeState()
+	assert.Equal(t, Idle, c.State())
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, Ready, c.State())
+	c.Close()
+	assert.Equal(t, Idle, c.State())
+	assert.Equal(t, []ConnState{Connecting, Ready, Idle}, drain(states))
+
+	cancel()
+	cancel()
+	_, open := <-states
+	assert.False(t, open)
+	assert.Equal(t, "TransientFailure", TransientFailure.String())
+}
+
+func TestClient_WaitForReady(t *testing.T) {
+	// reserve a port, then serve on it only after a while
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	c := NewClientWithCodec(nil, addr)
+	defer c.Close()
+	states, cancel := c.SubscribeState()
+	defer cancel()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		s := NewServerWithCodec(nil)
+		_ = s.Register(new(Int))
+		go func() { _ = s.serve(l) }()
+		t.Cleanup(func() { _ = l.Close() })
+	}()
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelCtx()
+	assert.Nil(t, c.WaitForReady(ctx))
+	assert.Equal(t, Ready, c.State())
+	assert.Contains(t, drain(states), TransientFailure)
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+}
+
+func TestDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	_, err = Dial(context.Background(), nil, addr)
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	err = NewClientWithCodec(nil, addr).WaitForReady(ctx)
+	assert.True(t, errors.Is(err, ErrTimeout))
+
+	s := NewServerWithCodec(nil)
+	c, err := Dial(context.Background(), nil, startServer(t, s))
+	if assert.Nil(t, err) {
+		assert.Equal(t, Ready, c.State())
+		c.Close()
+	}
+}
@@ -0,0 +1,60 @@
+package xrpc
+
+import "context"
+
+type (
+	metadataKey  struct{}
+	principalKey struct{}
+	tenantKey    struct{}
+	flagsKey     struct{}
+)
+
+func withMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns the metadata sent with the request being
+// handled.
+func MetadataFromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// WithTenant returns a copy of ctx carrying the tenant of the request.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// WithFeatureFlags returns a copy of ctx with the given flags enabled, in
+// addition to those already in ctx.
+func WithFeatureFlags(ctx context.Context, flags ...string) context.Context {
+	old, _ := ctx.Value(flagsKey{}).(map[string]bool)
+	set := make(map[string]bool, len(old)+len(flags))
+	for flag := range old {
+		set[flag] = true
+	}
+	for _, flag := range flags {
+		set[flag] = true
+	}
+	return context.WithValue(ctx, flagsKey{}, set)
+}
+
+func FeatureEnabled(ctx context.Context, flag string) bool {
+	set, _ := ctx.Value(flagsKey{}).(map[string]bool)
+	return set[flag]
+}
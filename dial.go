@@ -0,0 +1,143 @@
+package xrpc
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithDialTimeout bounds each dial of the client, within the deadline of
+// the call.
+func WithDialTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.dialTimeout = d
+	}
+}
+
+// WithHappyEyeballs races the addresses a host name resolves to, per
+// RFC 6555: an attempt starts every delay, or as soon as the previous one
+// fails, alternating address families, and the first connection
+// established is kept. A blackholed address then costs delay instead of
+// the whole dial timeout.
+func WithHappyEyeballs(delay time.Duration) ClientOption {
+	return func(c *Client) {
+		c.eyeballsDelay = delay
+	}
+}
+
+func (c *Client) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.dialTimeout)
+		defer cancel()
+	}
+	if c.eyeballsDelay > 0 && network == "tcp" {
+		return c.dialRace(ctx, network, addr)
+	}
+	return c.dialer.DialContext(ctx, network, addr)
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func (c *Client) dialRace(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return c.dialer.DialContext(ctx, network, addr)
+	}
+	lookupHost := c.lookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+	hosts, err := lookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	hosts = interleaveFamilies(hosts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(hosts))
+	next, pending := 0, 0
+	start := func() {
+		go func(addr string) {
+			conn, err := c.dialer.DialContext(ctx, network, addr)
+			results <- dialResult{conn: conn, err: err}
+		}(net.JoinHostPort(hosts[next], port))
+		next++
+		pending++
+	}
+
+	start()
+	timer := time.NewTimer(c.eyeballsDelay)
+	defer timer.Stop()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// close the connections of the attempts still running
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if r := <-results; r.conn != nil {
+							_ = r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(hosts) {
+				start()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(c.eyeballsDelay)
+			}
+		case <-timer.C:
+			if next < len(hosts) {
+				start()
+				timer.Reset(c.eyeballsDelay)
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// interleaveFamilies orders addrs alternating between IPv6 and IPv4,
+// starting with the family of the first one.
+func interleaveFamilies(addrs []string) []string {
+	var first, second []string
+	firstV4 := len(addrs) > 0 && isIPv4(addrs[0])
+	for _, addr := range addrs {
+		if isIPv4(addr) == firstV4 {
+			first = append(first, addr)
+		} else {
+			second = append(second, addr)
+		}
+	}
+	out := make([]string, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			out = append(out, first[i])
+		}
+		if i < len(second) {
+			out = append(out, second[i])
+		}
+	}
+	return out
+}
+
+func isIPv4(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.To4() != nil
+}
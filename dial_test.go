@@ -0,0 +1,57 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blackholeDialer hangs on addresses of 10.0.0.0/8 until ctx is done.
+var blackholeDialer = DialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if strings.HasPrefix(addr, "10.") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return (&net.Dialer{}).DialContext(ctx, network, addr)
+})
+
+func TestClient_DialTimeout(t *testing.T) {
+	c := NewClientWithCodec(nil, "10.255.255.1:1", WithDialer(blackholeDialer), WithDialTimeout(20*time.Millisecond))
+	defer c.Close()
+
+	start := time.Now()
+	var sum int
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestClient_HappyEyeballs(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	_, port, _ := net.SplitHostPort(startServer(t, s))
+
+	c := NewClientWithCodec(nil, net.JoinHostPort("multi.test", port),
+		WithDialer(blackholeDialer), WithHappyEyeballs(20*time.Millisecond))
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.255.255.1", "10.255.255.2", "127.0.0.1"}, nil
+	}
+	defer c.Close()
+
+	start := time.Now()
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	assert.Equal(t,
+		[]string{"::1", "10.0.0.1", "::2", "10.0.0.2", "10.0.0.3"},
+		interleaveFamilies([]string{"::1", "::2", "10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+}
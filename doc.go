@@ -0,0 +1,99 @@
+package xrpc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MethodDoc documents a method in the output of Describe.
+type MethodDoc struct {
+	Summary     string    `json:"summary,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Examples    []Example `json:"examples,omitempty"`
+}
+
+// Example is a sample call of a method.
+type Example struct {
+	Name   string      `json:"name,omitempty"`
+	Params interface{} `json:"params"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+// RegisterOption configures a service being registered.
+type RegisterOption func(srv *service) error
+
+// WithMethodDoc attaches doc to the method of the service being
+// registered, e.g. WithMethodDoc("Sum", MethodDoc{...}).
+func WithMethodDoc(method string, doc MethodDoc) RegisterOption {
+	return func(srv *service) error {
+		mt, ok := srv.method[method]
+		if !ok {
+			return fmt.Errorf("rpc: no method %s.%s to document", srv.name, method)
+		}
+		mt.doc = doc
+		return nil
+	}
+}
+
+// WithMethodTags tags the method of the service being registered, e.g.
+// for WithAuditor.
+func WithMethodTags(method string, tags ...string) RegisterOption {
+	return func(srv *service) error {
+		mt, ok := srv.method[method]
+		if !ok {
+			return fmt.Errorf("rpc: no method %s.%s to tag", srv.name, method)
+		}
+		mt.tags = append(mt.tags, tags...)
+		return nil
+	}
+}
+
+// MethodDescription describes a registered method.
+type MethodDescription struct {
+	Name       string   `json:"name"` // Service.Method
+	Params     Schema   `json:"params"`
+	Args       []Schema `json:"args,omitempty"` // of methods taking several params, in order
+	Result     Schema   `json:"result"`
+	Deprecated bool     `json:"deprecated,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	MethodDoc
+}
+
+// Describe returns the descriptions of the registered methods, sorted by
+// name.
+func (s *Server) Describe() []MethodDescription {
+	return s.describe(true)
+}
+
+// describe is Describe, leaving out the services of the package unless
+// internal.
+func (s *Server) describe(internal bool) []MethodDescription {
+	var descs []MethodDescription
+	s.m.Range(func(key, value interface{}) bool {
+		srv := value.(*service)
+		if srv.internal && !internal {
+			return true
+		}
+		for name, mt := range srv.method {
+			method := srv.name + "." + name
+			_, deprecated := s.deprecated.Load(method)
+			var args []Schema
+			for _, t := range mt.ArgTypes {
+				args = append(args, schemaOf(t, make(map[reflect.Type]bool)))
+			}
+			descs = append(descs, MethodDescription{
+				Name:       method,
+				Params:     schemaOf(mt.ArgType, make(map[reflect.Type]bool)),
+				Args:       args,
+				Result:     schemaOf(mt.ReplyType, make(map[reflect.Type]bool)),
+				Deprecated: deprecated,
+				Tags:       mt.tags,
+				MethodDoc:  mt.doc,
+			})
+		}
+		return true
+	})
+	sort.Slice(descs, func(i, j int) bool { return descs[i].Name < descs[j].Name })
+	return descs
+}
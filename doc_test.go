@@ -0,0 +1,38 @@
+package xrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Describe(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	err := s.Register(new(Int), WithMethodDoc("Sum", MethodDoc{
+		Summary:  "Adds two numbers",
+		Examples: []Example{{Params: Args{A: 1, B: 2}, Result: 3}},
+	}))
+	assert.Nil(t, err)
+	assert.Nil(t, s.RegisterName(new(Echo), "Say"))
+	s.Deprecate("Echo.Say", "")
+
+	descs := s.Describe()
+	assert.Len(t, descs, 2)
+	assert.Equal(t, "Echo.Say", descs[0].Name)
+	assert.True(t, descs[0].Deprecated)
+	assert.Equal(t, "Int.Sum", descs[1].Name)
+	assert.Equal(t, "Adds two numbers", descs[1].Summary)
+	assert.Len(t, descs[1].Examples, 1)
+	assert.Equal(t, SchemaOf(Args{}), descs[1].Params)
+	assert.Equal(t, "int", descs[1].Result.Kind)
+}
+
+func TestWithMethodDocUnknownMethod(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	err := s.Register(new(Int), WithMethodDoc("Sub", MethodDoc{}))
+	assert.NotNil(t, err)
+	assert.Empty(t, s.Services())
+
+	err = s.RegisterName(new(Echo), "Say", WithMethodDoc("Shout", MethodDoc{}))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,145 @@
+package xrpc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+// A server refusing a peer lacking a feature it requires, such as TLS or
+// a recent frame format, tells it why in a close frame before closing the
+// connection, rather than leaving it to fail on garbled frames.
+
+// tlsHandshakeRecord is the first byte of a TLS connection.
+const tlsHandshakeRecord = 0x16
+
+// refuseLinger bounds the wait for the peer to read the close frame.
+const refuseLinger = time.Second
+
+// defaultTLSSniffTimeout bounds the wait for the first byte of a peer
+// which may speak TLS, so silent peers don't hold connections forever.
+const defaultTLSSniffTimeout = 10 * time.Second
+
+// RefusedError is the reason a server gave for refusing the connection.
+type RefusedError struct {
+	Reason string
+}
+
+func (e *RefusedError) Error() string {
+	return "rpc: connection refused by server: " + e.Reason
+}
+
+// WithMinProtoVersion refuses peers sending frames of a version of the
+// frame format older than v, see proto.Ver1.
+func WithMinProtoVersion(v uint16) ServerOption {
+	return func(s *Server) {
+		s.minProtoVersion = v
+	}
+}
+
+// WithProtoVersion sets the version of the frame format the client sends.
+// Defaults to proto.Ver1.
+func WithProtoVersion(v uint16) ClientOption {
+	return func(c *Client) {
+		c.protoVersion = v
+	}
+}
+
+// requireTLS returns l for serving TLS: the server starts TLS on its
+// connections, or refuses peers speaking plaintext.
+func requireTLS(l net.Listener) net.Listener {
+	return &tlsRequiredListener{Listener: l}
+}
+
+type tlsRequiredListener struct {
+	net.Listener
+}
+
+func (l *tlsRequiredListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &tlsPendingConn{Conn: conn}, nil
+}
+
+// tlsPendingConn is a connection whose first bytes are yet to tell whether
+// the peer speaks TLS.
+type tlsPendingConn struct {
+	net.Conn
+}
+
+// startTLS starts the TLS server side of conn, or refuses it if the peer
+// speaks plaintext.
+func (s *Server) startTLS(conn *tlsPendingConn) (net.Conn, error) {
+	timeout := s.tlsSniffTimeout
+	if timeout <= 0 {
+		timeout = defaultTLSSniffTimeout
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	rr := bufio.NewReader(conn.Conn)
+	first, err := rr.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if first[0] != tlsHandshakeRecord {
+		return nil, s.refuse(conn.Conn, "TLS required")
+	}
+	return tls.Server(&peekedConn{Conn: conn.Conn, r: rr}, s.tlsConfig), nil
+}
+
+// peekedConn reads a connection through the reader which peeked at it.
+type peekedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// refuse sends a close frame stating reason on conn, which the caller then
+// closes, and returns the error ending it.
+func (s *Server) refuse(conn net.Conn, reason string) error {
+	remote := conn.RemoteAddr().String()
+	err := fmt.Errorf("rpc: refused %s: %s", remote, reason)
+	s.logger.Print(err)
+	if s.connEvents.OnAbnormalClose != nil {
+		s.connEvents.OnAbnormalClose(remote, err)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(refuseLinger))
+	wr := bufio.NewWriter(conn)
+	p := proto.New()
+	p.Op = proto.OpClose
+	p.Body = []byte(reason)
+	if p.WriteTCP(wr) != nil || wr.Flush() != nil {
+		return err
+	}
+	// closing with unread input resets the connection, which may drop the
+	// close frame before the peer reads it: wait for the peer to close
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok && cw.CloseWrite() == nil {
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}
+	return err
+}
+
+// closeFrameErr returns the error of the close frame p, nil if p is not
+// one.
+func closeFrameErr(p *proto.Proto) error {
+	if p.Op != proto.OpClose {
+		return nil
+	}
+	return &RefusedError{Reason: string(p.Body)}
+}
@@ -0,0 +1,99 @@
+package xrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+func TestServer_RequireTLS(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "localhost", time.Now())
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.Nil(t, err)
+
+	var (
+		mu      sync.Mutex
+		refused []error
+	)
+	s := NewServerWithCodec(nil,
+		WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+		WithLogger(log.New(ioutil.Discard, "", 0)),
+		WithServerConnEvents(ServerConnEvents{OnAbnormalClose: func(remote string, err error) {
+			mu.Lock()
+			refused = append(refused, err)
+			mu.Unlock()
+		}}))
+	assert.Nil(t, s.Register(new(Int)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() { _ = s.serve(requireTLS(l)) }()
+	t.Cleanup(func() { _ = l.Close() })
+
+	plain := NewClientWithCodec(nil, l.Addr().String())
+	defer plain.Close()
+	var reply int
+	err = plain.Call("Int.Sum", &Args{A: 1, B: 2}, &reply)
+	var refusedErr *RefusedError
+	assert.True(t, errors.As(err, &refusedErr), "%v", err)
+	assert.Equal(t, "TLS required", refusedErr.Reason)
+	mu.Lock()
+	assert.Len(t, refused, 1)
+	mu.Unlock()
+
+	secure := NewClientWithCodec(nil, l.Addr().String(), WithDialer(DialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+		return d.DialContext(ctx, network, addr)
+	})))
+	defer secure.Close()
+	assert.Nil(t, secure.Call("Int.Sum", &Args{A: 1, B: 2}, &reply))
+	assert.Equal(t, 3, reply)
+}
+
+func TestServer_RequireTLSSilentPeer(t *testing.T) {
+	s := NewServerWithCodec(nil, WithTLSConfig(&tls.Config{}))
+	s.tlsSniffTimeout = 50 * time.Millisecond
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() { _ = s.serve(requireTLS(l)) }()
+	t.Cleanup(func() { _ = l.Close() })
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	// the server hangs up on a peer sending nothing
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "%v", err)
+	assert.NotNil(t, err)
+}
+
+func TestServer_MinProtoVersion(t *testing.T) {
+	s := NewServerWithCodec(nil, WithMinProtoVersion(proto.Ver2), WithLogger(log.New(ioutil.Discard, "", 0)))
+	assert.Nil(t, s.Register(new(Int)))
+	addr := startServer(t, s)
+
+	legacy := NewClientWithCodec(nil, addr)
+	defer legacy.Close()
+	var reply int
+	err := legacy.Call("Int.Sum", &Args{A: 1, B: 2}, &reply)
+	var refused *RefusedError
+	assert.True(t, errors.As(err, &refused), "%v", err)
+	assert.Equal(t, "frame version 1 is below the minimum 2", refused.Reason)
+	assert.Equal(t, Idle, legacy.State())
+
+	current := NewClientWithCodec(nil, addr, WithProtoVersion(proto.Ver2))
+	defer current.Close()
+	assert.Nil(t, current.Call("Int.Sum", &Args{A: 1, B: 2}, &reply))
+	assert.Equal(t, 3, reply)
+}
@@ -0,0 +1,123 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/gob"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ReplyEnvelope wraps the results of the methods of a server in envelope
+// mode, so every reply has the same shape whatever the method. With the
+// gob codec, the result is sent encoded, as a RawMessage, since gob only
+// sends interfaces holding registered types.
+type ReplyEnvelope struct {
+	Result     interface{} `json:"result"`
+	TraceID    string      `json:"trace_id,omitempty"` // sent with the request under TraceIDKey
+	Duration   float64     `json:"duration_ms"`        // spent handling the request, in milliseconds
+	Warnings   []string    `json:"warnings,omitempty"` // see AddWarning
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// WithEnvelope wraps the results of successful calls to registered
+// methods in a ReplyEnvelope, after any transformer. The next cursor of
+// results which are a PageReply is copied to the envelope. Clients unwrap
+// it with WithEnvelopeInto.
+func WithEnvelope() ServerOption {
+	return func(s *Server) {
+		s.envelope = true
+	}
+}
+
+func init() {
+	gob.Register(RawMessage{})
+}
+
+type warningsKey struct{}
+
+type warnings struct {
+	mu   sync.Mutex
+	list []string
+}
+
+// AddWarning adds warning to the envelope of the reply to the request
+// being handled. It does nothing unless the server is in envelope mode.
+func AddWarning(ctx context.Context, warning string) {
+	w, ok := ctx.Value(warningsKey{}).(*warnings)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	w.list = append(w.list, warning)
+	w.mu.Unlock()
+}
+
+func withWarnings(ctx context.Context) (context.Context, *warnings) {
+	w := new(warnings)
+	return context.WithValue(ctx, warningsKey{}, w), w
+}
+
+// envelop wraps the result of req, handled since start.
+func (s *Server) envelop(req Request, result interface{}, w *warnings, start time.Time, methods ...string) *ReplyEnvelope {
+	env := &ReplyEnvelope{
+		TraceID:  req.GetMetadata().Get(TraceIDKey),
+		Duration: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if page, ok := result.(PageReply); ok {
+		env.NextCursor = page.NextCursor()
+	}
+	if v := reflect.ValueOf(result); v.Kind() == reflect.Ptr && !v.IsNil() {
+		result = v.Elem().Interface() // gob sends the value anyway
+	}
+	if g, ok := s.codec.(*gobCodec); ok {
+		if b, err := g.Encode(result); err == nil {
+			result = RawMessage(b)
+		}
+	}
+	env.Result = result
+
+	for _, method := range methods {
+		if d, ok := s.deprecated.Load(method); ok {
+			env.Warnings = append(env.Warnings, d.(*deprecation).warning)
+			break
+		}
+	}
+	w.mu.Lock()
+	env.Warnings = append(env.Warnings, w.list...)
+	w.mu.Unlock()
+	return env
+}
+
+// WithEnvelopeInto unwraps the ReplyEnvelope of the reply of a server in
+// envelope mode: the result is decoded into the reply of the call as
+// usual, and the other fields into env.
+func WithEnvelopeInto(env *ReplyEnvelope) CallOption {
+	return func(o *callOptions) {
+		o.envelope = env
+	}
+}
+
+// decodeEnvelope decodes the envelope of resp into env and its result
+// into reply.
+func decodeEnvelope(resp Response, env *ReplyEnvelope, reply interface{}) error {
+	*env = ReplyEnvelope{Result: reply} // decoded in place by JSON
+	if err := resp.DecodeInto(env); err != nil {
+		return err
+	}
+	if raw, ok := env.Result.(RawMessage); ok {
+		env.Result = reply
+		return new(gobCodec).Decode(raw, reply)
+	}
+	if env.Result != reply && env.Result != nil {
+		// gob decodes interfaces into new values
+		replyV, resultV := reflect.ValueOf(reply).Elem(), reflect.ValueOf(env.Result)
+		if resultV.Type().AssignableTo(replyV.Type()) {
+			replyV.Set(resultV)
+		} else if resultV.Kind() == reflect.Ptr && resultV.Elem().Type().AssignableTo(replyV.Type()) {
+			replyV.Set(resultV.Elem())
+		}
+	}
+	env.Result = reply
+	return nil
+}
@@ -0,0 +1,61 @@
+package xrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Warner int
+
+func (w *Warner) Echo(ctx context.Context, args *string, reply *string) error {
+	AddWarning(ctx, "echo is slow")
+	*reply = *args
+	return nil
+}
+
+func TestServer_Envelope(t *testing.T) {
+	s := NewServerWithCodec(nil, WithEnvelope())
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.Register(new(Warner)))
+	assert.Nil(t, s.Register(&Users{names: []string{"a", "b", "c"}}))
+	s.Deprecate("Int.Sum", "use Int.Add")
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var (
+		env ReplyEnvelope
+		sum int
+	)
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, WithEnvelopeInto(&env), WithMetadata(Metadata{TraceIDKey: "t1"})))
+	assert.Equal(t, 3, sum)
+	assert.Equal(t, "t1", env.TraceID)
+	assert.Equal(t, []string{"use Int.Add"}, env.Warnings)
+	assert.True(t, env.Duration >= 0)
+	assert.Equal(t, &sum, env.Result)
+
+	var echo string
+	msg := "hi"
+	assert.Nil(t, c.Call("Warner.Echo", &msg, &echo, WithEnvelopeInto(&env)))
+	assert.Equal(t, "hi", echo)
+	assert.Equal(t, []string{"echo is slow"}, env.Warnings)
+	assert.Empty(t, env.TraceID)
+
+	// gob sends results of unregistered types too
+	var page UserPage
+	assert.Nil(t, c.Call("Users.List", &ListUsers{Limit: 2}, &page, WithEnvelopeInto(&env)))
+	assert.Equal(t, "2", env.NextCursor)
+	assert.Equal(t, []string{"a", "b"}, page.Users)
+
+	// without the option, the reply is the envelope
+	sum = 0
+	assert.NotNil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+}
+
+func TestAddWarning_NoEnvelope(t *testing.T) {
+	AddWarning(context.Background(), "dropped")
+	ctx, w := withWarnings(context.Background())
+	AddWarning(ctx, "kept")
+	assert.Equal(t, []string{"kept"}, w.list)
+}
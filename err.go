@@ -1,36 +1,143 @@
 package xrpc
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+type Code int
 
 const (
 	// Success 0 .
-	Success = 0
+	Success Code = 0
 	// ParseErr -32700 语法解析错误,服务端接收到无效的json。该错误发送于服务器尝试解析json文本
-	ParseErr = -32700
+	ParseErr Code = -32700
 	// InvalidRequest -32600 无效请求发送的json不是一个有效的请求对象。
-	InvalidRequest = -32600
+	InvalidRequest Code = -32600
 	// MethodNotFound -32601 找不到方法 该方法不存在或无效
-	MethodNotFound = -32601
+	MethodNotFound Code = -32601
 	// InvalidParamErr -32602 无效的参数 无效的方法参数。
-	InvalidParamErr = -32602
+	InvalidParamErr Code = -32602
 	// InternalErr -32603 内部错误 JSON-RPC内部错误。
-	InternalErr = -32603
-	// ServerErr       = -32000 // ServerErr -32000 to -32099 Server error服务端错误, 预留用于自定义的服务器错误。
+	InternalErr Code = -32603
+	// ServerErrMax, ServerErrMin -32000 to -32099 服务端错误, 预留用于自定义的服务器错误。
+	ServerErrMax Code = -32000
+	ServerErrMin Code = -32099
+
+	// Overloaded -32010 the server shed the request to protect itself.
+	Overloaded Code = -32010
+	// TransferErr -32011 a blob was corrupted or cut short in transfer.
+	TransferErr Code = -32011
+)
+
+var codeNames = map[Code]string{
+	Success:         "Success",
+	ParseErr:        "ParseErr",
+	InvalidRequest:  "InvalidRequest",
+	MethodNotFound:  "MethodNotFound",
+	InvalidParamErr: "InvalidParamErr",
+	InternalErr:     "InternalErr",
+	Overloaded:      "Overloaded",
+	TransferErr:     "TransferErr",
+}
+
+var codeMessages = map[Code]string{
+	Success:         "Success",
+	ParseErr:        "Parse error",
+	InvalidRequest:  "Invalid Request",
+	MethodNotFound:  "Method not found",
+	InvalidParamErr: "Invalid params",
+	InternalErr:     "Internal error",
+	Overloaded:      "Server overloaded",
+	TransferErr:     "Transfer failed",
+}
+
+// codeCategories maps codes onto the canonical gRPC status names.
+var codeCategories = map[Code]string{
+	Success:         "OK",
+	ParseErr:        "INVALID_ARGUMENT",
+	InvalidRequest:  "INVALID_ARGUMENT",
+	MethodNotFound:  "UNIMPLEMENTED",
+	InvalidParamErr: "INVALID_ARGUMENT",
+	InternalErr:     "INTERNAL",
+	Overloaded:      "RESOURCE_EXHAUSTED",
+	TransferErr:     "DATA_LOSS",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	if c.inServerRange() {
+		return fmt.Sprintf("ServerErr(%d)", int(c))
+	}
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// Category returns the canonical gRPC-like category of the code.
+func (c Code) Category() string {
+	if category, ok := codeCategories[c]; ok {
+		return category
+	}
+	return "UNKNOWN"
+}
+
+// IsClientError reports whether the code blames the request.
+func (c Code) IsClientError() bool {
+	switch c {
+	case ParseErr, InvalidRequest, MethodNotFound, InvalidParamErr:
+		return true
+	}
+	return false
+}
+
+// IsServerError reports whether the code blames the server.
+func (c Code) IsServerError() bool {
+	return c == InternalErr || c.inServerRange()
+}
+
+func (c Code) inServerRange() bool {
+	return c >= ServerErrMin && c <= ServerErrMax
+}
+
+// CodeMessage returns the standard error text of code.
+func CodeMessage(code Code) string {
+	if msg, ok := codeMessages[code]; ok {
+		return msg
+	}
+	if code.inServerRange() {
+		return "Server error"
+	}
+	return "Unknown error"
+}
+
+var (
+	ErrParse          = &Error{ErrCode: ParseErr, ErrMsg: "Parse error"}
+	ErrInvalidRequest = &Error{ErrCode: InvalidRequest, ErrMsg: "Invalid Request"}
+	ErrMethodNotFound = &Error{ErrCode: MethodNotFound, ErrMsg: "Method not found"}
+	ErrInvalidParams  = &Error{ErrCode: InvalidParamErr, ErrMsg: "Invalid params"}
+	ErrInternal       = &Error{ErrCode: InternalErr, ErrMsg: "Internal error"}
+	ErrOverloaded     = &Error{ErrCode: Overloaded, ErrMsg: "Server overloaded"}
+
+	ErrTimeout     = errors.New("rpc: timeout")
+	ErrConnClosed  = errors.New("rpc: connection closed")
+	ErrQueueFull   = errors.New("rpc: too many pending calls")
+	ErrRateLimited = errors.New("rpc: rate limit exceeded")
 )
 
 type Error struct {
-	ErrCode int    `json:"code"`
-	ErrMsg  string `json:"message"`
+	ErrCode Code        `json:"code"`
+	ErrMsg  string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 func (r *Error) Error() string {
 	return fmt.Sprintf("Error(code: %d, errmsg: %s)", r.ErrCode, r.ErrMsg)
 }
 
-var errCodeMap = map[int]*Error{
-	ParseErr:        &Error{ParseErr, "ParseErr"},
-	InvalidRequest:  &Error{InvalidRequest, "InvalidRequest"},
-	MethodNotFound:  &Error{MethodNotFound, "MethodNotFound"},
-	InvalidParamErr: &Error{InvalidParamErr, "InvalidParamErr"},
-	InternalErr:     &Error{InternalErr, "InternalErr"},
+// Is reports whether target is an *Error carrying the same code, so remote
+// errors match the sentinels above regardless of their message.
+func (r *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.ErrCode == r.ErrCode
 }
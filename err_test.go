@@ -0,0 +1,40 @@
+package xrpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, "MethodNotFound", MethodNotFound.String())
+	assert.Equal(t, "ServerErr(-32001)", Code(-32001).String())
+	assert.Equal(t, "Code(7)", Code(7).String())
+
+	assert.Equal(t, "UNIMPLEMENTED", MethodNotFound.Category())
+	assert.Equal(t, "INTERNAL", InternalErr.Category())
+	assert.Equal(t, "UNKNOWN", Code(-32001).Category())
+	assert.Equal(t, "RESOURCE_EXHAUSTED", Overloaded.Category())
+	assert.True(t, Overloaded.IsServerError())
+
+	assert.True(t, ParseErr.IsClientError())
+	assert.False(t, ParseErr.IsServerError())
+	assert.True(t, InternalErr.IsServerError())
+	assert.True(t, Code(-32050).IsServerError())
+	assert.False(t, Success.IsClientError())
+	assert.False(t, Success.IsServerError())
+}
+
+func TestCodeMessage(t *testing.T) {
+	assert.Equal(t, "Invalid params", CodeMessage(InvalidParamErr))
+	assert.Equal(t, "Server error", CodeMessage(-32000))
+	assert.Equal(t, "Unknown error", CodeMessage(1))
+}
+
+func TestError_Is(t *testing.T) {
+	err := &Error{ErrCode: MethodNotFound, ErrMsg: "rpc: can't find method Int.Sub"}
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+	assert.False(t, errors.Is(err, ErrInvalidParams))
+	assert.False(t, errors.Is(err, ErrTimeout))
+}
@@ -0,0 +1,68 @@
+package xrpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// WithCacheableMethods marks methods whose HTTP responses carry an ETag,
+// computed from their replies. Requests with a matching If-None-Match get
+// a 304 Not Modified without a body.
+func WithCacheableMethods(methods ...string) ServerOption {
+	return func(s *Server) {
+		if s.cacheable == nil {
+			s.cacheable = make(map[string]bool)
+		}
+		for _, method := range methods {
+			s.cacheable[method] = true
+		}
+	}
+}
+
+// etag returns the ETag of resps, or "" unless every request is cacheable
+// and succeeded. Response ids are left out, as they change per request.
+func (s *Server) etag(reqs []Request, resps []Response) string {
+	if len(s.cacheable) == 0 || len(reqs) != len(resps) {
+		return ""
+	}
+	h := sha256.New()
+	for i, req := range reqs {
+		if !s.cacheable[req.GetMethod()] || resps[i] == nil || resps[i].GetErrCode() != Success {
+			return ""
+		}
+		h.Write([]byte(req.GetMethod()))
+		h.Write([]byte{0})
+		h.Write(resps[i].GetReply())
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatch reports whether the If-None-Match header matches etag, using
+// the weak comparison of RFC 7232.
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// sendCached sets the ETag of resps and answers 304 if the client has
+// them already, reporting whether it did.
+func (s *Server) sendCached(w http.ResponseWriter, req *http.Request, reqs []Request, resps []Response) bool {
+	etag := s.etag(reqs, resps)
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	if !etagMatch(req.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,57 @@
+package xrpc
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatch(t *testing.T) {
+	assert.True(t, etagMatch(`"a"`, `"a"`))
+	assert.True(t, etagMatch(`"b", W/"a"`, `"a"`))
+	assert.True(t, etagMatch(`*`, `"a"`))
+	assert.False(t, etagMatch(``, `"a"`))
+	assert.False(t, etagMatch(`"b"`, `"a"`))
+}
+
+func TestServer_ServeHTTPETag(t *testing.T) {
+	s := NewServerWithCodec(nil, WithCacheableMethods("Echo.Say"))
+	_ = s.Register(new(Echo))
+	_ = s.Register(new(Int))
+	codec := NewGobCodec()
+
+	post := func(method string, args interface{}, ifNoneMatch string) *httptest.ResponseRecorder {
+		reqs := []Request{codec.NewRequest(method, args)}
+		body, err := codec.EncodeRequests(&reqs)
+		assert.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	hello, bye := "hello", "bye"
+	w := post("Echo.Say", &hello, "")
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, etag, post("Echo.Say", &hello, "").Header().Get("ETag"))
+
+	w = post("Echo.Say", &hello, etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+
+	w = post("Echo.Say", &bye, etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	w = post("Int.Sum", &Args{A: 1, B: 2}, "*")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
@@ -0,0 +1,96 @@
+package xrpc
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ConnEvents are callbacks on the connection of a client, e.g. to log
+// connectivity churn or feed health checks. They run on the calling
+// goroutine with the client locked, so they must not call the client.
+type ConnEvents struct {
+	// OnDial is called when a connection is established, with the time
+	// the dial took.
+	OnDial func(addr string, d time.Duration)
+	// OnDialError is called when a dial fails.
+	OnDialError func(addr string, err error)
+	// OnClose is called when the connection is dropped, with the error
+	// which broke it, or nil when the client is closed.
+	OnClose func(addr string, err error)
+	// OnReconnect is called when a connection is established after a
+	// previous one was dropped, along with OnDial.
+	OnReconnect func(addr string)
+}
+
+// WithConnEvents sets the callbacks on the connection of the client.
+func WithConnEvents(events ConnEvents) ClientOption {
+	return func(c *Client) {
+		c.events = events
+	}
+}
+
+func (c *Client) dialed(d time.Duration) {
+	if c.events.OnDial != nil {
+		c.events.OnDial(c.tcpAddr, d)
+	}
+	if c.connected && c.events.OnReconnect != nil {
+		c.events.OnReconnect(c.tcpAddr)
+	}
+	c.connected = true
+}
+
+func (c *Client) dialFailed(err error) {
+	if c.events.OnDialError != nil {
+		c.events.OnDialError(c.tcpAddr, err)
+	}
+}
+
+func (c *Client) closed(err error) {
+	if c.events.OnClose != nil {
+		c.events.OnClose(c.tcpAddr, err)
+	}
+}
+
+// ServerConnEvents are callbacks on the TCP connections of a server. They
+// run on the goroutine serving the connection.
+type ServerConnEvents struct {
+	// OnClose is called when the peer closed the connection in order,
+	// after the responses to its requests were sent.
+	OnClose func(remote string)
+	// OnAbnormalClose is called when the connection is dropped on an
+	// error, e.g. a reset, a frame cut short or a stuck peer.
+	OnAbnormalClose func(remote string, err error)
+}
+
+// WithServerConnEvents sets the callbacks on the connections of the
+// server.
+func WithServerConnEvents(events ServerConnEvents) ServerOption {
+	return func(s *Server) {
+		s.connEvents = events
+	}
+}
+
+// endConn ends serving conn after reading it failed with err. A peer which
+// closed its side in order, if only for writing, gets the responses still
+// buffered first.
+func (s *Server) endConn(conn net.Conn, wr *bufio.Writer, err error) {
+	remote := conn.RemoteAddr().String()
+	if errors.Is(err, io.EOF) {
+		if err = wr.Flush(); err == nil {
+			if s.debugLogger != nil {
+				s.debugLogger.Printf("rpc: %s closed the connection", remote)
+			}
+			if s.connEvents.OnClose != nil {
+				s.connEvents.OnClose(remote)
+			}
+			return
+		}
+	}
+	s.logger.Printf("ReadTCP error: %v", err)
+	if s.connEvents.OnAbnormalClose != nil {
+		s.connEvents.OnAbnormalClose(remote, err)
+	}
+}
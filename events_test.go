@@ -0,0 +1,130 @@
+package xrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ConnEvents(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		// drop the first connection, serve the next ones
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+		_ = s.serve(l)
+	}()
+
+	var events []string
+	var closeErrs []error
+	c := NewClientWithCodec(nil, l.Addr().String(), WithConnEvents(ConnEvents{
+		OnDial:      func(addr string, d time.Duration) { events = append(events, "dial") },
+		OnDialError: func(addr string, err error) { events = append(events, "dial error") },
+		OnClose: func(addr string, err error) {
+			events = append(events, "close")
+			closeErrs = append(closeErrs, err)
+		},
+		OnReconnect: func(addr string) { events = append(events, "reconnect") },
+	}))
+
+	var sum int
+	err = c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrConnClosed))
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	c.Close()
+
+	assert.Equal(t, []string{"dial", "close", "dial", "reconnect", "close"}, events)
+	assert.NotNil(t, closeErrs[0])
+	assert.Nil(t, closeErrs[1])
+
+	addr := l.Addr().String()
+	l.Close()
+	var dialErr error
+	c = NewClientWithCodec(nil, addr, WithConnEvents(ConnEvents{
+		OnDialError: func(addr string, err error) { dialErr = err },
+	}))
+	assert.NotNil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.NotNil(t, dialErr)
+}
+
+func TestServer_ConnEvents(t *testing.T) {
+	closed := make(chan string, 1)
+	broken := make(chan error, 1)
+	var debug bytes.Buffer
+	s := NewServerWithCodec(nil, WithFlushInterval(time.Second), WithDebugLogger(log.New(&debug, "", 0)),
+		WithServerConnEvents(ServerConnEvents{
+			OnClose:         func(remote string) { closed <- remote },
+			OnAbnormalClose: func(remote string, err error) { broken <- err },
+		}))
+	assert.Nil(t, s.Register(new(Int)))
+	addr := startServer(t, s)
+
+	// requests in flight when the client shuts down its write side are
+	// still answered
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	codec := NewGobCodec()
+	wr := bufio.NewWriter(conn)
+	for i := 0; i < 2; i++ {
+		reqs := []Request{codec.NewRequest("Int.Sum", &Args{A: i, B: 1})}
+		p := proto.New()
+		p.Body, err = codec.EncodeRequests(&reqs)
+		assert.Nil(t, err)
+		assert.Nil(t, p.WriteTCP(wr))
+	}
+	assert.Nil(t, wr.Flush())
+	assert.Nil(t, conn.(*net.TCPConn).CloseWrite())
+
+	rr := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		p := proto.New()
+		assert.Nil(t, p.ReadTCP(rr))
+		resps, err := codec.ReadResponse(p.Body)
+		assert.Nil(t, err)
+		var sum int
+		assert.Nil(t, resps[0].DecodeInto(&sum))
+		assert.Equal(t, i+1, sum)
+	}
+	assert.Equal(t, io.EOF, proto.New().ReadTCP(rr))
+	select {
+	case remote := <-closed:
+		assert.Equal(t, conn.LocalAddr().String(), remote)
+	case <-time.After(time.Second):
+		t.Fatal("OnClose not called")
+	}
+	assert.Contains(t, debug.String(), "closed the connection")
+
+	// a frame cut short
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte{0, 0, 0, 40, 0})
+	conn.Close()
+	select {
+	case err := <-broken:
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	case <-time.After(time.Second):
+		t.Fatal("OnAbnormalClose not called")
+	}
+}
@@ -11,11 +11,6 @@ type Args struct {
 	B int `json:"b"`
 }
 
-type MultiArgs struct {
-	A *Args `json:"aa"`
-	B *Args `json:"bb"`
-}
-
 type MultiReply struct {
 	A int `json:"aa"`
 	B int `json:"bb"`
@@ -29,6 +24,6 @@ func main() {
 	fmt.Println(sum)
 
 	var reply MultiReply
-	_ = c.Call("Int.Multi", &MultiArgs{A: &Args{1, 2}, B: &Args{3, 4}}, &reply)
+	_ = c.Call("Int.Multi", xrpc.Positional{&Args{1, 2}, &Args{3, 4}}, &reply)
 	fmt.Println(reply.A, reply.B)
 }
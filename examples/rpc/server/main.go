@@ -25,19 +25,14 @@ func (i *Int) Sum2(args *[]int, reply *int) error {
 	return nil
 }
 
-type MultiArgs struct {
-	A *Args `json:"aa"`
-	B *Args `json:"bb"`
-}
-
 type MultiReply struct {
 	A int `json:"aa"`
 	B int `json:"bb"`
 }
 
-func (i *Int) Multi(args *MultiArgs, reply *MultiReply) error {
-	reply.A = args.A.A * args.A.B
-	reply.B = args.B.A * args.B.B
+func (i *Int) Multi(a, b *Args, reply *MultiReply) error {
+	reply.A = a.A * a.B
+	reply.B = b.A * b.B
 	return nil
 }
 
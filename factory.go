@@ -0,0 +1,178 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InstanceScope is how many instances of a service registered with
+// RegisterFactory serve the calls.
+type InstanceScope int
+
+const (
+	PerServer  InstanceScope = iota // one, built on the first call
+	PerConn                         // one per connection, or per batch of calls off one, e.g. over HTTP
+	PerRequest                      // one per call
+)
+
+// factory builds the instances of a service.
+type factory struct {
+	name    string
+	fn      reflect.Value
+	withErr bool
+	scope   InstanceScope
+
+	mu       sync.Mutex // held while building the PerServer instance
+	instance reflect.Value
+}
+
+// RegisterFactory registers the service name, whose instances factory
+// builds when calls need them rather than at registration: a func() T or
+// func() (T, error), e.g. func() *Heavy, T being the service type. There
+// is one instance, built on the first call, unless WithInstanceScope says
+// otherwise. Instances of a scope ending, i.e. a connection or a call, are
+// closed if they implement io.Closer.
+func (s *Server) RegisterFactory(name string, factory interface{}, opts ...RegisterOption) error {
+	fn := reflect.ValueOf(factory)
+	if fn.Kind() != reflect.Func {
+		return errors.New("rpc.RegisterFactory: factory of " + name + " is not a func")
+	}
+	t := fn.Type()
+	if t.NumIn() != 0 || t.NumOut() < 1 || t.NumOut() > 2 || t.NumOut() == 2 && t.Out(1) != typeOfError {
+		return errors.New("rpc.RegisterFactory: factory of " + name + " must be a func() T or func() (T, error)")
+	}
+	if t.Out(0).Kind() == reflect.Interface {
+		return fmt.Errorf("rpc.RegisterFactory: factory of %s returns an interface, not the service type, e.g. func() *%s", name, name)
+	}
+	if name == "" || strings.Contains(name, ".") || !isExported(name) {
+		return errors.New("rpc.RegisterFactory: invalid service name " + strconv.Quote(name))
+	}
+
+	srv := &service{
+		name:         name,
+		typ:          t.Out(0),
+		registeredAt: caller(),
+		factory:      newFactory(name, fn),
+	}
+	srv.method = suitableMethods(srv.typ)
+	for _, opt := range opts {
+		if err := opt(srv); err != nil {
+			return err
+		}
+	}
+	if i, dup := s.m.LoadOrStore(name, srv); dup {
+		return fmt.Errorf("rpc: service already defined: %s, by %s", name, i.(*service).origin())
+	}
+	s.registry.changed()
+	return nil
+}
+
+func newFactory(name string, fn reflect.Value) *factory {
+	return &factory{name: name, fn: fn, withErr: fn.Type().NumOut() == 2}
+}
+
+// WithInstanceScope sets the scope of the instances of a service
+// registered with RegisterFactory.
+func WithInstanceScope(scope InstanceScope) RegisterOption {
+	return func(srv *service) error {
+		if srv.factory == nil {
+			return fmt.Errorf("rpc: instance scope of %s, which has no factory", srv.name)
+		}
+		srv.factory.scope = scope
+		return nil
+	}
+}
+
+// build returns a new instance.
+func (f *factory) build() (reflect.Value, error) {
+	out := f.fn.Call(nil)
+	if f.withErr && !out[1].IsNil() {
+		return reflect.Value{}, out[1].Interface().(error)
+	}
+	if v := out[0]; v.Kind() == reflect.Ptr && v.IsNil() {
+		return reflect.Value{}, errors.New("rpc: factory of " + f.name + " returned nil")
+	}
+	return out[0], nil
+}
+
+// receiver returns the instance serving a call with ctx, and the func to
+// call once done with it.
+func (f *factory) receiver(ctx context.Context) (reflect.Value, func(), error) {
+	switch f.scope {
+	case PerServer:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if !f.instance.IsValid() {
+			v, err := f.build()
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+			f.instance = v
+		}
+		return f.instance, func() {}, nil
+	case PerConn:
+		if instances, ok := ctx.Value(connInstancesKey{}).(*connInstances); ok {
+			v, err := instances.get(f)
+			return v, func() {}, err
+		}
+	}
+	v, err := f.build()
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return v, func() { closeInstance(v) }, nil
+}
+
+type connInstancesKey struct{}
+
+// connInstances are the PerConn instances of a connection.
+type connInstances struct {
+	mu sync.Mutex
+	m  map[*factory]reflect.Value
+}
+
+func withConnInstances(ctx context.Context) (context.Context, *connInstances) {
+	instances := new(connInstances)
+	return context.WithValue(ctx, connInstancesKey{}, instances), instances
+}
+
+func (c *connInstances) get(f *factory) (reflect.Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.m[f]; ok {
+		return v, nil
+	}
+	v, err := f.build()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if c.m == nil {
+		c.m = make(map[*factory]reflect.Value)
+	}
+	c.m[f] = v
+	return v, nil
+}
+
+// close closes the instances, once the connection ended.
+func (c *connInstances) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for f, v := range c.m {
+		closeInstance(v)
+		delete(c.m, f)
+	}
+}
+
+func closeInstance(v reflect.Value) {
+	if closer, ok := v.Interface().(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
@@ -0,0 +1,157 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Session struct {
+	n      int
+	closed *int32
+}
+
+func (c *Session) Next(args *struct{}, reply *int) error {
+	c.n++
+	*reply = c.n
+	return nil
+}
+
+func (c *Session) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return nil
+}
+
+func TestServer_RegisterFactory(t *testing.T) {
+	var built, closed int32
+	newCounter := func() *Session {
+		atomic.AddInt32(&built, 1)
+		return &Session{closed: &closed}
+	}
+	next := func(c *Client) int {
+		var n int
+		assert.Nil(t, c.Call("Session.Next", &struct{}{}, &n))
+		return n
+	}
+	serve := func(scope InstanceScope) string {
+		s := NewServerWithCodec(nil)
+		assert.Nil(t, s.RegisterFactory("Session", newCounter, WithInstanceScope(scope)))
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		go func() { _ = s.serve(l) }()
+		t.Cleanup(func() { _ = l.Close() })
+		return l.Addr().String()
+	}
+
+	addr := serve(PerServer)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&built))
+	c1, c2 := NewClientWithCodec(nil, addr), NewClientWithCodec(nil, addr)
+	assert.Equal(t, 1, next(c1))
+	assert.Equal(t, 2, next(c2))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&built))
+	c1.Close()
+	c2.Close()
+
+	atomic.StoreInt32(&built, 0)
+	addr = serve(PerConn)
+	c1, c2 = NewClientWithCodec(nil, addr), NewClientWithCodec(nil, addr)
+	assert.Equal(t, 1, next(c1))
+	assert.Equal(t, 2, next(c1))
+	assert.Equal(t, 1, next(c2))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&built))
+	c1.Close()
+	c2.Close()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&closed) == 2 }, time.Second, 10*time.Millisecond)
+
+	atomic.StoreInt32(&closed, 0)
+	addr = serve(PerRequest)
+	c1 = NewClientWithCodec(nil, addr)
+	defer c1.Close()
+	assert.Equal(t, 1, next(c1))
+	assert.Equal(t, 1, next(c1))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&closed))
+}
+
+func TestServer_RegisterFactoryOverHTTP(t *testing.T) {
+	var built int32
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.RegisterFactory("Session", func() (*Session, error) {
+		if atomic.AddInt32(&built, 1) == 1 {
+			return nil, errors.New("not ready")
+		}
+		return &Session{closed: new(int32)}, nil
+	}, WithInstanceScope(PerConn)))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := NewClientWithCodec(nil, srv.URL)
+	defer c.Close()
+	var n int
+	err := c.Call("Session.Next", &struct{}{}, &n)
+	assert.Contains(t, err.Error(), "not ready")
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			assert.Nil(t, c.Call("Session.Next", &struct{}{}, &n))
+			assert.Equal(t, 1, n) // a connection per request
+		}()
+	}
+	wg.Wait()
+}
+
+func TestServer_RegisterFactoryErrors(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.EqualError(t, s.RegisterFactory("Heavy", func() interface{} { return new(Session) }),
+		"rpc.RegisterFactory: factory of Heavy returns an interface, not the service type, e.g. func() *Heavy")
+	assert.EqualError(t, s.RegisterFactory("Heavy", new(Session)), "rpc.RegisterFactory: factory of Heavy is not a func")
+	assert.EqualError(t, s.RegisterFactory("Heavy", func(int) *Session { return nil }),
+		"rpc.RegisterFactory: factory of Heavy must be a func() T or func() (T, error)")
+	assert.EqualError(t, s.RegisterFactory("a.b", func() *Session { return nil }), `rpc.RegisterFactory: invalid service name "a.b"`)
+	assert.EqualError(t, s.Register(new(Int), WithInstanceScope(PerConn)), "rpc: instance scope of Int, which has no factory")
+
+	assert.Nil(t, s.RegisterFactory("Heavy", func() *Session { return nil }))
+	assert.Equal(t, []string{"Next"}, s.Services()["Heavy"])
+	assert.NotNil(t, s.RegisterFactory("Heavy", func() *Session { return nil }))
+
+	assert.Nil(t, s.RegisterFactory("Session", func() *Session { return nil }))
+	assert.EqualError(t, s.RegisterName(new(Session), "Next"),
+		"rpc: service already defined: Session, by a factory; can't add method Next of *xrpc.Session")
+}
+
+func TestServer_RegisterFactoryPerConnBatch(t *testing.T) {
+	var built, closed int32
+	codec := NewGobCodec()
+	s := NewServerWithCodec(codec, WithBatchWorkers(1))
+	assert.Nil(t, s.RegisterFactory("Session", func() *Session {
+		atomic.AddInt32(&built, 1)
+		return &Session{closed: &closed}
+	}, WithInstanceScope(PerConn)))
+
+	// a batch off a connection, as over HTTP, shares one instance
+	resps := s.call(context.Background(), []Request{
+		codec.NewRequest("Session.Next", &struct{}{}),
+		codec.NewRequest("Session.Next", &struct{}{}),
+		codec.NewRequest("Session.Next", &struct{}{}),
+	})
+	var last int
+	for _, resp := range resps {
+		var n int
+		assert.Nil(t, resp.DecodeInto(&n))
+		if n > last {
+			last = n
+		}
+	}
+	assert.Equal(t, 3, last)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&built))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&closed))
+}
@@ -0,0 +1,41 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+)
+
+// FallbackHandler handles requests for unknown methods. params are the
+// request params as encoded by the client codec; the result is encoded as
+// the reply.
+type FallbackHandler func(ctx context.Context, method string, params []byte) (interface{}, error)
+
+// SetFallbackHandler sets the handler of requests whose service or method
+// is not registered, e.g. to forward them to another backend. A nil
+// handler restores the MethodNotFound error.
+func (s *Server) SetFallbackHandler(fn FallbackHandler) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	s.fallback = fn
+}
+
+func (s *Server) fallbackHandler() FallbackHandler {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	return s.fallback
+}
+
+func (s *Server) callFallback(ctx context.Context, fn FallbackHandler, req Request) Response {
+	ctx = withMetadata(ctx, req.GetMetadata())
+	result, err := fn(ctx, req.GetMethod(), req.GetParams())
+	if err != nil {
+		return s.errResponse(err)
+	}
+	reply := s.codec.NewResponse(result)
+	if reply == nil {
+		reply = s.codec.ErrResponse(InternalErr, errors.New("rpc: could not encode the fallback reply of "+req.GetMethod()))
+	}
+	return reply
+}
@@ -0,0 +1,66 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_FallbackHandler(t *testing.T) {
+	backend := NewServerWithCodec(nil)
+	assert.Nil(t, backend.Register(new(Int)))
+	backendClient := NewClientWithCodec(nil, startServer(t, backend))
+	defer backendClient.Close()
+
+	// forward unknown methods to the backend, keeping the params as is
+	gateway := NewServerWithCodec(nil)
+	assert.Nil(t, gateway.Register(new(Whoami)))
+	gateway.SetFallbackHandler(func(ctx context.Context, method string, params []byte) (interface{}, error) {
+		if MetadataFromContext(ctx).Get("deny") != "" {
+			return nil, &Error{ErrCode: InvalidRequest, ErrMsg: "denied"}
+		}
+		var args Args
+		if err := NewGobCodec().ReadRequestBody(params, &args); err != nil {
+			return nil, err
+		}
+		var sum int
+		err := backendClient.CallContext(ctx, method, &args, &sum)
+		return sum, err
+	})
+
+	c := NewClientWithCodec(nil, startServer(t, gateway))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 2, B: 5}, &sum))
+	assert.Equal(t, 7, sum)
+
+	err := c.Call("Int.Sum", &Args{}, &sum, WithMetadata(Metadata{"deny": "1"}))
+	assert.True(t, errors.Is(err, ErrInvalidRequest))
+
+	err = c.Call("Int.Nope", &Args{}, &sum)
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+
+	gateway.SetFallbackHandler(nil)
+	err = c.Call("Int.Sum", &Args{}, &sum)
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+}
+
+func TestServer_FallbackUnencodableResult(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	s.SetFallbackHandler(func(ctx context.Context, method string, params []byte) (interface{}, error) {
+		return make(chan int), nil
+	})
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var reply int
+	err := c.Call("Int.Sum", &Args{}, &reply)
+	assert.True(t, errors.Is(err, ErrInternal), "%v", err)
+
+	// the server is still up
+	err = c.Call("Int.Sum", &Args{}, &reply)
+	assert.True(t, errors.Is(err, ErrInternal), "%v", err)
+}
@@ -0,0 +1,109 @@
+package xrpc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Fault describes the failure injected into a share of the calls of a
+// method.
+type Fault struct {
+	Percent float64       // share of the calls hit, 0-100
+	Delay   time.Duration // added before the call
+	Code    Code          // if not Success, the call fails with this code
+	// Drop fails client calls with ErrConnClosed, as if the connection
+	// broke before the response. Servers ignore it.
+	Drop bool
+}
+
+// FaultInjector injects faults into calls, on the server through
+// Interceptor and on clients through WithFaults. The same seed gives the
+// same sequence of faults.
+type FaultInjector struct {
+	mu     sync.Mutex
+	rnd    *rand.Rand
+	faults map[string]Fault // by method, "*" for any
+}
+
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{
+		rnd:    rand.New(rand.NewSource(seed)),
+		faults: make(map[string]Fault),
+	}
+}
+
+// Set injects fault into the calls of method, or of every method without
+// a fault of its own if method is "*".
+func (f *FaultInjector) Set(method string, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults[method] = fault
+}
+
+func (f *FaultInjector) Clear(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.faults, method)
+}
+
+// pick returns the fault to inject into a call of method, if any.
+func (f *FaultInjector) pick(method string) (Fault, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fault, ok := f.faults[method]
+	if !ok {
+		if fault, ok = f.faults["*"]; !ok {
+			return fault, false
+		}
+	}
+	return fault, f.rnd.Float64()*100 < fault.Percent
+}
+
+// inject applies the fault picked for method, returning the error the call
+// must fail with.
+func (f *FaultInjector) inject(ctx context.Context, method string, client bool) error {
+	fault, ok := f.pick(method)
+	if !ok {
+		return nil
+	}
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if client && fault.Drop {
+		return fmt.Errorf("%w: injected drop of %s", ErrConnClosed, method)
+	}
+	if fault.Code != Success {
+		return &Error{ErrCode: fault.Code, ErrMsg: "rpc: injected fault in " + method}
+	}
+	return nil
+}
+
+// Interceptor returns a server interceptor injecting the faults.
+func (f *FaultInjector) Interceptor() Interceptor {
+	return func(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error {
+		if err := f.inject(ctx, info.Method, false); err != nil {
+			return err
+		}
+		return next(ctx, args, reply)
+	}
+}
+
+// WithFaults injects the faults of f into each attempt of the client
+// calls, before the request is sent.
+func WithFaults(f *FaultInjector) ClientOption {
+	return func(c *Client) {
+		c.faults = f
+	}
+}
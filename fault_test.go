@@ -0,0 +1,81 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultInjector_Deterministic(t *testing.T) {
+	hits := func(seed int64) []bool {
+		f := NewFaultInjector(seed)
+		f.Set("*", Fault{Percent: 50})
+		out := make([]bool, 20)
+		for i := range out {
+			_, out[i] = f.pick("A.B")
+		}
+		return out
+	}
+	assert.Equal(t, hits(1), hits(1))
+	assert.Contains(t, hits(1), true)
+	assert.Contains(t, hits(1), false)
+
+	f := NewFaultInjector(1)
+	f.Set("*", Fault{Percent: 100})
+	f.Set("A.B", Fault{Percent: 0})
+	_, hit := f.pick("A.B")
+	assert.False(t, hit)
+	_, hit = f.pick("A.C")
+	assert.True(t, hit)
+	f.Clear("*")
+	_, hit = f.pick("A.C")
+	assert.False(t, hit)
+}
+
+func TestFaultInjector_Server(t *testing.T) {
+	f := NewFaultInjector(1)
+	s := NewServerWithCodec(nil, WithInterceptors(f.Interceptor()))
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	f.Set("Int.Sum", Fault{Percent: 100, Code: InternalErr, Drop: true})
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrInternal))
+
+	f.Set("Int.Sum", Fault{Percent: 100, Delay: 20 * time.Millisecond})
+	start := time.Now()
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func TestFaultInjector_ClientRetry(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	f := NewFaultInjector(1)
+	f.Set("Int.Sum", Fault{Percent: 100, Drop: true})
+	c := NewClientWithCodec(nil, startServer(t, s), WithFaults(f), WithRetry(2))
+	defer c.Close()
+
+	var sum int
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrConnClosed))
+
+	// half of the attempts are dropped, retries get through
+	f.Set("Int.Sum", Fault{Percent: 50, Drop: true})
+	retrying := NewClientWithCodec(nil, c.tcpAddr, WithFaults(f), WithRetry(20))
+	defer retrying.Close()
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, retrying.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	}
+
+	f.Set("Int.Sum", Fault{Percent: 100, Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = c.CallContext(ctx, "Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrTimeout))
+}
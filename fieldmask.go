@@ -0,0 +1,86 @@
+package xrpc
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldMaskKey is the metadata key listing the reply fields a caller needs,
+// as comma separated paths of wire names, e.g. "id,owner.name". The other
+// fields of the reply are zeroed, which leaves them out with gob and with
+// JSON fields tagged omitempty. Paths through maps are not supported; a map
+// is kept or dropped as a whole.
+const FieldMaskKey = "xrpc-fields"
+
+// WithFieldMask asks for the given reply fields only, see FieldMaskKey.
+func WithFieldMask(paths ...string) CallOption {
+	return WithMetadata(Metadata{FieldMaskKey: strings.Join(paths, ",")})
+}
+
+// fieldMask is a tree of field names; a leaf keeps the whole field.
+type fieldMask map[string]fieldMask
+
+func parseFieldMask(s string) fieldMask {
+	mask := make(fieldMask)
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		node := mask
+		for _, name := range strings.Split(path, ".") {
+			child, ok := node[name]
+			if !ok {
+				child = make(fieldMask)
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	return mask
+}
+
+// apply returns a copy of v keeping the fields in the mask. v itself and
+// what it points to are left untouched, since handlers may return shared
+// values.
+func (m fieldMask) apply(v reflect.Value) reflect.Value {
+	if len(m) == 0 {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(m.apply(v.Elem()))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(m.apply(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(m.apply(v.Index(i)))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if sub, ok := m[fieldName(f)]; ok {
+				out.Field(i).Set(sub.apply(v.Field(i)))
+			}
+		}
+		return out
+	}
+	return v
+}
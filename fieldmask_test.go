@@ -0,0 +1,64 @@
+package xrpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Owner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type Repo struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Owner  *Owner   `json:"owner"`
+	Forks  []Owner  `json:"forks"`
+	Topics []string `json:"topics"`
+}
+
+var sharedRepo = &Repo{
+	ID:     1,
+	Name:   "xrpc",
+	Owner:  &Owner{Name: "dabao", Email: "d@example.com"},
+	Forks:  []Owner{{Name: "a", Email: "a@example.com"}},
+	Topics: []string{"rpc"},
+}
+
+type Repos struct{}
+
+func (r *Repos) Get(ctx context.Context, args *int) (*Repo, error) {
+	return sharedRepo, nil
+}
+
+func TestFieldMask_apply(t *testing.T) {
+	mask := parseFieldMask("id, owner.name,forks.email,")
+	got := mask.apply(reflect.ValueOf(sharedRepo)).Interface()
+	assert.Equal(t, &Repo{
+		ID:    1,
+		Owner: &Owner{Name: "dabao"},
+		Forks: []Owner{{Email: "a@example.com"}},
+	}, got)
+	assert.Equal(t, "d@example.com", sharedRepo.Owner.Email)
+
+	assert.Equal(t, 3, parseFieldMask("id").apply(reflect.ValueOf(3)).Interface())
+}
+
+func TestFieldMask_Call(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Repos)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var repo Repo
+	assert.Nil(t, c.Call("Repos.Get", new(int), &repo, WithFieldMask("name", "topics")))
+	assert.Equal(t, Repo{Name: "xrpc", Topics: []string{"rpc"}}, repo)
+
+	var full Repo
+	assert.Nil(t, c.Call("Repos.Get", new(int), &full))
+	assert.Equal(t, *sharedRepo, full)
+}
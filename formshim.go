@@ -0,0 +1,115 @@
+package xrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// FormShimConfig configures the handler returned by FormShim.
+type FormShimConfig struct {
+	// AllowedOrigins are the origins of the pages allowed to call the
+	// server and read the responses, e.g. "https://legacy.example.com",
+	// or "*" for any.
+	AllowedOrigins []string
+}
+
+// FormShim returns an HTTP handler for legacy web pages which can't post
+// JSON: the JSON-RPC request comes in the "request" field of a form post.
+// The response is JSON with the CORS headers of the allowed origin, or, if
+// the form has a "post_message" field naming an allowed origin, an HTML
+// page posting it to its parent window with window.postMessage, for forms
+// targeting a hidden iframe. Only codecs of JSON bodies are served.
+//
+// Form posts need no CORS preflight, so any page could make a browser call
+// the server with its cookies: posts are refused unless their Origin, or
+// their Referer lacking one, is allowed.
+func (s *Server) FormShim(cfg FormShimConfig) http.Handler {
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		allowed[origin] = true
+	}
+	allows := func(origin string) bool {
+		return origin != "" && (allowed["*"] || allowed[origin])
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if origin := req.Header.Get("Origin"); allows(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		w.Header().Add("Vary", "Origin")
+		switch req.Method {
+		case http.MethodOptions:
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPost:
+		default:
+			w.Header().Set("Allow", "POST, OPTIONS")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.codec.ContentType() != "application/json" {
+			http.Error(w, "rpc: the codec of the server doesn't take JSON", http.StatusNotImplemented)
+			return
+		}
+		if origin := postOrigin(req); !allows(origin) || origin == "*" {
+			http.Error(w, "rpc: origin not allowed: "+origin, http.StatusForbidden)
+			return
+		}
+
+		if err := req.ParseForm(); err != nil {
+			resp := s.frameErr(ParseErr, err)
+			b, _ := s.codec.EncodeResponses(resp)
+			_ = s.codec.Send(w, http.StatusOK, b)
+			return
+		}
+		target := req.PostForm.Get("post_message")
+		if target != "" && (target == "*" || !allows(target)) {
+			http.Error(w, "rpc: origin not allowed: "+target, http.StatusForbidden)
+			return
+		}
+
+		var resps []Response
+		if rpcReqs, err := s.codec.ReadRequest([]byte(req.PostForm.Get("request"))); err != nil {
+			resps = []Response{s.frameErr(ParseErr, err)}
+		} else {
+			resps = s.call(withPeer(req.Context(), req.RemoteAddr), rpcReqs)
+		}
+		b := s.encodeHTTPResponses(resps)
+		s.releaseResponses(resps)
+
+		if target != "" {
+			writePostMessage(w, b, target)
+			return
+		}
+		_ = s.codec.Send(w, http.StatusOK, b)
+	})
+}
+
+// postOrigin returns the origin of the page which posted req, "" if
+// unknown.
+func postOrigin(req *http.Request) string {
+	if origin := req.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	referer, err := url.Parse(req.Header.Get("Referer"))
+	if err != nil || referer.Scheme == "" || referer.Host == "" {
+		return ""
+	}
+	return referer.Scheme + "://" + referer.Host
+}
+
+// writePostMessage answers with a page posting the JSON msg to the parent
+// window, if it's at the target origin.
+func writePostMessage(w http.ResponseWriter, msg []byte, target string) {
+	var escaped bytes.Buffer
+	json.HTMLEscape(&escaped, msg) // a JSON value can't end the script then
+	origin, _ := json.Marshal(target)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'")
+	_, _ = fmt.Fprintf(w, "<!DOCTYPE html><script>parent.postMessage(%s, %s);</script>\n", escaped.Bytes(), origin)
+}
@@ -2,4 +2,7 @@ module github.com/dabao-zhao/xrpc
 
 go 1.16
 
-require github.com/stretchr/testify v1.8.2
+require (
+	github.com/stretchr/testify v1.8.2
+	gopkg.in/yaml.v3 v3.0.1
+)
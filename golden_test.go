@@ -0,0 +1,56 @@
+package xrpc
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// checkGolden compares b with testdata/name, which -update rewrites. A
+// mismatch means the wire format changed and older peers may break.
+func checkGolden(t *testing.T, name string, b []byte) []byte {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, golden, b, "wire format of %s changed", name)
+	return golden
+}
+
+func TestGobCodec_Golden(t *testing.T) {
+	codec := NewGobCodec()
+	params, err := codec.(*gobCodec).Encode(&Args{A: 1, B: 2})
+	assert.Nil(t, err)
+	reply, err := codec.(*gobCodec).Encode(3)
+	assert.Nil(t, err)
+
+	reqs := []Request{&defaultRequest{Method: "Int.Sum", Args: params, Id: "1", Meta: Metadata{"k": "v"}}}
+	b, err := codec.EncodeRequests(&reqs)
+	assert.Nil(t, err)
+	golden := checkGolden(t, "gob_requests.golden", b)
+	decoded, err := codec.ReadRequest(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, reqs, decoded)
+
+	resps := []Response{
+		&defaultResponse{Reply: reply, Id: "1"},
+		&defaultResponse{Err: "boom", ErrCode: InternalErr, Id: "2"},
+	}
+	b, err = codec.EncodeResponses(resps)
+	assert.Nil(t, err)
+	golden = checkGolden(t, "gob_responses.golden", b)
+	decodedResps, err := codec.ReadResponse(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, resps, decodedResps)
+}
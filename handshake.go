@@ -0,0 +1,138 @@
+package xrpc
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+// A client expecting a given schema opens its connections with a hello
+// frame carrying the hash of its service map, which the server answers
+// with the hash of its own. Each side then applies its SchemaPolicy, so
+// clients deployed before the server they call fail at connect time rather
+// than on the first call of a missing method.
+
+// SchemaPolicy is what a peer does when the schema hashes exchanged by the
+// handshake differ.
+type SchemaPolicy int
+
+const (
+	SchemaIgnore SchemaPolicy = iota // go on
+	SchemaWarn                       // log the mismatch and go on
+	SchemaFail                       // close the connection
+)
+
+// SchemaMismatchError is the error of a connection whose peers expect
+// different schemas. The hashes are those of ServiceMap.Hash, Remote is
+// empty if the peer sent none.
+type SchemaMismatchError struct {
+	Local, Remote string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	remote := e.Remote
+	if remote == "" {
+		remote = "none"
+	}
+	return fmt.Sprintf("rpc: schema hash mismatch: local %s, remote %s", e.Local, remote)
+}
+
+type schemaCheck struct {
+	hash   string
+	policy SchemaPolicy
+}
+
+// WithSchemaHash opens connections with a handshake comparing the hash of
+// want, the service map the client was built against, with that of the
+// server, see Server.ExportServiceMap. On a mismatch, policy decides
+// whether the connection is used; SchemaFail makes calls fail with a
+// *SchemaMismatchError. Client.VerifyServiceMap tells the differences.
+func WithSchemaHash(want ServiceMap, policy SchemaPolicy) ClientOption {
+	return func(c *Client) {
+		c.schema = &schemaCheck{hash: want.Hash(), policy: policy}
+	}
+}
+
+// WithSchemaPolicy sets what the server does when the schema hash a client
+// sends in its handshake differs from its own. Defaults to SchemaIgnore;
+// with SchemaFail, the client is refused with a close frame.
+func WithSchemaPolicy(policy SchemaPolicy) ServerOption {
+	return func(s *Server) {
+		s.schemaPolicy = policy
+	}
+}
+
+// hello answers the hello frame p, and reports whether the connection goes
+// on.
+func (s *Server) hello(conn net.Conn, wr *bufio.Writer, p *proto.Proto) bool {
+	local := s.ExportServiceMap().Hash()
+	if remote := string(p.Body); remote != local {
+		mismatch := &SchemaMismatchError{Local: local, Remote: remote}
+		switch s.schemaPolicy {
+		case SchemaWarn:
+			s.logger.Printf("%s: %v", conn.RemoteAddr(), mismatch)
+		case SchemaFail:
+			_ = wr.Flush()
+			_ = s.refuse(conn, "schema hash mismatch")
+			return false
+		}
+	}
+
+	reply := proto.New()
+	reply.Op = proto.OpHello
+	reply.Ver = p.Ver
+	reply.Body = []byte(local)
+	_ = reply.WriteTCP(wr)
+	_ = wr.Flush()
+	return true
+}
+
+// hello does the handshake of conn, newly dialed.
+func (c *Client) hello(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	wr := bufio.NewWriter(conn)
+	p := proto.New()
+	p.Op = proto.OpHello
+	if c.protoVersion != 0 {
+		p.Ver = c.protoVersion
+	}
+	p.Body = []byte(c.schema.hash)
+	err := p.WriteTCP(wr)
+	if err == nil {
+		err = wr.Flush()
+	}
+	if err == nil {
+		err = p.ReadTCP(bufio.NewReader(conn))
+	}
+	if err == nil {
+		err = closeFrameErr(p)
+	}
+	if err != nil {
+		return err
+	}
+
+	// servers unaware of the handshake answer with an error response
+	var remote string
+	if p.Op == proto.OpHello {
+		remote = string(p.Body)
+	}
+	if remote == c.schema.hash {
+		return nil
+	}
+	mismatch := &SchemaMismatchError{Local: c.schema.hash, Remote: remote}
+	switch c.schema.policy {
+	case SchemaWarn:
+		log.Printf("%s: %v", c.tcpAddr, mismatch)
+	case SchemaFail:
+		return mismatch
+	}
+	return nil
+}
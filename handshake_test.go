@@ -0,0 +1,88 @@
+package xrpc
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+func TestSchemaHandshake(t *testing.T) {
+	serve := func(opts ...ServerOption) (*Server, string) {
+		s := NewServerWithCodec(nil, append(opts, WithLogger(log.New(ioutil.Discard, "", 0)))...)
+		assert.Nil(t, s.Register(new(Int)))
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		go func() { _ = s.serve(l) }()
+		t.Cleanup(func() { _ = l.Close() })
+		return s, l.Addr().String()
+	}
+	s, addr := serve()
+	want := s.ExportServiceMap()
+
+	newer := NewServerWithCodec(nil)
+	assert.Nil(t, newer.Register(new(Int)))
+	assert.Nil(t, newer.Register(new(Files)))
+	ahead := newer.ExportServiceMap()
+
+	var reply int
+	c := NewClientWithCodec(nil, addr, WithSchemaHash(want, SchemaFail))
+	defer c.Close()
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &reply))
+	assert.Equal(t, 3, reply)
+
+	c = NewClientWithCodec(nil, addr, WithSchemaHash(ahead, SchemaFail))
+	defer c.Close()
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &reply)
+	var mismatch *SchemaMismatchError
+	assert.True(t, errors.As(err, &mismatch), "%v", err)
+	assert.Equal(t, SchemaMismatchError{Local: ahead.Hash(), Remote: want.Hash()}, *mismatch)
+
+	c = NewClientWithCodec(nil, addr, WithSchemaHash(ahead, SchemaWarn))
+	defer c.Close()
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &reply))
+
+	_, strict := serve(WithSchemaPolicy(SchemaFail))
+	c = NewClientWithCodec(nil, strict, WithSchemaHash(ahead, SchemaIgnore))
+	defer c.Close()
+	err = c.Call("Int.Sum", &Args{A: 1, B: 2}, &reply)
+	var refused *RefusedError
+	assert.True(t, errors.As(err, &refused), "%v", err)
+	assert.Equal(t, "schema hash mismatch", refused.Reason)
+}
+
+func TestSchemaHandshake_unawareServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		p := proto.New()
+		if p.ReadTCP(bufio.NewReader(conn)) != nil {
+			return
+		}
+		p.Op = proto.OpResponse
+		wr := bufio.NewWriter(conn)
+		_ = p.WriteTCP(wr)
+		_ = wr.Flush()
+	}()
+
+	c := NewClientWithCodec(nil, l.Addr().String(), WithSchemaHash(ServiceMap{}, SchemaFail))
+	defer c.Close()
+	var reply int
+	err = c.Call("Int.Sum", &Args{A: 1, B: 2}, &reply)
+	var mismatch *SchemaMismatchError
+	assert.True(t, errors.As(err, &mismatch), "%v", err)
+	assert.Equal(t, "", mismatch.Remote)
+	assert.Contains(t, err.Error(), "remote none")
+}
@@ -0,0 +1,188 @@
+package xrpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+var (
+	errPeerIdle  = errors.New("rpc: connection idle, no heartbeat")
+	errPeerStuck = errors.New("rpc: peer stalled sending a request")
+)
+
+// WithReadTimeouts tells idle connections from stuck peers. A peer which
+// started sending a request must complete it within stuck, else its
+// connection is closed. A connection with no request outstanding is idle
+// and kept open, unless idle is not 0 and nothing, heartbeats included,
+// arrived for that long. See WithHeartbeat.
+func WithReadTimeouts(stuck, idle time.Duration) ServerOption {
+	return func(s *Server) {
+		s.stuckTimeout, s.idleTimeout = stuck, idle
+	}
+}
+
+// readFrame reads the next frame of conn into p. Until its first byte
+// arrives the connection is idle, afterwards a request is outstanding.
+func (s *Server) readFrame(conn net.Conn, rr *bufio.Reader, p *proto.Proto) error {
+	if s.stuckTimeout == 0 && s.idleTimeout == 0 {
+		return p.ReadTCP(rr)
+	}
+	if rr.Buffered() == 0 {
+		var deadline time.Time
+		if s.idleTimeout > 0 {
+			deadline = time.Now().Add(s.idleTimeout)
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return err
+		}
+		if _, err := rr.Peek(1); err != nil {
+			if isTimeout(err) {
+				return errPeerIdle
+			}
+			return err
+		}
+	}
+
+	var deadline time.Time
+	if s.stuckTimeout > 0 {
+		deadline = time.Now().Add(s.stuckTimeout)
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	if err := p.ReadTCP(rr); err != nil {
+		if isTimeout(err) {
+			return errPeerStuck
+		}
+		return err
+	}
+	return nil
+}
+
+// watchConn returns a context canceled if the peer resets conn, as nobody
+// will read the replies then. A peer closing in order only closed its
+// write side as far as the server knows, so it still gets them. rr must
+// not be read until stop returns.
+func watchConn(ctx context.Context, conn net.Conn, rr *bufio.Reader) (_ context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	if rr.Buffered() > 0 {
+		// pipelining, so still there
+		return ctx, cancel
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := rr.Peek(1); err != nil && !errors.Is(err, io.EOF) && !isTimeout(err) {
+			cancel()
+		}
+	}()
+	return ctx, func() {
+		_ = conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		_ = conn.SetReadDeadline(time.Time{})
+		cancel()
+	}
+}
+
+// abortConn closes conn with a reset rather than in order, telling the
+// server to drop the call in flight.
+func abortConn(conn net.Conn) {
+	if tc, ok := conn.(interface{ SetLinger(sec int) error }); ok {
+		_ = tc.SetLinger(0)
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// WithHeartbeat sends a heartbeat on the connection once it was idle for
+// interval, so servers with an idle read timeout keep it open and a dead
+// peer is noticed before the next call.
+func WithHeartbeat(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		if interval > 0 {
+			c.heartbeat = &heartbeat{interval: interval, stop: make(chan struct{})}
+		}
+	}
+}
+
+type heartbeat struct {
+	interval time.Duration
+	lastUsed int64 // unix nanoseconds of the latest round trip, atomic
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func (h *heartbeat) used() {
+	atomic.StoreInt64(&h.lastUsed, time.Now().UnixNano())
+}
+
+func (h *heartbeat) idle() bool {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&h.lastUsed))) >= h.interval
+}
+
+func (h *heartbeat) close() {
+	h.once.Do(func() { close(h.stop) })
+}
+
+func (c *Client) heartbeatLoop() {
+	ticker := time.NewTicker(c.heartbeat.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.heartbeat.stop:
+			return
+		case <-ticker.C:
+			c.sendHeartbeat()
+		}
+	}
+}
+
+// sendHeartbeat round trips a heartbeat on the connection if it's idle,
+// dropping the connection if it fails. Any frame answers it, as servers
+// unaware of heartbeats reply with an error.
+func (c *Client) sendHeartbeat() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn := c.tcpConn
+	if conn == nil || !c.heartbeat.idle() {
+		return
+	}
+	c.heartbeat.used()
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		c.close(err)
+		return
+	}
+	wr := bufio.NewWriter(conn)
+	p := proto.New()
+	p.Op = proto.OpHeartbeat
+	if c.protoVersion != 0 {
+		p.Ver = c.protoVersion
+	}
+	err := p.WriteTCP(wr)
+	if err == nil {
+		err = wr.Flush()
+	}
+	if err == nil {
+		err = p.ReadTCP(bufio.NewReader(conn))
+	}
+	if err == nil {
+		err = closeFrameErr(p)
+	}
+	if err != nil {
+		c.close(err)
+	}
+}
@@ -0,0 +1,127 @@
+package xrpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_ReadTimeouts(t *testing.T) {
+	s := NewServerWithCodec(nil, WithReadTimeouts(50*time.Millisecond, 0))
+	addr := startServer(t, s)
+
+	// idle for longer than the stuck timeout, then a heartbeat
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	time.Sleep(150 * time.Millisecond)
+	wr := bufio.NewWriter(conn)
+	p := proto.New()
+	p.Op = proto.OpHeartbeat
+	assert.Nil(t, p.WriteTCP(wr))
+	assert.Nil(t, wr.Flush())
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	assert.Nil(t, p.ReadTCP(bufio.NewReader(conn)))
+	assert.Equal(t, proto.OpHeartbeat, p.Op)
+
+	// stalling in the middle of a frame
+	stuck, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stuck.Close()
+	_, _ = stuck.Write([]byte{0, 0})
+	_ = stuck.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = stuck.Read(make([]byte, 1))
+	assert.False(t, isTimeout(err), "connection of a stuck peer left open")
+}
+
+func TestClient_Heartbeat(t *testing.T) {
+	s := NewServerWithCodec(nil, WithReadTimeouts(time.Second, 100*time.Millisecond))
+	assert.Nil(t, s.Register(new(Int)))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr, WithHeartbeat(20*time.Millisecond))
+	defer c.Close()
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	conns := s.Channelz().Connections
+	time.Sleep(300 * time.Millisecond)
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	if assert.Len(t, conns, 1) {
+		assert.Equal(t, conns[0].ID, s.Channelz().Connections[0].ID, "connection replaced")
+	}
+
+	// without heartbeats, the idle connection is closed
+	quiet, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer quiet.Close()
+	_ = quiet.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = quiet.Read(make([]byte, 1))
+	assert.False(t, isTimeout(err), "idle connection left open")
+}
+
+// Blocker waits for its call to be canceled, for up to 200ms.
+type Blocker struct {
+	canceled chan struct{}
+}
+
+func (b *Blocker) Wait(ctx context.Context, args *int, reply *bool) error {
+	select {
+	case <-ctx.Done():
+		b.canceled <- struct{}{}
+		return ctx.Err()
+	case <-time.After(200 * time.Millisecond):
+		*reply = true
+		return nil
+	}
+}
+
+func TestServer_callCanceledOnReset(t *testing.T) {
+	b := &Blocker{canceled: make(chan struct{}, 1)}
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(b))
+	addr := startServer(t, s)
+
+	// a client giving up resets the connection
+	c := NewClientWithCodec(nil, addr, WithTimeout(50*time.Millisecond))
+	defer c.Close()
+	var done bool
+	err := c.Call("Blocker.Wait", new(int), &done)
+	assert.True(t, errors.Is(err, ErrTimeout))
+	select {
+	case <-b.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("call of an abandoned connection not canceled")
+	}
+
+	// one closing its write side still gets the reply
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	codec := NewGobCodec()
+	reqs := []Request{codec.NewRequest("Blocker.Wait", new(int))}
+	p := proto.New()
+	p.Body, _ = codec.EncodeRequests(&reqs)
+	wr := bufio.NewWriter(conn)
+	assert.Nil(t, p.WriteTCP(wr))
+	assert.Nil(t, wr.Flush())
+	assert.Nil(t, conn.(*net.TCPConn).CloseWrite())
+	assert.Nil(t, p.ReadTCP(bufio.NewReader(conn)))
+	resps, err := codec.ReadResponse(p.Body)
+	assert.Nil(t, err)
+	assert.Nil(t, resps[0].DecodeInto(&done))
+	assert.True(t, done)
+}
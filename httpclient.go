@@ -0,0 +1,86 @@
+package xrpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// isHTTPAddr reports whether the client posts its calls to addr over HTTP,
+// e.g. "https://node.example.com/rpc", instead of using TCP frames.
+func isHTTPAddr(addr string) bool {
+	return strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://")
+}
+
+// WithHTTPClient sets the HTTP client used for http:// and https://
+// addresses. Defaults to a client dialing through the client's dialer.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+func (c *Client) newHTTPClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: c.dial,
+	}}
+}
+
+// callHTTP is like callTcp, posting reqs to the URL of the client.
+func (c *Client) callHTTP(ctx context.Context, reqs []Request, resps *[]Response) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	body, err := c.codec.EncodeRequests(&reqs)
+	if err != nil {
+		return err
+	}
+	var contentType string
+	if ct, ok := c.codec.(interface{ ContentType() string }); ok {
+		contentType = ct.ContentType()
+	}
+	if attachments, ok := ctx.Value(outgoingAttachmentsKey{}).([]Attachment); ok {
+		if body, contentType, err = encodeMultipart(body, contentType, attachments); err != nil {
+			return err
+		}
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tcpAddr, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return c.ctxErr(ctxErr)
+		}
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK && len(bytes.TrimSpace(data)) == 0 {
+		return fmt.Errorf("rpc: unexpected HTTP status %s", httpResp.Status)
+	}
+
+	if c.validate != nil {
+		if err = c.validate(reqs, data); err != nil {
+			return err
+		}
+	}
+	*resps, err = c.codec.ReadResponse(data)
+	return err
+}
@@ -0,0 +1,47 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_CallHTTP(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := NewClientWithCodec(nil, srv.URL)
+	defer c.Close()
+	assert.Nil(t, c.WaitForReady(context.Background()))
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+
+	err := c.Call("Int.Missing", &Args{}, &sum)
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+}
+
+func TestClient_CallHTTPTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClientWithCodec(nil, srv.URL, WithTimeout(20*time.Millisecond))
+	defer c.Close()
+
+	var sum int
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrTimeout))
+}
@@ -0,0 +1,61 @@
+package xrpc
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WithHTTPGet serves the read-only methods over HTTP GET too, for browsers,
+// cURL and simple webhooks, e.g.
+//
+//	GET /rpc?method=Int.Sum&params={"a":1,"b":2}
+//
+// params, which may be left out, are in JSON, so only codecs of JSON
+// bodies serve GET. POST stays the contract of every method.
+func WithHTTPGet(methods ...string) ServerOption {
+	return func(s *Server) {
+		if s.getMethods == nil {
+			s.getMethods = make(map[string]bool)
+		}
+		for _, method := range methods {
+			s.getMethods[method] = true
+		}
+	}
+}
+
+// readGetRequest returns the request encoded in the query of req.
+func (s *Server) readGetRequest(req *http.Request) ([]Request, *Error) {
+	codec, ok := s.codec.(ClientCodec)
+	if !ok || s.codec.ContentType() != "application/json" {
+		return nil, &Error{ErrCode: MethodNotFound, ErrMsg: "method not allowed: " + req.Method}
+	}
+	query := req.URL.Query()
+	method := query.Get("method")
+	if method == "" {
+		return nil, &Error{ErrCode: InvalidRequest, ErrMsg: "rpc: missing method in query"}
+	}
+	if !s.getMethods[method] && !s.getMethods[s.resolveAlias(method)] {
+		return nil, &Error{ErrCode: MethodNotFound, ErrMsg: "rpc: " + method + " is not served over GET"}
+	}
+
+	var params RawMessage
+	if p := query.Get("params"); p != "" {
+		if !json.Valid([]byte(p)) {
+			return nil, &Error{ErrCode: ParseErr, ErrMsg: "rpc: params in query are not JSON"}
+		}
+		params = RawMessage(p)
+	}
+	rpcReq := codec.NewRequest(method, params)
+	if rpcReq == nil {
+		return nil, &Error{ErrCode: InternalErr, ErrMsg: "rpc: could not encode request " + method}
+	}
+	data, err := codec.EncodeRequests(&[]Request{rpcReq})
+	if err != nil {
+		return nil, &Error{ErrCode: InternalErr, ErrMsg: err.Error()}
+	}
+	reqs, err := s.codec.ReadRequest(data)
+	if err != nil {
+		return nil, &Error{ErrCode: ParseErr, ErrMsg: err.Error()}
+	}
+	return reqs, nil
+}
@@ -0,0 +1,63 @@
+package xrpc
+
+import "context"
+
+// CallInfo describes the request seen by an Interceptor.
+type CallInfo struct {
+	Method   string
+	Metadata Metadata
+	Peer     string
+}
+
+// Invoker calls the method with the decoded args and stores the result in
+// reply, which is always a pointer to the reply value.
+type Invoker func(ctx context.Context, args, reply interface{}) error
+
+// Interceptor wraps the invocation of a method. It may reject the call by
+// returning an error (an *Error keeps its code), or pass a derived context
+// to next, e.g. with WithPrincipal, for context-aware methods to read.
+type Interceptor func(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error
+
+// WithInterceptors appends interceptors to the server; the first one is
+// the outermost.
+func WithInterceptors(interceptors ...Interceptor) ServerOption {
+	return func(s *Server) {
+		s.interceptors = append(s.interceptors, interceptors...)
+	}
+}
+
+func chainInterceptors(interceptors []Interceptor, info *CallInfo, invoke Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoke
+		invoke = func(ctx context.Context, args, reply interface{}) error {
+			return interceptor(ctx, info, args, reply, next)
+		}
+	}
+	return invoke
+}
+
+// ClientInvoker does the call of a Client.
+type ClientInvoker func(ctx context.Context, method string, args, reply interface{}, opts ...CallOption) error
+
+// ClientInterceptor wraps the calls of a Client made with Call and
+// CallContext. It may fail the call, or pass next a derived context or
+// more options, e.g. WithMetadata.
+type ClientInterceptor func(ctx context.Context, method string, args, reply interface{}, next ClientInvoker, opts ...CallOption) error
+
+// WithClientInterceptors appends interceptors to the client; the first one
+// is the outermost.
+func WithClientInterceptors(interceptors ...ClientInterceptor) ClientOption {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+func chainClientInterceptors(interceptors []ClientInterceptor, invoke ClientInvoker) ClientInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoke
+		invoke = func(ctx context.Context, method string, args, reply interface{}, opts ...CallOption) error {
+			return interceptor(ctx, method, args, reply, next, opts...)
+		}
+	}
+	return invoke
+}
@@ -0,0 +1,93 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Whoami int
+
+func (w *Whoami) Get(ctx context.Context, args *int, reply *string) error {
+	principal, _ := PrincipalFromContext(ctx)
+	tenant, _ := TenantFromContext(ctx)
+	*reply = principal + "@" + tenant
+	if FeatureEnabled(ctx, "shout") {
+		*reply += "!"
+	}
+	return nil
+}
+
+func auth(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error {
+	token := info.Metadata.Get("token")
+	if token == "" {
+		return &Error{ErrCode: InvalidRequest, ErrMsg: "missing token"}
+	}
+	ctx = WithPrincipal(ctx, token)
+	ctx = WithTenant(ctx, MetadataFromContext(ctx).Get("tenant"))
+	return next(ctx, args, reply)
+}
+
+func TestInterceptors(t *testing.T) {
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error {
+			order = append(order, name)
+			return next(ctx, args, reply)
+		}
+	}
+	flags := func(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error {
+		return next(WithFeatureFlags(ctx, "shout"), args, reply)
+	}
+
+	s := NewServerWithCodec(nil, WithInterceptors(trace("a"), trace("b"), auth, flags))
+	assert.Nil(t, s.Register(new(Whoami)))
+	addr := startServer(t, s)
+	c := NewClientWithCodec(nil, addr)
+	defer c.Close()
+
+	var reply string
+	err := c.Call("Whoami.Get", new(int), &reply,
+		WithMetadata(Metadata{"token": "alice", "tenant": "acme"}))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@acme!", reply)
+	assert.Equal(t, []string{"a", "b"}, order)
+
+	err = c.Call("Whoami.Get", new(int), &reply)
+	assert.True(t, errors.Is(err, ErrInvalidRequest))
+}
+
+func TestFeatureFlags(t *testing.T) {
+	ctx := WithFeatureFlags(context.Background(), "a")
+	child := WithFeatureFlags(ctx, "b")
+	assert.True(t, FeatureEnabled(child, "a"))
+	assert.True(t, FeatureEnabled(child, "b"))
+	assert.False(t, FeatureEnabled(ctx, "b"))
+	assert.False(t, FeatureEnabled(context.Background(), "a"))
+}
+
+func TestClientInterceptors(t *testing.T) {
+	s := NewServerWithCodec(nil, WithInterceptors(auth))
+	assert.Nil(t, s.Register(new(Whoami)))
+	addr := startServer(t, s)
+
+	var order []string
+	trace := func(name string) ClientInterceptor {
+		return func(ctx context.Context, method string, args, reply interface{}, next ClientInvoker, opts ...CallOption) error {
+			order = append(order, name+" "+method)
+			return next(ctx, method, args, reply, opts...)
+		}
+	}
+	token := func(ctx context.Context, method string, args, reply interface{}, next ClientInvoker, opts ...CallOption) error {
+		return next(ctx, method, args, reply, append(opts, WithMetadata(Metadata{"token": "bob"}))...)
+	}
+	c := NewClientWithCodec(nil, addr, WithClientInterceptors(trace("a"), trace("b"), token))
+	defer c.Close()
+
+	var reply string
+	assert.Nil(t, c.Call("Whoami.Get", new(int), &reply, WithMetadata(Metadata{"tenant": "acme"})))
+	assert.Equal(t, "bob@acme", reply)
+	assert.Equal(t, []string{"a Whoami.Get", "b Whoami.Get"}, order)
+}
@@ -0,0 +1,265 @@
+package xrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// DurableTag tags the methods whose requests are written to the journal,
+// see WithJournal.
+const DurableTag = "durable"
+
+// defaultJournalCompactSize is the size past which the journal is emptied
+// once no request is pending.
+const defaultJournalCompactSize = 64 << 20
+
+// Journal is a write-ahead log of requests. Requests to durable methods are
+// appended and synced before the method runs, and acknowledged once it
+// returns, so the requests cut short by a crash can be replayed with
+// Server.ReplayJournal: each request runs at least once.
+type Journal struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	size    int64
+	nextID  uint64
+	pending map[uint64]*journalEntry
+}
+
+type journalEntry struct {
+	Op     string   `json:"op"` // "begin" or "ack"
+	ID     uint64   `json:"id"`
+	Method string   `json:"method,omitempty"`
+	Params []byte   `json:"params,omitempty"`
+	Meta   Metadata `json:"meta,omitempty"`
+}
+
+// OpenJournal opens the journal at path, creating it if needed, and loads
+// the requests it holds which were never acknowledged.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{f: f, pending: make(map[uint64]*journalEntry)}
+	if err := j.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	j.w = bufio.NewWriter(f)
+	return j, nil
+}
+
+func (j *Journal) load() error {
+	r := bufio.NewReader(j.f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a torn last entry was never synced, so its request never ran
+			break
+		}
+		if err != nil {
+			return err
+		}
+		j.size += int64(len(line))
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("rpc: corrupt journal entry at offset %d: %v", j.size-int64(len(line)), err)
+		}
+		switch e.Op {
+		case "begin":
+			j.pending[e.ID] = &e
+		case "ack":
+			delete(j.pending, e.ID)
+		}
+		if e.ID >= j.nextID {
+			j.nextID = e.ID + 1
+		}
+	}
+	// drop a torn entry, later entries are appended after the last good one
+	if err := j.f.Truncate(j.size); err != nil {
+		return err
+	}
+	_, err := j.f.Seek(j.size, io.SeekStart)
+	return err
+}
+
+func (j *Journal) append(e *journalEntry, sync bool) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if _, err := j.w.Write(b); err != nil {
+		return err
+	}
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	j.size += int64(len(b))
+	if sync {
+		return j.f.Sync()
+	}
+	return nil
+}
+
+// begin records req before it runs.
+func (j *Journal) begin(method string, req Request) (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e := &journalEntry{Op: "begin", ID: j.nextID, Method: method, Params: req.GetParams(), Meta: req.GetMetadata()}
+	if err := j.append(e, true); err != nil {
+		return 0, err
+	}
+	j.nextID++
+	j.pending[e.ID] = e
+	return e.ID, nil
+}
+
+// ack records that the request id ran. A lost ack only means the request
+// runs again, so it isn't synced.
+func (j *Journal) ack(id uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.append(&journalEntry{Op: "ack", ID: id}, false); err != nil {
+		return err
+	}
+	delete(j.pending, id)
+	if len(j.pending) == 0 && j.size > defaultJournalCompactSize {
+		return j.compact()
+	}
+	return nil
+}
+
+// compact empties the journal, which holds no pending request.
+func (j *Journal) compact() error {
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	j.size = 0
+	return j.f.Sync()
+}
+
+// first returns the oldest pending entry.
+func (j *Journal) first() (journalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var first *journalEntry
+	for _, e := range j.pending {
+		if first == nil || e.ID < first.ID {
+			first = e
+		}
+	}
+	if first == nil {
+		return journalEntry{}, false
+	}
+	return *first, true
+}
+
+// Pending returns the number of requests not acknowledged yet.
+func (j *Journal) Pending() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return len(j.pending)
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	return j.f.Close()
+}
+
+// WithJournal writes the requests to the methods tagged with DurableTag to
+// j before they run.
+func WithJournal(j *Journal) ServerOption {
+	return func(s *Server) {
+		s.journal = j
+	}
+}
+
+// journalRequest is a request read back from the journal.
+type journalRequest struct {
+	id uint64
+	journalEntry
+}
+
+func (r *journalRequest) GetMethod() string       { return r.Method }
+func (r *journalRequest) GetParams() []byte       { return r.Params }
+func (r *journalRequest) GetId() string           { return fmt.Sprintf("journal-%d", r.id) }
+func (r *journalRequest) GetMetadata() Metadata   { return r.Meta }
+func (r *journalRequest) SetMetadata(md Metadata) { r.Meta = md }
+
+type replayKey struct{}
+
+// ReplayJournal runs again, in order, the requests of the journal which
+// were not acknowledged, e.g. after a crash. Call it once the services are
+// registered, before serving. It returns the number of requests replayed;
+// the failures among them are logged.
+func (s *Server) ReplayJournal(ctx context.Context) (int, error) {
+	if s.journal == nil {
+		return 0, nil
+	}
+	s.journal.mu.Lock()
+	reqs := make([]*journalRequest, 0, len(s.journal.pending))
+	for id, e := range s.journal.pending {
+		reqs = append(reqs, &journalRequest{id: id, journalEntry: *e})
+	}
+	s.journal.mu.Unlock()
+	sort.Slice(reqs, func(a, b int) bool { return reqs[a].id < reqs[b].id })
+
+	for i, req := range reqs {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		resp := s.handleRequest(context.WithValue(ctx, replayKey{}, req.id), req)
+		if err := resp.Error(); err != nil {
+			s.logger.Printf("rpc: replayed %s (journal entry %d) failed: %v", req.Method, req.id, err)
+		}
+	}
+	return len(reqs), nil
+}
+
+// journalBegin records req if its method is durable, returning a func to
+// acknowledge it. Replayed requests keep their entry.
+func (s *Server) journalBegin(ctx context.Context, mType *methodType, method string, req Request) (ack func(), err error) {
+	if s.journal == nil || !hasTag(mType, DurableTag) {
+		return func() {}, nil
+	}
+	id, replayed := ctx.Value(replayKey{}).(uint64)
+	if !replayed {
+		if id, err = s.journal.begin(method, req); err != nil {
+			return nil, err
+		}
+	}
+	return func() {
+		if err := s.journal.ack(id); err != nil {
+			s.logger.Printf("rpc: could not acknowledge journal entry %d: %v", id, err)
+		}
+	}, nil
+}
+
+func hasTag(mType *methodType, tag string) bool {
+	for _, t := range mType.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package xrpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Ledger struct {
+	mu      sync.Mutex
+	entries []int
+}
+
+func (l *Ledger) Add(args *int, reply *int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, *args)
+	*reply = len(l.entries)
+	return nil
+}
+
+func TestJournal_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	codec := NewGobCodec()
+
+	j, err := OpenJournal(path)
+	assert.Nil(t, err)
+	ledger := new(Ledger)
+	s := NewServerWithCodec(codec, WithJournal(j))
+	assert.Nil(t, s.Register(ledger, WithMethodTags("Add", DurableTag)))
+	c := NewClientWithCodec(codec, startServer(t, s))
+	defer c.Close()
+
+	var n int
+	assert.Nil(t, c.Call("Ledger.Add", 1, &n))
+	assert.Equal(t, 0, j.Pending())
+
+	// requests cut short by a crash: journaled, never acknowledged
+	for _, v := range []int{2, 3} {
+		_, err = j.begin("Ledger.Add", codec.NewRequest("Ledger.Add", v))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, j.Close())
+
+	// a torn entry left by the crash
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	_, _ = f.WriteString(`{"op":"begin","id":9`)
+	f.Close()
+
+	j, err = OpenJournal(path)
+	assert.Nil(t, err)
+	defer j.Close()
+	assert.Equal(t, 2, j.Pending())
+
+	restarted := new(Ledger)
+	s = NewServerWithCodec(codec, WithJournal(j))
+	assert.Nil(t, s.Register(restarted, WithMethodTags("Add", DurableTag)))
+	replayed, err := s.ReplayJournal(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, replayed)
+	assert.Equal(t, []int{2, 3}, restarted.entries)
+	assert.Equal(t, 0, j.Pending())
+
+	// new entries don't reuse the ids of old ones
+	id, err := j.begin("Ledger.Add", codec.NewRequest("Ledger.Add", 4))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), id)
+}
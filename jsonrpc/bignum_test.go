@@ -0,0 +1,70 @@
+package jsonrpc
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type Ledger struct{}
+
+type Transfer struct {
+	Wei    xrpc.BigInt  `json:"wei"`
+	Amount xrpc.Decimal `json:"amount"`
+}
+
+func (l *Ledger) Double(t *Transfer, reply *Transfer) error {
+	reply.Wei.Add(&t.Wei.Int, &t.Wei.Int)
+	reply.Amount = xrpc.NewDecimal(new(big.Int).Lsh(t.Amount.Unscaled(), 1), t.Amount.Scale())
+	return nil
+}
+
+func TestBigNum_overJSON(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec())
+	assert.Nil(t, s.Register(new(Ledger)))
+
+	// bare numbers beyond the precision of a float64 are kept
+	body := `{"jsonrpc":"2.0","id":"1","method":"Ledger.Double","params":{"wei":9007199254740993,"amount":0.10000000000000000001}}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"result":{"wei":"18014398509481986","amount":"0.20000000000000000002"}`)
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	c := xrpc.NewClientWithCodec(NewJSONCodec(), srv.URL)
+	defer c.Close()
+
+	wei, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	amount, _ := xrpc.ParseDecimal("19.99")
+	var reply Transfer
+	assert.Nil(t, c.Call("Ledger.Double", &Transfer{Wei: xrpc.NewBigInt(wei), Amount: amount}, &reply))
+	assert.Equal(t, "246913578024691357802469135780", reply.Wei.String())
+	assert.Equal(t, "39.98", reply.Amount.String())
+}
+
+func TestJsonCodec_NumbersStayFloat64(t *testing.T) {
+	codec := NewJSONCodec()
+	reqs, err := codec.ReadRequest([]byte(`{"jsonrpc":"2.0","id":"1","method":"M.N","params":{"small":1.5,"big":9007199254740993}}`))
+	assert.Nil(t, err)
+	args := reqs[0].(*jsonRequest).Args.(map[string]interface{})
+	assert.Equal(t, 1.5, args["small"])
+	assert.Equal(t, float64(9007199254740992), args["big"])
+	assert.JSONEq(t, `{"small":1.5,"big":9007199254740993}`, string(reqs[0].GetParams()))
+
+	var out map[string]interface{}
+	assert.Nil(t, codec.ReadRequestBody(reqs[0].GetParams(), &out))
+	assert.IsType(t, float64(0), out["big"])
+
+	resps, err := codec.ReadResponse([]byte(`{"jsonrpc":"2.0","id":"1","result":[1,12345678901234567890]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1.0, 12345678901234567890.0}, resps[0].GetResult())
+	var wei []xrpc.BigInt
+	assert.Nil(t, resps[0].DecodeInto(&wei))
+	assert.Equal(t, "12345678901234567890", wei[1].String())
+}
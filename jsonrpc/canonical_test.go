@@ -0,0 +1,41 @@
+package jsonrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	in := `{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001, -0, 1e21, 1e-7],
+		"string": "€$\u000F\u000aA'B\"\\\"\/<>&",
+		"literals": [null, true, false],
+		"€": 1, "\r": 2, "1": 3, "😀": 4, "\u0080": 5, "ö": 6, "\ufb33": 7
+	}`
+	b, err := Canonicalize([]byte(in))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"\r":2,"1":3,"literals":[null,true,false],`+
+		`"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27,0,1e+21,1e-7],`+
+		`"string":"€$\u000f\nA'B\"\\\"/<>&","`+"\u0080"+`":5,"ö":6,"€":1,"😀":4,"`+"\ufb33"+`":7}`, string(b))
+
+	_, err = Canonicalize([]byte(`{"big": 1e400}`))
+	assert.NotNil(t, err)
+	_, err = Canonicalize([]byte(`{} x`))
+	assert.NotNil(t, err)
+}
+
+func TestCanonicalJSONCodec(t *testing.T) {
+	codec := NewCanonicalJSONCodec()
+	resp := codec.NewResponse(map[string]interface{}{"z": 1.50, "a": "<b>"})
+	resp.(*jsonResponse).SetReqId("7")
+	b, err := codec.EncodeResponses(resp)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"7","jsonrpc":"2.0","result":{"a":"<b>","z":1.5}}`, string(b))
+
+	resps, err := codec.ReadResponse(b)
+	assert.Nil(t, err)
+	var out map[string]interface{}
+	assert.Nil(t, resps[0].DecodeInto(&out))
+	assert.Equal(t, "<b>", out["a"])
+}
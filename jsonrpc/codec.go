@@ -5,9 +5,12 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
 	"math/rand"
 	"net/http"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/dabao-zhao/xrpc"
@@ -17,6 +20,8 @@ var (
 	_ xrpc.Request  = &jsonRequest{}
 	_ xrpc.Response = &jsonResponse{}
 	_ xrpc.Codec    = &jsonCodec{}
+
+	_ xrpc.ResponseReleaser = &jsonCodec{}
 )
 
 const (
@@ -26,16 +31,28 @@ const (
 	lenReqId   = 8
 )
 
+func init() {
+	xrpc.RegisterCodec("json", NewJSONCodec)
+}
+
 type jsonRequest struct {
-	Id      string      `json:"id"`
-	Method  string      `json:"method"`
-	Args    interface{} `json:"params"`
-	Version string      `json:"jsonrpc"`
+	Id      string        `json:"id"`
+	Method  string        `json:"method"`
+	Args    interface{}   `json:"params"`
+	Meta    xrpc.Metadata `json:"meta,omitempty"`
+	Version string        `json:"jsonrpc"`
+
+	params json.RawMessage // as sent, if Args rounded its numbers
 }
 
-func (j *jsonRequest) GetId() string     { return j.Id }
-func (j *jsonRequest) GetMethod() string { return j.Method }
+func (j *jsonRequest) GetId() string                { return j.Id }
+func (j *jsonRequest) GetMethod() string            { return j.Method }
+func (j *jsonRequest) GetMetadata() xrpc.Metadata   { return j.Meta }
+func (j *jsonRequest) SetMetadata(md xrpc.Metadata) { j.Meta = md }
 func (j *jsonRequest) GetParams() []byte {
+	if j.params != nil {
+		return j.params
+	}
 	b, err := json.Marshal(j.Args)
 	if err != nil {
 		panic(err)
@@ -44,15 +61,30 @@ func (j *jsonRequest) GetParams() []byte {
 }
 
 type jsonResponse struct {
-	Id      string      `json:"id"`
-	Err     *xrpc.Error `json:"error,omitempty"`
-	Result  interface{} `json:"result,omitempty"`
-	Version string      `json:"jsonrpc"`
+	Id      string        `json:"id"`
+	Err     *xrpc.Error   `json:"error,omitempty"`
+	Result  interface{}   `json:"result,omitempty"`
+	Meta    xrpc.Metadata `json:"meta,omitempty"`
+	Version string        `json:"jsonrpc"`
+
+	lenient bool            // decode the result without rejecting unknown fields
+	reply   json.RawMessage // as sent, if Result rounded its numbers
 }
 
-func (j *jsonResponse) SetReqId(id string) { j.Id = id }
-func (j *jsonResponse) Error() error       { return j.Err }
+func (j *jsonResponse) SetReqId(id string)           { j.Id = id }
+func (j *jsonResponse) GetReqId() string             { return j.Id }
+func (j *jsonResponse) GetMetadata() xrpc.Metadata   { return j.Meta }
+func (j *jsonResponse) SetMetadata(md xrpc.Metadata) { j.Meta = md }
+func (j *jsonResponse) Error() error {
+	if j.Err == nil {
+		return nil
+	}
+	return j.Err
+}
 func (j *jsonResponse) GetReply() []byte {
+	if j.reply != nil {
+		return j.reply
+	}
 	b, err := json.Marshal(j.Result)
 	if err != nil {
 		panic(err)
@@ -62,36 +94,72 @@ func (j *jsonResponse) GetReply() []byte {
 func (j *jsonResponse) GetResult() interface{} {
 	return j.Result
 }
-func (j *jsonResponse) GetErrCode() int {
+func (j *jsonResponse) DecodeInto(out interface{}) error {
+	if j.lenient {
+		return json.Unmarshal(j.GetReply(), out)
+	}
+	return decode(j.GetReply(), out)
+}
+func (j *jsonResponse) GetErrCode() xrpc.Code {
 	if j.Err == nil {
 		return xrpc.Success
 	}
 	return j.Err.ErrCode
 }
+func (j *jsonResponse) Reset() { *j = jsonResponse{} }
+
+var responsePool = sync.Pool{
+	New: func() interface{} { return new(jsonResponse) },
+}
 
 type jsonCodec struct {
+	interop   bool
+	canonical bool
 }
 
 func NewJSONCodec() xrpc.Codec {
 	return &jsonCodec{}
 }
 
+// NewInteropJSONCodec returns a JSON codec for third-party JSON-RPC servers,
+// e.g. Ethereum or Bitcoin nodes. Their responses may carry integer or null
+// ids, omit the version, add unknown fields, or answer a batch with a single
+// object, which the default codec rejects in part.
+func NewInteropJSONCodec() xrpc.Codec {
+	return &jsonCodec{interop: true}
+}
+
 func (j *jsonCodec) encode(argv interface{}) ([]byte, error) {
-	return json.Marshal(argv)
+	b, err := json.Marshal(argv)
+	if err != nil || !j.canonical {
+		return b, err
+	}
+	return Canonicalize(b)
 }
 
 func (j *jsonCodec) decode(data []byte, out interface{}) error {
+	if j.interop {
+		return json.Unmarshal(data, out)
+	}
+	return decode(data, out)
+}
+
+// decodeMessage decodes the request or response data into out, keeping
+// the numbers of params and results as json.Number, see settleNumbers.
+func (j *jsonCodec) decodeMessage(data []byte, out interface{}) error {
+	if j.interop {
+		return decodeLenient(data, out)
+	}
 	dec := json.NewDecoder(bytes.NewBuffer(data))
+	dec.UseNumber()
 	dec.DisallowUnknownFields()
 	return dec.Decode(out)
 }
 
 func (j *jsonCodec) NewResponse(reply interface{}) xrpc.Response {
-	resp := &jsonResponse{
-		Version: version,
-		Id:      "",
-		Result:  reply,
-	}
+	resp := responsePool.Get().(*jsonResponse)
+	resp.Version = version
+	resp.Result = reply
 
 	return resp
 }
@@ -108,17 +176,26 @@ func (j *jsonCodec) NewRequest(method string, argv interface{}) xrpc.Request {
 }
 
 func (j *jsonCodec) ReadResponse(data []byte) (resps []xrpc.Response, err error) {
-	jsonResps := make([]*jsonResponse, 0)
-	if err = j.decode(data, &jsonResps); err != nil {
+	if j.interop {
+		return readInteropResponse(data)
+	}
+	// servers may answer a batch of one with a bare object
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '[' {
 		resp := new(jsonResponse)
-		if err = j.decode(data, resp); err != nil {
+		if err = j.decodeMessage(data, resp); err != nil {
 			return nil, err
 		}
+		resp.settle()
 		resps = append(resps, resp)
 		return resps, nil
 	}
+	jsonResps := make([]*jsonResponse, 0)
+	if err = j.decodeMessage(data, &jsonResps); err != nil {
+		return nil, err
+	}
 
 	for _, jsonResp := range jsonResps {
+		jsonResp.settle()
 		resps = append(resps, jsonResp)
 	}
 
@@ -126,29 +203,54 @@ func (j *jsonCodec) ReadResponse(data []byte) (resps []xrpc.Response, err error)
 }
 
 func (j *jsonCodec) ReadRequest(data []byte) (reqs []xrpc.Request, err error) {
-	jsonReqs := make([]*jsonRequest, 0)
-	if err = j.decode(data, &jsonReqs); err != nil {
+	var elems []json.RawMessage
+	if err = json.Unmarshal(data, &elems); err != nil {
 		req := new(jsonRequest)
-		if err = j.decode(data, req); err != nil {
+		if err = j.decodeMessage(data, req); err != nil {
 			return nil, err
 		}
+		req.settle()
 		reqs = append(reqs, req)
 		return reqs, nil
 	}
 
-	for _, jsonReq := range jsonReqs {
-		reqs = append(reqs, jsonReq)
+	// an invalid element is answered at its position, the others are
+	// served
+	for _, elem := range elems {
+		req := new(jsonRequest)
+		if err := j.decodeMessage(elem, req); err != nil {
+			reqs = append(reqs, &xrpc.MalformedRequest{Id: elementId(elem), Err: err})
+			continue
+		}
+		req.settle()
+		reqs = append(reqs, req)
 	}
 
 	return reqs, nil
 }
 
+// elementId returns the id of a malformed batch element, if it has one.
+func elementId(elem json.RawMessage) string {
+	var probe struct {
+		Id interface{} `json:"id"`
+	}
+	if json.Unmarshal(elem, &probe) != nil {
+		return ""
+	}
+	id, _ := probe.Id.(string)
+	return id
+}
+
 func (j *jsonCodec) ReadRequestBody(data []byte, out interface{}) error {
 	var v interface{}
 	err := json.Unmarshal(data, &v)
 	if err != nil {
 		return err
 	}
+	// null or missing params leave the args zero
+	if v == nil {
+		return nil
+	}
 	typeOfV := reflect.TypeOf(v)
 	typeOfO := reflect.TypeOf(out)
 	if typeOfV.Kind() == reflect.Ptr {
@@ -159,11 +261,12 @@ func (j *jsonCodec) ReadRequestBody(data []byte, out interface{}) error {
 		typeOfO = typeOfO.Elem()
 	}
 	if typeOfV.Kind() == reflect.Slice && typeOfO.Kind() != reflect.Slice {
-		args := v.([]interface{})
-		data, err = json.Marshal(args[0])
-		if err != nil {
+		// the first param as sent, v rounded its numbers
+		var args []json.RawMessage
+		if err = json.Unmarshal(data, &args); err != nil {
 			return err
 		}
+		data = args[0]
 	}
 
 	return j.decode(data, out)
@@ -181,35 +284,76 @@ func (j *jsonCodec) EncodeResponses(v interface{}) ([]byte, error) {
 	return j.encode(v)
 }
 
-func (j *jsonCodec) ErrResponse(errCode int, err error) xrpc.Response {
-	errMsg := ""
-	if err != nil {
+func (j *jsonCodec) ErrResponse(errCode xrpc.Code, err error) xrpc.Response {
+	errMsg := xrpc.CodeMessage(errCode)
+	var data interface{}
+	if rpcErr, ok := err.(*xrpc.Error); ok {
+		errMsg, data = rpcErr.ErrMsg, rpcErr.Data
+	} else if err != nil {
 		errMsg = err.Error()
 	}
 
-	return &jsonResponse{
-		Err: &xrpc.Error{
-			ErrCode: errCode,
-			ErrMsg:  errMsg,
-		},
-		Version: version,
+	resp := responsePool.Get().(*jsonResponse)
+	resp.Err = &xrpc.Error{
+		ErrCode: errCode,
+		ErrMsg:  errMsg,
+		Data:    data,
 	}
+	resp.Version = version
+	return resp
 }
 
+func (j *jsonCodec) ReleaseResponse(resp xrpc.Response) {
+	if r, ok := resp.(*jsonResponse); ok {
+		r.Reset()
+		responsePool.Put(r)
+	}
+}
+
+func (j *jsonCodec) ContentType() string { return "application/json" }
+
 func (j *jsonCodec) Send(w http.ResponseWriter, statusCode int, b []byte) error {
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", j.ContentType())
 	w.WriteHeader(statusCode)
 	_, err := w.Write(b)
 	return err
 }
 
+func decode(data []byte, out interface{}) error {
+	dec := json.NewDecoder(bytes.NewBuffer(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(out)
+}
+
+// decodeLenient is like json.Unmarshal, decoding numbers into interfaces
+// as json.Number.
+func decodeLenient(data []byte, out interface{}) error {
+	dec := json.NewDecoder(bytes.NewBuffer(data))
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// idRand is shared, since sources seeded with the time on each call gave
+// requests created together the same id.
+var (
+	idRandMu sync.Mutex
+	idRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
 func randId() string {
 	bs := []byte(baseStr)
 	result := make([]byte, 0, lenReqId)
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	idRandMu.Lock()
 	for i := 0; i < lenReqId; i++ {
-		result = append(result, bs[r.Intn(baseStrLen)])
+		result = append(result, bs[idRand.Intn(baseStrLen)])
 	}
+	idRandMu.Unlock()
 	m := md5.New()
 	m.Write(result)
 	return hex.EncodeToString(m.Sum(nil))
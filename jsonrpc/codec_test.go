@@ -3,6 +3,7 @@ package jsonrpc
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/dabao-zhao/xrpc"
@@ -87,6 +88,18 @@ func TestJsonCodec_NewRequest(t *testing.T) {
 	assert.Equal(t, b, req.GetParams())
 
 	assert.NotEqual(t, "", req.GetId())
+
+	ids := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := codec.NewRequest(method, arg).GetId()
+		assert.False(t, ids[id], "duplicate id %s", id)
+		ids[id] = true
+	}
+
+	c := xrpc.NewClientWithCodec(codec, "127.0.0.1:1")
+	var sums []int
+	err := c.CallBatch([]xrpc.Request{req, req}, &sums)
+	assert.EqualError(t, err, fmt.Sprintf("rpc: duplicate request id %q in batch", req.GetId()))
 }
 
 func TestJsonCodec_ReadResponse(t *testing.T) {
@@ -107,6 +120,12 @@ func TestJsonCodec_ReadResponse(t *testing.T) {
 	resps, err = codec.ReadResponse(b)
 	assert.Nil(t, err)
 	assert.Equal(t, []xrpc.Response{resp}, resps)
+
+	// a bad element is reported as such, not as a bad object
+	_, err = codec.ReadResponse([]byte(` [{"jsonrpc": "2.0", "id": "1", "bogus": 1}]`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "bogus")
+	}
 }
 
 func TestJsonCodec_ReadRequest(t *testing.T) {
@@ -131,6 +150,30 @@ func TestJsonCodec_ReadRequest(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, []xrpc.Request{req}, requests)
 
+	// malformed elements stand in for themselves
+	requests, err = codec.ReadRequest([]byte(`[1, {"id": "2", "method": "Int.Sum", "bogus": true}, ` + string(b[1:])))
+	assert.Nil(t, err)
+	if assert.Len(t, requests, 3) {
+		assert.IsType(t, &xrpc.MalformedRequest{}, requests[0])
+		assert.Equal(t, "2", requests[1].GetId())
+		assert.IsType(t, &xrpc.MalformedRequest{}, requests[1])
+		assert.Equal(t, req, requests[2])
+	}
+
+	_, err = codec.ReadRequest([]byte(`[{"id": "1"}`))
+	assert.NotNil(t, err)
+}
+
+func TestJsonCodec_ReadRequestMetadata(t *testing.T) {
+	codec := NewJSONCodec()
+
+	req := codec.NewRequest("Int.Sum", "arg")
+	req.SetMetadata(xrpc.Metadata{"tenant": "a"})
+	b, _ := json.Marshal(req)
+
+	requests, err := codec.ReadRequest(b)
+	assert.Nil(t, err)
+	assert.Equal(t, xrpc.Metadata{"tenant": "a"}, requests[0].GetMetadata())
 }
 
 func TestJsonCodec_ReadRequestBody(t *testing.T) {
@@ -162,6 +205,10 @@ func TestJsonCodec_ReadRequestBody(t *testing.T) {
 	err = codec.ReadRequestBody(req.GetParams(), out)
 	assert.Nil(t, err)
 	assert.Equal(t, &arg2[0], out)
+
+	out = new(Args)
+	assert.Nil(t, codec.ReadRequestBody([]byte("null"), out))
+	assert.Equal(t, new(Args), out)
 }
 
 func TestJsonCodec_ReadResponseBody(t *testing.T) {
@@ -176,6 +223,23 @@ func TestJsonCodec_ReadResponseBody(t *testing.T) {
 	assert.Equal(t, &data, out)
 }
 
+func TestJsonResponse_DecodeInto(t *testing.T) {
+	codec := NewJSONCodec()
+
+	type Reply struct {
+		A int `json:"a"`
+	}
+	b, _ := json.Marshal(codec.NewResponse(&Reply{A: 1}))
+	resps, err := codec.ReadResponse(b)
+	assert.Nil(t, err)
+
+	out := new(Reply)
+	assert.Nil(t, resps[0].DecodeInto(out))
+	assert.Equal(t, &Reply{A: 1}, out)
+
+	assert.NotNil(t, resps[0].DecodeInto(new(string)))
+}
+
 func TestJsonCodec_EncodeRequests(t *testing.T) {
 	codec := NewJSONCodec()
 	data := "1"
@@ -199,7 +263,7 @@ func TestJsonCodec_EncodeResponses(t *testing.T) {
 func TestJsonCodec_ErrResponse(t *testing.T) {
 	codec := NewJSONCodec()
 
-	errCode := 1
+	errCode := xrpc.Code(1)
 	err := errors.New("1")
 	resp := codec.ErrResponse(errCode, err)
 
@@ -210,6 +274,64 @@ func TestJsonCodec_ErrResponse(t *testing.T) {
 	assert.Equal(t, errCode, resp.GetErrCode())
 }
 
+func TestJsonCodec_ErrResponseNilErr(t *testing.T) {
+	codec := NewJSONCodec()
+
+	resp := codec.ErrResponse(xrpc.InvalidRequest, nil)
+	assert.Equal(t, &xrpc.Error{
+		ErrCode: xrpc.InvalidRequest,
+		ErrMsg:  xrpc.CodeMessage(xrpc.InvalidRequest),
+	}, resp.Error())
+}
+
+func TestJsonCodec_RawMessage(t *testing.T) {
+	codec := NewJSONCodec()
+
+	req := codec.NewRequest("Int.Sum", xrpc.RawMessage(`{"A":1,"B":2}`))
+	assert.JSONEq(t, `{"A":1,"B":2}`, string(req.GetParams()))
+
+	var raw xrpc.RawMessage
+	assert.Nil(t, codec.ReadRequestBody(req.GetParams(), &raw))
+	assert.JSONEq(t, `{"A":1,"B":2}`, string(raw))
+
+	b, err := codec.EncodeResponses(codec.NewResponse(raw))
+	assert.Nil(t, err)
+	resps, err := codec.ReadResponse(b)
+	assert.Nil(t, err)
+	var out map[string]int
+	assert.Nil(t, resps[0].DecodeInto(&out))
+	assert.Equal(t, map[string]int{"A": 1, "B": 2}, out)
+}
+
+func TestJsonCodec_ErrResponseData(t *testing.T) {
+	codec := NewJSONCodec()
+
+	data := []xrpc.FieldError{{Field: "q", Reason: "is required"}}
+	resp := codec.ErrResponse(xrpc.InvalidParamErr, &xrpc.Error{
+		ErrCode: xrpc.InvalidParamErr,
+		ErrMsg:  "missing q",
+		Data:    data,
+	})
+	assert.Equal(t, &xrpc.Error{
+		ErrCode: xrpc.InvalidParamErr,
+		ErrMsg:  "missing q",
+		Data:    data,
+	}, resp.Error())
+}
+
 func TestJsonCodec_Send(t *testing.T) {
 
 }
+
+func TestJsonCodec_ReleaseResponse(t *testing.T) {
+	codec := NewJSONCodec().(*jsonCodec)
+
+	resp := codec.ErrResponse(xrpc.InternalErr, errors.New("boom"))
+	resp.SetReqId("1")
+	codec.ReleaseResponse(resp)
+	assert.Equal(t, &jsonResponse{}, resp)
+
+	resp = codec.NewResponse("data")
+	assert.Nil(t, resp.Error())
+	assert.Equal(t, "data", resp.GetResult())
+}
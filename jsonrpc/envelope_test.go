@@ -0,0 +1,40 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec(), xrpc.WithEnvelope())
+	assert.Nil(t, s.Register(new(Geo)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+
+	c := xrpc.NewClientWithCodec(NewJSONCodec(), l.Addr().String())
+	defer c.Close()
+
+	var (
+		p   Point
+		env xrpc.ReplyEnvelope
+	)
+	assert.Nil(t, c.Call("Geo.Add", xrpc.Positional{&Point{1, 2}, &Point{3, 4}}, &p,
+		xrpc.WithEnvelopeInto(&env), xrpc.WithMetadata(xrpc.Metadata{xrpc.TraceIDKey: "t1"})))
+	assert.Equal(t, Point{4, 6}, p)
+	assert.Equal(t, "t1", env.TraceID)
+
+	// the contract as any client sees it
+	var raw map[string]json.RawMessage
+	assert.Nil(t, c.Call("Geo.Add", xrpc.Positional{&Point{1, 2}, &Point{3, 4}}, &raw))
+	assert.JSONEq(t, `{"x": 4, "y": 6}`, string(raw["result"]))
+	assert.Contains(t, raw, "duration_ms")
+	assert.NotContains(t, raw, "trace_id")
+}
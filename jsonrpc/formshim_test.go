@@ -0,0 +1,116 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_FormShim(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec())
+	assert.Nil(t, s.Register(new(Geo)))
+	h := s.FormShim(xrpc.FormShimConfig{AllowedOrigins: []string{"https://legacy.example.com"}})
+
+	post := func(origin string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/rpc/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	call := `{"jsonrpc":"2.0","id":"1","method":"Geo.Norm","params":{"x":3,"y":-4}}`
+
+	w := post("https://legacy.example.com", url.Values{"request": {call}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result":7`)
+	assert.Equal(t, "https://legacy.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = post("https://evil.example.com", url.Values{"request": {call}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = post("", url.Values{"request": {call}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = post("https://legacy.example.com", url.Values{"request": {`{"jsonrpc":`}})
+	assert.Contains(t, w.Body.String(), `"code":-32700`)
+
+	w = post("https://legacy.example.com", url.Values{"request": {call}, "post_message": {"https://legacy.example.com"}})
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `parent.postMessage({"id":"1"`)
+	assert.Contains(t, w.Body.String(), `"result":7,"jsonrpc":"2.0"}, "https://legacy.example.com");`)
+
+	w = post("https://legacy.example.com", url.Values{"request": {call}, "post_message": {"*"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = post("https://legacy.example.com", url.Values{"request": {call}, "post_message": {"https://evil.example.com"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req := httptest.NewRequest(http.MethodOptions, "/rpc/form", nil)
+	req.Header.Set("Origin", "https://legacy.example.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestWritePostMessage_escapesScript(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec())
+	assert.Nil(t, s.Register(new(Geo)))
+	h := s.FormShim(xrpc.FormShimConfig{AllowedOrigins: []string{"*"}})
+
+	form := url.Values{
+		"request":      {`{"jsonrpc":"2.0","id":"</script><script>alert(1)</script>","method":"Geo.Norm","params":{}}`},
+		"post_message": {"https://legacy.example.com"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/rpc/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://legacy.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), "</script>"))
+}
+
+type Visits struct {
+	n int
+}
+
+func (v *Visits) Add(p *Point, reply *int) error {
+	v.n++
+	*reply = v.n
+	return nil
+}
+
+func TestServer_FormShim_disallowedOriginNotCalled(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec())
+	visits := new(Visits)
+	assert.Nil(t, s.Register(visits))
+	h := s.FormShim(xrpc.FormShimConfig{AllowedOrigins: []string{"https://legacy.example.com"}})
+
+	post := func(header, value string) int {
+		form := url.Values{"request": {`{"jsonrpc":"2.0","id":"1","method":"Visits.Add","params":{}}`}}
+		req := httptest.NewRequest(http.MethodPost, "/rpc/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, post("Origin", "https://evil.example.com"))
+	assert.Equal(t, http.StatusForbidden, post("Origin", "null"))
+	assert.Equal(t, http.StatusForbidden, post("Referer", "https://evil.example.com/legacy.example.com"))
+	assert.Equal(t, http.StatusForbidden, post("", ""))
+	assert.Equal(t, 0, visits.n)
+
+	assert.Equal(t, http.StatusOK, post("Referer", "https://legacy.example.com/page?x=1"))
+	assert.Equal(t, 1, visits.n)
+}
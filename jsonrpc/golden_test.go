@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func checkGolden(t *testing.T, name string, b []byte) []byte {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(golden), string(b), "wire format of %s changed", name)
+	return golden
+}
+
+func TestJsonCodec_Golden(t *testing.T) {
+	codec := NewJSONCodec()
+
+	reqs := []xrpc.Request{&jsonRequest{
+		Id:      "1",
+		Method:  "Int.Sum",
+		Args:    map[string]interface{}{"A": float64(1), "B": float64(2)},
+		Meta:    xrpc.Metadata{"k": "v"},
+		Version: version,
+	}}
+	b, err := codec.EncodeRequests(reqs)
+	assert.Nil(t, err)
+	golden := checkGolden(t, "requests.golden", b)
+	decoded, err := codec.ReadRequest(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, reqs, decoded)
+
+	resps := []xrpc.Response{
+		&jsonResponse{Id: "1", Result: float64(3), Version: version},
+		&jsonResponse{Id: "2", Err: &xrpc.Error{ErrCode: xrpc.InternalErr, ErrMsg: "boom"}, Version: version},
+	}
+	b, err = codec.EncodeResponses(resps)
+	assert.Nil(t, err)
+	golden = checkGolden(t, "responses.golden", b)
+	decodedResps, err := codec.ReadResponse(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, resps, decodedResps)
+}
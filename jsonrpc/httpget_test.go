@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func (g *Geo) Norm(p *Point, reply *int) error {
+	*reply = abs(p.X) + abs(p.Y)
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func TestServer_HTTPGet(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec(), xrpc.WithHTTPGet("Geo.Norm"), xrpc.WithCacheableMethods("Geo.Norm"))
+	assert.Nil(t, s.Register(new(Geo)))
+
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rpc?"+query.Encode(), nil))
+		return w
+	}
+
+	w := get(url.Values{"method": {"Geo.Norm"}, "params": {`{"x": -1, "y": 2}`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result":3`)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	w = get(url.Values{"method": {"Geo.Norm"}})
+	assert.Contains(t, w.Body.String(), `"result":0`)
+
+	w = get(url.Values{"method": {"Geo.Add"}, "params": {`[{}, {}]`}})
+	assert.Contains(t, w.Body.String(), `"code":-32601`)
+	assert.Contains(t, w.Body.String(), "Geo.Add is not served over GET")
+
+	w = get(url.Values{"method": {"Geo.Norm"}, "params": {`{"x": `}})
+	assert.Contains(t, w.Body.String(), `"code":-32700`)
+
+	w = get(url.Values{})
+	assert.Contains(t, w.Body.String(), `"code":-32600`)
+
+	// off by default
+	s = xrpc.NewServerWithCodec(NewJSONCodec())
+	assert.Nil(t, s.Register(new(Geo)))
+	w = get(url.Values{"method": {"Geo.Norm"}})
+	assert.Contains(t, w.Body.String(), "method not allowed: GET")
+}
@@ -0,0 +1,66 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+func init() {
+	xrpc.RegisterCodec("json-interop", NewInteropJSONCodec)
+}
+
+// interopResponse accepts any JSON value as id.
+type interopResponse struct {
+	jsonResponse
+	Id json.RawMessage `json:"id"`
+}
+
+func (r *interopResponse) response() *jsonResponse {
+	resp := r.jsonResponse
+	resp.Id = interopId(r.Id)
+	resp.lenient = true
+	resp.settle()
+	return &resp
+}
+
+// interopId returns the id as sent by this package: strings unquoted,
+// numbers as is, and null as "".
+func interopId(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	if n, err := strconv.ParseFloat(string(raw), 64); err == nil {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return string(raw)
+}
+
+func readInteropResponse(data []byte) ([]xrpc.Response, error) {
+	data = bytes.TrimSpace(data)
+	var raws []*interopResponse
+	if len(data) > 0 && data[0] == '[' {
+		if err := decodeLenient(data, &raws); err != nil {
+			return nil, err
+		}
+	} else {
+		raw := new(interopResponse)
+		if err := decodeLenient(data, raw); err != nil {
+			return nil, err
+		}
+		raws = append(raws, raw)
+	}
+
+	resps := make([]xrpc.Response, 0, len(raws))
+	for _, raw := range raws {
+		resps = append(resps, raw.response())
+	}
+	return resps, nil
+}
@@ -0,0 +1,105 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInteropCodec_ReadResponse(t *testing.T) {
+	codec := NewInteropJSONCodec()
+
+	resps, err := codec.ReadResponse([]byte(`[
+		{"id":1,"result":{"number":"0x1b4","extra":true}},
+		{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"header not found"}},
+		{"id":"a","result":null}]`))
+	assert.Nil(t, err)
+	assert.Len(t, resps, 3)
+
+	var block struct {
+		Number string `json:"number"`
+	}
+	assert.Nil(t, resps[0].DecodeInto(&block))
+	assert.Equal(t, "0x1b4", block.Number)
+	assert.Equal(t, "1", resps[0].(*jsonResponse).Id)
+	assert.Equal(t, xrpc.Code(-32000), resps[1].GetErrCode())
+	assert.Equal(t, "", resps[1].(*jsonResponse).Id)
+	assert.Equal(t, "a", resps[2].(*jsonResponse).Id)
+
+	resps, err = codec.ReadResponse([]byte(`{"id":7,"result":"0x1"}`))
+	assert.Nil(t, err)
+	assert.Len(t, resps, 1)
+
+	_, err = NewJSONCodec().ReadResponse([]byte(`{"id":7,"result":"0x1"}`))
+	assert.NotNil(t, err)
+}
+
+func TestInteropCodec_HTTP(t *testing.T) {
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		contentType = req.Header.Get("Content-Type")
+		_, _ = io.ReadAll(req.Body)
+		// a bare object answering a batch of one, with an integer id
+		_, _ = w.Write([]byte(`{"id":1,"jsonrpc":"2.0","result":"0x10"}`))
+	}))
+	defer srv.Close()
+
+	c := xrpc.NewClientWithCodec(NewInteropJSONCodec(), srv.URL)
+	defer c.Close()
+
+	var number string
+	assert.Nil(t, c.Call("eth_blockNumber", []interface{}{}, &number))
+	assert.Equal(t, "0x10", number)
+	assert.Equal(t, "application/json", contentType)
+}
+
+func TestCallBatch_Reordered(t *testing.T) {
+	codec := NewJSONCodec()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		var reqs []jsonRequest
+		_ = json.Unmarshal(body, &reqs)
+		// answered last to first
+		var resps []map[string]interface{}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resps = append(resps, map[string]interface{}{"jsonrpc": "2.0", "id": reqs[i].Id, "result": reqs[i].Args})
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	c := xrpc.NewClientWithCodec(codec, srv.URL)
+	defer c.Close()
+
+	var results []int
+	assert.Nil(t, c.CallBatch([]xrpc.Request{
+		codec.NewRequest("Echo", 1),
+		codec.NewRequest("Echo", 2),
+		codec.NewRequest("Echo", 3),
+	}, &results))
+	assert.Equal(t, []int{1, 2, 3}, results)
+}
+
+func TestCallBatch_BareObject(t *testing.T) {
+	for _, codec := range []xrpc.Codec{NewJSONCodec(), NewInteropJSONCodec()} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			var reqs []jsonRequest
+			_ = json.Unmarshal(body, &reqs)
+			// a bare object answering a batch of one
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": reqs[0].Id, "result": 7})
+		}))
+
+		c := xrpc.NewClientWithCodec(codec, srv.URL)
+		var results []int
+		assert.Nil(t, c.CallBatch([]xrpc.Request{codec.NewRequest("Seven", nil)}, &results))
+		assert.Equal(t, []int{7}, results)
+		c.Close()
+		srv.Close()
+	}
+}
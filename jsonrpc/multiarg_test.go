@@ -0,0 +1,47 @@
+package jsonrpc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Geo int
+
+func (g *Geo) Add(a, b *Point, reply *Point) error {
+	*reply = Point{X: a.X + b.X, Y: a.Y + b.Y}
+	return nil
+}
+
+func TestMultiArgMethod(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec())
+	assert.Nil(t, s.Register(new(Geo)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+
+	c := xrpc.NewClientWithCodec(NewJSONCodec(), l.Addr().String())
+	defer c.Close()
+
+	var p Point
+	assert.Nil(t, c.Call("Geo.Add", xrpc.Positional{&Point{1, 2}, &Point{3, 4}}, &p))
+	assert.Equal(t, Point{4, 6}, p)
+
+	// positional params as any client sends them
+	assert.Nil(t, c.Call("Geo.Add", []interface{}{map[string]int{"x": 1}, map[string]int{"y": 1}}, &p))
+	assert.Equal(t, Point{1, 1}, p)
+
+	err = c.Call("Geo.Add", &Point{1, 2}, &p)
+	assert.True(t, errors.Is(err, xrpc.ErrInvalidParams))
+}
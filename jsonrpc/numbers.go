@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Params and results are decoded with their numbers as json.Number, then
+// settled into the float64s handlers and clients get from json.Unmarshal.
+// A message whose numbers don't survive as float64s keeps its params or
+// result as sent, so typed args and replies, e.g. xrpc.BigInt and
+// xrpc.Decimal, still decode them exactly.
+
+func (j *jsonRequest) settle() {
+	if !numbersExact(j.Args) {
+		j.params, _ = json.Marshal(j.Args)
+	}
+	j.Args = settleNumbers(j.Args)
+}
+
+func (j *jsonResponse) settle() {
+	if !numbersExact(j.Result) {
+		j.reply, _ = json.Marshal(j.Result)
+	}
+	j.Result = settleNumbers(j.Result)
+}
+
+// numbersExact reports whether the numbers of v are all float64s.
+func numbersExact(v interface{}) bool {
+	switch v := v.(type) {
+	case json.Number:
+		return numberExact(v)
+	case []interface{}:
+		for _, elem := range v {
+			if !numbersExact(elem) {
+				return false
+			}
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			if !numbersExact(elem) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func numberExact(n json.Number) bool {
+	s := string(n)
+	if len(s) <= 15 && !strings.ContainsAny(s, ".eE") {
+		return true // small integers
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+	sent, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return false
+	}
+	// float64s encode as their shortest representation
+	got, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return got != nil && sent.Cmp(got) == 0
+}
+
+// settleNumbers replaces the json.Numbers of v by float64s, in place.
+func settleNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = settleNumbers(elem)
+		}
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = settleNumbers(elem)
+		}
+	}
+	return v
+}
@@ -0,0 +1,90 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+// ErrInvalidResponse is wrapped by the errors of StrictResponses.
+var ErrInvalidResponse = errors.New("jsonrpc: invalid response")
+
+// StrictResponses checks responses against the JSON-RPC 2.0 spec: each one
+// must carry "jsonrpc": "2.0", echo the id of a request, and hold either a
+// result or an error object. Use it with xrpc.WithResponseValidator when
+// talking to third-party servers.
+func StrictResponses(reqs []xrpc.Request, data []byte) error {
+	data = bytes.TrimSpace(data)
+	var raws []json.RawMessage
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+	} else {
+		raws = []json.RawMessage{data}
+	}
+	if len(raws) != len(reqs) {
+		return fmt.Errorf("%w: got %d responses to %d requests", ErrInvalidResponse, len(raws), len(reqs))
+	}
+
+	ids := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		id, _ := json.Marshal(req.GetId())
+		ids[string(id)] = true
+	}
+	for i, raw := range raws {
+		if err := checkResponse(raw, ids); err != nil {
+			return fmt.Errorf("%w: response %d: %v", ErrInvalidResponse, i, err)
+		}
+	}
+	return nil
+}
+
+func checkResponse(raw json.RawMessage, ids map[string]bool) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	if v := string(fields["jsonrpc"]); v != `"2.0"` {
+		return fmt.Errorf("jsonrpc is %s, want \"2.0\"", orMissing(v))
+	}
+	id, ok := fields["id"]
+	if !ok {
+		return errors.New("id is missing")
+	}
+	if !ids[string(id)] {
+		return fmt.Errorf("id %s matches no request", id)
+	}
+	// each id is answered once
+	delete(ids, string(id))
+
+	_, hasResult := fields["result"]
+	errObj, hasErr := fields["error"]
+	if hasResult == hasErr {
+		return errors.New("exactly one of result and error must be set")
+	}
+	if hasResult {
+		return nil
+	}
+	var e struct {
+		Code    *int    `json:"code"`
+		Message *string `json:"message"`
+	}
+	if err := json.Unmarshal(errObj, &e); err != nil {
+		return fmt.Errorf("error is not an object: %v", err)
+	}
+	if e.Code == nil || e.Message == nil {
+		return errors.New("error must have a code and a message")
+	}
+	return nil
+}
+
+func orMissing(v string) string {
+	if v == "" {
+		return "missing"
+	}
+	return v
+}
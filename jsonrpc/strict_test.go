@@ -0,0 +1,80 @@
+package jsonrpc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictResponses(t *testing.T) {
+	codec := NewJSONCodec()
+	req := codec.NewRequest("Int.Sum", 1)
+	id := `"` + req.GetId() + `"`
+	reqs := []xrpc.Request{req}
+
+	valid := []string{
+		`{"jsonrpc":"2.0","id":` + id + `,"result":3}`,
+		`{"jsonrpc":"2.0","id":` + id + `,"result":null}`,
+		`{"jsonrpc":"2.0","id":` + id + `,"error":{"code":-32601,"message":"nope"}}`,
+		`[{"jsonrpc":"2.0","id":` + id + `,"result":3}]`,
+	}
+	for _, data := range valid {
+		assert.Nil(t, StrictResponses(reqs, []byte(data)), data)
+	}
+
+	invalid := []string{
+		`{"id":` + id + `,"result":3}`,
+		`{"jsonrpc":"1.0","id":` + id + `,"result":3}`,
+		`{"jsonrpc":"2.0","result":3}`,
+		`{"jsonrpc":"2.0","id":"other","result":3}`,
+		`{"jsonrpc":"2.0","id":` + id + `}`,
+		`{"jsonrpc":"2.0","id":` + id + `,"result":3,"error":{"code":1,"message":"x"}}`,
+		`{"jsonrpc":"2.0","id":` + id + `,"error":{"message":"x"}}`,
+		`[]`,
+		`not json`,
+	}
+	for _, data := range invalid {
+		err := StrictResponses(reqs, []byte(data))
+		assert.True(t, errors.Is(err, ErrInvalidResponse), data)
+	}
+
+	other := codec.NewRequest("Int.Sum", 2)
+	batch := []xrpc.Request{req, other}
+	assert.Nil(t, StrictResponses(batch, []byte(`[
+		{"jsonrpc":"2.0","id":"`+other.GetId()+`","result":1},
+		{"jsonrpc":"2.0","id":`+id+`,"result":2}]`)))
+	assert.NotNil(t, StrictResponses(batch, []byte(`[
+		{"jsonrpc":"2.0","id":`+id+`,"result":1},
+		{"jsonrpc":"2.0","id":`+id+`,"result":2}]`)))
+}
+
+type Int struct{}
+
+func (i *Int) Double(args *int, reply *int) error {
+	*reply = *args * 2
+	return nil
+}
+
+func TestStrictResponses_Server(t *testing.T) {
+	s := xrpc.NewServerWithCodec(NewJSONCodec())
+	assert.Nil(t, s.Register(new(Int)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+
+	c := xrpc.NewClientWithCodec(NewJSONCodec(), l.Addr().String(), xrpc.WithResponseValidator(StrictResponses))
+	defer c.Close()
+
+	var reply int
+	assert.Nil(t, c.Call("Int.Double", 2, &reply))
+	assert.Equal(t, 4, reply)
+
+	err = c.Call("Int.Missing", 2, &reply)
+	assert.True(t, errors.Is(err, xrpc.ErrMethodNotFound))
+}
@@ -0,0 +1,335 @@
+// Package k8s resolves Kubernetes Services into xrpc server addresses by
+// reading their EndpointSlices from the API server.
+package k8s
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+var _ xrpc.Resolver = &Resolver{}
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	serviceNameLabel  = "kubernetes.io/service-name"
+
+	watchMinBackoff = 100 * time.Millisecond
+	watchMaxBackoff = 30 * time.Second
+)
+
+// Resolver lists the ready endpoints of a Service. Its Watch follows
+// topology changes as the API server streams them.
+type Resolver struct {
+	apiServer string
+	token     string
+	client    *http.Client
+
+	namespace string
+	service   string
+	portName  string
+}
+
+// NewResolver resolves service in namespace through the API server at
+// apiServer. portName selects the EndpointSlice port; it may be empty when
+// the Service exposes a single port.
+func NewResolver(apiServer, token string, client *http.Client, namespace, service, portName string) *Resolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Resolver{
+		apiServer: apiServer,
+		token:     token,
+		client:    client,
+		namespace: namespace,
+		service:   service,
+		portName:  portName,
+	}
+}
+
+// NewInClusterResolver is NewResolver using the pod's service account.
+func NewInClusterResolver(namespace, service, portName string) (*Resolver, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("k8s: not running in a cluster")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("k8s: invalid ca.crt")
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = string(ns)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	return NewResolver("https://"+net.JoinHostPort(host, port), string(token), client, namespace, service, portName), nil
+}
+
+type endpointSlice struct {
+	Metadata  objectMeta `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name *string `json:"name"`
+		Port *int32  `json:"port"`
+	} `json:"ports"`
+}
+
+type objectMeta struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type endpointSliceList struct {
+	Metadata objectMeta      `json:"metadata"`
+	Items    []endpointSlice `json:"items"`
+}
+
+// watchEvent is an event of a watch stream. Object is an EndpointSlice, or
+// a Status for ERROR events.
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// errExpired ends a watch whose resource version the API server no longer
+// has, which takes a new list.
+var errExpired = errors.New("k8s: resource version expired")
+
+func (r *Resolver) Resolve(ctx context.Context) ([]string, error) {
+	list, err := r.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.addrs(list.Items)
+}
+
+// Watch calls fn with the ready endpoints of the Service, e.g.
+// MultiClient.SetAddrs, then again whenever they change, until ctx is
+// done. It lists the EndpointSlices, then follows a watch stream from the
+// version listed, listing again when the stream ends on an expired
+// version or fails. Resolutions without any ready endpoint keep the
+// current addresses. It blocks, so run it in a goroutine.
+func (r *Resolver) Watch(ctx context.Context, fn func(addrs []string)) {
+	backoff := time.Duration(0)
+	for ctx.Err() == nil {
+		err := r.watch(ctx, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errExpired) {
+			backoff = 0
+			continue
+		}
+		log.Printf("k8s: watch %s/%s err=%v", r.namespace, r.service, err)
+		if backoff == 0 {
+			backoff = watchMinBackoff
+		} else if backoff *= 2; backoff > watchMaxBackoff {
+			backoff = watchMaxBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+}
+
+// watch lists the slices, then applies the events of watch streams until
+// one fails.
+func (r *Resolver) watch(ctx context.Context, fn func(addrs []string)) error {
+	list, err := r.list(ctx)
+	if err != nil {
+		return err
+	}
+	slices := make(map[string]endpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		slices[slice.Metadata.Name] = slice
+	}
+	r.apply(slices, fn)
+
+	version := list.Metadata.ResourceVersion
+	for {
+		if err := r.stream(ctx, &version, slices, fn); err != nil {
+			return err
+		}
+		// the API server ended the stream after its timeout: resume
+	}
+}
+
+// stream applies the events of a watch stream from *version to slices,
+// keeping *version at the last seen, until the stream ends.
+func (r *Resolver) stream(ctx context.Context, version *string, slices map[string]endpointSlice, fn func(addrs []string)) error {
+	query := url.Values{
+		"labelSelector":       {serviceNameLabel + "=" + r.service},
+		"watch":               {"true"},
+		"resourceVersion":     {*version},
+		"allowWatchBookmarks": {"true"},
+	}
+	resp, err := r.get(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return errExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("k8s: watch endpointslices got status %s", resp.Status)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev watchEvent
+		if err := dec.Decode(&ev); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if ev.Type == "ERROR" {
+			var st status
+			_ = json.Unmarshal(ev.Object, &st)
+			if st.Code == http.StatusGone {
+				return errExpired
+			}
+			return fmt.Errorf("k8s: watch endpointslices: %s", st.Message)
+		}
+
+		var slice endpointSlice
+		if err := json.Unmarshal(ev.Object, &slice); err != nil {
+			return err
+		}
+		if slice.Metadata.ResourceVersion != "" {
+			*version = slice.Metadata.ResourceVersion
+		}
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			slices[slice.Metadata.Name] = slice
+		case "DELETED":
+			delete(slices, slice.Metadata.Name)
+		default: // BOOKMARK
+			continue
+		}
+		r.apply(slices, fn)
+	}
+}
+
+// apply calls fn with the addresses of slices, unless there are none.
+func (r *Resolver) apply(slices map[string]endpointSlice, fn func(addrs []string)) {
+	items := make([]endpointSlice, 0, len(slices))
+	for _, slice := range slices {
+		items = append(items, slice)
+	}
+	addrs, err := r.addrs(items)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	fn(addrs)
+}
+
+func (r *Resolver) list(ctx context.Context) (*endpointSliceList, error) {
+	resp, err := r.get(ctx, url.Values{"labelSelector": {serviceNameLabel + "=" + r.service}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("k8s: list endpointslices got status %s", resp.Status)
+	}
+
+	list := new(endpointSliceList)
+	if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Resolver) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	u := fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s",
+		r.apiServer, url.PathEscape(r.namespace), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	return r.client.Do(req)
+}
+
+// addrs returns the ready endpoints of slices, sorted.
+func (r *Resolver) addrs(slices []endpointSlice) ([]string, error) {
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, slice := range slices {
+		port := ""
+		for _, p := range slice.Ports {
+			name := ""
+			if p.Name != nil {
+				name = *p.Name
+			}
+			if p.Port != nil && (name == r.portName || len(slice.Ports) == 1 && r.portName == "") {
+				port = strconv.Itoa(int(*p.Port))
+				break
+			}
+		}
+		if port == "" {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			// a nil ready condition means ready
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				if addr := net.JoinHostPort(addr, port); !seen[addr] {
+					seen[addr] = true
+					addrs = append(addrs, addr)
+				}
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("k8s: no ready endpoints for %s/%s", r.namespace, r.service)
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
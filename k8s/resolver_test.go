@@ -0,0 +1,129 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sliceList = `{
+  "items": [
+    {
+      "endpoints": [
+        {"addresses": ["10.0.0.2"], "conditions": {"ready": true}},
+        {"addresses": ["10.0.0.3"], "conditions": {"ready": false}},
+        {"addresses": ["10.0.0.1"], "conditions": {}}
+      ],
+      "ports": [{"name": "xrpc", "port": 9999}, {"name": "http", "port": 9998}]
+    },
+    {
+      "endpoints": [{"addresses": ["10.0.0.4"]}],
+      "ports": [{"name": "http", "port": 9998}]
+    }
+  ]
+}`
+
+func TestResolver_Resolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices", req.URL.Path)
+		assert.Equal(t, "kubernetes.io/service-name=int", req.URL.Query().Get("labelSelector"))
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(sliceList))
+	}))
+	defer srv.Close()
+
+	r := NewResolver(srv.URL, "token", nil, "default", "int", "xrpc")
+	addrs, err := r.Resolve(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:9999", "10.0.0.2:9999"}, addrs)
+
+	r = NewResolver(srv.URL, "token", nil, "default", "int", "grpc")
+	_, err = r.Resolve(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestResolver_ResolveStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	r := NewResolver(srv.URL, "", nil, "default", "int", "")
+	_, err := r.Resolve(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestResolver_Watch(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		lists   int
+		watches []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		assert.Equal(t, "kubernetes.io/service-name=int", q.Get("labelSelector"))
+		mu.Lock()
+		if q.Get("watch") != "true" {
+			defer mu.Unlock()
+			lists++
+			if lists == 1 {
+				_, _ = w.Write([]byte(`{"metadata": {"resourceVersion": "1"}, "items": [
+					{"metadata": {"name": "a"}, "endpoints": [{"addresses": ["10.0.0.1"]}], "ports": [{"port": 9999}]}]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"metadata": {"resourceVersion": "5"}, "items": [
+					{"metadata": {"name": "b"}, "endpoints": [{"addresses": ["10.0.0.3"]}], "ports": [{"port": 9999}]}]}`))
+			}
+			return
+		}
+
+		watches = append(watches, q.Get("resourceVersion"))
+		mu.Unlock()
+		if q.Get("resourceVersion") != "1" {
+			<-req.Context().Done() // nothing happens
+			return
+		}
+		for _, ev := range []string{
+			`{"type": "MODIFIED", "object": {"metadata": {"name": "a", "resourceVersion": "2"}, "endpoints": [{"addresses": ["10.0.0.2"]}], "ports": [{"port": 9999}]}}`,
+			`{"type": "ADDED", "object": {"metadata": {"name": "b", "resourceVersion": "3"}, "endpoints": [{"addresses": ["10.0.0.3"]}], "ports": [{"port": 9999}]}}`,
+			`{"type": "BOOKMARK", "object": {"metadata": {"resourceVersion": "4"}}}`,
+			`{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}`,
+		} {
+			_, _ = w.Write([]byte(ev + "\n"))
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []string, 10)
+	r := NewResolver(srv.URL, "", nil, "default", "int", "")
+	go r.Watch(ctx, func(addrs []string) { updates <- addrs })
+
+	for _, want := range [][]string{
+		{"10.0.0.1:9999"},
+		{"10.0.0.2:9999"},
+		{"10.0.0.2:9999", "10.0.0.3:9999"},
+		{"10.0.0.3:9999"}, // listed again once the version expired
+	} {
+		select {
+		case addrs := <-updates:
+			assert.Equal(t, want, addrs)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no update %v", want)
+		}
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(watches) == 2
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{"1", "5"}, watches)
+	assert.Equal(t, 2, lists)
+	mu.Unlock()
+}
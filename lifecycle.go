@@ -0,0 +1,99 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// WithListener adds a listener served by Start and Run. protocol is "tcp",
+// "unix" (addr is a socket path) or "http".
+func WithListener(protocol, addr string) ServerOption {
+	return func(s *Server) {
+		s.listeners = append(s.listeners, ListenerConfig{Protocol: protocol, Addr: addr})
+	}
+}
+
+// Start binds every listener of the server, returning the first error,
+// then serves them in the background until ctx is done or one of them
+// fails. Err reports how serving ended; the server may be started again
+// afterwards.
+func (s *Server) Start(ctx context.Context) error {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+
+	if s.running {
+		return errors.New("rpc: server already started")
+	}
+	if len(s.listeners) == 0 {
+		return errors.New("rpc: no listeners configured")
+	}
+
+	var (
+		closers []io.Closer
+		serves  []func() error
+	)
+	for _, l := range s.listeners {
+		network := "tcp"
+		if l.Protocol == "unix" {
+			network = "unix"
+		}
+		listener, err := net.Listen(network, l.Addr)
+		if err != nil {
+			for _, c := range closers {
+				_ = c.Close()
+			}
+			return err
+		}
+		closers = append(closers, listener)
+
+		if l.Protocol == "http" {
+			srv := s.newHTTPServer()
+			closers = append(closers, srv)
+			serves = append(serves, func() error { return s.serveHTTP(srv, listener) })
+			continue
+		}
+		if s.tlsConfig != nil {
+			listener = requireTLS(listener)
+		}
+		serves = append(serves, func() error { return s.serve(listener) })
+	}
+
+	failed := make(chan error, len(serves))
+	for _, serve := range serves {
+		go func(serve func() error) {
+			failed <- serve()
+		}(serve)
+	}
+
+	errCh := make(chan error, 1)
+	s.running, s.errCh = true, errCh
+	go func() {
+		var err error
+		select {
+		case <-ctx.Done():
+		case err = <-failed:
+		}
+		for _, c := range closers {
+			_ = c.Close()
+		}
+
+		s.lifeMu.Lock()
+		s.running = false
+		s.lifeMu.Unlock()
+		errCh <- err
+		close(errCh)
+	}()
+	return nil
+}
+
+// Err returns a channel receiving nil once the server started by Start
+// stops because its context is done, or the error which stopped it. It
+// is nil before the first Start.
+func (s *Server) Err() <-chan error {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+
+	return s.errCh
+}
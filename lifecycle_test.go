@@ -0,0 +1,71 @@
+package xrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenerAddrs waits until the server serves n listeners and returns
+// their addresses by protocol.
+func listenerAddrs(t *testing.T, s *Server, n int) map[string]string {
+	assert.Eventually(t, func() bool {
+		return len(s.Channelz().Listeners) == n
+	}, time.Second, time.Millisecond)
+	addrs := make(map[string]string)
+	for _, l := range s.Channelz().Listeners {
+		addrs[l.Protocol] = l.Addr
+	}
+	return addrs
+}
+
+func TestServer_StartStop(t *testing.T) {
+	s := NewServerWithCodec(nil, WithListener("tcp", "127.0.0.1:0"), WithListener("http", "127.0.0.1:0"))
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.Err())
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		assert.Nil(t, s.Start(ctx))
+		assert.NotNil(t, s.Start(ctx), "already started")
+
+		addrs := listenerAddrs(t, s, 2)
+		c := NewClientWithCodec(nil, addrs["tcp"])
+		var sum int
+		assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+		assert.Equal(t, 3, sum)
+		c.Close()
+
+		cancel()
+		select {
+		case err := <-s.Err():
+			assert.Nil(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("server did not stop")
+		}
+		listenerAddrs(t, s, 0)
+	}
+}
+
+func TestServer_StartBindError(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	s := NewServerWithCodec(nil, WithListener("tcp", "127.0.0.1:0"), WithListener("http", taken.Addr().String()))
+	assert.NotNil(t, s.Start(context.Background()))
+	assert.Nil(t, s.Err())
+	assert.NotNil(t, NewServerWithCodec(nil).Start(context.Background()))
+
+	// the first listener was released
+	s = NewServerWithCodec(nil, WithListener("tcp", "127.0.0.1:0"))
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.Nil(t, s.Start(ctx))
+	cancel()
+	assert.Nil(t, <-s.Err())
+}
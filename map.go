@@ -1,6 +1,8 @@
 package xrpc
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"reflect"
 	"unicode"
@@ -10,20 +12,56 @@ import (
 type methodType struct {
 	method    reflect.Method
 	ArgType   reflect.Type
+	ArgTypes  []reflect.Type // of methods taking several args, in order
 	ReplyType reflect.Type
+	withCtx   bool // method takes a context.Context first
+	returns   bool // method returns the reply instead of filling it
+	doc       MethodDoc
+	tags      []string
+	slo       *sloTracker // nil without an SLO
 }
 
 type service struct {
-	name   string
-	val    reflect.Value
-	typ    reflect.Type
-	method map[string]*methodType
+	name         string
+	val          reflect.Value
+	typ          reflect.Type
+	method       map[string]*methodType
+	registeredAt string   // file:line of the registration
+	internal     bool     // a service of the package, e.g. the blob service
+	factory      *factory // builds the receivers of the calls, instead of val
 }
 
-func (s *service) call(mType *methodType, arg, reply reflect.Value) error {
+// origin describes where the service comes from, for error messages.
+func (s *service) origin() string {
+	return fmt.Sprintf("type %s registered at %s", s.typ, s.registeredAt)
+}
+
+func (s *service) call(ctx context.Context, mType *methodType, args []reflect.Value, reply reflect.Value) error {
 	function := mType.method.Func
-	returnValues := function.Call([]reflect.Value{s.val, arg, reply})
-	if i := returnValues[0].Interface(); i != nil {
+	recv := s.val
+	if s.factory != nil {
+		var (
+			release func()
+			err     error
+		)
+		if recv, release, err = s.factory.receiver(ctx); err != nil {
+			return err
+		}
+		defer release()
+	}
+	in := []reflect.Value{recv}
+	if mType.withCtx {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	in = append(in, args...)
+	if !mType.returns {
+		in = append(in, reply)
+	}
+	returnValues := function.Call(in)
+	if mType.returns {
+		reply.Elem().Set(returnValues[0])
+	}
+	if i := returnValues[len(returnValues)-1].Interface(); i != nil {
 		return i.(error)
 	}
 	return nil
@@ -60,6 +98,13 @@ func suitableMethodWithName(typ reflect.Type, methodName string) *methodType {
 	return nil
 }
 
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+
+	typeOfPositional = reflect.TypeOf(Positional(nil))
+)
+
 func suitableMethod(method reflect.Method) *methodType {
 	mType := method.Type
 	mName := method.Name
@@ -68,19 +113,37 @@ func suitableMethod(method reflect.Method) *methodType {
 	if method.PkgPath != "" {
 		return nil
 	}
-	// Method needs three ins: receiver, *args, *reply.
-	if mType.NumIn() != 3 {
+	// Method needs three ins: receiver, *args, *reply, optionally preceded
+	// by a context.Context. The reply may instead be returned along with
+	// the error, in which case the context is required. Methods taking
+	// several args get them from positional params.
+	withCtx := mType.NumIn() > 1 && mType.In(1) == typeOfContext
+	if withCtx && mType.NumIn() == 3 && mType.NumOut() == 2 {
+		return returningMethod(method)
+	}
+	first := 1
+	if withCtx {
+		first = 2
+	}
+	if mType.NumIn() > first+2 {
+		return multiArgMethod(method, first)
+	}
+	if mType.NumIn() != first+2 {
 		log.Printf("rpc.Register: method %q has %d input parameters; needs exactly three\n", mName, mType.NumIn())
 		return nil
 	}
 	// First arg need not be a pointer.
-	argType := mType.In(1)
+	argType := mType.In(first)
 	if !isExportedOrBuiltinType(argType) {
 		log.Printf("rpc.Register: argument type of method %q is not exported: %q\n", mName, argType)
 		return nil
 	}
+	if _, err := rulesFor(argType); err != nil {
+		log.Printf("rpc.Register: argument type of method %q: %v\n", mName, err)
+		return nil
+	}
 	// Second arg must be a pointer.
-	replyType := mType.In(2)
+	replyType := mType.In(first + 1)
 	if replyType.Kind() != reflect.Ptr {
 		log.Printf("rpc.Register: reply type of method %q is not a pointer: %q\n", mName, replyType)
 		return nil
@@ -96,9 +159,75 @@ func suitableMethod(method reflect.Method) *methodType {
 		return nil
 	}
 	// The return type of the method must be error.
-	if returnType := mType.Out(0); returnType != reflect.TypeOf((*error)(nil)).Elem() {
+	if returnType := mType.Out(0); returnType != typeOfError {
 		log.Printf("rpc.Register: return type of method %q is %q, must be error\n", mName, returnType)
 		return nil
 	}
-	return &methodType{method: method, ArgType: argType, ReplyType: replyType}
+	return &methodType{method: method, ArgType: argType, ReplyType: replyType, withCtx: withCtx}
+}
+
+// multiArgMethod checks a method of the form
+// func (t *T) Method(ctx context.Context, a *A, b *B, reply *R) error,
+// taking its args from positional params. The context is optional.
+func multiArgMethod(method reflect.Method, first int) *methodType {
+	mType := method.Type
+	mName := method.Name
+
+	last := mType.NumIn() - 1
+	var argTypes []reflect.Type
+	for i := first; i < last; i++ {
+		argType := mType.In(i)
+		if !isExportedOrBuiltinType(argType) {
+			log.Printf("rpc.Register: argument type of method %q is not exported: %q\n", mName, argType)
+			return nil
+		}
+		if _, err := rulesFor(argType); err != nil {
+			log.Printf("rpc.Register: argument type of method %q: %v\n", mName, err)
+			return nil
+		}
+		argTypes = append(argTypes, argType)
+	}
+	replyType := mType.In(last)
+	if replyType.Kind() != reflect.Ptr || !isExportedOrBuiltinType(replyType) {
+		log.Printf("rpc.Register: reply type of method %q is not an exported pointer: %q\n", mName, replyType)
+		return nil
+	}
+	if mType.NumOut() != 1 || mType.Out(0) != typeOfError {
+		log.Printf("rpc.Register: method %q must return only an error\n", mName)
+		return nil
+	}
+	return &methodType{
+		method:    method,
+		ArgType:   typeOfPositional,
+		ArgTypes:  argTypes,
+		ReplyType: replyType,
+		withCtx:   first == 2,
+	}
+}
+
+// returningMethod checks a method of the form
+// func (t *T) Method(ctx context.Context, args *A) (*R, error).
+func returningMethod(method reflect.Method) *methodType {
+	mType := method.Type
+	mName := method.Name
+
+	argType := mType.In(2)
+	if !isExportedOrBuiltinType(argType) {
+		log.Printf("rpc.Register: argument type of method %q is not exported: %q\n", mName, argType)
+		return nil
+	}
+	if _, err := rulesFor(argType); err != nil {
+		log.Printf("rpc.Register: argument type of method %q: %v\n", mName, err)
+		return nil
+	}
+	replyType := mType.Out(0)
+	if !isExportedOrBuiltinType(replyType) {
+		log.Printf("rpc.Register: reply type of method %q is not exported: %q\n", mName, replyType)
+		return nil
+	}
+	if returnType := mType.Out(1); returnType != typeOfError {
+		log.Printf("rpc.Register: second return type of method %q is %q, must be error\n", mName, returnType)
+		return nil
+	}
+	return &methodType{method: method, ArgType: argType, ReplyType: replyType, withCtx: true, returns: true}
 }
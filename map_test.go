@@ -0,0 +1,87 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Pair struct {
+	Sum, Product int
+}
+
+type Calc int
+
+func (c *Calc) Both(ctx context.Context, args *Args) (*Pair, error) {
+	return &Pair{Sum: args.A + args.B, Product: args.A * args.B}, nil
+}
+
+func (c *Calc) Keys(ctx context.Context, args *Args) (map[string]int, error) {
+	return map[string]int{"a": args.A, "b": args.B}, nil
+}
+
+func (c *Calc) Fail(ctx context.Context, args *Args) (*Pair, error) {
+	return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: "bad"}
+}
+
+func TestSuitableMethods_Returning(t *testing.T) {
+	methods := suitableMethods(reflect.TypeOf(new(Calc)))
+	if assert.Contains(t, methods, "Both") {
+		assert.True(t, methods["Both"].returns)
+		assert.True(t, methods["Both"].withCtx)
+	}
+	assert.Contains(t, methods, "Keys")
+}
+
+func TestServer_ReturningMethod(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Calc)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var pair Pair
+	assert.Nil(t, c.Call("Calc.Both", &Args{A: 3, B: 4}, &pair))
+	assert.Equal(t, Pair{Sum: 7, Product: 12}, pair)
+
+	var keys map[string]int
+	assert.Nil(t, c.Call("Calc.Keys", &Args{A: 1, B: 2}, &keys))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, keys)
+
+	err := c.Call("Calc.Fail", &Args{}, &pair)
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+}
+
+func (c *Calc) Scale(ctx context.Context, args *Args, k int, reply *Pair) error {
+	reply.Sum = (args.A + args.B) * k
+	reply.Product = args.A * args.B * k
+	return nil
+}
+
+func TestServer_MultiArgMethod(t *testing.T) {
+	methods := suitableMethods(reflect.TypeOf(new(Calc)))
+	if assert.Contains(t, methods, "Scale") {
+		assert.Equal(t, []reflect.Type{reflect.TypeOf(&Args{}), reflect.TypeOf(0)}, methods["Scale"].ArgTypes)
+	}
+
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Calc)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var pair Pair
+	assert.Nil(t, c.Call("Calc.Scale", Positional{&Args{A: 3, B: 4}, 2}, &pair))
+	assert.Equal(t, Pair{Sum: 14, Product: 24}, pair)
+
+	err := c.Call("Calc.Scale", Positional{&Args{A: 3, B: 4}}, &pair)
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+	assert.Contains(t, err.Error(), "takes 2 params, got 1")
+
+	for _, desc := range s.Describe() {
+		if desc.Name == "Calc.Scale" {
+			assert.Equal(t, []Schema{SchemaOf(&Args{}), SchemaOf(0)}, desc.Args)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package xrpc
+
+import "sort"
+
+// Metadata is a set of string key/values sent along with a request.
+type Metadata map[string]string
+
+func (md Metadata) Get(key string) string {
+	return md[key]
+}
+
+// Copy returns a copy of md which is safe to modify.
+func (md Metadata) Copy() Metadata {
+	if md == nil {
+		return nil
+	}
+	out := make(Metadata, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// Join returns md with the entries of others added, later ones winning.
+func (md Metadata) Join(others ...Metadata) Metadata {
+	out := md.Copy()
+	for _, other := range others {
+		for k, v := range other {
+			if out == nil {
+				out = make(Metadata)
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func (md Metadata) keys() []string {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
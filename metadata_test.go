@@ -0,0 +1,22 @@
+package xrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata(t *testing.T) {
+	var md Metadata
+	assert.Nil(t, md.Copy())
+	assert.Equal(t, "", md.Get("a"))
+
+	md = Metadata{"a": "1", "b": "2"}
+	cp := md.Copy()
+	cp["a"] = "3"
+	assert.Equal(t, "1", md.Get("a"))
+
+	assert.Equal(t, Metadata{"a": "3", "b": "2", "c": "4"}, md.Join(cp, Metadata{"c": "4"}))
+	assert.Equal(t, Metadata{"a": "1", "b": "2"}, md)
+	assert.Equal(t, []string{"a", "b"}, md.keys())
+}
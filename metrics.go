@@ -0,0 +1,120 @@
+package xrpc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// unknownLabel stands for the service and method of requests which name no
+// registered method, so clients can't grow the counters without bound.
+const unknownLabel = "unknown"
+
+// CodeCount counts the calls of a method answered with a code, Success
+// included. Requests naming no registered method, and errors answering a
+// whole frame or batch, count under the service and method "unknown".
+type CodeCount struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+	Code    Code   `json:"code"`
+	Count   uint64 `json:"count"`
+}
+
+type codeKey struct {
+	service, method string
+	code            Code
+}
+
+// codeCounters counts the outcomes of calls, lock free once a key exists.
+type codeCounters struct {
+	m sync.Map // codeKey -> *uint64
+}
+
+func (cc *codeCounters) add(service, method string, code Code) {
+	key := codeKey{service: service, method: method, code: code}
+	n, ok := cc.m.Load(key)
+	if !ok {
+		n, _ = cc.m.LoadOrStore(key, new(uint64))
+	}
+	atomic.AddUint64(n.(*uint64), 1)
+}
+
+// CodeCounts returns the counts of the codes answered by the server, sorted
+// by service, method and code.
+func (s *Server) CodeCounts() []CodeCount {
+	var counts []CodeCount
+	s.codeCounts.m.Range(func(k, v interface{}) bool {
+		key := k.(codeKey)
+		counts = append(counts, CodeCount{
+			Service: key.service,
+			Method:  key.method,
+			Code:    key.code,
+			Count:   atomic.LoadUint64(v.(*uint64)),
+		})
+		return true
+	})
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Code > b.Code // Success first, then -32000 and down
+	})
+	return counts
+}
+
+// countCode counts the code answering req.
+func (s *Server) countCode(req Request, code Code) {
+	service, method, _ := s.registeredMethod(req)
+	s.codeCounts.add(service, method, code)
+}
+
+// registeredMethod returns the method req calls, or "unknown" and nil if
+// it names no registered method.
+func (s *Server) registeredMethod(req Request) (serviceName, methodName string, mt *methodType) {
+	name := s.resolveAlias(req.GetMethod())
+	if name == "" {
+		return unknownLabel, unknownLabel, nil
+	}
+	if s.normalizeName != nil {
+		name = s.canonicalMethod(name)
+	}
+	svcName, mName, err := parseFromRPCMethod(name)
+	if err != nil {
+		return unknownLabel, unknownLabel, nil
+	}
+	svc, ok := s.m.Load(svcName)
+	if !ok || svc.(*service).method[mName] == nil {
+		return unknownLabel, unknownLabel, nil
+	}
+	return svcName, mName, svc.(*service).method[mName]
+}
+
+// WriteOpenMetrics writes the code counts and SLO burn rates of the server
+// in the OpenMetrics text format, for scrapers which accept it.
+func (s *Server) WriteOpenMetrics(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# TYPE xrpc_server_calls counter\n")
+	b.WriteString("# HELP xrpc_server_calls Calls answered by the server, by method and code.\n")
+	for _, c := range s.CodeCounts() {
+		fmt.Fprintf(&b, "xrpc_server_calls_total{service=%q,method=%q,code=%q,category=%q} %d\n",
+			c.Service, c.Method, c.Code.String(), c.Code.Category(), c.Count)
+	}
+	if status := s.SLOStatus(); len(status) > 0 {
+		b.WriteString("# TYPE xrpc_server_slo_burn_rate gauge\n")
+		b.WriteString("# HELP xrpc_server_slo_burn_rate Error budget burn rate of methods with an SLO, by window.\n")
+		for _, st := range status {
+			fmt.Fprintf(&b, "xrpc_server_slo_burn_rate{service=%q,method=%q,window=\"5m\"} %g\n", st.Service, st.Method, st.Short)
+			fmt.Fprintf(&b, "xrpc_server_slo_burn_rate{service=%q,method=%q,window=\"1h\"} %g\n", st.Service, st.Method, st.Long)
+		}
+	}
+	b.WriteString("# EOF\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
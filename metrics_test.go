@@ -0,0 +1,43 @@
+package xrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_CodeCounts(t *testing.T) {
+	codec := NewGobCodec()
+	s := NewServerWithCodec(codec)
+	assert.Nil(t, s.Register(new(Int)))
+
+	s.call(context.Background(), []Request{
+		codec.NewRequest("Int.Sum", &Args{A: 1, B: 2}),
+		codec.NewRequest("Int.Sum", &Args{A: 1, B: 2}),
+		codec.NewRequest("Int.Missing", &Args{}),
+		codec.NewRequest("Nope.Missing", &Args{}),
+	})
+	s.call(context.Background(), nil)
+
+	assert.Equal(t, []CodeCount{
+		{Service: "Int", Method: "Sum", Code: Success, Count: 2},
+		{Service: unknownLabel, Method: unknownLabel, Code: InvalidRequest, Count: 1},
+		{Service: unknownLabel, Method: unknownLabel, Code: MethodNotFound, Count: 2},
+	}, s.CodeCounts())
+
+	w := httptest.NewRecorder()
+	s.AdminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, strings.Join([]string{
+		"# TYPE xrpc_server_calls counter",
+		"# HELP xrpc_server_calls Calls answered by the server, by method and code.",
+		`xrpc_server_calls_total{service="Int",method="Sum",code="Success",category="OK"} 2`,
+		`xrpc_server_calls_total{service="unknown",method="unknown",code="InvalidRequest",category="INVALID_ARGUMENT"} 1`,
+		`xrpc_server_calls_total{service="unknown",method="unknown",code="MethodNotFound",category="UNIMPLEMENTED"} 2`,
+		"# EOF",
+		"",
+	}, "\n"), w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "openmetrics")
+}
@@ -0,0 +1,46 @@
+package xrpc
+
+import (
+	"context"
+	"math/rand"
+)
+
+// maxMirrorInFlight bounds the mirrored calls waiting for the shadow, so a
+// slow shadow drops traffic instead of piling up goroutines.
+const maxMirrorInFlight = 64
+
+type mirror struct {
+	addr    string
+	percent float64
+	client  *Client
+	sem     chan struct{}
+}
+
+// WithMirror also sends percent (0-100) of the calls to the shadow server
+// at addr, in the background, ignoring its responses and errors. Calls
+// answered from the cache are not mirrored.
+func WithMirror(addr string, percent float64) ClientOption {
+	return func(c *Client) {
+		c.mirror = &mirror{addr: addr, percent: percent}
+	}
+}
+
+func (m *mirror) init(c *Client) {
+	m.client = NewClientWithCodec(c.codec, m.addr, WithTimeout(c.timeout), WithDialer(c.dialer), WithDialTimeout(c.dialTimeout))
+	m.sem = make(chan struct{}, maxMirrorInFlight)
+}
+
+func (m *mirror) send(req Request) {
+	if rand.Float64()*100 >= m.percent {
+		return
+	}
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-m.sem }()
+		_, _ = m.client.roundTrip(context.Background(), req)
+	}()
+}
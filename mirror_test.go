@@ -0,0 +1,55 @@
+package xrpc
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Tally struct {
+	n int64
+}
+
+func (t *Tally) Add(args *int, reply *int64) error {
+	*reply = atomic.AddInt64(&t.n, int64(*args))
+	return nil
+}
+
+func TestClient_Mirror(t *testing.T) {
+	primary, shadow := new(Tally), new(Tally)
+	ps, ss := NewServerWithCodec(nil), NewServerWithCodec(nil)
+	assert.Nil(t, ps.Register(primary))
+	assert.Nil(t, ss.Register(shadow))
+
+	all := NewClientWithCodec(nil, startServer(t, ps), WithMirror(startServer(t, ss), 100))
+	none := NewClientWithCodec(nil, all.tcpAddr, WithMirror(all.mirror.addr, 0))
+	defer all.Close()
+	defer none.Close()
+
+	var reply int64
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, all.Call("Tally.Add", new(int), &reply))
+		assert.Nil(t, none.Call("Tally.Add", new(int), &reply))
+	}
+	one := 1
+	assert.Nil(t, all.Call("Tally.Add", &one, &reply))
+	assert.Nil(t, none.Call("Tally.Add", &one, &reply))
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&primary.n))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&shadow.n) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestClient_MirrorDown(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s), WithMirror("127.0.0.1:1", 100))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 1}, &sum))
+	assert.Equal(t, 2, sum)
+}
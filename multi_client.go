@@ -0,0 +1,168 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+func NewMultiClientWithCodec(codec ClientCodec, addrs ...string) *MultiClient {
+	if codec == nil {
+		codec = NewGobCodec()
+	}
+
+	m := &MultiClient{
+		codec:   codec,
+		clients: make(map[string]*Client),
+	}
+	m.SetAddrs(addrs)
+	return m
+}
+
+// MultiClient holds one Client per server address.
+type MultiClient struct {
+	codec ClientCodec
+	next  uint32 // round-robin position of Call
+
+	mu       sync.RWMutex
+	addrs    []string
+	clients  map[string]*Client
+	affinity *affinity
+}
+
+type BroadcastResult struct {
+	Addr  string
+	Reply Response // nil if the call failed before a response arrived
+	Err   error
+}
+
+// Addrs returns the current server addresses.
+func (m *MultiClient) Addrs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]string(nil), m.addrs...)
+}
+
+// SetAddrs replaces the server addresses, closing clients of removed ones.
+func (m *MultiClient) SetAddrs(addrs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keep := make(map[string]bool, len(addrs))
+	m.addrs = m.addrs[:0]
+	for _, addr := range addrs {
+		if keep[addr] {
+			continue
+		}
+		keep[addr] = true
+		m.addrs = append(m.addrs, addr)
+		if _, ok := m.clients[addr]; !ok {
+			m.clients[addr] = NewClientWithCodec(m.codec, addr)
+		}
+	}
+	for addr, c := range m.clients {
+		if !keep[addr] {
+			c.Close()
+			delete(m.clients, addr)
+		}
+	}
+}
+
+// Client returns the client of addr, or nil if addr is unknown.
+func (m *MultiClient) Client(addr string) *Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.clients[addr]
+}
+
+func (m *MultiClient) Call(method string, args, reply interface{}, opts ...CallOption) error {
+	return m.CallContext(context.Background(), method, args, reply, opts...)
+}
+
+// CallContext calls the server chosen by WithTarget, or the next server in
+// turn.
+func (m *MultiClient) CallContext(ctx context.Context, method string, args, reply interface{}, opts ...CallOption) (err error) {
+	o := newCallOptions(opts)
+
+	var c *Client
+	var session string
+	if o.target != "" {
+		if c = m.Client(o.target); c == nil {
+			return fmt.Errorf("rpc: unknown target %s", o.target)
+		}
+	} else {
+		m.mu.RLock()
+		a := m.affinity
+		if a != nil {
+			session = o.md.Get(a.key)
+		}
+		if session != "" {
+			if addr, ok := a.get(session, func(addr string) bool { return m.clients[addr] != nil }); ok {
+				c = m.clients[addr]
+			}
+		}
+		if c == nil && len(m.addrs) > 0 {
+			idx := atomic.AddUint32(&m.next, 1) % uint32(len(m.addrs))
+			c = m.clients[m.addrs[idx]]
+			if session != "" {
+				a.set(session, c.tcpAddr)
+			}
+		}
+		m.mu.RUnlock()
+		if c == nil {
+			return errors.New("rpc: no server address")
+		}
+		if session != "" {
+			defer func() {
+				if retryable(err) {
+					a.unpin(session, c.tcpAddr)
+				}
+			}()
+		}
+	}
+
+	resp, err := c.call(ctx, method, args, o)
+	if err != nil {
+		return err
+	}
+	return resp.DecodeInto(reply)
+}
+
+// Broadcast invokes method on every known server concurrently. Results are
+// returned in address order; decode a reply with Reply.DecodeInto.
+func (m *MultiClient) Broadcast(method string, args interface{}) []*BroadcastResult {
+	m.mu.RLock()
+	results := make([]*BroadcastResult, len(m.addrs))
+	clients := make([]*Client, len(m.addrs))
+	for idx, addr := range m.addrs {
+		results[idx] = &BroadcastResult{Addr: addr}
+		clients[idx] = m.clients[addr]
+	}
+	m.mu.RUnlock()
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(clients))
+	for idx, c := range clients {
+		go func(c *Client, res *BroadcastResult) {
+			defer wg.Done()
+			res.Reply, res.Err = c.call(context.Background(), method, args, callOptions{})
+		}(c, results[idx])
+	}
+	wg.Wait()
+	return results
+}
+
+func (m *MultiClient) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for addr, c := range m.clients {
+		c.Close()
+		delete(m.clients, addr)
+	}
+	m.addrs = nil
+}
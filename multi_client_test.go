@@ -0,0 +1,119 @@
+package xrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiClient_Broadcast(t *testing.T) {
+	s1 := NewServerWithCodec(nil)
+	_ = s1.Register(new(Int))
+	s2 := NewServerWithCodec(nil)
+	addr1, addr2 := startServer(t, s1), startServer(t, s2)
+
+	m := NewMultiClientWithCodec(nil, addr1, addr2, addr1)
+	defer m.Close()
+	assert.Equal(t, []string{addr1, addr2}, m.Addrs())
+
+	results := m.Broadcast("Int.Sum", &Args{A: 1, B: 2})
+	assert.Len(t, results, 2)
+
+	assert.Equal(t, addr1, results[0].Addr)
+	assert.Nil(t, results[0].Err)
+	var sum int
+	assert.Nil(t, results[0].Reply.DecodeInto(&sum))
+	assert.Equal(t, 3, sum)
+
+	assert.Equal(t, addr2, results[1].Addr)
+	assert.True(t, errors.Is(results[1].Err, ErrMethodNotFound))
+}
+
+func TestMultiClient_SetAddrs(t *testing.T) {
+	m := NewMultiClientWithCodec(nil, "a:1", "b:1")
+	a := m.Client("a:1")
+
+	m.SetAddrs([]string{"a:1", "c:1"})
+	assert.Equal(t, []string{"a:1", "c:1"}, m.Addrs())
+	assert.Equal(t, a, m.Client("a:1"))
+	assert.Nil(t, m.Client("b:1"))
+	assert.NotNil(t, m.Client("c:1"))
+}
+
+type Backend string
+
+func (b *Backend) Name(args *Args, reply *string) error {
+	*reply = string(*b)
+	return nil
+}
+
+func TestMultiClient_SetAffinity(t *testing.T) {
+	var addrs []string
+	for _, name := range []string{"a", "b", "c"} {
+		s := NewServerWithCodec(nil)
+		b := Backend(name)
+		_ = s.Register(&b)
+		addrs = append(addrs, startServer(t, s))
+	}
+
+	m := NewMultiClientWithCodec(nil, addrs...)
+	defer m.Close()
+	m.SetAffinity("session", time.Minute)
+
+	call := func(session string) string {
+		var name string
+		assert.Nil(t, m.Call("Backend.Name", &Args{}, &name, WithMetadata(Metadata{"session": session})))
+		return name
+	}
+
+	first := call("s1")
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first, call("s1"))
+	}
+	// calls without a session are still spread
+	names := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		var name string
+		assert.Nil(t, m.Call("Backend.Name", &Args{}, &name))
+		names[name] = true
+	}
+	assert.Len(t, names, 3)
+
+	// the pinned server disappears
+	var rest []string
+	for _, addr := range addrs {
+		if c := m.Client(addr); c != nil && addr != m.affinity.pins["s1"].addr {
+			rest = append(rest, addr)
+		}
+	}
+	m.SetAddrs(rest)
+	moved := call("s1")
+	assert.NotEqual(t, first, moved)
+	assert.Equal(t, moved, call("s1"))
+}
+
+func TestMultiClient_SetAffinityTTL(t *testing.T) {
+	m := NewMultiClientWithCodec(nil, "a:1", "b:1")
+	m.SetAffinity("session", 20*time.Millisecond)
+	a := m.affinity
+	known := func(string) bool { return true }
+
+	a.set("s1", "a:1")
+	addr, ok := a.get("s1", known)
+	assert.True(t, ok)
+	assert.Equal(t, "a:1", addr)
+
+	time.Sleep(40 * time.Millisecond)
+	_, ok = a.get("s1", known)
+	assert.False(t, ok)
+
+	a.set("s1", "b:1")
+	a.unpin("s1", "a:1")
+	_, ok = a.get("s1", known)
+	assert.True(t, ok)
+	a.unpin("s1", "b:1")
+	_, ok = a.get("s1", known)
+	assert.False(t, ok)
+}
@@ -0,0 +1,99 @@
+package xrpc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ServiceNamer derives the wire name of a service from its type, the type
+// the registered value points to, e.g. to strip a "Service" suffix or add
+// a package prefix. Names must not contain dots.
+type ServiceNamer func(t reflect.Type) string
+
+// WithServiceNamer names the services registered with Register and
+// RegisterName with fn instead of their type name.
+func WithServiceNamer(fn ServiceNamer) ServerOption {
+	return func(s *Server) {
+		s.serviceNamer = fn
+	}
+}
+
+// serviceName returns the wire name of the service v, whose type is named
+// typeName.
+func (s *Server) serviceName(v reflect.Value, typeName string) (string, error) {
+	if s.serviceNamer == nil {
+		return typeName, nil
+	}
+	name := s.serviceNamer(reflect.Indirect(v).Type())
+	if name == "" {
+		return "", errors.New("rpc.Register: no service name for type " + typeName)
+	}
+	if strings.Contains(name, ".") {
+		return "", fmt.Errorf("rpc.Register: service name %q of type %s contains a dot", name, typeName)
+	}
+	return name, nil
+}
+
+// NameNormalizer maps a service or method name onto the form requested and
+// registered names are compared in.
+type NameNormalizer func(name string) string
+
+// WithNameNormalizer makes requests for unknown methods match the method
+// whose service and method names normalize the same, e.g. with
+// strings.ToLower or SnakeCaseInsensitive. Exact matches still win.
+func WithNameNormalizer(fn NameNormalizer) ServerOption {
+	return func(s *Server) {
+		s.normalizeName = fn
+	}
+}
+
+// WithCaseInsensitiveMethods matches service and method names ignoring
+// case.
+func WithCaseInsensitiveMethods() ServerOption {
+	return WithNameNormalizer(strings.ToLower)
+}
+
+// SnakeCaseInsensitive normalizes camelCase, PascalCase and snake_case
+// names alike, so "get_user" matches "GetUser".
+func SnakeCaseInsensitive(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r != '_' {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// canonicalMethod returns the registered method matching method once
+// normalized, or method if there is none or it's registered as is.
+func (s *Server) canonicalMethod(method string) string {
+	serviceName, methodName, err := parseFromRPCMethod(method)
+	if err != nil {
+		return method
+	}
+	if svcI, ok := s.m.Load(serviceName); ok {
+		if _, ok := svcI.(*service).method[methodName]; ok {
+			return method
+		}
+	}
+	wantService, wantMethod := s.normalizeName(serviceName), s.normalizeName(methodName)
+	canonical := method
+	s.m.Range(func(key, value interface{}) bool {
+		svc := value.(*service)
+		if s.normalizeName(svc.name) != wantService {
+			return true
+		}
+		for name := range svc.method {
+			if s.normalizeName(name) == wantMethod {
+				canonical = svc.name + "." + name
+				return false
+			}
+		}
+		return true
+	})
+	return canonical
+}
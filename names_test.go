@@ -0,0 +1,89 @@
+package xrpc
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_WithCaseInsensitiveMethods(t *testing.T) {
+	s := NewServerWithCodec(nil, WithCaseInsensitiveMethods())
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("int.sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+	assert.Nil(t, c.Call("INT.Sum", &Args{A: 1, B: 2}, &sum))
+	err := c.Call("int.get_sum", &Args{}, &sum)
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+}
+
+type UserStore struct{}
+
+func (u *UserStore) GetUser(id *int, name *string) error {
+	*name = "gopher"
+	return nil
+}
+
+func TestServer_WithNameNormalizer(t *testing.T) {
+	s := NewServerWithCodec(nil, WithNameNormalizer(SnakeCaseInsensitive))
+	assert.Nil(t, s.Register(new(UserStore)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	for _, method := range []string{"UserStore.GetUser", "user_store.get_user", "userStore.getUser"} {
+		var name string
+		assert.Nil(t, c.Call(method, new(int), &name), method)
+		assert.Equal(t, "gopher", name)
+	}
+
+	// off by default
+	s2 := NewServerWithCodec(nil)
+	assert.Nil(t, s2.Register(new(UserStore)))
+	c2 := NewClientWithCodec(nil, startServer(t, s2))
+	defer c2.Close()
+	err := c2.Call("user_store.get_user", new(int), new(string))
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+}
+
+func TestSnakeCaseInsensitive(t *testing.T) {
+	assert.Equal(t, "getuserbyid", SnakeCaseInsensitive("GetUserByID"))
+	assert.Equal(t, "getuserbyid", SnakeCaseInsensitive("get_user_by_id"))
+}
+
+type BillingService struct{}
+
+func (b *BillingService) Charge(amount *int, reply *int) error {
+	*reply = *amount
+	return nil
+}
+
+func TestServer_WithServiceNamer(t *testing.T) {
+	namer := func(t reflect.Type) string {
+		return "billing_" + strings.ToLower(strings.TrimSuffix(t.Name(), "Service"))
+	}
+	s := NewServerWithCodec(nil, WithServiceNamer(namer))
+	assert.Nil(t, s.Register(new(BillingService)))
+	assert.Nil(t, s.RegisterName(new(UserStore), "GetUser"))
+	assert.Equal(t, map[string][]string{
+		"billing_billing":   {"Charge"},
+		"billing_userstore": {"GetUser"},
+	}, s.Services())
+
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+	var n int
+	assert.Nil(t, c.Call("billing_billing.Charge", &[]int{5}[0], &n))
+	assert.Equal(t, 5, n)
+	err := c.Call("BillingService.Charge", &[]int{5}[0], &n)
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+
+	bad := NewServerWithCodec(nil, WithServiceNamer(func(t reflect.Type) string { return "a." + t.Name() }))
+	assert.Error(t, bad.Register(new(BillingService)))
+	assert.Error(t, bad.RegisterName(new(BillingService), "Charge"))
+}
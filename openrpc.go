@@ -0,0 +1,240 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+const (
+	reflectionService = "XrpcReflection"
+	openRPCVersion    = "1.2.6"
+)
+
+// OpenRPCInfo is the info object of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenRPCDocument describes the methods of a server in the OpenRPC format,
+// see https://spec.open-rpc.org, for doc generators and client generators.
+type OpenRPCDocument struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+type OpenRPCMethod struct {
+	Name           string              `json:"name"`
+	Summary        string              `json:"summary,omitempty"`
+	Description    string              `json:"description,omitempty"`
+	Tags           []OpenRPCTag        `json:"tags,omitempty"`
+	ParamStructure string              `json:"paramStructure"` // by-name or by-position
+	Params         []OpenRPCDescriptor `json:"params"`
+	Result         OpenRPCDescriptor   `json:"result"`
+	Deprecated     bool                `json:"deprecated,omitempty"`
+	Examples       []OpenRPCExample    `json:"examples,omitempty"`
+}
+
+type OpenRPCTag struct {
+	Name string `json:"name"`
+}
+
+// OpenRPCDescriptor is a content descriptor: a param or a result, with
+// the JSON Schema of its value.
+type OpenRPCDescriptor struct {
+	Name     string                 `json:"name"`
+	Required bool                   `json:"required,omitempty"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+type OpenRPCExample struct {
+	Name   string                `json:"name"`
+	Params []OpenRPCExampleValue `json:"params"`
+	Result *OpenRPCExampleValue  `json:"result,omitempty"`
+}
+
+type OpenRPCExampleValue struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// OpenRPC returns the OpenRPC document of the methods registered on the
+// server, with their docs, leaving out the services of the package. The
+// params of a method taking a struct are its fields, by name; those of
+// other methods are their args, by position.
+func (s *Server) OpenRPC(info OpenRPCInfo) OpenRPCDocument {
+	doc := OpenRPCDocument{OpenRPC: openRPCVersion, Info: info, Methods: []OpenRPCMethod{}}
+	for _, desc := range s.describe(false) {
+		m := OpenRPCMethod{
+			Name:        desc.Name,
+			Summary:     desc.Summary,
+			Description: desc.Description,
+			Result:      OpenRPCDescriptor{Name: "result", Schema: jsonSchema(desc.Result)},
+			Deprecated:  desc.Deprecated,
+		}
+		for _, tag := range desc.Tags {
+			m.Tags = append(m.Tags, OpenRPCTag{Name: tag})
+		}
+		switch {
+		case len(desc.Args) > 0:
+			m.ParamStructure = "by-position"
+			for i, arg := range desc.Args {
+				m.Params = append(m.Params, OpenRPCDescriptor{Name: "arg" + strconv.Itoa(i), Required: true, Schema: jsonSchema(arg)})
+			}
+		case desc.Params.Kind == reflect.Struct.String():
+			m.ParamStructure = "by-name"
+			m.Params = []OpenRPCDescriptor{}
+			for _, f := range desc.Params.Fields {
+				m.Params = append(m.Params, OpenRPCDescriptor{Name: f.Name, Required: f.Required, Schema: jsonSchema(f.Type)})
+			}
+		default:
+			m.ParamStructure = "by-position"
+			m.Params = []OpenRPCDescriptor{{Name: "params", Required: true, Schema: jsonSchema(desc.Params)}}
+		}
+		for i, ex := range desc.Examples {
+			m.Examples = append(m.Examples, openRPCExample(i, ex, m))
+		}
+		doc.Methods = append(doc.Methods, m)
+	}
+	return doc
+}
+
+// openRPCExample pairs the params of ex with those of m.
+func openRPCExample(i int, ex Example, m OpenRPCMethod) OpenRPCExample {
+	out := OpenRPCExample{Name: ex.Name, Params: []OpenRPCExampleValue{}}
+	if out.Name == "" {
+		out.Name = fmt.Sprintf("example%d", i+1)
+	}
+	b, _ := json.Marshal(ex.Params)
+	var values []json.RawMessage
+	switch m.ParamStructure {
+	case "by-name":
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal(b, &fields)
+		for _, p := range m.Params {
+			if v, ok := fields[p.Name]; ok {
+				out.Params = append(out.Params, OpenRPCExampleValue{Name: p.Name, Value: v})
+			}
+		}
+	default:
+		if len(m.Params) > 1 {
+			_ = json.Unmarshal(b, &values)
+		} else {
+			values = []json.RawMessage{b}
+		}
+		for j, v := range values {
+			if j < len(m.Params) {
+				out.Params = append(out.Params, OpenRPCExampleValue{Name: m.Params[j].Name, Value: v})
+			}
+		}
+	}
+	if ex.Result != nil {
+		out.Result = &OpenRPCExampleValue{Name: "result", Value: ex.Result}
+	}
+	return out
+}
+
+// jsonSchema returns the JSON Schema of the values of the JSON codecs
+// described by s.
+func jsonSchema(s Schema) map[string]interface{} {
+	out := make(map[string]interface{})
+	if s.Name != "" && s.Name != s.Kind { // named types only
+		out["title"] = s.Name
+	}
+	switch kindClass(s.Kind) {
+	case "bool":
+		out["type"] = "boolean"
+	case "int", "uint":
+		out["type"] = "integer"
+	case "float":
+		out["type"] = "number"
+	case "string":
+		out["type"] = "string"
+	case "list":
+		if s.Elem != nil && s.Elem.Kind == reflect.Uint8.String() && s.Kind == reflect.Slice.String() {
+			out["type"] = "string"
+			out["contentEncoding"] = "base64"
+			break
+		}
+		out["type"] = "array"
+		if s.Elem != nil {
+			out["items"] = jsonSchema(*s.Elem)
+		}
+	case "map":
+		out["type"] = "object"
+		if s.Elem != nil {
+			out["additionalProperties"] = jsonSchema(*s.Elem)
+		}
+	case "struct":
+		out["type"] = "object"
+		props := make(map[string]interface{}, len(s.Fields))
+		var required []string
+		for _, f := range s.Fields {
+			props[f.Name] = jsonSchema(f.Type)
+			if f.Required {
+				required = append(required, f.Name)
+			}
+		}
+		if len(props) > 0 {
+			out["properties"] = props
+		}
+		if len(required) > 0 {
+			out["required"] = required
+		}
+	case "nil":
+		out["type"] = "null"
+	}
+	return out
+}
+
+// ExposeReflection serves the descriptions of the methods of the server
+// to clients, see Client.Describe and Client.OpenRPC.
+func (s *Server) ExposeReflection() error {
+	return s.registerInternal(reflectionService, &reflection{s: s})
+}
+
+// reflection is the reflection service. It replies with JSON whatever the
+// codec, since examples hold values of any type, which gob can't send
+// without registering them.
+type reflection struct {
+	s *Server
+}
+
+func (r *reflection) Describe(args *struct{}, reply *[]byte) (err error) {
+	*reply, err = json.Marshal(r.s.Describe())
+	return err
+}
+
+func (r *reflection) OpenRPC(info *OpenRPCInfo, reply *[]byte) (err error) {
+	*reply, err = json.Marshal(r.s.OpenRPC(*info))
+	return err
+}
+
+// Describe fetches the descriptions of the methods of the server, which
+// must call ExposeReflection.
+func (c *Client) Describe(ctx context.Context) ([]MethodDescription, error) {
+	var b []byte
+	if err := c.CallContext(ctx, reflectionService+".Describe", &struct{}{}, &b); err != nil {
+		return nil, err
+	}
+	var descs []MethodDescription
+	err := json.Unmarshal(b, &descs)
+	return descs, err
+}
+
+// OpenRPC fetches the OpenRPC document of the server, which must call
+// ExposeReflection.
+func (c *Client) OpenRPC(ctx context.Context, info OpenRPCInfo) (OpenRPCDocument, error) {
+	var b []byte
+	if err := c.CallContext(ctx, reflectionService+".OpenRPC", &info, &b); err != nil {
+		return OpenRPCDocument{}, err
+	}
+	var doc OpenRPCDocument
+	err := json.Unmarshal(b, &doc)
+	return doc, err
+}
@@ -0,0 +1,84 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_OpenRPC(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int), WithMethodDoc("Sum", MethodDoc{
+		Summary:  "Adds two numbers",
+		Examples: []Example{{Name: "small", Params: Args{A: 1, B: 2}, Result: 3}},
+	}), WithMethodTags("Sum", "math")))
+	assert.Nil(t, s.Register(new(Calc)))
+	assert.Nil(t, s.RegisterName(new(Echo), "Say"))
+	assert.Nil(t, s.ExposeReflection())
+
+	doc := s.OpenRPC(OpenRPCInfo{Title: "calc", Version: "1.0.0"})
+	assert.Equal(t, "calc", doc.Info.Title)
+	names := make(map[string]OpenRPCMethod)
+	for _, m := range doc.Methods {
+		names[m.Name] = m
+	}
+	assert.NotContains(t, names, reflectionService+".Describe")
+
+	sum := names["Int.Sum"]
+	assert.Equal(t, "Adds two numbers", sum.Summary)
+	assert.Equal(t, []OpenRPCTag{{Name: "math"}}, sum.Tags)
+	assert.Equal(t, "by-name", sum.ParamStructure)
+	if assert.Len(t, sum.Params, 2) {
+		assert.Equal(t, "A", sum.Params[0].Name)
+		assert.Equal(t, "integer", sum.Params[0].Schema["type"])
+	}
+	assert.Equal(t, "integer", sum.Result.Schema["type"])
+	b, _ := json.Marshal(sum.Examples)
+	assert.JSONEq(t, `[{"name":"small","params":[{"name":"A","value":1},{"name":"B","value":2}],"result":{"name":"result","value":3}}]`, string(b))
+
+	scale := names["Calc.Scale"]
+	assert.Equal(t, "by-position", scale.ParamStructure)
+	if assert.Len(t, scale.Params, 2) {
+		assert.Equal(t, "object", scale.Params[0].Schema["type"])
+		assert.Equal(t, "integer", scale.Params[1].Schema["type"])
+	}
+
+	say := names["Echo.Say"]
+	assert.Equal(t, "by-position", say.ParamStructure)
+	assert.Equal(t, []OpenRPCDescriptor{{Name: "params", Required: true, Schema: map[string]interface{}{"type": "string"}}}, say.Params)
+}
+
+func TestClient_Reflection(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int), WithMethodDoc("Sum", MethodDoc{
+		Summary:  "Adds two numbers",
+		Examples: []Example{{Params: Args{A: 1, B: 2}, Result: 3}},
+	})))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	_, err := c.Describe(context.Background())
+	assert.NotNil(t, err)
+
+	assert.Nil(t, s.ExposeReflection())
+	descs, err := c.Describe(context.Background())
+	assert.Nil(t, err)
+	var sum MethodDescription
+	for _, desc := range descs {
+		if desc.Name == "Int.Sum" {
+			sum = desc
+		}
+	}
+	assert.Equal(t, "Adds two numbers", sum.Summary)
+	assert.Len(t, sum.Examples, 1)
+
+	doc, err := c.OpenRPC(context.Background(), OpenRPCInfo{Title: "int", Version: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, openRPCVersion, doc.OpenRPC)
+	if assert.Len(t, doc.Methods, 1) {
+		assert.Equal(t, "Int.Sum", doc.Methods[0].Name)
+		assert.Len(t, doc.Methods[0].Examples, 1)
+	}
+}
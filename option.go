@@ -0,0 +1,199 @@
+package xrpc
+
+import (
+	"crypto/tls"
+	"log"
+	"time"
+)
+
+const (
+	defaultAcceptMinBackoff = 5 * time.Millisecond
+	defaultAcceptMaxBackoff = time.Second
+
+	defaultCallTimeout = 5 * time.Second
+
+	defaultBufSize = 4096
+
+	defaultBatchWorkers = 64
+
+	maxMethodLen = 256 // bytes, longer method names are rejected by default
+)
+
+type ServerOption func(*Server)
+
+// WithAcceptBackoff sets the bounds of the exponential backoff applied
+// when Accept returns a temporary error (e.g. EMFILE).
+func WithAcceptBackoff(min, max time.Duration) ServerOption {
+	return func(s *Server) {
+		s.setAcceptBackoff(min, max)
+	}
+}
+
+// WithAcceptErrorHandler sets a callback invoked with the error which makes
+// ServeTCP stop accepting connections.
+func WithAcceptErrorHandler(fn func(err error)) ServerOption {
+	return func(s *Server) {
+		s.onAcceptErr = fn
+	}
+}
+
+// WithLogger sets the logger of the server. Defaults to log.Default().
+func WithLogger(logger *log.Logger) ServerOption {
+	return func(s *Server) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+// WithDebugLogger logs routine events, such as peers closing their
+// connections, to logger. They are dropped by default.
+func WithDebugLogger(logger *log.Logger) ServerOption {
+	return func(s *Server) {
+		s.debugLogger = logger
+	}
+}
+
+// WithTLSConfig serves both TCP and HTTP over TLS. The config must carry
+// a certificate. TCP peers speaking plaintext are refused, see
+// RefusedError.
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithBufferSizes sets the sizes of the buffered reader and writer of
+// each TCP connection. Larger buffers suit large payloads, smaller ones
+// save memory with many connections. Defaults to 4KB each.
+func WithBufferSizes(read, write int) ServerOption {
+	return func(s *Server) {
+		s.readBufSize, s.writeBufSize = read, write
+	}
+}
+
+// WithFlushInterval coalesces the responses to pipelined requests into
+// fewer writes: while complete requests are waiting on a connection, the
+// responses are buffered for up to d before being flushed. Responses are
+// flushed right away when no request is waiting, so idle connections see
+// no added latency.
+func WithFlushInterval(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.flushInterval = d
+	}
+}
+
+// WithMaxBatchSize rejects batches of more than n requests with an
+// InvalidRequest error.
+func WithMaxBatchSize(n int) ServerOption {
+	return func(s *Server) {
+		s.maxBatchSize = n
+	}
+}
+
+// WithBatchWorkers bounds the number of goroutines handling the requests of
+// a batch. Defaults to 64.
+func WithBatchWorkers(n int) ServerOption {
+	return func(s *Server) {
+		s.batchWorkers = n
+	}
+}
+
+// WithMaxMethodLength rejects requests whose method name is longer than n
+// bytes with an InvalidRequest error. Defaults to 256.
+func WithMaxMethodLength(n int) ServerOption {
+	return func(s *Server) {
+		s.maxMethodLen = n
+	}
+}
+
+// bufSize returns n, or the default size if n is not positive.
+func bufSize(n int) int {
+	if n <= 0 {
+		return defaultBufSize
+	}
+	return n
+}
+
+type ClientOption func(*Client)
+
+// WithTimeout bounds calls whose context has no deadline. Defaults to 5s.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithDialer replaces the default net.Dialer, e.g. to go through a proxy,
+// bind a source address or resolve names differently.
+func WithDialer(d Dialer) ClientOption {
+	return func(c *Client) {
+		if d != nil {
+			c.dialer = d
+		}
+	}
+}
+
+// WithRetry retries calls up to n times when the connection fails or
+// breaks. A broken connection may have delivered the request, so only use
+// it with idempotent methods, or disable it per call with
+// WithRetryDisabled.
+func WithRetry(n int) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// WithSingleflight makes concurrent identical calls (same method and
+// params) share one round trip. It applies to the given methods, or to all
+// methods if none are given, so only use it for read-only methods. The
+// shared round trip is bounded by the timeout of the client rather than
+// the context of any one caller; each caller still returns once its own
+// context is done.
+func WithSingleflight(methods ...string) ClientOption {
+	return func(c *Client) {
+		c.flight = &flightGroup{methods: methodSet(methods)}
+	}
+}
+
+// methodSet returns nil for an empty list, which matches every method.
+func methodSet(methods []string) map[string]bool {
+	if len(methods) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+	return set
+}
+
+// WithCache caches successful responses of the given methods (all methods
+// if none are given) for ttl, keeping at most maxEntries (0 for no limit).
+func WithCache(ttl time.Duration, maxEntries int, methods ...string) ClientOption {
+	return func(c *Client) {
+		c.cache = newResponseCache(ttl, maxEntries, methods)
+	}
+}
+
+// WithClientBufferSizes is like WithBufferSizes, for the connection of the
+// client.
+func WithClientBufferSizes(read, write int) ClientOption {
+	return func(c *Client) {
+		c.readBufSize, c.writeBufSize = read, write
+	}
+}
+
+// ResponseValidator checks the encoded responses to reqs before they are
+// decoded.
+type ResponseValidator func(reqs []Request, data []byte) error
+
+// WithResponseValidator makes calls fail with the error of v when the
+// responses are rejected, e.g. with jsonrpc.StrictResponses.
+func WithResponseValidator(v ResponseValidator) ClientOption {
+	return func(c *Client) {
+		c.validate = v
+	}
+}
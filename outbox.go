@@ -0,0 +1,134 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	outboxMinBackoff = 100 * time.Millisecond
+	outboxMaxBackoff = 30 * time.Second
+)
+
+// outboxPermanent are the codes of errors which retrying a call can't fix,
+// on which the outbox drops it. Other errors, e.g. a method not found while
+// a deploy rolls out or an internal error, are retried.
+var outboxPermanent = map[Code]bool{
+	ParseErr:        true,
+	InvalidRequest:  true,
+	InvalidParamErr: true,
+}
+
+// outbox sends the calls persisted by Enqueue in the background.
+type outbox struct {
+	j       *Journal
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// WithOutbox persists the calls made with Enqueue to j and sends them in
+// the background, in order, retrying until the server accepts them or
+// rejects them for good, e.g. for invalid params. Calls left
+// in j by a previous process are sent too, so none is lost on restart, but
+// a call may be delivered more than once.
+func WithOutbox(j *Journal) ClientOption {
+	return func(c *Client) {
+		c.outbox = &outbox{
+			j:       j,
+			wake:    make(chan struct{}, 1),
+			stopped: make(chan struct{}),
+		}
+	}
+}
+
+// Enqueue persists a call of method and returns; the call is sent in the
+// background, see WithOutbox. Its reply is dropped, and remote errors are
+// logged.
+func (c *Client) Enqueue(method string, args interface{}, opts ...CallOption) error {
+	if c.outbox == nil {
+		return errors.New("rpc: client has no outbox")
+	}
+	req := c.codec.NewRequest(method, args)
+	if req == nil {
+		return errors.New("rpc: could not encode request " + method)
+	}
+	if o := newCallOptions(opts); len(o.md) > 0 {
+		req.SetMetadata(o.md)
+	}
+	if _, err := c.outbox.j.begin(method, req); err != nil {
+		return err
+	}
+	select {
+	case c.outbox.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// OutboxPending returns the number of enqueued calls not delivered yet.
+func (c *Client) OutboxPending() int {
+	if c.outbox == nil {
+		return 0
+	}
+	return c.outbox.j.Pending()
+}
+
+func (o *outbox) start(c *Client) {
+	var ctx context.Context
+	ctx, o.cancel = context.WithCancel(context.Background())
+	go o.run(ctx, c)
+}
+
+func (o *outbox) stop() {
+	o.cancel()
+	<-o.stopped
+}
+
+func (o *outbox) run(ctx context.Context, c *Client) {
+	defer close(o.stopped)
+
+	backoff := time.Duration(0)
+	for {
+		e, ok := o.j.first()
+		if !ok {
+			select {
+			case <-o.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		err := c.CallContext(ctx, e.Method, RawMessage(e.Params), new(RawMessage), WithMetadata(e.Meta))
+		var rpcErr *Error
+		if err == nil || errors.As(err, &rpcErr) && outboxPermanent[rpcErr.ErrCode] {
+			if err != nil {
+				log.Printf("rpc: outbox call %s failed: %v", e.Method, err)
+			}
+			if err := o.j.ack(e.ID); err != nil {
+				log.Printf("rpc: could not acknowledge outbox entry %d: %v", e.ID, err)
+			}
+			backoff = 0
+			continue
+		}
+		if rpcErr != nil {
+			log.Printf("rpc: outbox call %s failed, retrying: %v", e.Method, err)
+		}
+
+		if backoff == 0 {
+			backoff = outboxMinBackoff
+		} else if backoff *= 2; backoff > outboxMaxBackoff {
+			backoff = outboxMaxBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
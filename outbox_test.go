@@ -0,0 +1,88 @@
+package xrpc
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Outbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox")
+
+	// reserve an address, down for now
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	j, err := OpenJournal(path)
+	assert.Nil(t, err)
+	c := NewClientWithCodec(nil, addr, WithOutbox(j))
+	assert.Nil(t, c.Enqueue("Ledger.Add", 1))
+	assert.Nil(t, c.Enqueue("Ledger.Add", 2))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 2, c.OutboxPending())
+
+	// the process restarts
+	c.Close()
+	assert.Nil(t, j.Close())
+	j, err = OpenJournal(path)
+	assert.Nil(t, err)
+	defer j.Close()
+	c = NewClientWithCodec(nil, addr, WithOutbox(j))
+	defer c.Close()
+	assert.Nil(t, c.Enqueue("Ledger.Add", 3))
+	assert.Nil(t, c.Enqueue("Calc.Fail", &Args{})) // invalid params, dropped
+
+	ledger := new(Ledger)
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(ledger))
+	assert.Nil(t, s.Register(new(Calc)))
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.OutboxPending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, c.OutboxPending())
+	ledger.mu.Lock()
+	assert.Equal(t, []int{1, 2, 3}, ledger.entries)
+	ledger.mu.Unlock()
+
+	assert.NotNil(t, NewClientWithCodec(nil, addr).Enqueue("Ledger.Add", 5))
+}
+
+func TestClient_OutboxRetriesMethodNotFound(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "outbox"))
+	assert.Nil(t, err)
+	defer j.Close()
+
+	// the server is up, but Ledger is yet to be deployed
+	s := NewServerWithCodec(nil)
+	c := NewClientWithCodec(nil, startServer(t, s), WithOutbox(j))
+	defer c.Close()
+	assert.Nil(t, c.Enqueue("Ledger.Add", 1))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, c.OutboxPending())
+
+	ledger := new(Ledger)
+	assert.Nil(t, s.Register(ledger))
+	deadline := time.Now().Add(5 * time.Second)
+	for c.OutboxPending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, c.OutboxPending())
+	ledger.mu.Lock()
+	assert.Equal(t, []int{1}, ledger.entries)
+	ledger.mu.Unlock()
+}
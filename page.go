@@ -0,0 +1,85 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrStopPaging stops ForEachPage without error when returned by its
+// callback.
+var ErrStopPaging = errors.New("rpc: stop paging")
+
+// The cursor convention of list methods: the params carry the cursor of
+// the page to return, empty for the first one, and the reply the cursor
+// of the next page, empty after the last one. Embedding PageParams and
+// PageInfo follows it.
+
+// PageRequest is implemented by the params of list methods.
+type PageRequest interface {
+	SetCursor(cursor string)
+}
+
+// PageReply is implemented by the replies of list methods.
+type PageReply interface {
+	NextCursor() string
+}
+
+// PageParams is embedded in the params of list methods.
+type PageParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+func (p *PageParams) SetCursor(cursor string) { p.Cursor = cursor }
+
+// PageInfo is embedded in the replies of list methods.
+type PageInfo struct {
+	Next string `json:"next_cursor,omitempty"`
+}
+
+func (p *PageInfo) NextCursor() string { return p.Next }
+
+var typeOfPageReply = reflect.TypeOf((*PageReply)(nil)).Elem()
+
+// ForEachPage calls the list method with req, then again with the cursor
+// of each reply until the last page, passing each page to fn. fn is a
+// func(page *T) error, with *T a PageReply, e.g.
+//
+//	err := c.ForEachPage(ctx, "Users.List", &ListUsers{Limit: 100}, func(page *UserPage) error {
+//		...
+//	})
+//
+// It stops at the first error of a call or of fn, which is returned unless
+// it's ErrStopPaging.
+func (c *Client) ForEachPage(ctx context.Context, method string, req PageRequest, fn interface{}, opts ...CallOption) error {
+	fnV, fnT := reflect.ValueOf(fn), reflect.TypeOf(fn)
+	if fnT == nil || fnT.Kind() != reflect.Func || fnT.NumIn() != 1 || fnT.NumOut() != 1 ||
+		fnT.In(0).Kind() != reflect.Ptr || !fnT.In(0).Implements(typeOfPageReply) || fnT.Out(0) != typeOfError {
+		return fmt.Errorf("rpc: ForEachPage wants a func(*T) error with *T a PageReply, got %T", fn)
+	}
+
+	seen := make(map[string]bool)
+	for {
+		page := reflect.New(fnT.In(0).Elem())
+		if err := c.CallContext(ctx, method, req, page.Interface(), opts...); err != nil {
+			return err
+		}
+		if out := fnV.Call([]reflect.Value{page})[0]; !out.IsNil() {
+			if err := out.Interface().(error); err != ErrStopPaging {
+				return err
+			}
+			return nil
+		}
+
+		next := page.Interface().(PageReply).NextCursor()
+		if next == "" {
+			return nil
+		}
+		if seen[next] {
+			return fmt.Errorf("rpc: %s returned the cursor %q twice", method, next)
+		}
+		seen[next] = true
+		req.SetCursor(next)
+	}
+}
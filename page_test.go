@@ -0,0 +1,89 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ListUsers struct {
+	PageParams
+	Limit int
+}
+
+type UserPage struct {
+	PageInfo
+	Users []string
+}
+
+type Users struct {
+	names []string
+	loop  bool // return the same cursor forever
+}
+
+func (u *Users) List(args *ListUsers, reply *UserPage) error {
+	start, _ := strconv.Atoi(args.Cursor)
+	end := start + args.Limit
+	if end >= len(u.names) {
+		end = len(u.names)
+	} else {
+		reply.Next = strconv.Itoa(end)
+	}
+	if u.loop {
+		reply.Next = "1"
+	}
+	reply.Users = u.names[start:end]
+	return nil
+}
+
+func TestClient_ForEachPage(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(&Users{names: []string{"a", "b", "c", "d", "e"}}))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+	ctx := context.Background()
+
+	var pages [][]string
+	err := c.ForEachPage(ctx, "Users.List", &ListUsers{Limit: 2}, func(page *UserPage) error {
+		pages = append(pages, page.Users)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, pages)
+
+	pages = nil
+	err = c.ForEachPage(ctx, "Users.List", &ListUsers{Limit: 2}, func(page *UserPage) error {
+		pages = append(pages, page.Users)
+		return ErrStopPaging
+	})
+	assert.Nil(t, err)
+	assert.Len(t, pages, 1)
+
+	boom := errors.New("boom")
+	err = c.ForEachPage(ctx, "Users.List", &ListUsers{Limit: 2}, func(page *UserPage) error { return boom })
+	assert.Equal(t, boom, err)
+
+	err = c.ForEachPage(ctx, "Users.List", &ListUsers{Limit: 2}, func(page UserPage) error { return nil })
+	assert.EqualError(t, err, "rpc: ForEachPage wants a func(*T) error with *T a PageReply, got func(xrpc.UserPage) error")
+
+	err = c.ForEachPage(ctx, "Users.Missing", &ListUsers{Limit: 2}, func(page *UserPage) error { return nil })
+	assert.True(t, errors.Is(err, ErrMethodNotFound))
+}
+
+func TestClient_ForEachPageLoop(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(&Users{names: []string{"a", "b", "c"}, loop: true}))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	calls := 0
+	err := c.ForEachPage(context.Background(), "Users.List", &ListUsers{Limit: 1}, func(page *UserPage) error {
+		calls++
+		return nil
+	})
+	assert.EqualError(t, err, `rpc: Users.List returned the cursor "1" twice`)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,66 @@
+package xrpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+const (
+	// TimeoutKey is the metadata key carrying the time left to answer a
+	// request, in milliseconds. The server cancels the context of the
+	// method once it elapsed.
+	TimeoutKey = "xrpc-timeout"
+	// TenantKey is the metadata key carrying the tenant of a request. The
+	// server doesn't trust it, an interceptor of a server trusting its
+	// callers may pass it to WithTenant.
+	TenantKey = "xrpc-tenant"
+	// TraceIDKey is the metadata key carrying the id of the trace a request
+	// is part of.
+	TraceIDKey = "xrpc-trace-id"
+)
+
+// PropagateContext returns a client interceptor for servers calling other
+// services while handling a request: it sends the priority and trace id
+// of the request being handled, the tenant of ctx, and the time left
+// before the deadline of ctx along with the call. Options of the call
+// override them.
+func PropagateContext() ClientInterceptor {
+	return func(ctx context.Context, method string, args, reply interface{}, next ClientInvoker, opts ...CallOption) error {
+		md := propagatedMetadata(ctx)
+		if len(md) > 0 {
+			opts = append([]CallOption{WithMetadata(md)}, opts...)
+		}
+		return next(ctx, method, args, reply, opts...)
+	}
+}
+
+func propagatedMetadata(ctx context.Context) Metadata {
+	md := make(Metadata)
+	incoming := MetadataFromContext(ctx)
+	for _, key := range []string{PriorityKey, TraceIDKey} {
+		if v := incoming.Get(key); v != "" {
+			md[key] = v
+		}
+	}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		md[TenantKey] = tenant
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline).Milliseconds()
+		if left < 1 {
+			left = 1 // expired, which the client tells itself
+		}
+		md[TimeoutKey] = strconv.FormatInt(left, 10)
+	}
+	return md
+}
+
+// requestTimeout returns the timeout sent with a request, see TimeoutKey.
+func requestTimeout(md Metadata) (time.Duration, bool) {
+	ms, err := strconv.ParseInt(md.Get(TimeoutKey), 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
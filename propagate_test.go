@@ -0,0 +1,117 @@
+package xrpc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Downstream replies with the metadata and time left of its calls.
+type Downstream int
+
+func (d *Downstream) Peek(ctx context.Context, args *int, reply *Metadata) error {
+	*reply = MetadataFromContext(ctx).Copy()
+	if deadline, ok := ctx.Deadline(); ok {
+		(*reply)["left"] = time.Until(deadline).Round(time.Second).String()
+	}
+	return nil
+}
+
+// Upstream calls Downstream while handling its calls.
+type Upstream struct {
+	c *Client
+}
+
+func (u *Upstream) Forward(ctx context.Context, args *int, reply *Metadata) error {
+	return u.c.CallContext(ctx, "Downstream.Peek", args, reply, WithMetadata(Metadata{"own": "yes"}))
+}
+
+func TestPropagateContext(t *testing.T) {
+	down := NewServerWithCodec(nil)
+	assert.Nil(t, down.Register(new(Downstream)))
+	downAddr := startServer(t, down)
+
+	up := NewServerWithCodec(nil, WithInterceptors(func(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error {
+		return next(WithTenant(ctx, "acme"), args, reply)
+	}))
+	upstream := &Upstream{c: NewClientWithCodec(nil, downAddr, WithClientInterceptors(PropagateContext()))}
+	defer upstream.c.Close()
+	assert.Nil(t, up.Register(upstream))
+	c := NewClientWithCodec(nil, startServer(t, up))
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var reply Metadata
+	err := c.CallContext(ctx, "Upstream.Forward", new(int), &reply,
+		WithPriority(2), WithMetadata(Metadata{TraceIDKey: "t1", TimeoutKey: "3000", "token": "secret"}))
+	assert.Nil(t, err)
+	timeout, _ := strconv.Atoi(reply[TimeoutKey])
+	assert.True(t, timeout > 2000 && timeout <= 3000, reply[TimeoutKey])
+	delete(reply, TimeoutKey)
+	assert.Equal(t, Metadata{
+		PriorityKey: "2",
+		TraceIDKey:  "t1",
+		TenantKey:   "acme",
+		"own":       "yes",
+		"left":      "3s",
+	}, reply)
+}
+
+func TestPropagatedMetadata(t *testing.T) {
+	assert.Empty(t, propagatedMetadata(context.Background()))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	assert.Equal(t, Metadata{TimeoutKey: "1"}, propagatedMetadata(ctx))
+
+	_, ok := requestTimeout(Metadata{TimeoutKey: "-5"})
+	assert.False(t, ok)
+	d, ok := requestTimeout(Metadata{TimeoutKey: "250"})
+	assert.True(t, ok)
+	assert.Equal(t, 250*time.Millisecond, d)
+}
+
+func TestPropagateContext_CacheAndSingleflight(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	counter := new(Counter)
+	assert.Nil(t, s.Register(counter))
+	addr := startServer(t, s)
+
+	call := func(c *Client, trace string, d time.Duration, reply *int32) error {
+		ctx, cancel := context.WithTimeout(withMetadata(context.Background(), Metadata{TraceIDKey: trace}), time.Second)
+		defer cancel()
+		return c.CallContext(ctx, "Counter.Get", &d, reply)
+	}
+
+	cached := NewClientWithCodec(nil, addr, WithCache(time.Minute, 0, "Counter.Get"), WithClientInterceptors(PropagateContext()))
+	defer cached.Close()
+	var reply int32
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, call(cached, "t"+strconv.Itoa(i), 0, &reply))
+		assert.Equal(t, int32(1), reply)
+	}
+	var d time.Duration
+	cached.InvalidateCache("Counter.Get", &d)
+	assert.Nil(t, call(cached, "t3", 0, &reply))
+	assert.Equal(t, int32(2), reply)
+
+	flights := NewClientWithCodec(nil, addr, WithSingleflight("Counter.Get"), WithClientInterceptors(PropagateContext()))
+	defer flights.Close()
+	replies := make([]int32, 5)
+	var wg sync.WaitGroup
+	for i := range replies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Nil(t, call(flights, "t"+strconv.Itoa(i), 200*time.Millisecond, &replies[i]))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&counter.n))
+}
@@ -0,0 +1,87 @@
+package proto
+
+import "errors"
+
+// Extension types carried in the header extension area.
+const (
+	ExtCompression uint8 = iota + 1 // compression of the body, e.g. "gzip"
+	ExtCodec                        // codec of the body
+	ExtPriority                     // request priority
+	ExtTraceID                      // trace id
+)
+
+// MaxExtSize bounds the encoded extension area of a header. Each entry is
+// encoded as type(8bit):len(8bit):value, so a value holds at most 255
+// bytes.
+const MaxExtSize = 256
+
+var (
+	// ErrExtTooLarge .
+	ErrExtTooLarge = errors.New("proto header extensions too large")
+	// ErrExtMalformed .
+	ErrExtMalformed = errors.New("malformed proto header extension")
+)
+
+// Ext is a header extension entry. Entries of unknown types are kept as is,
+// so peers can add new ones without changing the frame format.
+type Ext struct {
+	Type  uint8
+	Value []byte
+}
+
+// SetExt sets the value of the extension typ, replacing any previous one.
+func (p *Proto) SetExt(typ uint8, value []byte) {
+	for i := range p.Ext {
+		if p.Ext[i].Type == typ {
+			p.Ext[i].Value = value
+			return
+		}
+	}
+	p.Ext = append(p.Ext, Ext{Type: typ, Value: value})
+}
+
+// GetExt returns the value of the extension typ.
+func (p *Proto) GetExt(typ uint8) ([]byte, bool) {
+	for _, e := range p.Ext {
+		if e.Type == typ {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func extSize(exts []Ext) (int, error) {
+	size := 0
+	for _, e := range exts {
+		if len(e.Value) > 255 {
+			return 0, ErrExtTooLarge
+		}
+		size += 2 + len(e.Value)
+	}
+	if size > MaxExtSize {
+		return 0, ErrExtTooLarge
+	}
+	return size, nil
+}
+
+func putExt(buf []byte, exts []Ext) {
+	for _, e := range exts {
+		buf[0] = e.Type
+		buf[1] = uint8(len(e.Value))
+		copy(buf[2:], e.Value)
+		buf = buf[2+len(e.Value):]
+	}
+}
+
+func parseExt(buf []byte) ([]Ext, error) {
+	var exts []Ext
+	for len(buf) > 0 {
+		if len(buf) < 2 || len(buf) < 2+int(buf[1]) {
+			return nil, ErrExtMalformed
+		}
+		n := int(buf[1])
+		exts = append(exts, Ext{Type: buf[0], Value: buf[2 : 2+n]})
+		buf = buf[2+n:]
+	}
+	return exts, nil
+}
@@ -0,0 +1,76 @@
+package proto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ProtoExt(t *testing.T) {
+	p := New()
+	p.Body = []byte("body")
+	p.SetExt(ExtCompression, []byte("gzip"))
+	p.SetExt(ExtTraceID, []byte("abc"))
+	p.SetExt(42, []byte{1})
+	p.SetExt(ExtTraceID, []byte("def"))
+
+	buf := bytes.NewBuffer(nil)
+	wr := bufio.NewWriter(buf)
+	if err := p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+
+	p2 := New()
+	if err := p2.ReadTCP(bufio.NewReader(buf)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, p2) {
+		t.Fatalf("not equal: want(%v) got(%v)", p, p2)
+	}
+	if v, ok := p2.GetExt(ExtTraceID); !ok || string(v) != "def" {
+		t.Errorf("unexpected trace id %q", v)
+	}
+	if _, ok := p2.GetExt(ExtCodec); ok {
+		t.Error("unexpected codec extension")
+	}
+}
+
+func Test_ProtoExtLimits(t *testing.T) {
+	p := New()
+	p.SetExt(ExtTraceID, []byte(strings.Repeat("a", 256)))
+	if err := p.WriteTCP(bufio.NewWriter(bytes.NewBuffer(nil))); err != ErrExtTooLarge {
+		t.Errorf("want ErrExtTooLarge, got %v", err)
+	}
+
+	p = New()
+	for i := 0; i < 3; i++ {
+		p.SetExt(uint8(i), []byte(strings.Repeat("a", 100)))
+	}
+	if err := p.WriteTCP(bufio.NewWriter(bytes.NewBuffer(nil))); err != ErrExtTooLarge {
+		t.Errorf("want ErrExtTooLarge, got %v", err)
+	}
+}
+
+func Test_ProtoExtMalformed(t *testing.T) {
+	frame := func(headerLen uint16, ext []byte) *bufio.Reader {
+		buf := make([]byte, int(_rawHeaderSize)+len(ext))
+		binary.BigEndian.PutUint32(buf[_packOffset:], uint32(len(buf)))
+		binary.BigEndian.PutUint16(buf[_headerOffset:], headerLen)
+		copy(buf[_rawHeaderSize:], ext)
+		return bufio.NewReader(bytes.NewReader(buf))
+	}
+
+	if err := New().ReadTCP(frame(_rawHeaderSize+3, []byte{1, 5, 0})); err != ErrExtMalformed {
+		t.Errorf("want ErrExtMalformed, got %v", err)
+	}
+	if err := New().ReadTCP(frame(_rawHeaderSize-1, nil)); err != ErrProtoHeaderLen {
+		t.Errorf("want ErrProtoHeaderLen, got %v", err)
+	}
+	if err := New().ReadTCP(frame(_rawHeaderSize+MaxExtSize+1, nil)); err != ErrProtoHeaderLen {
+		t.Errorf("want ErrProtoHeaderLen, got %v", err)
+	}
+}
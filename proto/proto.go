@@ -5,6 +5,8 @@ import (
 	"bufio"
 	"encoding/binary"
 	"errors"
+	"io"
+	"time"
 )
 
 const (
@@ -12,6 +14,14 @@ const (
 	OpRequest uint16 = iota + 1
 	// OpResponse .
 	OpResponse
+	// OpHeartbeat keeps an idle connection alive, answered in kind.
+	OpHeartbeat
+	// OpClose is sent by a server refusing a connection, before closing
+	// it. The body states the reason.
+	OpClose
+	// OpHello opens a connection with the hash of the schema the client
+	// expects, answered in kind with the hash of the server's.
+	OpHello
 )
 
 const (
@@ -51,6 +61,45 @@ type Proto struct {
 	Op   uint16 // Type of Proto
 	Seq  uint16 // Seq of message, 0 means done, else means not finished
 	Body []byte // Body of Proto
+	Ext  []Ext  // header extensions
+
+	Hook FrameHook // called after each frame read or written, if set
+}
+
+// Direction of a frame.
+type Direction int
+
+const (
+	Read Direction = iota
+	Write
+)
+
+// FrameInfo describes a frame read or written. A read starts when the first
+// byte of the frame is available, so waiting for the peer is left out; a
+// write ends once the frame is buffered, before the writer is flushed.
+type FrameInfo struct {
+	Dir   Direction
+	Ver   uint16
+	Op    uint16
+	Seq   uint16
+	Size  int // header and body
+	Start time.Time
+	End   time.Time
+}
+
+// FrameHook observes frames, e.g. for wire-level metrics.
+type FrameHook func(info FrameInfo)
+
+func (p *Proto) hook(dir Direction, size int, start time.Time) {
+	p.Hook(FrameInfo{
+		Dir:   dir,
+		Ver:   p.Ver,
+		Op:    p.Op,
+		Seq:   p.Seq,
+		Size:  size,
+		Start: start,
+		End:   time.Now(),
+	})
 }
 
 // New .
@@ -64,28 +113,44 @@ func New() *Proto {
 }
 
 // WriteTCP .
-// packLen(32bit):headerLen(16bit):ver(16bit):op(16bit):body
+// packLen(32bit):headerLen(16bit):ver(16bit):op(16bit):seq(16bit):ext:body
 func (p *Proto) WriteTCP(wr *bufio.Writer) (err error) {
 	var (
-		buf     = make([]byte, _rawHeaderSize)
+		buf     []byte
+		extLen  int
 		packLen int
+		start   time.Time
 	)
+	if p.Hook != nil {
+		start = time.Now()
+	}
+	if extLen, err = extSize(p.Ext); err != nil {
+		return
+	}
 
-	packLen = int(_rawHeaderSize) + len(p.Body)
+	headerLen := int(_rawHeaderSize) + extLen
+	buf = make([]byte, headerLen)
+	packLen = headerLen + len(p.Body)
 	binary.BigEndian.PutUint32(buf[_packOffset:], uint32(packLen))
-	binary.BigEndian.PutUint16(buf[_headerOffset:], _rawHeaderSize)
+	binary.BigEndian.PutUint16(buf[_headerOffset:], uint16(headerLen))
 	binary.BigEndian.PutUint16(buf[_verOffset:], p.Ver)
 	binary.BigEndian.PutUint16(buf[_opOffset:], p.Op)
 	binary.BigEndian.PutUint16(buf[_seqOffset:], p.Seq)
+	putExt(buf[_rawHeaderSize:], p.Ext)
 
 	if _, err = wr.Write(buf); err != nil {
 		return
 	}
 
 	if p.Body != nil {
-		_, err = wr.Write(p.Body)
+		if _, err = wr.Write(p.Body); err != nil {
+			return
+		}
 	}
 
+	if p.Hook != nil {
+		p.hook(Write, packLen, start)
+	}
 	return
 }
 
@@ -96,8 +161,15 @@ func (p *Proto) ReadTCP(rr *bufio.Reader) (err error) {
 		headerLen uint16
 		packLen   int
 		buf       []byte
+		start     time.Time
 	)
 
+	if p.Hook != nil {
+		if _, err = rr.Peek(1); err != nil {
+			return
+		}
+		start = time.Now()
+	}
 	if buf, err = ReadNBytes(rr, int(_rawHeaderSize)); err != nil {
 		return
 	}
@@ -108,19 +180,57 @@ func (p *Proto) ReadTCP(rr *bufio.Reader) (err error) {
 	p.Op = binary.BigEndian.Uint16(buf[_opOffset:_seqOffset])
 	p.Seq = binary.BigEndian.Uint16(buf[_seqOffset:])
 
-	if headerLen != _rawHeaderSize {
+	if headerLen < _rawHeaderSize || int(headerLen) > int(_rawHeaderSize)+MaxExtSize ||
+		packLen < int(headerLen) {
 		return ErrProtoHeaderLen
 	}
+	p.Ext = nil
+	if extLen := int(headerLen - _rawHeaderSize); extLen > 0 {
+		if buf, err = ReadNBytes(rr, extLen); err != nil {
+			return unexpectedEOF(err)
+		}
+		if p.Ext, err = parseExt(buf); err != nil {
+			return
+		}
+	}
 
 	if bodyLen = packLen - int(headerLen); bodyLen > 0 {
-		p.Body, err = ReadNBytes(rr, bodyLen)
+		if p.Body, err = ReadNBytes(rr, bodyLen); err != nil {
+			return unexpectedEOF(err)
+		}
 	} else {
 		p.Body = nil
 	}
 
+	if p.Hook != nil {
+		p.hook(Read, packLen, start)
+	}
 	return
 }
 
+// unexpectedEOF reports the end of the stream within a frame as
+// io.ErrUnexpectedEOF, so only a stream ending between frames gives io.EOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// FrameBuffered reports whether rr holds a complete frame, which ReadTCP
+// can then read without blocking.
+func FrameBuffered(rr *bufio.Reader) bool {
+	n := rr.Buffered()
+	if n < int(_rawHeaderSize) {
+		return false
+	}
+	buf, err := rr.Peek(int(_packSize))
+	if err != nil {
+		return false
+	}
+	return int(binary.BigEndian.Uint32(buf)) <= n
+}
+
 // ReadNBytes . read limitted `N` bytes from bufio.Reader.
 func ReadNBytes(rr *bufio.Reader, N int) ([]byte, error) {
 	if rr == nil {
@@ -133,6 +243,9 @@ func ReadNBytes(rr *bufio.Reader, N int) ([]byte, error) {
 	)
 	for i := 0; i < N; i++ {
 		if buf[i], err = rr.ReadByte(); err != nil {
+			if err == io.EOF && i > 0 {
+				err = io.ErrUnexpectedEOF
+			}
 			return nil, err
 		}
 	}
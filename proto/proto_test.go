@@ -3,6 +3,7 @@ package proto
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
@@ -70,3 +71,87 @@ func Test_MaxSizeToTrans(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func Test_ProtoHook(t *testing.T) {
+	var infos []FrameInfo
+	hook := func(info FrameInfo) { infos = append(infos, info) }
+
+	p := New()
+	p.Op = OpRequest
+	p.Body = []byte("body")
+	p.Hook = hook
+
+	buf := bytes.NewBuffer(nil)
+	wr := bufio.NewWriter(buf)
+	if err := p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+
+	p2 := New()
+	p2.Hook = hook
+	if err := p2.ReadTCP(bufio.NewReader(buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 2 {
+		t.Fatalf("want 2 frames, got %d", len(infos))
+	}
+	for i, dir := range []Direction{Write, Read} {
+		info := infos[i]
+		if info.Dir != dir || info.Op != OpRequest || info.Size != int(_rawHeaderSize)+4 {
+			t.Errorf("unexpected frame info %+v", info)
+		}
+		if info.End.Before(info.Start) {
+			t.Errorf("frame ends before it starts: %+v", info)
+		}
+	}
+}
+
+func Test_FrameBuffered(t *testing.T) {
+	p := New()
+	p.Body = []byte("body")
+	buf := bytes.NewBuffer(nil)
+	wr := bufio.NewWriter(buf)
+	if err := p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	frame := buf.Bytes()
+
+	rr := bufio.NewReader(bytes.NewReader(frame[:len(frame)-1]))
+	rr.Peek(1)
+	if FrameBuffered(rr) {
+		t.Error("partial frame reported as buffered")
+	}
+	rr = bufio.NewReader(bytes.NewReader(frame))
+	rr.Peek(1)
+	if !FrameBuffered(rr) {
+		t.Error("complete frame not reported as buffered")
+	}
+}
+
+func Test_ReadTCPEOF(t *testing.T) {
+	p := New()
+	p.Body = []byte("body")
+	buf := bytes.NewBuffer(nil)
+	wr := bufio.NewWriter(buf)
+	if err := p.WriteTCP(wr); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	frame := buf.Bytes()
+
+	for _, tt := range []struct {
+		n    int
+		want error
+	}{
+		{0, io.EOF},
+		{3, io.ErrUnexpectedEOF},              // within the header
+		{len(frame) - 1, io.ErrUnexpectedEOF}, // within the body
+	} {
+		if err := New().ReadTCP(bufio.NewReader(bytes.NewReader(frame[:tt.n]))); err != tt.want {
+			t.Errorf("ReadTCP of %d bytes = %v, want %v", tt.n, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package xrpc
+
+import (
+	"context"
+	"time"
+)
+
+// WithMaxPending bounds the calls in flight or waiting for the connection
+// to n. Further calls wait up to wait for a slot, then fail with
+// ErrQueueFull; with wait <= 0 they fail at once. This keeps a dead or
+// saturated server from piling up blocked goroutines.
+func WithMaxPending(n int, wait time.Duration) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.queue = &callQueue{slots: make(chan struct{}, n), wait: wait}
+		}
+	}
+}
+
+type callQueue struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func (q *callQueue) acquire(ctx context.Context) error {
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if q.wait <= 0 {
+		return ErrQueueFull
+	}
+
+	t := time.NewTimer(q.wait)
+	defer t.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case <-t.C:
+		return ErrQueueFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *callQueue) release() {
+	<-q.slots
+}
+
+// Pending returns the number of calls in flight or waiting for the
+// connection, if WithMaxPending is set.
+func (c *Client) Pending() int {
+	if c.queue == nil {
+		return 0
+	}
+	return len(c.queue.slots)
+}
@@ -0,0 +1,63 @@
+package xrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_WithMaxPending(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Slow))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr, WithMaxPending(1, 0))
+	defer c.Close()
+
+	d := 100 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- c.Call("Slow.Sleep", &d, new(int)) }()
+	for c.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	zero := time.Duration(0)
+	err := c.Call("Slow.Sleep", &zero, new(int))
+	assert.True(t, errors.Is(err, ErrQueueFull))
+	assert.Nil(t, <-done)
+	assert.Equal(t, 0, c.Pending())
+	assert.Nil(t, c.Call("Slow.Sleep", &zero, new(int)))
+}
+
+func TestClient_WithMaxPendingWait(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Slow))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr, WithMaxPending(1, time.Second))
+	defer c.Close()
+
+	d := 50 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- c.Call("Slow.Sleep", &d, new(int)) }()
+	for c.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	zero := time.Duration(0)
+	assert.Nil(t, c.Call("Slow.Sleep", &zero, new(int)))
+	assert.Nil(t, <-done)
+
+	c2 := NewClientWithCodec(nil, addr, WithMaxPending(1, 20*time.Millisecond))
+	defer c2.Close()
+	d = 200 * time.Millisecond
+	go func() { done <- c2.Call("Slow.Sleep", &d, new(int)) }()
+	for c2.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	err := c2.Call("Slow.Sleep", &zero, new(int))
+	assert.True(t, errors.Is(err, ErrQueueFull))
+	assert.Nil(t, <-done)
+}
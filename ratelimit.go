@@ -0,0 +1,130 @@
+package xrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit paces calls to Rate per second, allowing bursts of Burst calls.
+// Calls over the limit wait for their turn if Wait is set, bounded by their
+// context, and fail with ErrRateLimited otherwise.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	Wait  bool
+}
+
+// WithRateLimit paces all calls of the client, e.g. to keep within the
+// quota of the server.
+func WithRateLimit(limit RateLimit) ClientOption {
+	return func(c *Client) {
+		c.limiter().global = newBucket(limit)
+	}
+}
+
+// WithMethodRateLimit paces the calls of method, in addition to the limit
+// of WithRateLimit.
+func WithMethodRateLimit(method string, limit RateLimit) ClientOption {
+	return func(c *Client) {
+		c.limiter().methods[method] = newBucket(limit)
+	}
+}
+
+func (c *Client) limiter() *rateLimiter {
+	if c.limits == nil {
+		c.limits = &rateLimiter{methods: make(map[string]*bucket)}
+	}
+	return c.limits
+}
+
+type rateLimiter struct {
+	global  *bucket
+	methods map[string]*bucket
+}
+
+// wait takes a token of method, waiting for it if allowed.
+func (l *rateLimiter) wait(ctx context.Context, method string) error {
+	m := l.methods[method]
+	if m != nil {
+		if err := m.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.global != nil {
+		if err := l.global.wait(ctx); err != nil {
+			if m != nil {
+				m.refund()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// bucket is a token bucket. Waiting calls take tokens in advance, so the
+// count goes negative and later calls queue behind them.
+type bucket struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit RateLimit) *bucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &bucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// take takes a token and returns how long to wait before using it, or
+// false when it isn't available and the limit doesn't wait.
+func (b *bucket) take() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if burst := float64(b.limit.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if !b.limit.Wait || b.limit.Rate <= 0 {
+		return 0, false
+	}
+	b.tokens--
+	return time.Duration(-b.tokens / b.limit.Rate * float64(time.Second)), true
+}
+
+func (b *bucket) refund() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+func (b *bucket) wait(ctx context.Context) error {
+	d, ok := b.take()
+	if !ok {
+		return ErrRateLimited
+	}
+	if d == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		b.refund()
+		return ctx.Err()
+	}
+}
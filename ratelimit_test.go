@@ -0,0 +1,55 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_WithRateLimit(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr,
+		WithRateLimit(RateLimit{Rate: 1, Burst: 3}),
+		WithMethodRateLimit("Int.Sum", RateLimit{Rate: 1, Burst: 1}))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+
+	// the method limit doesn't take from the global one when rejected
+	assert.True(t, errors.Is(c.Call("Int.Missing", &Args{}, &sum), ErrMethodNotFound))
+	assert.True(t, errors.Is(c.Call("Int.Missing", &Args{}, &sum), ErrMethodNotFound))
+	assert.True(t, errors.Is(c.Call("Int.Missing", &Args{}, &sum), ErrRateLimited))
+}
+
+func TestClient_WithRateLimitWait(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	addr := startServer(t, s)
+
+	c := NewClientWithCodec(nil, addr, WithRateLimit(RateLimit{Rate: 50, Burst: 1, Wait: true}))
+	defer c.Close()
+
+	var sum int
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	}
+	assert.True(t, time.Since(start) >= 35*time.Millisecond)
+
+	c2 := NewClientWithCodec(nil, addr, WithRateLimit(RateLimit{Rate: 1, Burst: 1, Wait: true}))
+	defer c2.Close()
+	assert.Nil(t, c2.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c2.CallContext(ctx, "Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrTimeout))
+}
@@ -0,0 +1,135 @@
+package xrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// serviceMapWatchWait is how long a watch call waits for a change.
+	serviceMapWatchWait = 30 * time.Second
+	// maxServiceMapWatchWait caps the wait asked by clients.
+	maxServiceMapWatchWait = 5 * time.Minute
+)
+
+// registry notifies the changes of the services registered on a server.
+type registry struct {
+	mu   sync.Mutex
+	next chan struct{} // closed on the next change
+	subs map[chan struct{}]struct{}
+}
+
+// wait returns a channel closed on the next change.
+func (r *registry) wait() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next == nil {
+		r.next = make(chan struct{})
+	}
+	return r.next
+}
+
+func (r *registry) changed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next != nil {
+		close(r.next)
+		r.next = nil
+	}
+	for ch := range r.subs {
+		select {
+		case ch <- struct{}{}:
+		default: // a change is pending already
+		}
+	}
+}
+
+// SubscribeServiceMap returns a channel receiving a value whenever
+// services were registered since the previous one, for gateways in the
+// process to refresh their routes from ExportServiceMap. Cancel ends the
+// subscription and closes the channel.
+func (s *Server) SubscribeServiceMap() (changes <-chan struct{}, cancel func()) {
+	ch := make(chan struct{}, 1)
+	s.registry.mu.Lock()
+	if s.registry.subs == nil {
+		s.registry.subs = make(map[chan struct{}]struct{})
+	}
+	s.registry.subs[ch] = struct{}{}
+	s.registry.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.registry.mu.Lock()
+			delete(s.registry.subs, ch)
+			s.registry.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// ServiceMapWatch are the args of XrpcServiceMap.Watch.
+type ServiceMapWatch struct {
+	Hash   string // of the map the client has, "" for none
+	WaitMs int64  // how long to wait for a change
+}
+
+// Watch replies with the service map once its hash is not args.Hash, or
+// after waiting args.WaitMs for it to change.
+func (sm *serviceMaps) Watch(ctx context.Context, args *ServiceMapWatch) (*ServiceMap, error) {
+	wait := time.Duration(args.WaitMs) * time.Millisecond
+	if wait > maxServiceMapWatchWait {
+		wait = maxServiceMapWatchWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		changed := sm.s.registry.wait()
+		m := sm.s.ExportServiceMap()
+		if m.Hash() != args.Hash {
+			return &m, nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return &m, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// WatchServiceMap calls fn with the service map of the server, which must
+// call ExposeServiceMap, then again whenever services are registered on
+// it, until ctx is done or a call fails. Each call waits on the server for
+// a change, holding the connection: watch with a client of its own.
+func (c *Client) WatchServiceMap(ctx context.Context, fn func(ServiceMap)) error {
+	var hash string
+	for {
+		m, err := c.watchServiceMap(ctx, hash)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		if h := m.Hash(); h != hash {
+			hash = h
+			fn(m)
+		}
+	}
+}
+
+func (c *Client) watchServiceMap(ctx context.Context, hash string) (ServiceMap, error) {
+	ctx, cancel := context.WithTimeout(ctx, serviceMapWatchWait+c.timeout)
+	defer cancel()
+
+	args := &ServiceMapWatch{Hash: hash, WaitMs: serviceMapWatchWait.Milliseconds()}
+	var m ServiceMap
+	err := c.CallContext(ctx, serviceMapService+".Watch", args, &m)
+	return m, err
+}
@@ -0,0 +1,78 @@
+package xrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_SubscribeServiceMap(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	changes, cancel := s.SubscribeServiceMap()
+
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.RegisterName(new(Files), "Stat"))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("no change notified")
+	}
+	select {
+	case <-changes:
+		t.Fatal("changes not coalesced")
+	default:
+	}
+
+	assert.NotNil(t, s.Register(new(Int)))
+	select {
+	case <-changes:
+		t.Fatal("failed registration notified")
+	default:
+	}
+
+	cancel()
+	_, open := <-changes
+	assert.False(t, open)
+	assert.Nil(t, s.Register(new(Calc))) // no subscriber left
+}
+
+func TestClient_WatchServiceMap(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.ExposeServiceMap())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() { _ = s.serve(l) }()
+	defer l.Close()
+
+	c := NewClientWithCodec(nil, l.Addr().String())
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	maps := make(chan ServiceMap, 4)
+	done := make(chan error)
+	go func() {
+		done <- c.WatchServiceMap(ctx, func(m ServiceMap) { maps <- m })
+	}()
+
+	next := func() ServiceMap {
+		select {
+		case m := <-maps:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("no service map")
+			return ServiceMap{}
+		}
+	}
+	assert.Equal(t, s.ExportServiceMap(), next())
+
+	assert.Nil(t, s.Register(new(Files)))
+	m := next()
+	assert.Equal(t, s.ExportServiceMap(), m)
+	assert.Equal(t, "Files.Stat", m.Methods[0].Name)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
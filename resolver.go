@@ -0,0 +1,98 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolver resolves a target into the addresses of its servers.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// dnsLookup is the subset of *net.Resolver used by DNSResolver.
+type dnsLookup interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNSResolver resolves a host name into one address per A/AAAA record, or
+// an SRV name into one address per record.
+type DNSResolver struct {
+	host    string
+	port    string
+	service string
+	proto   string
+	srv     bool
+	lookup  dnsLookup
+}
+
+func NewDNSResolver(hostport string) (*DNSResolver, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSResolver{host: host, port: port, lookup: net.DefaultResolver}, nil
+}
+
+// NewSRVResolver looks up _service._proto.name. Empty service and proto
+// look up name directly.
+func NewSRVResolver(service, proto, name string) *DNSResolver {
+	return &DNSResolver{host: name, service: service, proto: proto, srv: true, lookup: net.DefaultResolver}
+}
+
+func (r *DNSResolver) Resolve(ctx context.Context) ([]string, error) {
+	var addrs []string
+	if r.srv {
+		_, records, err := r.lookup.LookupSRV(ctx, r.service, r.proto, r.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+	} else {
+		hosts, err := r.lookup.LookupHost(ctx, r.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			addrs = append(addrs, net.JoinHostPort(host, r.port))
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("rpc: no address resolved for " + r.host)
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// Watch re-resolves r every interval and applies the result with SetAddrs
+// until ctx is done. Failed or empty resolutions keep the current
+// addresses. It blocks, so run it in a goroutine.
+func (m *MultiClient) Watch(ctx context.Context, r Resolver, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if addrs, err := r.Resolve(ctx); err != nil {
+			log.Printf("rpc: resolve err=%v", err)
+		} else {
+			m.SetAddrs(addrs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
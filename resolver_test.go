@@ -0,0 +1,94 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLookup struct {
+	mu    sync.Mutex
+	hosts []string
+	srvs  []*net.SRV
+	err   error
+}
+
+func (f *fakeLookup) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hosts, f.err
+}
+
+func (f *fakeLookup) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return name, f.srvs, f.err
+}
+
+func (f *fakeLookup) set(hosts []string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hosts, f.err = hosts, err
+}
+
+func TestDNSResolver_Resolve(t *testing.T) {
+	_, err := NewDNSResolver("no-port")
+	assert.NotNil(t, err)
+
+	r, err := NewDNSResolver("int.service:9999")
+	assert.Nil(t, err)
+	r.lookup = &fakeLookup{hosts: []string{"10.0.0.2", "10.0.0.1", "::1"}}
+	addrs, err := r.Resolve(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:9999", "10.0.0.2:9999", "[::1]:9999"}, addrs)
+
+	r.lookup = &fakeLookup{}
+	_, err = r.Resolve(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestSRVResolver_Resolve(t *testing.T) {
+	r := NewSRVResolver("xrpc", "tcp", "int.service")
+	r.lookup = &fakeLookup{srvs: []*net.SRV{
+		{Target: "b.int.service.", Port: 9999},
+		{Target: "a.int.service.", Port: 9998},
+	}}
+	addrs, err := r.Resolve(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.int.service:9998", "b.int.service:9999"}, addrs)
+}
+
+func TestMultiClient_Watch(t *testing.T) {
+	lookup := &fakeLookup{hosts: []string{"10.0.0.1"}}
+	r, _ := NewDNSResolver("int.service:9999")
+	r.lookup = lookup
+
+	m := NewMultiClientWithCodec(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Watch(ctx, r, time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(m.Addrs()) == 1
+	}, time.Second, time.Millisecond)
+
+	lookup.set(nil, errors.New("no such host"))
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, []string{"10.0.0.1:9999"}, m.Addrs())
+
+	lookup.set([]string{"10.0.0.1", "10.0.0.2"}, nil)
+	assert.Eventually(t, func() bool {
+		return len(m.Addrs()) == 2
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,148 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrPolicyNotMet = errors.New("rpc: scatter-gather policy not met")
+
+type GatherPolicy int
+
+const (
+	// GatherAll waits for every call to succeed.
+	GatherAll GatherPolicy = iota
+	// GatherAny returns as soon as one call succeeds.
+	GatherAny
+	// GatherQuorum returns as soon as a quorum of calls succeed.
+	GatherQuorum
+)
+
+type BatchCall struct {
+	Addr   string
+	Method string
+	Args   interface{}
+	Reply  interface{} // decoded into when the call succeeds, may be nil
+	Err    error
+	Done   bool // false if the call was still in flight when ScatterGather returned
+}
+
+type scatterOptions struct {
+	policy   GatherPolicy
+	quorum   int
+	deadline time.Duration
+}
+
+type ScatterOption func(*scatterOptions)
+
+func WithGatherPolicy(policy GatherPolicy) ScatterOption {
+	return func(o *scatterOptions) {
+		o.policy = policy
+	}
+}
+
+// WithQuorum selects GatherQuorum with n required successes. Without it the
+// quorum is a majority of the calls.
+func WithQuorum(n int) ScatterOption {
+	return func(o *scatterOptions) {
+		o.policy = GatherQuorum
+		o.quorum = n
+	}
+}
+
+func WithGatherDeadline(d time.Duration) ScatterOption {
+	return func(o *scatterOptions) {
+		o.deadline = d
+	}
+}
+
+// ScatterGather sends calls concurrently to their Addr and fills in their
+// outcome. It returns once the policy is met, every call finished, or the
+// deadline passed; calls still in flight at that point are left with Done
+// unset. The returned error reports whether the policy was met.
+func (m *MultiClient) ScatterGather(calls []*BatchCall, opts ...ScatterOption) error {
+	o := scatterOptions{policy: GatherAll}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	need := len(calls)
+	switch o.policy {
+	case GatherAny:
+		need = 1
+	case GatherQuorum:
+		need = len(calls)/2 + 1
+		if o.quorum > 0 {
+			need = o.quorum
+		}
+	}
+	if need > len(calls) {
+		return fmt.Errorf("%w: need %d of %d calls", ErrPolicyNotMet, need, len(calls))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if o.deadline > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), o.deadline)
+	}
+	// calls still in flight on return are no longer needed
+	defer cancel()
+
+	type outcome struct {
+		idx  int
+		resp Response
+		err  error
+	}
+	// buffered so calls abandoned after return do not block forever
+	ch := make(chan outcome, len(calls))
+	for idx, call := range calls {
+		c := m.Client(call.Addr)
+		if c == nil {
+			ch <- outcome{idx: idx, err: fmt.Errorf("rpc: unknown target %s", call.Addr)}
+			continue
+		}
+		go func(idx int, c *Client, method string, args interface{}) {
+			resp, err := c.call(ctx, method, args, callOptions{})
+			ch <- outcome{idx: idx, resp: resp, err: err}
+		}(idx, c, call.Method, call.Args)
+	}
+
+	var timeout <-chan struct{}
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		timeout = ctx.Done()
+	}
+
+	finished, succeeded := 0, 0
+	for finished < len(calls) && succeeded < need {
+		select {
+		case out := <-ch:
+			if out.err != nil && hasDeadline && !time.Now().Before(deadline) {
+				// the call was cut short by the deadline
+				return deadlineErr(succeeded, len(calls), need)
+			}
+			finished++
+			call := calls[out.idx]
+			call.Done = true
+			call.Err = out.err
+			if call.Err == nil && call.Reply != nil {
+				call.Err = out.resp.DecodeInto(call.Reply)
+			}
+			if call.Err == nil {
+				succeeded++
+			}
+		case <-timeout:
+			return deadlineErr(succeeded, len(calls), need)
+		}
+	}
+
+	if succeeded < need {
+		return fmt.Errorf("%w: %d of %d calls succeeded, need %d", ErrPolicyNotMet, succeeded, len(calls), need)
+	}
+	return nil
+}
+
+func deadlineErr(succeeded, total, need int) error {
+	return fmt.Errorf("%w: %d of %d calls succeeded before deadline, need %d", ErrTimeout, succeeded, total, need)
+}
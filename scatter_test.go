@@ -0,0 +1,56 @@
+package xrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Slow struct{}
+
+func (s *Slow) Sleep(d *time.Duration, reply *int) error {
+	time.Sleep(*d)
+	return nil
+}
+
+func TestMultiClient_ScatterGather(t *testing.T) {
+	s1 := NewServerWithCodec(nil)
+	_ = s1.Register(new(Int))
+	_ = s1.Register(new(Slow))
+	s2 := NewServerWithCodec(nil)
+	addr1, addr2 := startServer(t, s1), startServer(t, s2)
+
+	m := NewMultiClientWithCodec(nil, addr1, addr2)
+	defer m.Close()
+
+	newCalls := func() []*BatchCall {
+		return []*BatchCall{
+			{Addr: addr1, Method: "Int.Sum", Args: &Args{A: 1, B: 2}, Reply: new(int)},
+			{Addr: addr2, Method: "Int.Sum", Args: &Args{A: 1, B: 2}, Reply: new(int)},
+			{Addr: "unknown:1", Method: "Int.Sum", Args: &Args{A: 1, B: 2}},
+		}
+	}
+
+	calls := newCalls()
+	err := m.ScatterGather(calls)
+	assert.True(t, errors.Is(err, ErrPolicyNotMet))
+	assert.True(t, calls[0].Done)
+	assert.Nil(t, calls[0].Err)
+	assert.Equal(t, 3, *calls[0].Reply.(*int))
+	assert.True(t, errors.Is(calls[1].Err, ErrMethodNotFound))
+	assert.NotNil(t, calls[2].Err)
+
+	assert.Nil(t, m.ScatterGather(newCalls(), WithGatherPolicy(GatherAny)))
+	assert.True(t, errors.Is(m.ScatterGather(newCalls(), WithQuorum(2)), ErrPolicyNotMet))
+	assert.True(t, errors.Is(m.ScatterGather(newCalls(), WithQuorum(4)), ErrPolicyNotMet))
+
+	sleep := time.Second
+	calls = []*BatchCall{
+		{Addr: addr1, Method: "Slow.Sleep", Args: &sleep},
+	}
+	err = m.ScatterGather(calls, WithGatherDeadline(10*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.False(t, calls[0].Done)
+}
@@ -0,0 +1,139 @@
+package xrpc
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Schema describes the wire shape of an arg or reply type. It marshals to
+// JSON, so the schema of a released version can be stored and compared
+// against the current one with CheckCompat. Pointers are described by the
+// type they point to, since codecs flatten them.
+type Schema struct {
+	Name   string        `json:"name,omitempty"`
+	Kind   string        `json:"kind"`
+	Elem   *Schema       `json:"elem,omitempty"` // slice, array and map values
+	Key    *Schema       `json:"key,omitempty"`  // map keys
+	Fields []SchemaField `json:"fields,omitempty"`
+}
+
+type SchemaField struct {
+	Name     string `json:"name"` // as on the wire
+	Type     Schema `json:"type"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// SchemaOf returns the schema of the type of v.
+func SchemaOf(v interface{}) Schema {
+	return schemaOf(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) Schema {
+	if t == nil {
+		return Schema{Kind: "nil"}
+	}
+	t = indirectType(t)
+	s := Schema{Name: t.Name(), Kind: t.Kind().String()}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := schemaOf(t.Elem(), visiting)
+		s.Elem = &elem
+	case reflect.Map:
+		key, elem := schemaOf(t.Key(), visiting), schemaOf(t.Elem(), visiting)
+		s.Key, s.Elem = &key, &elem
+	case reflect.Struct:
+		// recursive types are described once
+		if visiting[t] {
+			return s
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+				continue
+			}
+			var rule fieldRule
+			_ = rule.parse(f, f.Tag.Get("xrpc"))
+			s.Fields = append(s.Fields, SchemaField{
+				Name:     fieldName(f),
+				Type:     schemaOf(f.Type, visiting),
+				Required: rule.required,
+			})
+		}
+	}
+	return s
+}
+
+// CheckCompat lists the changes from oldSchema to newSchema which break
+// peers still using the old one: removed fields, fields whose type
+// changed, and new required fields. An empty result means the change is
+// safe.
+func CheckCompat(oldSchema, newSchema Schema) []string {
+	var problems []string
+	checkCompat("", oldSchema, newSchema, &problems)
+	return problems
+}
+
+func checkCompat(path string, old, new Schema, problems *[]string) {
+	where := path
+	if where == "" {
+		where = "root"
+	}
+	if kindClass(old.Kind) != kindClass(new.Kind) {
+		*problems = append(*problems, fmt.Sprintf("%s: type changed from %s to %s", where, old.Kind, new.Kind))
+		return
+	}
+	if old.Key != nil && new.Key != nil {
+		checkCompat(path+"[key]", *old.Key, *new.Key, problems)
+	}
+	if old.Elem != nil && new.Elem != nil {
+		checkCompat(path+"[]", *old.Elem, *new.Elem, problems)
+	}
+	if old.Kind != reflect.Struct.String() {
+		return
+	}
+
+	newFields := make(map[string]SchemaField, len(new.Fields))
+	for _, f := range new.Fields {
+		newFields[f.Name] = f
+	}
+	oldFields := make(map[string]bool, len(old.Fields))
+	for _, f := range old.Fields {
+		oldFields[f.Name] = true
+		nf, ok := newFields[f.Name]
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: field removed", joinPath(path, f.Name)))
+			continue
+		}
+		checkCompat(joinPath(path, f.Name), f.Type, nf.Type, problems)
+	}
+	for _, f := range new.Fields {
+		if !oldFields[f.Name] && f.Required {
+			*problems = append(*problems, fmt.Sprintf("%s: new required field", joinPath(path, f.Name)))
+		}
+	}
+}
+
+// kindClass groups the kinds the codecs convert between.
+func kindClass(kind string) string {
+	switch kind {
+	case "int", "int8", "int16", "int32", "int64":
+		return "int"
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return "uint"
+	case "float32", "float64":
+		return "float"
+	case "slice", "array":
+		return "list"
+	}
+	return kind
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,66 @@
+package xrpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Node struct {
+	Name     string
+	Children []*Node
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf(&SearchArgs{})
+	assert.Equal(t, "SearchArgs", s.Name)
+	assert.Equal(t, "struct", s.Kind)
+	assert.Equal(t, SchemaField{Name: "q", Type: Schema{Name: "string", Kind: "string"}, Required: true}, s.Fields[0])
+	assert.Equal(t, "Page", s.Fields[5].Type.Name)
+	assert.Equal(t, "map", s.Fields[6].Type.Kind)
+
+	// recursive types terminate
+	node := SchemaOf(Node{})
+	assert.Equal(t, "Node", node.Fields[1].Type.Elem.Name)
+	assert.Empty(t, node.Fields[1].Type.Elem.Fields)
+
+	// schemas survive being stored as JSON
+	b, err := json.Marshal(s)
+	assert.Nil(t, err)
+	var stored Schema
+	assert.Nil(t, json.Unmarshal(b, &stored))
+	assert.Equal(t, s, stored)
+	assert.Empty(t, CheckCompat(stored, s))
+}
+
+func TestCheckCompat(t *testing.T) {
+	type v1 struct {
+		A    int
+		B    string
+		C    []int
+		Keep map[string]int32
+	}
+	type v2 struct {
+		A    int64  // same class
+		B    int    // changed
+		C    []bool // changed element
+		Keep map[string]int
+		D    string // new optional field
+		E    int    `xrpc:"required"`
+	}
+	problems := CheckCompat(SchemaOf(v1{}), SchemaOf(&v2{}))
+	assert.Equal(t, []string{
+		"B: type changed from string to int",
+		"C[]: type changed from int to bool",
+		"E: new required field",
+	}, problems)
+
+	type v3 struct {
+		A int
+	}
+	assert.Equal(t, []string{"B: field removed", "C: field removed", "Keep: field removed"},
+		CheckCompat(SchemaOf(v1{}), SchemaOf(v3{})))
+	assert.Equal(t, []string{"root: type changed from struct to int"},
+		CheckCompat(SchemaOf(v1{}), SchemaOf(0)))
+}
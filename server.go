@@ -2,16 +2,22 @@ package xrpc
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"reflect"
+	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dabao-zhao/xrpc/proto"
@@ -20,19 +26,90 @@ import (
 type Server struct {
 	m     sync.Map    // map[string]*service
 	codec ServerCodec // codec to read request and writeResponse
+
+	settingsMu       sync.RWMutex // guards settings reloaded from config
+	acceptMinBackoff time.Duration
+	acceptMaxBackoff time.Duration
+	onAcceptErr      func(err error)
+	fallback         FallbackHandler
+	gzipMinSize      int             // 0 disables compression
+	cacheable        map[string]bool // methods answered with an ETag
+	getMethods       map[string]bool // methods served over GET too
+	watchdog         *watchdog
+
+	lifeMu  sync.Mutex // guards running and errCh
+	running bool
+	errCh   chan error
+
+	logger       *log.Logger
+	debugLogger  *log.Logger // nil drops debug messages
+	tlsConfig    *tls.Config
+	listeners    []ListenerConfig // served by Run
+	configPath   string
+	certReloader *CertReloader
+
+	slowLog      *SlowLogConfig
+	trace        *traceRing
+	interceptors []Interceptor
+
+	aliases    sync.Map // alias -> method
+	deprecated sync.Map // method -> *deprecation
+
+	frameHook       proto.FrameHook
+	readBufSize     int
+	writeBufSize    int
+	flushInterval   time.Duration
+	maxBatchSize    int // 0 for no limit
+	batchWorkers    int
+	codes           *CodeTranslator
+	transformers    []ResultTransformer
+	auditor         *Auditor
+	journal         *Journal
+	transactor      BatchTransactor
+	blobs           *blobs
+	normalizeName   NameNormalizer
+	serviceNamer    ServiceNamer
+	stuckTimeout    time.Duration // 0 waits for frames forever
+	idleTimeout     time.Duration
+	connEvents      ServerConnEvents
+	codeCounts      codeCounters
+	debugToken      string // enables the debug endpoints of AdminHandler
+	adminRoutes     map[string]http.Handler
+	burnAlert       *burnAlert
+	envelope        bool // wrap results in a ReplyEnvelope
+	minProtoVersion uint16
+	tlsSniffTimeout time.Duration // 0 for the default
+	maxMethodLen    int           // bytes, 0 for the default
+	maxAttachments  int64         // bytes of multipart requests, 0 refuses them
+	schemaPolicy    SchemaPolicy
+	registry        registry // notifies changes of the registered services
+
+	id int64 // channelz id
+	cz serverz
 }
 
-func NewServerWithCodec(codec ServerCodec) *Server {
+func NewServerWithCodec(codec ServerCodec, opts ...ServerOption) *Server {
 	if codec == nil {
 		codec = NewGobCodec()
 	}
-	return &Server{codec: codec}
+	s := &Server{
+		codec:            codec,
+		acceptMinBackoff: defaultAcceptMinBackoff,
+		acceptMaxBackoff: defaultAcceptMaxBackoff,
+		logger:           log.Default(),
+		id:               nextChannelzID(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *Server) Register(data interface{}) error {
+func (s *Server) Register(data interface{}, opts ...RegisterOption) error {
 	srv := new(service)
 	srv.typ = reflect.TypeOf(data)
 	srv.val = reflect.ValueOf(data)
+	srv.registeredAt = caller()
 	sName := reflect.Indirect(srv.val).Type().Name()
 
 	if sName == "" {
@@ -42,137 +119,464 @@ func (s *Server) Register(data interface{}) error {
 	if !isExported(sName) {
 		return errors.New("rpc.Register: type " + sName + " is not exported")
 	}
+	sName, err := s.serviceName(srv.val, sName)
+	if err != nil {
+		return err
+	}
 	srv.name = sName
 	srv.method = suitableMethods(srv.typ)
+	for _, opt := range opts {
+		if err := opt(srv); err != nil {
+			return err
+		}
+	}
 
-	if _, dup := s.m.LoadOrStore(sName, srv); dup {
-		return errors.New("rpc: service already defined: " + sName)
+	if i, dup := s.m.LoadOrStore(sName, srv); dup {
+		return fmt.Errorf("rpc: service already defined: %s, by %s", sName, i.(*service).origin())
 	}
+	s.registry.changed()
 	return nil
 }
 
-func (s *Server) RegisterName(data interface{}, methodName string) error {
+func (s *Server) RegisterName(data interface{}, methodName string, opts ...RegisterOption) error {
 	srv := new(service)
 	srv.typ = reflect.TypeOf(data)
 	srv.val = reflect.ValueOf(data)
-	sName := reflect.Indirect(srv.val).Type().Name()
+	srv.registeredAt = caller()
+	typeName := reflect.Indirect(srv.val).Type().Name()
 
 	mt := suitableMethodWithName(srv.typ, methodName)
+	if mt == nil {
+		return fmt.Errorf("rpc.RegisterName: type %s has no suitable method %s", srv.typ, methodName)
+	}
+	if typeName == "" {
+		return errors.New("rpc.Register: no service name for type " + srv.typ.String())
+	}
+	if !isExported(typeName) {
+		return errors.New("rpc.Register: type " + typeName + " is not exported")
+	}
+	sName, err := s.serviceName(srv.val, typeName)
+	if err != nil {
+		return err
+	}
+	srv.name = sName
+	srv.method = map[string]*methodType{mt.method.Name: mt}
+	for _, opt := range opts {
+		if err := opt(srv); err != nil {
+			return err
+		}
+	}
 
 	i, ex := s.m.Load(sName)
 	if ex {
 		loadedSrv := i.(*service)
-		loadedSrv.method[mt.method.Name] = mt
-		s.m.Store(sName, loadedSrv)
-	} else {
-		if sName == "" {
-			return errors.New("rpc.Register: no service name for type " + srv.typ.String())
+		if loadedSrv.factory != nil {
+			return fmt.Errorf("rpc: service already defined: %s, by a factory; can't add method %s of %s",
+				sName, methodName, srv.typ)
 		}
-
-		if !isExported(sName) {
-			return errors.New("rpc.Register: type " + sName + " is not exported")
+		if loadedSrv.typ != srv.typ {
+			return fmt.Errorf("rpc: service already defined: %s, by %s; can't add method %s of %s",
+				sName, loadedSrv.origin(), methodName, srv.typ)
+		}
+		if _, dup := loadedSrv.method[mt.method.Name]; dup {
+			return fmt.Errorf("rpc: method already defined: %s.%s, by %s", sName, methodName, loadedSrv.origin())
+		}
+		// copy the method map, which may be read by calls in flight
+		updated := *loadedSrv
+		updated.method = make(map[string]*methodType, len(loadedSrv.method)+1)
+		for name, m := range loadedSrv.method {
+			updated.method[name] = m
 		}
-		srv.name = sName
-		srv.method = make(map[string]*methodType)
-		srv.method[mt.method.Name] = mt
+		updated.method[mt.method.Name] = mt
+		s.m.Store(sName, &updated)
+	} else {
 		s.m.Store(sName, srv)
 	}
+	s.registry.changed()
 	return nil
 }
 
-func (s *Server) call(reqs []Request) (replies []Response) {
-	replies = make([]Response, len(reqs))
+// Services returns the registered services and their methods, sorted by
+// name.
+func (s *Server) Services() map[string][]string {
+	services := make(map[string][]string)
+	s.m.Range(func(key, value interface{}) bool {
+		srv := value.(*service)
+		methods := make([]string, 0, len(srv.method))
+		for name := range srv.method {
+			methods = append(methods, name)
+		}
+		sort.Strings(methods)
+		services[key.(string)] = methods
+		return true
+	})
+	return services
+}
+
+// caller returns the position of the code which called the caller of
+// caller, for diagnostics.
+func caller() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func (s *Server) call(ctx context.Context, reqs []Request) (replies []Response) {
+	if len(reqs) == 0 {
+		return append(getResponses(0), s.frameErr(InvalidRequest, errors.New("rpc: empty batch")))
+	}
+	if s.maxBatchSize > 0 && len(reqs) > s.maxBatchSize {
+		err := fmt.Errorf("rpc: batch of %d requests exceeds the limit of %d", len(reqs), s.maxBatchSize)
+		return append(getResponses(0), s.frameErr(InvalidRequest, err))
+	}
+
+	// replies are matched to requests by id
+	if id, dup := duplicateId(reqs); dup {
+		return append(getResponses(0), s.frameErr(InvalidRequest, fmt.Errorf("rpc: duplicate request id %q in batch", id)))
+	}
+
+	// calls off a connection, e.g. over HTTP, share PerConn instances with
+	// their batch
+	if _, ok := ctx.Value(connInstancesKey{}).(*connInstances); !ok {
+		var instances *connInstances
+		ctx, instances = withConnInstances(ctx)
+		defer instances.close()
+	}
+
+	if s.transactor != nil && len(reqs) > 1 {
+		return s.callInTx(ctx, reqs)
+	}
+
+	// the requests of the batch share its context, and none outlives it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	replies = getResponses(len(reqs))
+	handle := func(idx int) {
+		req := reqs[idx]
+		if err := ctx.Err(); err != nil {
+			replies[idx] = s.errResponse(fmt.Errorf("rpc: batch abandoned: %w", err))
+			replies[idx].SetReqId(req.GetId())
+			s.countCode(req, replies[idx].GetErrCode())
+			return
+		}
+		start := time.Now()
+		replies[idx] = s.handleRequest(ctx, req)
+		s.observe(ctx, req, replies[idx], time.Since(start))
+	}
+
+	// one goroutine per request, or a bounded number of workers taking
+	// turns on large batches
+	workers := s.batchWorkers
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
 	wg := sync.WaitGroup{}
-	wg.Add(len(reqs))
-	for idx, req := range reqs {
-		go func(req Request, idx int) {
-			defer wg.Done()
-			replies[idx] = s.handleRequest(req)
-		}(req, idx)
+	if len(reqs) <= workers {
+		wg.Add(len(reqs))
+		for idx := range reqs {
+			go func(idx int) {
+				defer wg.Done()
+				handle(idx)
+			}(idx)
+		}
+	} else {
+		next := int64(-1)
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				defer wg.Done()
+				for idx := int(atomic.AddInt64(&next, 1)); idx < len(reqs); idx = int(atomic.AddInt64(&next, 1)) {
+					handle(idx)
+				}
+			}()
+		}
 	}
 	wg.Wait()
 	return
 }
 
+var responsesPool = sync.Pool{
+	New: func() interface{} {
+		resps := make([]Response, 0, 8)
+		return &resps
+	},
+}
+
+// getResponses returns a slice of n responses, which can be handed back
+// with releaseResponses.
+func getResponses(n int) []Response {
+	resps := *responsesPool.Get().(*[]Response)
+	if cap(resps) < n {
+		return make([]Response, n)
+	}
+	return resps[:n]
+}
+
+// releaseResponses hands resps and, if the codec reuses them, the
+// responses themselves back for reuse.
+func (s *Server) releaseResponses(resps []Response) {
+	for i, resp := range resps {
+		if resp != nil {
+			s.releaseResponse(resp)
+		}
+		resps[i] = nil
+	}
+	resps = resps[:0]
+	responsesPool.Put(&resps)
+}
+
+// releaseResponse hands resp back for reuse if the codec reuses responses.
+func (s *Server) releaseResponse(resp Response) {
+	if releaser, ok := s.codec.(ResponseReleaser); ok {
+		releaser.ReleaseResponse(resp)
+	}
+}
+
+// observe is called once per handled request.
+func (s *Server) observe(ctx context.Context, req Request, resp Response, d time.Duration) {
+	svcName, mName, mt := s.registeredMethod(req)
+	s.codeCounts.add(svcName, mName, resp.GetErrCode())
+	if mt != nil && mt.slo != nil {
+		s.recordSLO(mt.slo, resp.GetErrCode(), d, time.Now())
+	}
+	if s.slowLog != nil {
+		s.logSlow(req, resp, d)
+	}
+	if s.trace != nil {
+		s.trace.add(ctx, req, resp, d)
+	}
+}
+
 func (s *Server) serveConn(conn net.Conn) {
-	rr := bufio.NewReader(conn)
-	wr := bufio.NewWriter(conn)
+	if pending, ok := conn.(*tlsPendingConn); ok {
+		tlsConn, err := s.startTLS(pending)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		conn = tlsConn
+	}
+	atomic.AddInt64(&openConns, 1)
+	defer atomic.AddInt64(&openConns, -1)
+	defer conn.Close()
+	cc, untrack := s.trackConn(conn)
+	defer untrack()
+
+	rr := bufio.NewReaderSize(conn, bufSize(s.readBufSize))
+	wr := bufio.NewWriterSize(conn, bufSize(s.writeBufSize))
+	ctx, instances := withConnInstances(withPeer(context.Background(), conn.RemoteAddr().String()))
+	defer instances.close()
 
 	var (
 		pRec  = proto.New()
 		pSend = proto.New()
-		resps = make([]Response, 0)
+		frame proto.FrameInfo
 	)
+	pRec.Hook = func(info proto.FrameInfo) {
+		frame = info
+		if s.frameHook != nil {
+			s.frameHook(info)
+		}
+	}
+	pSend.Hook = s.frameHook
 
+	// write sends pSend. With a flush interval, responses to pipelined
+	// requests are coalesced while complete requests are already buffered,
+	// as long as the oldest unflushed one waited less than the interval.
+	var unflushed time.Time
+	write := func() {
+		_ = pSend.WriteTCP(wr)
+		if s.flushInterval > 0 && proto.FrameBuffered(rr) {
+			if unflushed.IsZero() {
+				unflushed = time.Now()
+			}
+			if time.Since(unflushed) < s.flushInterval {
+				return
+			}
+		}
+		_ = wr.Flush()
+		unflushed = time.Time{}
+	}
+
+	beat := proto.New()
+	beat.Op = proto.OpHeartbeat
 	for {
-		if err := pRec.ReadTCP(rr); err != nil {
-			log.Printf("ReadTCP error: %v", err)
+		if err := s.readFrame(conn, rr, pRec); err != nil {
+			s.endConn(conn, wr, err)
 			break
 		}
-		reqs, err := s.codec.ReadRequest(pRec.Body)
-		if err != nil {
-			resps = append(resps, s.codec.ErrResponse(ParseErr, err))
-			if pSend.Body, err = s.codec.EncodeResponses(resps); err != nil {
-				log.Printf("could not encode responses, err=%v", err)
-				continue
+		if pRec.Ver < s.minProtoVersion {
+			_ = wr.Flush()
+			_ = s.refuse(conn, fmt.Sprintf("frame version %d is below the minimum %d", pRec.Ver, s.minProtoVersion))
+			break
+		}
+		if pRec.Op == proto.OpHello {
+			if !s.hello(conn, wr, pRec) {
+				break
 			}
-			_ = pSend.WriteTCP(wr)
+			continue
+		}
+		if pRec.Op == proto.OpHeartbeat {
+			_ = beat.WriteTCP(wr)
 			_ = wr.Flush()
 			continue
 		}
-		resps = s.call(reqs)
+		reqs, err := s.codec.ReadRequest(pRec.Body)
+		decoded := time.Now()
+		cc.received(len(pRec.Body), len(reqs))
+
+		var resps []Response
+		if err != nil {
+			resps = append(getResponses(0), s.frameErr(ParseErr, err))
+		} else {
+			callCtx, stop := watchConn(ctx, conn, rr)
+			resps = s.call(withWireStats(callCtx, WireStats{Frame: frame, Decode: decoded.Sub(frame.End)}), reqs)
+			stop()
+		}
 		if pSend.Body, err = s.codec.EncodeResponses(resps); err != nil {
-			log.Printf("could not encode responses, err=%v", err)
-			continue
+			s.logger.Printf("could not encode responses, err=%v", err)
+		} else {
+			write()
+			cc.sent(len(pSend.Body), resps)
 		}
-		_ = pSend.WriteTCP(wr)
-		_ = wr.Flush()
+		s.releaseResponses(resps)
 	}
 }
 
-func (s *Server) ServeTCP(addr string) {
-	log.Printf("RPC server over TCP is listening: %s", addr)
-
+func (s *Server) ServeTCP(addr string) error {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if s.tlsConfig != nil {
+		listener = requireTLS(listener)
 	}
+	s.logger.Printf("RPC server over TCP is listening: %s", addr)
+
+	return s.serve(listener)
+}
+
+// Serve accepts TCP connections on listener until it fails. The listener
+// is used as is, without the TLS config.
+func (s *Server) Serve(listener net.Listener) error {
+	return s.serve(listener)
+}
+
+func (s *Server) serve(listener net.Listener) error {
+	defer listener.Close()
+	defer s.trackListener("tcp", listener)()
 
+	var backoff time.Duration
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("listener.Accept(), err=%v", err)
-			continue
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				min, max := s.acceptBackoff()
+				if backoff == 0 {
+					backoff = min
+				} else if backoff *= 2; backoff > max {
+					backoff = max
+				}
+				s.logger.Printf("listener.Accept(), err=%v; retrying in %v", err, backoff)
+				time.Sleep(backoff)
+				continue
+			}
+			if s.onAcceptErr != nil {
+				s.onAcceptErr(err)
+			}
+			return err
 		}
+		backoff = 0
 
 		go s.serveConn(conn)
 	}
 }
 
+func (s *Server) acceptBackoff() (min, max time.Duration) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	return s.acceptMinBackoff, s.acceptMaxBackoff
+}
+
+func (s *Server) setAcceptBackoff(min, max time.Duration) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	if min > 0 {
+		s.acceptMinBackoff = min
+	}
+	if max >= s.acceptMinBackoff {
+		s.acceptMaxBackoff = max
+	}
+}
+
 func (s *Server) ListenAndServe(addr string) {
-	log.Printf("RPC server over HTTP is listening: %s", addr)
-	if err := http.ListenAndServe(
-		addr,
-		http.TimeoutHandler(s, 5*time.Second, "timeout"),
-	); err != nil {
+	if err := s.listenAndServe(addr); err != nil {
 		panic(err)
 	}
 }
 
+func (s *Server) listenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.serveHTTP(s.newHTTPServer(), listener)
+}
+
+func (s *Server) newHTTPServer() *http.Server {
+	return &http.Server{
+		Handler:   http.TimeoutHandler(s, 5*time.Second, "timeout"),
+		TLSConfig: s.tlsConfig,
+		ErrorLog:  s.logger,
+	}
+}
+
+func (s *Server) serveHTTP(srv *http.Server, listener net.Listener) error {
+	defer s.trackListener("http", listener)()
+	s.logger.Printf("RPC server over HTTP is listening: %s", listener.Addr())
+
+	if s.tlsConfig != nil {
+		return srv.ServeTLS(listener, "", "")
+	}
+	return srv.Serve(listener)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	defer func() {
 		if err, ok := recover().(error); ok && err != nil {
-			log.Printf("[ServeHTTP] recover %v with stack: \n", err)
+			s.logger.Printf("[ServeHTTP] recover %v with stack: \n", err)
 			debug.PrintStack()
 		}
 	}()
 
 	var (
 		data []byte
-		b    []byte
 		err  error
 	)
 
+	if s.gzipMinSize > 0 && acceptsGzip(req) {
+		gw := newGzipResponseWriter(w, s.gzipMinSize)
+		defer gw.Close()
+		w = gw
+	}
+
+	if req.Method == http.MethodGet && s.getMethods != nil {
+		rpcReqs, rpcErr := s.readGetRequest(req)
+		if rpcErr != nil {
+			resp := s.frameErr(rpcErr.ErrCode, rpcErr)
+			b, _ := s.codec.EncodeResponses(resp)
+			_ = s.codec.Send(w, http.StatusOK, b)
+			return
+		}
+		s.respondHTTP(w, req, rpcReqs)
+		return
+	}
+
 	if req.Method != http.MethodPost {
 		err := errors.New("method not allowed: " + req.Method)
 		resp := s.codec.ErrResponse(MethodNotFound, err)
@@ -181,6 +585,26 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if s.maxAttachments > 0 && isMultipart(req.Header.Get("Content-Type")) {
+		rpcReqs, attachments, rpcErr := s.readMultipart(req)
+		if rpcErr != nil {
+			resp := s.frameErr(rpcErr.ErrCode, rpcErr)
+			b, _ := s.codec.EncodeResponses(resp)
+			_ = s.codec.Send(w, http.StatusOK, b)
+			return
+		}
+		s.respondHTTP(w, req.WithContext(withAttachments(req.Context(), attachments)), rpcReqs)
+		return
+	}
+
+	if !s.acceptsContentType(req.Header.Get("Content-Type")) {
+		err := errors.New("unsupported content type, want " + s.codec.ContentType())
+		resp := s.codec.ErrResponse(InvalidRequest, err)
+		b, _ := s.codec.EncodeResponses(resp)
+		_ = s.codec.Send(w, http.StatusUnsupportedMediaType, b)
+		return
+	}
+
 	if data, err = io.ReadAll(req.Body); err != nil {
 		resp := s.codec.ErrResponse(InvalidParamErr, err)
 		b, _ := s.codec.EncodeResponses(resp)
@@ -193,30 +617,102 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	rpcReqs, err := s.codec.ReadRequest(data)
 	if err != nil {
-		resp := s.codec.ErrResponse(ParseErr, err)
+		resp := s.frameErr(ParseErr, err)
 		b, _ := s.codec.EncodeResponses(resp)
 		_ = s.codec.Send(w, http.StatusOK, b)
 		return
 	}
 
-	resps := s.call(rpcReqs)
+	s.respondHTTP(w, req, rpcReqs)
+}
+
+// respondHTTP calls the requests of req and sends their responses.
+func (s *Server) respondHTTP(w http.ResponseWriter, req *http.Request, rpcReqs []Request) {
+	resps := s.call(withPeer(req.Context(), req.RemoteAddr), rpcReqs)
+	if s.sendCached(w, req, rpcReqs, resps) {
+		return
+	}
+	_ = s.codec.Send(w, http.StatusOK, s.encodeHTTPResponses(resps))
+}
+
+// encodeHTTPResponses encodes resps as the body of an HTTP response: a
+// single response unless the request was a batch.
+func (s *Server) encodeHTTPResponses(resps []Response) []byte {
+	var b []byte
 	if len(resps) == 1 {
 		b, _ = s.codec.EncodeResponses(resps[0])
 	} else {
 		b, _ = s.codec.EncodeResponses(resps)
 	}
-	_ = s.codec.Send(w, http.StatusOK, b)
-	return
+	return b
+}
+
+// acceptsContentType reports whether a request body of media type
+// contentType can be read by the codec. A missing Content-Type is accepted.
+func (s *Server) acceptsContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == s.codec.ContentType()
+}
+
+// frameErr answers a whole frame or batch with an error.
+func (s *Server) frameErr(code Code, err error) Response {
+	s.codeCounts.add(unknownLabel, unknownLabel, code)
+	return s.codec.ErrResponse(code, err)
 }
 
-func (s *Server) handleRequest(req Request) Response {
+// duplicateId returns an id shared by requests of reqs. Requests without
+// an id, as the gob codec sends them, are matched by position.
+func duplicateId(reqs []Request) (string, bool) {
+	if len(reqs) < 2 {
+		return "", false
+	}
+	seen := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		id := req.GetId()
+		if id == "" {
+			continue
+		}
+		if seen[id] {
+			return id, true
+		}
+		seen[id] = true
+	}
+	return "", false
+}
+
+func (s *Server) handleRequest(ctx context.Context, req Request) Response {
 	var (
 		reply Response
 	)
 	defer func() {
 		reply.SetReqId(req.GetId())
 	}()
-	serviceName, methodName, err := parseFromRPCMethod(req.GetMethod())
+	if bad, ok := req.(*MalformedRequest); ok {
+		reply = s.codec.ErrResponse(InvalidRequest, bad.Err)
+		return reply
+	}
+	if s.watchdog != nil {
+		if err := s.watchdog.admit(req); err != nil {
+			reply = s.errResponse(err)
+			return reply
+		}
+	}
+	limit := s.maxMethodLen
+	if limit <= 0 {
+		limit = maxMethodLen
+	}
+	if n := len(req.GetMethod()); n > limit {
+		reply = s.codec.ErrResponse(InvalidRequest, fmt.Errorf("rpc: method name of %d bytes exceeds the limit of %d", n, limit))
+		return reply
+	}
+	method := s.resolveAlias(req.GetMethod())
+	if s.normalizeName != nil {
+		method = s.canonicalMethod(method)
+	}
+	serviceName, methodName, err := parseFromRPCMethod(method)
 	if err != nil {
 		reply = s.codec.ErrResponse(InvalidRequest, err)
 		return reply
@@ -224,54 +720,217 @@ func (s *Server) handleRequest(req Request) Response {
 
 	svcI, ok := s.m.Load(serviceName)
 	if !ok {
-		reply = s.codec.ErrResponse(MethodNotFound, errors.New("rpc: can't find service "+serviceName))
+		if fn := s.fallbackHandler(); fn != nil {
+			reply = s.callFallback(ctx, fn, req)
+			return reply
+		}
+		reply = s.codec.ErrResponse(MethodNotFound, s.notFound(method, "rpc: can't find service "+serviceName))
 		return reply
 	}
 
 	svc := svcI.(*service)
 	mType := svc.method[methodName]
 	if mType == nil {
-		reply = s.codec.ErrResponse(MethodNotFound, errors.New("rpc: can't find method "+req.GetMethod()))
+		if fn := s.fallbackHandler(); fn != nil {
+			reply = s.callFallback(ctx, fn, req)
+			return reply
+		}
+		reply = s.codec.ErrResponse(MethodNotFound, s.notFound(method, "rpc: can't find method "+req.GetMethod()))
 		return reply
 	}
+	defer func() {
+		s.warnDeprecated(reply, req.GetMethod(), method)
+	}()
 
 	var (
 		argV       reflect.Value
+		argVs      []reflect.Value
 		argIsValue = false
 	)
-	if mType.ArgType.Kind() == reflect.Ptr {
-		argV = reflect.New(mType.ArgType.Elem())
+	if mType.ArgTypes != nil {
+		if argVs, err = s.readPositional(req, mType); err != nil {
+			reply = s.errResponse(err)
+			return reply
+		}
+		params := make(Positional, len(argVs))
+		for i, v := range argVs {
+			params[i] = v.Interface()
+		}
+		argV = reflect.ValueOf(params)
 	} else {
-		argV = reflect.New(mType.ArgType)
-		argIsValue = true
+		if mType.ArgType.Kind() == reflect.Ptr {
+			argV = reflect.New(mType.ArgType.Elem())
+		} else {
+			argV = reflect.New(mType.ArgType)
+			argIsValue = true
+		}
+		if argIsValue {
+			argV = argV.Elem() // argV guaranteed to be a pointer now.
+		}
+
+		if err := s.codec.ReadRequestBody(req.GetParams(), argV.Interface()); err != nil {
+			reply = s.codec.ErrResponse(InternalErr, errors.New("rpc: could not read request body "+req.GetMethod()))
+			return reply
+		}
+		if err := prepareArgs(argV); err != nil {
+			reply = s.errResponse(err)
+			return reply
+		}
+		argVs = []reflect.Value{argV}
 	}
-	if argIsValue {
-		argV = argV.Elem() // argV guaranteed to be a pointer now.
+
+	// replyV is a pointer to the reply, which a returning method stores
+	// its result into.
+	var replyV reflect.Value
+	if mType.returns {
+		replyV = reflect.New(mType.ReplyType)
+	} else {
+		replyV = reflect.New(mType.ReplyType.Elem())
+		switch mType.ReplyType.Elem().Kind() {
+		case reflect.Map:
+			replyV.Elem().Set(reflect.MakeMap(mType.ReplyType.Elem()))
+		case reflect.Slice:
+			replyV.Elem().Set(reflect.MakeSlice(mType.ReplyType.Elem(), 0, 0))
+		}
 	}
 
-	if err := s.codec.ReadRequestBody(req.GetParams(), argV.Interface()); err != nil {
-		reply = s.codec.ErrResponse(InternalErr, errors.New("rpc: could not read request body "+req.GetMethod()))
+	start := time.Now()
+	ctx = withMetadata(ctx, req.GetMetadata())
+	var warns *warnings
+	if s.envelope {
+		ctx, warns = withWarnings(ctx)
+	}
+	if timeout, ok := requestTimeout(req.GetMetadata()); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// callCtx is the context the method got, as set by interceptors
+	callCtx := ctx
+	invoke := func(ctx context.Context, args, reply interface{}) error {
+		callCtx = ctx
+		return svc.call(ctx, mType, argVs, replyV)
+	}
+	if len(s.interceptors) > 0 {
+		info := &CallInfo{
+			Method:   req.GetMethod(),
+			Metadata: req.GetMetadata(),
+			Peer:     peerFromContext(ctx),
+		}
+		invoke = chainInterceptors(s.interceptors, info, invoke)
+	}
+
+	ack, err := s.journalBegin(ctx, mType, method, req)
+	if err != nil {
+		reply = s.codec.ErrResponse(InternalErr, fmt.Errorf("rpc: could not journal request: %v", err))
 		return reply
 	}
+	defer ack()
 
-	var replyV reflect.Value
-	replyV = reflect.New(mType.ReplyType.Elem())
-	switch mType.ReplyType.Elem().Kind() {
-	case reflect.Map:
-		replyV.Elem().Set(reflect.MakeMap(mType.ReplyType.Elem()))
-	case reflect.Slice:
-		replyV.Elem().Set(reflect.MakeSlice(mType.ReplyType.Elem(), 0, 0))
+	if s.auditor != nil && s.auditor.audits(mType) {
+		start := time.Now()
+		defer func() {
+			s.audit(callCtx, method, req, reply, time.Since(start))
+		}()
 	}
 
-	if err := svc.call(mType, argV, replyV); err != nil {
-		reply = s.codec.ErrResponse(InternalErr, err)
+	if err := invoke(ctx, argV.Interface(), replyV.Interface()); err != nil {
+		reply = s.errResponse(err)
 	} else {
-		reply = s.codec.NewResponse(replyV.Interface())
+		result := replyV
+		if mType.returns {
+			result = replyV.Elem()
+		}
+		if mask := req.GetMetadata().Get(FieldMaskKey); mask != "" {
+			result = parseFieldMask(mask).apply(result)
+		}
+		res := s.transformResult(method, result.Interface())
+		if s.envelope {
+			res = s.envelop(req, res, warns, start, req.GetMethod(), method)
+		}
+		if reply = s.codec.NewResponse(res); reply == nil {
+			reply = s.codec.ErrResponse(InternalErr, errors.New("rpc: could not encode the reply of "+method))
+		}
 	}
 
 	return reply
 }
 
+// readPositional reads the params of a method taking several args, which
+// come as an array.
+func (s *Server) readPositional(req Request, mType *methodType) ([]reflect.Value, error) {
+	var params []RawMessage
+	if err := s.codec.ReadRequestBody(req.GetParams(), &params); err != nil {
+		return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: "rpc: params of " + req.GetMethod() + " must be an array"}
+	}
+	if len(params) != len(mType.ArgTypes) {
+		return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: fmt.Sprintf("rpc: %s takes %d params, got %d", req.GetMethod(), len(mType.ArgTypes), len(params))}
+	}
+
+	args := make([]reflect.Value, len(params))
+	for i, t := range mType.ArgTypes {
+		argV := reflect.New(t)
+		if t.Kind() == reflect.Ptr {
+			argV = reflect.New(t.Elem())
+		}
+		if err := s.codec.ReadRequestBody(params[i], argV.Interface()); err != nil {
+			return nil, &Error{ErrCode: InvalidParamErr, ErrMsg: fmt.Sprintf("rpc: could not read param %d of %s", i, req.GetMethod())}
+		}
+		if err := prepareArgs(argV); err != nil {
+			return nil, err
+		}
+		if t.Kind() != reflect.Ptr {
+			argV = argV.Elem()
+		}
+		args[i] = argV
+	}
+	return args, nil
+}
+
+// audit hands the record of a call which took d to the auditor.
+func (s *Server) audit(ctx context.Context, method string, req Request, resp Response, d time.Duration) {
+	rec := AuditRecord{
+		Time:       time.Now(),
+		Method:     method,
+		Peer:       peerFromContext(ctx),
+		ParamsHash: paramsHash(req.GetParams()),
+		Code:       resp.GetErrCode(),
+		Duration:   d,
+	}
+	rec.Principal, _ = PrincipalFromContext(ctx)
+	rec.Tenant, _ = TenantFromContext(ctx)
+	if err := resp.Error(); err != nil {
+		rec.Error = err.Error()
+	}
+	s.auditor.add(rec)
+}
+
+// errResponse keeps the code of an *Error, other errors are internal.
+func (s *Server) errResponse(err error) Response {
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		if s.codes == nil {
+			return s.codec.ErrResponse(InternalErr, err)
+		}
+		rpcErr = &Error{ErrCode: InternalErr, ErrMsg: err.Error()}
+	}
+	if s.codes != nil {
+		rpcErr = s.codes.ToWire(rpcErr)
+	}
+	return s.codec.ErrResponse(rpcErr.ErrCode, rpcErr)
+}
+
+type peerKey struct{}
+
+func withPeer(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, peerKey{}, addr)
+}
+
+func peerFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(peerKey{}).(string)
+	return addr
+}
+
 func parseFromRPCMethod(reqMethod string) (serviceName, methodName string, err error) {
 	if strings.Count(reqMethod, ".") != 1 {
 		return "", "", fmt.Errorf("rpc: service/method request ill-formed: %s", reqMethod)
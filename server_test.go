@@ -1,8 +1,19 @@
 package xrpc
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 type Args struct {
@@ -48,9 +59,198 @@ func TestServer_call(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := s.call([]Request{tt.args.req}); !reflect.DeepEqual(got, tt.want) {
+			if got := s.call(context.Background(), []Request{tt.args.req}); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Server.call() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+type tempErr struct{}
+
+func (tempErr) Error() string   { return "temporary" }
+func (tempErr) Timeout() bool   { return false }
+func (tempErr) Temporary() bool { return true }
+
+type flakyListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	err := l.errs[0]
+	l.errs = l.errs[1:]
+	return nil, err
+}
+
+func (l *flakyListener) Close() error { return nil }
+
+func (l *flakyListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestServer_serveBackoff(t *testing.T) {
+	var fatal error
+	s := NewServerWithCodec(nil,
+		WithAcceptBackoff(time.Millisecond, 2*time.Millisecond),
+		WithAcceptErrorHandler(func(err error) { fatal = err }),
+	)
+
+	closed := errors.New("closed")
+	l := &flakyListener{errs: []error{tempErr{}, tempErr{}, tempErr{}, closed}}
+	err := s.serve(l)
+	assert.Equal(t, closed, err)
+	assert.Equal(t, closed, fatal)
+	assert.Empty(t, l.errs)
+}
+
+func TestServer_ServeTCPListenErr(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.NotNil(t, s.ServeTCP("256.0.0.1:0"))
+}
+
+func TestServer_ServeHTTPContentType(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	codec := NewGobCodec()
+	reqs := []Request{codec.NewRequest("Int.Sum", &Args{A: 1, B: 2})}
+	body, err := codec.EncodeRequests(&reqs)
+	assert.Nil(t, err)
+
+	for contentType, want := range map[string]int{
+		"":                                  http.StatusOK,
+		"application/x-gob":                 http.StatusOK,
+		"application/x-gob; charset=binary": http.StatusOK,
+		"application/json":                  http.StatusUnsupportedMediaType,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, contentType)
+		assert.Equal(t, "application/x-gob", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestServer_releaseResponses(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	resps := getResponses(2)
+	assert.Len(t, resps, 2)
+	resps[0] = s.codec.NewResponse(1)
+
+	s.releaseResponses(resps)
+	assert.Equal(t, []Response{nil, nil}, resps)
+}
+
+type Gauge struct {
+	cur, max int64
+}
+
+func (g *Gauge) Hold(args *int, reply *int) error {
+	n := atomic.AddInt64(&g.cur, 1)
+	defer atomic.AddInt64(&g.cur, -1)
+	for {
+		max := atomic.LoadInt64(&g.max)
+		if n <= max || atomic.CompareAndSwapInt64(&g.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	*reply = *args
+	return nil
+}
+
+func TestServer_callBatchLimits(t *testing.T) {
+	codec := NewGobCodec()
+	g := new(Gauge)
+	s := NewServerWithCodec(codec, WithMaxBatchSize(50), WithBatchWorkers(4))
+	assert.Nil(t, s.Register(g))
+
+	reqs := make([]Request, 40)
+	for i := range reqs {
+		reqs[i] = codec.NewRequest("Gauge.Hold", i)
+	}
+	resps := s.call(context.Background(), reqs)
+	assert.Len(t, resps, 40)
+	for i, resp := range resps {
+		var n int
+		assert.Nil(t, resp.DecodeInto(&n))
+		assert.Equal(t, i, n)
+	}
+	assert.True(t, atomic.LoadInt64(&g.max) <= 4)
+
+	resps = s.call(context.Background(), make([]Request, 51))
+	assert.Len(t, resps, 1)
+	assert.Equal(t, InvalidRequest, resps[0].GetErrCode())
+}
+
+func TestServer_callMalformed(t *testing.T) {
+	codec := NewGobCodec()
+	s := NewServerWithCodec(codec)
+	assert.Nil(t, s.Register(new(Int)))
+
+	resps := s.call(context.Background(), nil)
+	assert.Len(t, resps, 1)
+	assert.Equal(t, InvalidRequest, resps[0].GetErrCode())
+	assert.Equal(t, "rpc: empty batch", resps[0].Error().Error())
+
+	resps = s.call(context.Background(), []Request{
+		codec.NewRequest("Int."+strings.Repeat("x", maxMethodLen), &Args{}),
+		codec.NewRequest("Int.Sum", &Args{A: 1, B: 2}),
+	})
+	assert.Equal(t, InvalidRequest, resps[0].GetErrCode())
+	assert.Equal(t, Success, resps[1].GetErrCode())
+
+	resps = s.call(context.Background(), []Request{
+		&MalformedRequest{Id: "1", Err: errors.New("bad")},
+		codec.NewRequest("Int.Sum", &Args{A: 1, B: 2}),
+	})
+	assert.Equal(t, InvalidRequest, resps[0].GetErrCode())
+	assert.Equal(t, Success, resps[1].GetErrCode())
+
+	dup := []Request{
+		&defaultRequest{Method: "Int.Sum", Id: "7"},
+		&defaultRequest{Method: "Int.Sum", Id: "8"},
+		&defaultRequest{Method: "Int.Sum", Id: "7"},
+	}
+	resps = s.call(context.Background(), dup)
+	assert.Len(t, resps, 1)
+	assert.Equal(t, InvalidRequest, resps[0].GetErrCode())
+	assert.Equal(t, `rpc: duplicate request id "7" in batch`, resps[0].Error().Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resps = s.call(ctx, dup[:2])
+	assert.Len(t, resps, 2)
+	assert.Contains(t, resps[1].Error().Error(), "batch abandoned")
+}
+
+func TestServer_Services(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.RegisterName(new(Echo), "Say"))
+	assert.Equal(t, map[string][]string{
+		"Int":  {"Sum"},
+		"Echo": {"Say"},
+	}, s.Services())
+}
+
+func TestServer_RegisterConflicts(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+
+	err := s.Register(new(Int))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "service already defined: Int")
+		assert.Contains(t, err.Error(), "type *xrpc.Int registered at")
+		assert.Contains(t, err.Error(), "server_test.go")
+	}
+
+	err = s.RegisterName(new(Int), "Sum")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "method already defined: Int.Sum")
+	}
+
+	err = s.RegisterName(new(Int), "Missing")
+	assert.NotNil(t, err)
+}
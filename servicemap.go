@@ -0,0 +1,139 @@
+package xrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const serviceMapService = "XrpcServiceMap"
+
+// ServiceMap is the RPC surface of a server: the signatures of its
+// methods, without their docs. It marshals to JSON, so a deploy can export
+// the map of one node and check every other node, or clients, against it.
+type ServiceMap struct {
+	Methods []MethodSignature `json:"methods"` // sorted by name
+}
+
+// MethodSignature is the wire shape of a method.
+type MethodSignature struct {
+	Name   string   `json:"name"` // Service.Method
+	Params Schema   `json:"params"`
+	Args   []Schema `json:"args,omitempty"` // of methods taking several params, in order
+	Result Schema   `json:"result"`
+}
+
+// ExportServiceMap returns the service map of the methods registered on
+// the server, leaving out the services of the package.
+func (s *Server) ExportServiceMap() ServiceMap {
+	m := ServiceMap{Methods: []MethodSignature{}}
+	s.m.Range(func(key, value interface{}) bool {
+		srv := value.(*service)
+		if srv.internal {
+			return true
+		}
+		for name, mt := range srv.method {
+			var args []Schema
+			for _, t := range mt.ArgTypes {
+				args = append(args, schemaOf(t, make(map[reflect.Type]bool)))
+			}
+			m.Methods = append(m.Methods, MethodSignature{
+				Name:   srv.name + "." + name,
+				Params: schemaOf(mt.ArgType, make(map[reflect.Type]bool)),
+				Args:   args,
+				Result: schemaOf(mt.ReplyType, make(map[reflect.Type]bool)),
+			})
+		}
+		return true
+	})
+	sort.Slice(m.Methods, func(i, j int) bool { return m.Methods[i].Name < m.Methods[j].Name })
+	return m
+}
+
+// Hash returns a digest of m, equal for equal maps.
+func (m ServiceMap) Hash() string {
+	b, _ := json.Marshal(m)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff lists the differences from m to other: methods missing from other,
+// methods only other has, and methods whose signatures differ.
+func (m ServiceMap) Diff(other ServiceMap) []string {
+	theirs := make(map[string]MethodSignature, len(other.Methods))
+	for _, sig := range other.Methods {
+		theirs[sig.Name] = sig
+	}
+	var diff []string
+	for _, sig := range m.Methods {
+		their, ok := theirs[sig.Name]
+		delete(theirs, sig.Name)
+		switch {
+		case !ok:
+			diff = append(diff, sig.Name+": missing")
+		case !reflect.DeepEqual(sig.Params, their.Params) || !reflect.DeepEqual(sig.Args, their.Args):
+			diff = append(diff, sig.Name+": params differ")
+		case !reflect.DeepEqual(sig.Result, their.Result):
+			diff = append(diff, sig.Name+": result differs")
+		}
+	}
+	for name := range theirs {
+		diff = append(diff, name+": unexpected")
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// ServiceMapMismatch is the error of a service map check, listing the
+// differences from the expected map, see ServiceMap.Diff.
+type ServiceMapMismatch struct {
+	Diff []string
+}
+
+func (e *ServiceMapMismatch) Error() string {
+	return "rpc: service map mismatch: " + strings.Join(e.Diff, "; ")
+}
+
+// VerifyServiceMap checks that the server exposes exactly the methods of
+// want, returning a *ServiceMapMismatch otherwise.
+func (s *Server) VerifyServiceMap(want ServiceMap) error {
+	if diff := want.Diff(s.ExportServiceMap()); len(diff) > 0 {
+		return &ServiceMapMismatch{Diff: diff}
+	}
+	return nil
+}
+
+// ExposeServiceMap serves the service map of the server to clients, for
+// Client.VerifyServiceMap.
+func (s *Server) ExposeServiceMap() error {
+	return s.registerInternal(serviceMapService, &serviceMaps{s: s})
+}
+
+// serviceMaps is the service map service.
+type serviceMaps struct {
+	s *Server
+}
+
+func (sm *serviceMaps) Get(args *struct{}, reply *ServiceMap) error {
+	*reply = sm.s.ExportServiceMap()
+	return nil
+}
+
+// VerifyServiceMap fetches the service map of the server, which must call
+// ExposeServiceMap, and checks it's want, returning a *ServiceMapMismatch
+// otherwise. Clients call it at startup to fail fast on version skew.
+func (c *Client) VerifyServiceMap(ctx context.Context, want ServiceMap) error {
+	var got ServiceMap
+	if err := c.CallContext(ctx, serviceMapService+".Get", &struct{}{}, &got); err != nil {
+		return fmt.Errorf("rpc: could not fetch the service map: %w", err)
+	}
+	if diff := want.Diff(got); len(diff) > 0 {
+		return &ServiceMapMismatch{Diff: diff}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// IntV2 is Int with a changed result and a new method.
+type IntV2 struct{}
+
+func (i *IntV2) Sum(args *Args, reply *int64) error { return nil }
+
+func (i *IntV2) Max(args *Args, reply *int) error { return nil }
+
+func TestServer_ExportServiceMap(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.HandleBlob("logs", func(ctx context.Context, r io.Reader) (interface{}, error) { return "", nil }))
+	assert.Nil(t, s.ExposeServiceMap())
+
+	m := s.ExportServiceMap()
+	assert.Len(t, m.Methods, 1)
+	assert.Equal(t, "Int.Sum", m.Methods[0].Name)
+
+	// a round trip through JSON, as between deploys, keeps it
+	b, err := json.Marshal(m)
+	assert.Nil(t, err)
+	var imported ServiceMap
+	assert.Nil(t, json.Unmarshal(b, &imported))
+	assert.Equal(t, m.Hash(), imported.Hash())
+	assert.Nil(t, s.VerifyServiceMap(imported))
+
+	v2 := NewServerWithCodec(nil, WithServiceNamer(func(reflect.Type) string { return "Int" }))
+	assert.Nil(t, v2.Register(new(IntV2)))
+	err = v2.VerifyServiceMap(imported)
+	var mismatch *ServiceMapMismatch
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, []string{"Int.Max: unexpected", "Int.Sum: result differs"}, mismatch.Diff)
+	assert.NotEqual(t, m.Hash(), v2.ExportServiceMap().Hash())
+
+	assert.Equal(t, []string{"Int.Sum: missing"}, m.Diff(ServiceMap{}))
+}
+
+func TestClient_VerifyServiceMap(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Int)))
+	addr := startServer(t, s)
+	c := NewClientWithCodec(nil, addr)
+	defer c.Close()
+
+	want := s.ExportServiceMap()
+	assert.NotNil(t, c.VerifyServiceMap(context.Background(), want)) // not exposed
+
+	assert.Nil(t, s.ExposeServiceMap())
+	assert.Nil(t, c.VerifyServiceMap(context.Background(), want))
+
+	want.Methods[0].Name = "Int.Add"
+	err := c.VerifyServiceMap(context.Background(), want)
+	var mismatch *ServiceMapMismatch
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, []string{"Int.Add: missing", "Int.Sum: unexpected"}, mismatch.Diff)
+}
@@ -0,0 +1,107 @@
+package xrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type flightCall struct {
+	done   chan struct{} // closed once resp and err are set
+	resp   Response
+	err    error
+	cancel context.CancelFunc
+
+	waiters int // guarded by the mu of the group
+}
+
+// flightGroup coalesces concurrent calls sharing a key into one.
+type flightGroup struct {
+	methods map[string]bool
+
+	mu sync.Mutex
+	m  map[string]*flightCall
+}
+
+func (g *flightGroup) match(method string) bool {
+	return g.methods == nil || g.methods[method]
+}
+
+// do returns the outcome of fn for key, joining the call in flight if any.
+// fn runs on a context with the values of the ctx of the first caller but
+// neither its deadline nor its cancellation, so callers giving up don't
+// fail the others: each waits on its own ctx, and fn is canceled once none
+// is left waiting.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (Response, error)) (Response, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*flightCall)
+	}
+	fc, ok := g.m[key]
+	if !ok {
+		var shared context.Context
+		shared, cancel := context.WithCancel(detachedContext{ctx})
+		fc = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.m[key] = fc
+		go func() {
+			fc.resp, fc.err = fn(shared)
+			g.mu.Lock()
+			if g.m[key] == fc {
+				delete(g.m, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(fc.done)
+		}()
+	}
+	fc.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-fc.done:
+		return fc.resp, fc.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if fc.waiters--; fc.waiters == 0 {
+			fc.cancel()
+			// later callers start afresh rather than join a canceled call
+			if g.m[key] == fc {
+				delete(g.m, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext has the values of its parent, but neither its deadline
+// nor its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// perCallKeys are the metadata keys which differ between calls of the same
+// request, e.g. set by PropagateContext, and don't tell requests apart.
+var perCallKeys = map[string]bool{TimeoutKey: true, TraceIDKey: true, PriorityKey: true}
+
+// requestKey identifies a request by its method, encoded params and
+// metadata, but for perCallKeys.
+func requestKey(req Request) string {
+	h := sha256.New()
+	h.Write(req.GetParams())
+	md := req.GetMetadata()
+	for _, k := range md.keys() {
+		if perCallKeys[k] {
+			continue
+		}
+		h.Write([]byte("\x00" + k + "\x00" + md[k]))
+	}
+	return req.GetMethod() + "\x00" + hex.EncodeToString(h.Sum(nil))
+}
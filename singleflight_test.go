@@ -0,0 +1,81 @@
+package xrpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Counter struct {
+	n int32
+}
+
+func (c *Counter) Get(d *time.Duration, reply *int32) error {
+	time.Sleep(*d)
+	*reply = atomic.AddInt32(&c.n, 1)
+	return nil
+}
+
+func TestClient_Singleflight(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	counter := new(Counter)
+	_ = s.Register(counter)
+	c := NewClientWithCodec(nil, startServer(t, s), WithSingleflight("Counter.Get"))
+	defer c.Close()
+
+	d := 50 * time.Millisecond
+	replies := make([]int32, 5)
+	wg := sync.WaitGroup{}
+	for i := range replies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Nil(t, c.Call("Counter.Get", &d, &replies[i]))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&counter.n))
+	for _, reply := range replies {
+		assert.Equal(t, int32(1), reply)
+	}
+
+	var reply int32
+	assert.Nil(t, c.Call("Counter.Get", &d, &reply))
+	assert.Equal(t, int32(2), reply)
+}
+
+func TestFlightGroup_match(t *testing.T) {
+	assert.True(t, (&flightGroup{}).match("Int.Sum"))
+	assert.True(t, (&flightGroup{methods: methodSet([]string{"Int.Sum"})}).match("Int.Sum"))
+	assert.False(t, (&flightGroup{methods: methodSet([]string{"Int.Sum"})}).match("Int.Sub"))
+}
+
+func TestClient_SingleflightLeaderCanceled(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	counter := new(Counter)
+	_ = s.Register(counter)
+	c := NewClientWithCodec(nil, startServer(t, s), WithSingleflight("Counter.Get"))
+	defer c.Close()
+
+	d := 100 * time.Millisecond
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		var reply int32
+		leaderErr <- c.CallContext(leaderCtx, "Counter.Get", &d, &reply)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// the waiter joins, then the leader gives up
+	time.AfterFunc(20*time.Millisecond, cancel)
+	var reply int32
+	assert.Nil(t, c.Call("Counter.Get", &d, &reply))
+	assert.Equal(t, int32(1), reply)
+	assert.Equal(t, context.Canceled, <-leaderErr)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&counter.n))
+}
@@ -0,0 +1,180 @@
+package xrpc
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLO is the objective of a method: the fraction of its calls which must
+// be good. A call is bad if it failed with a server error or, if Latency
+// is not 0, took longer than Latency.
+type SLO struct {
+	Objective float64       // e.g. 0.999
+	Latency   time.Duration // 0 judges errors only
+}
+
+// WithMethodSLO sets the objective of the method of the service being
+// registered, e.g. WithMethodSLO("Sum", SLO{Objective: 0.999}). See
+// SLOStatus.
+func WithMethodSLO(method string, slo SLO) RegisterOption {
+	return func(srv *service) error {
+		mt, ok := srv.method[method]
+		if !ok {
+			return fmt.Errorf("rpc: no method %s.%s to set an SLO for", srv.name, method)
+		}
+		if slo.Objective <= 0 || slo.Objective >= 1 {
+			return fmt.Errorf("rpc: SLO objective of %s.%s must be in (0, 1), got %v", srv.name, method, slo.Objective)
+		}
+		mt.slo = &sloTracker{service: srv.name, method: method, slo: slo}
+		return nil
+	}
+}
+
+// The burn rates are computed over a short and a long window, the long one
+// keeping a short spike from alerting, the short one telling when it's over.
+const (
+	sloShortWindow = 5 * time.Minute
+	sloLongWindow  = time.Hour
+	sloBuckets     = int(sloLongWindow / time.Minute)
+)
+
+// SLOStatus is how fast a method spends its error budget. A burn rate of 1
+// spends the budget exactly over the SLO period, 14.4 spends 2% of a 30 day
+// budget in an hour.
+type SLOStatus struct {
+	Service string  `json:"service"`
+	Method  string  `json:"method"`
+	SLO     SLO     `json:"slo"`
+	Short   float64 `json:"short"` // burn rate over the last 5 minutes
+	Long    float64 `json:"long"`  // burn rate over the last hour
+}
+
+// SLOStatus returns the burn rates of the methods with an SLO, sorted by
+// service and method.
+func (s *Server) SLOStatus() []SLOStatus {
+	now := time.Now()
+	var status []SLOStatus
+	s.m.Range(func(key, value interface{}) bool {
+		for _, mt := range value.(*service).method {
+			if mt.slo != nil {
+				status = append(status, mt.slo.status(now))
+			}
+		}
+		return true
+	})
+	sort.Slice(status, func(i, j int) bool {
+		if status[i].Service != status[j].Service {
+			return status[i].Service < status[j].Service
+		}
+		return status[i].Method < status[j].Method
+	})
+	return status
+}
+
+type burnAlert struct {
+	threshold float64
+	fn        func(SLOStatus)
+}
+
+// WithBurnRateAlert calls fn when a method with an SLO burns its error
+// budget faster than threshold over both the last 5 minutes and the last
+// hour, at most once per 5 minutes per method. 14.4 is the usual threshold
+// to page at. fn is called on the goroutine of the call and must not block.
+func WithBurnRateAlert(threshold float64, fn func(SLOStatus)) ServerOption {
+	return func(s *Server) {
+		s.burnAlert = &burnAlert{threshold: threshold, fn: fn}
+	}
+}
+
+func (s *Server) recordSLO(t *sloTracker, code Code, d time.Duration, now time.Time) {
+	good := !code.IsServerError() && (t.slo.Latency == 0 || d <= t.slo.Latency)
+	t.record(now, good)
+	if s.burnAlert == nil {
+		return
+	}
+	if st, ok := t.alert(now, s.burnAlert.threshold); ok {
+		s.burnAlert.fn(st)
+	}
+}
+
+type sloBucket struct {
+	minute     int64 // unix minute the counts are of
+	total, bad uint64
+}
+
+// sloTracker counts the good and bad calls of a method per minute, over the
+// long window.
+type sloTracker struct {
+	service, method string
+	slo             SLO
+
+	mu        sync.Mutex
+	buckets   [sloBuckets]sloBucket
+	lastAlert time.Time
+}
+
+func (t *sloTracker) record(now time.Time, good bool) {
+	minute := now.Unix() / 60
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.buckets[minute%int64(sloBuckets)]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	b.total++
+	if !good {
+		b.bad++
+	}
+}
+
+func (t *sloTracker) status(now time.Time) SLOStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statusLocked(now)
+}
+
+// alert returns the status of the method if it burns its budget faster than
+// threshold and it didn't alert within the short window.
+func (t *sloTracker) alert(now time.Time, threshold float64) (SLOStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastAlert) < sloShortWindow {
+		return SLOStatus{}, false
+	}
+	st := t.statusLocked(now)
+	if st.Short < threshold || st.Long < threshold {
+		return SLOStatus{}, false
+	}
+	t.lastAlert = now
+	return st, true
+}
+
+func (t *sloTracker) statusLocked(now time.Time) SLOStatus {
+	return SLOStatus{
+		Service: t.service,
+		Method:  t.method,
+		SLO:     t.slo,
+		Short:   t.burnRate(now, sloShortWindow),
+		Long:    t.burnRate(now, sloLongWindow),
+	}
+}
+
+// burnRate is the ratio of bad calls over the window, in units of the
+// error budget.
+func (t *sloTracker) burnRate(now time.Time, window time.Duration) float64 {
+	minute := now.Unix() / 60
+	since := minute - int64(window/time.Minute)
+	var total, bad uint64
+	for _, b := range t.buckets {
+		if b.minute > since && b.minute <= minute {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(bad) / float64(total) / (1 - t.slo.Objective)
+}
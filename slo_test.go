@@ -0,0 +1,89 @@
+package xrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSloTracker_burnRate(t *testing.T) {
+	tr := &sloTracker{slo: SLO{Objective: 0.99}}
+	now := time.Unix(3600*24, 0)
+
+	// an hour ago, out of both windows
+	tr.record(now.Add(-time.Hour), false)
+	// 30 minutes ago, 1 bad in 10
+	for i := 0; i < 10; i++ {
+		tr.record(now.Add(-30*time.Minute), i > 0)
+	}
+	// now, 1 bad in 2
+	tr.record(now, true)
+	tr.record(now, false)
+
+	st := tr.status(now)
+	assert.InDelta(t, 50, st.Short, 1e-9)
+	assert.InDelta(t, 2.0/12/0.01, st.Long, 1e-9)
+	assert.Equal(t, 0.0, tr.status(now.Add(2*time.Hour)).Long)
+}
+
+func TestSloTracker_alert(t *testing.T) {
+	tr := &sloTracker{slo: SLO{Objective: 0.9}}
+	now := time.Unix(3600*24, 0)
+
+	tr.record(now, true)
+	_, ok := tr.alert(now, 1)
+	assert.False(t, ok)
+
+	tr.record(now, false)
+	st, ok := tr.alert(now, 1)
+	assert.True(t, ok)
+	assert.InDelta(t, 5, st.Short, 1e-9)
+
+	// once per short window
+	_, ok = tr.alert(now.Add(time.Minute), 1)
+	assert.False(t, ok)
+	tr.record(now.Add(sloShortWindow), false)
+	_, ok = tr.alert(now.Add(sloShortWindow), 1)
+	assert.True(t, ok)
+}
+
+func TestServer_SLO(t *testing.T) {
+	var alerts []SLOStatus
+	codec := NewGobCodec()
+	s := NewServerWithCodec(codec, WithBurnRateAlert(1.5, func(st SLOStatus) {
+		alerts = append(alerts, st)
+	}))
+	assert.Nil(t, s.Register(new(Int), WithMethodSLO("Sum", SLO{Objective: 0.75, Latency: time.Hour})))
+	assert.NotNil(t, s.Register(new(Shop), WithMethodSLO("Missing", SLO{Objective: 0.999})))
+	assert.NotNil(t, s.Register(new(Shop), WithMethodSLO("Buy", SLO{Objective: 1})))
+
+	s.call(context.Background(), []Request{codec.NewRequest("Int.Sum", &Args{A: 1, B: 2})})
+	assert.Equal(t, []SLOStatus{{Service: "Int", Method: "Sum", SLO: SLO{Objective: 0.75, Latency: time.Hour}}}, s.SLOStatus())
+	assert.Empty(t, alerts)
+
+	// client errors spend no budget
+	mt := registeredMethodType(t, s, "Int", "Sum")
+	s.recordSLO(mt.slo, InvalidParamErr, 0, time.Now())
+	assert.Equal(t, 0.0, s.SLOStatus()[0].Short)
+
+	s.recordSLO(mt.slo, InternalErr, 0, time.Now())
+	s.recordSLO(mt.slo, Success, 2*time.Hour, time.Now())
+	st := s.SLOStatus()[0]
+	assert.InDelta(t, 2.0, st.Short, 1e-9)
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, "Sum", alerts[0].Method)
+
+	w := httptest.NewRecorder()
+	s.AdminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "xrpc_server_slo_burn_rate{service=\"Int\",method=\"Sum\",window=\"5m\"} 2\n")
+	assert.Contains(t, w.Body.String(), "xrpc_server_slo_burn_rate{service=\"Int\",method=\"Sum\",window=\"1h\"} 2\n")
+}
+
+func registeredMethodType(t *testing.T, s *Server, serviceName, methodName string) *methodType {
+	svc, ok := s.m.Load(serviceName)
+	assert.True(t, ok)
+	return svc.(*service).method[methodName]
+}
@@ -0,0 +1,55 @@
+package xrpc
+
+import "time"
+
+// SlowLogConfig configures the logging of slow requests.
+type SlowLogConfig struct {
+	// Threshold is the latency above which a request is logged.
+	Threshold time.Duration
+	// Methods overrides Threshold per "Service.Method"; a negative value
+	// disables logging for the method.
+	Methods map[string]time.Duration
+	// CapturePayload adds the request params to the log line, cut to
+	// MaxPayload bytes (256 if zero).
+	CapturePayload bool
+	MaxPayload     int
+}
+
+const defaultMaxSlowPayload = 256
+
+// WithSlowRequestLog logs every request exceeding the configured latency.
+func WithSlowRequestLog(cfg SlowLogConfig) ServerOption {
+	return func(s *Server) {
+		if cfg.MaxPayload <= 0 {
+			cfg.MaxPayload = defaultMaxSlowPayload
+		}
+		s.slowLog = &cfg
+	}
+}
+
+func (s *Server) logSlow(req Request, resp Response, d time.Duration) {
+	cfg := s.slowLog
+	threshold := cfg.Threshold
+	if t, ok := cfg.Methods[req.GetMethod()]; ok {
+		threshold = t
+	}
+	if threshold < 0 || d <= threshold {
+		return
+	}
+
+	code := InternalErr
+	if resp != nil {
+		code = resp.GetErrCode()
+	}
+	if !cfg.CapturePayload {
+		s.logger.Printf("rpc: slow request %s took %v (threshold %v) code=%v",
+			req.GetMethod(), d, threshold, code)
+		return
+	}
+	params := req.GetParams()
+	if len(params) > cfg.MaxPayload {
+		params = params[:cfg.MaxPayload]
+	}
+	s.logger.Printf("rpc: slow request %s took %v (threshold %v) code=%v params=%q",
+		req.GetMethod(), d, threshold, code, params)
+}
@@ -0,0 +1,59 @@
+package xrpc
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServer_SlowRequestLog(t *testing.T) {
+	out := new(syncBuffer)
+	s := NewServerWithCodec(nil,
+		WithLogger(log.New(out, "", 0)),
+		WithSlowRequestLog(SlowLogConfig{
+			Threshold:      10 * time.Millisecond,
+			Methods:        map[string]time.Duration{"Int.Sum": -1},
+			CapturePayload: true,
+			MaxPayload:     4,
+		}),
+	)
+	_ = s.Register(new(Slow))
+	_ = s.Register(new(Int))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	d := time.Millisecond
+	assert.Nil(t, c.Call("Slow.Sleep", &d, new(int)))
+	assert.Nil(t, c.Call("Int.Sum", &Args{}, new(int)))
+	assert.NotContains(t, out.String(), "slow request")
+
+	d = 20 * time.Millisecond
+	assert.Nil(t, c.Call("Slow.Sleep", &d, new(int)))
+	assert.Contains(t, out.String(), "rpc: slow request Slow.Sleep took")
+	assert.Contains(t, out.String(), "code=Success params=")
+
+	p := NewGobCodec().NewRequest("Slow.Sleep", &d).GetParams()
+	assert.Contains(t, out.String(), fmt.Sprintf("params=%q", p[:4]))
+}
@@ -0,0 +1,108 @@
+package xrpc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of latest latencies kept per method.
+const latencySamples = 1024
+
+// MethodStats summarizes the calls of one method since the client started.
+// Percentiles cover the latest calls only.
+type MethodStats struct {
+	Calls     uint64
+	Errors    uint64
+	ErrorRate float64
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+// latencyRing keeps the latest latencies in a fixed ring.
+type latencyRing struct {
+	samples [latencySamples]time.Duration
+	next    int
+	full    bool
+}
+
+func (r *latencyRing) add(d time.Duration) {
+	r.samples[r.next] = d
+	if r.next++; r.next == len(r.samples) {
+		r.next, r.full = 0, true
+	}
+}
+
+func (r *latencyRing) sorted() []time.Duration {
+	n := r.next
+	if r.full {
+		n = len(r.samples)
+	}
+	out := append([]time.Duration(nil), r.samples[:n]...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+type methodCounters struct {
+	calls   uint64
+	errors  uint64
+	latency latencyRing
+}
+
+type callStats struct {
+	mu      sync.Mutex
+	methods map[string]*methodCounters
+}
+
+func (cs *callStats) record(method string, d time.Duration, err error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.methods == nil {
+		cs.methods = make(map[string]*methodCounters)
+	}
+	mc, ok := cs.methods[method]
+	if !ok {
+		mc = new(methodCounters)
+		cs.methods[method] = mc
+	}
+	mc.calls++
+	if err != nil {
+		mc.errors++
+	}
+	mc.latency.add(d)
+}
+
+func (cs *callStats) snapshot() map[string]MethodStats {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	out := make(map[string]MethodStats, len(cs.methods))
+	for method, mc := range cs.methods {
+		sorted := mc.latency.sorted()
+		out[method] = MethodStats{
+			Calls:     mc.calls,
+			Errors:    mc.errors,
+			ErrorRate: float64(mc.errors) / float64(mc.calls),
+			P50:       percentile(sorted, 0.5),
+			P90:       percentile(sorted, 0.9),
+			P99:       percentile(sorted, 0.99),
+			Max:       sorted[len(sorted)-1],
+		}
+	}
+	return out
+}
+
+// Stats returns per-method call statistics since the client was created.
+func (c *Client) Stats() map[string]MethodStats {
+	return c.stats.snapshot()
+}
@@ -0,0 +1,44 @@
+package xrpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Stats(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	_ = s.Register(new(Int))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	}
+	assert.NotNil(t, c.Call("Int.Sub", &Args{A: 1, B: 2}, &sum))
+
+	stats := c.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, uint64(3), stats["Int.Sum"].Calls)
+	assert.Equal(t, uint64(0), stats["Int.Sum"].Errors)
+	assert.True(t, stats["Int.Sum"].P50 > 0)
+	assert.True(t, stats["Int.Sum"].Max >= stats["Int.Sum"].P99)
+	assert.Equal(t, uint64(1), stats["Int.Sub"].Errors)
+	assert.Equal(t, 1.0, stats["Int.Sub"].ErrorRate)
+}
+
+func TestLatencyRing(t *testing.T) {
+	var r latencyRing
+	assert.Empty(t, r.sorted())
+	assert.Equal(t, time.Duration(0), percentile(r.sorted(), 0.5))
+
+	for i := 1; i <= latencySamples+100; i++ {
+		r.add(time.Duration(i))
+	}
+	sorted := r.sorted()
+	assert.Len(t, sorted, latencySamples)
+	assert.Equal(t, time.Duration(101), sorted[0])
+	assert.Equal(t, time.Duration(latencySamples+100), percentile(sorted, 1))
+}
@@ -0,0 +1,144 @@
+// Package stubgen generates TypeScript and Python clients of the methods
+// described by Server.Describe, calling them with the JSON codec over HTTP.
+package stubgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+// Generate writes the client of methods in lang, "typescript" or "python".
+func Generate(w io.Writer, lang string, methods []xrpc.MethodDescription) error {
+	var g generator
+	switch lang {
+	case "typescript", "ts":
+		g = &tsGenerator{}
+	case "python", "py":
+		g = &pyGenerator{}
+	default:
+		return fmt.Errorf("stubgen: unknown language %q", lang)
+	}
+
+	types := collectTypes(methods)
+	var buf bytes.Buffer
+	g.header(&buf)
+	for _, t := range types {
+		g.typ(&buf, t)
+	}
+	for _, svc := range groupServices(methods) {
+		g.service(&buf, svc)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type generator interface {
+	header(b *bytes.Buffer)
+	typ(b *bytes.Buffer, s xrpc.Schema)
+	service(b *bytes.Buffer, svc service)
+}
+
+type service struct {
+	name    string
+	methods []xrpc.MethodDescription
+}
+
+func groupServices(methods []xrpc.MethodDescription) []service {
+	var services []service
+	for _, m := range methods {
+		name := m.Name
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[:i]
+		}
+		if len(services) == 0 || services[len(services)-1].name != name {
+			services = append(services, service{name: name})
+		}
+		svc := &services[len(services)-1]
+		svc.methods = append(svc.methods, m)
+	}
+	return services
+}
+
+// methodName returns the name of m within its service.
+func methodName(m xrpc.MethodDescription) string {
+	return m.Name[strings.Index(m.Name, ".")+1:]
+}
+
+// collectTypes returns the named structs of the methods, sorted by name.
+// Recursive types are described once in full, the first description with
+// fields wins.
+func collectTypes(methods []xrpc.MethodDescription) []xrpc.Schema {
+	types := make(map[string]xrpc.Schema)
+	var visit func(s xrpc.Schema)
+	visit = func(s xrpc.Schema) {
+		if s.Elem != nil {
+			visit(*s.Elem)
+		}
+		if s.Key != nil {
+			visit(*s.Key)
+		}
+		if s.Kind != "struct" {
+			return
+		}
+		if s.Name != "" && !isTime(s) {
+			if old, ok := types[s.Name]; !ok || len(old.Fields) == 0 {
+				types[s.Name] = s
+			}
+		}
+		for _, f := range s.Fields {
+			visit(f.Type)
+		}
+	}
+	for _, m := range methods {
+		visit(m.Params)
+		for _, arg := range m.Args {
+			visit(arg)
+		}
+		visit(m.Result)
+	}
+
+	var sorted []xrpc.Schema
+	for _, s := range types {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// isTime reports whether s is a time.Time, which marshals to a string.
+func isTime(s xrpc.Schema) bool {
+	return s.Kind == "struct" && s.Name == "Time" && len(s.Fields) == 0
+}
+
+func isBytes(s xrpc.Schema) bool {
+	return s.Kind == "slice" && s.Elem != nil && s.Elem.Kind == "uint8"
+}
+
+func isNumber(kind string) bool {
+	switch kind {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"float32", "float64":
+		return true
+	}
+	return false
+}
+
+// docLines returns the doc of m, one line each.
+func docLines(m xrpc.MethodDescription) []string {
+	var lines []string
+	for _, text := range []string{m.Summary, m.Description} {
+		if text != "" {
+			lines = append(lines, strings.Split(strings.TrimSpace(text), "\n")...)
+		}
+	}
+	if m.Deprecated {
+		lines = append(lines, "Deprecated.")
+	}
+	return lines
+}
@@ -0,0 +1,85 @@
+package stubgen
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type Item struct {
+	SKU      string            `json:"sku" xrpc:"required"`
+	Qty      int               `json:"qty"`
+	Price    float64           `json:"price"`
+	Tags     []string          `json:"tags"`
+	Attrs    map[string]string `json:"attrs"`
+	Children []*Item           `json:"children"`
+	Added    time.Time         `json:"added"`
+}
+
+type Cart struct{}
+
+func (c *Cart) Add(item *Item, reply *int) error { return nil }
+
+func (c *Cart) List(filter *string, reply *[]Item) error { return nil }
+
+func (c *Cart) Move(item *Item, qty int, reply *bool) error { return nil }
+
+func describe(t *testing.T) []xrpc.MethodDescription {
+	s := xrpc.NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Cart), xrpc.WithMethodDoc("Add", xrpc.MethodDoc{Summary: "Add puts an item in the cart."})))
+	return s.Describe()
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, Generate(&buf, "typescript", describe(t)))
+	out := buf.String()
+
+	assert.Contains(t, out, `export interface Item {
+  sku: string;
+  qty?: number;
+  price?: number;
+  tags?: string[];
+  attrs?: Record<string, string>;
+  children?: Item[];
+  added?: string;
+}`)
+	assert.Contains(t, out, `export class CartClient {`)
+	assert.Contains(t, out, `   * Add puts an item in the cart.
+   */
+  Add(params: Item): Promise<number> {
+    return this.call("Cart.Add", params) as Promise<number>;
+  }`)
+	assert.Contains(t, out, `  List(params: string): Promise<Item[]> {`)
+	assert.Contains(t, out, `  Move(arg0: Item, arg1: number): Promise<boolean> {
+    return this.call("Cart.Move", [arg0, arg1]) as Promise<boolean>;`)
+}
+
+func TestGeneratePython(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, Generate(&buf, "python", describe(t)))
+	out := buf.String()
+
+	assert.Contains(t, out, `Item = TypedDict("Item", {
+    "sku": "str",
+    "qty": "int",
+    "price": "float",
+    "tags": "List[str]",
+    "attrs": "Dict[str, str]",
+    "children": "List[Item]",
+    "added": "str",
+}, total=False)`)
+	assert.Contains(t, out, `    def Add(self, params: Item) -> int:
+        """Add puts an item in the cart."""
+        return self._transport.call("Cart.Add", params)`)
+	assert.Contains(t, out, `    def List(self, params: str) -> List[Item]:`)
+	assert.Contains(t, out, `    def Move(self, arg0: Item, arg1: int) -> bool:
+        return self._transport.call("Cart.Move", [arg0, arg1])`)
+}
+
+func TestGenerateUnknownLanguage(t *testing.T) {
+	assert.Error(t, Generate(new(bytes.Buffer), "cobol", nil))
+}
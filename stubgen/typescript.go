@@ -0,0 +1,134 @@
+package stubgen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+type tsGenerator struct{}
+
+const tsHeader = `// Code generated by xrpc-stubgen. DO NOT EDIT.
+
+export class RPCError extends Error {
+  constructor(public code: number, message: string, public data?: unknown) {
+    super(message);
+  }
+}
+
+export type Call = (method: string, params: unknown) => Promise<unknown>;
+
+let nextId = 0;
+
+// httpTransport calls the methods of the server at url with the JSON codec.
+export function httpTransport(url: string): Call {
+  return async (method, params) => {
+    const id = String(++nextId);
+    const resp = await fetch(url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ jsonrpc: "2.0", id, method, params }),
+    });
+    const body = await resp.json();
+    if (body.error) {
+      throw new RPCError(body.error.code, body.error.message, body.error.data);
+    }
+    return body.result;
+  };
+}
+`
+
+func (g *tsGenerator) header(b *bytes.Buffer) {
+	b.WriteString(tsHeader)
+}
+
+func (g *tsGenerator) typ(b *bytes.Buffer, s xrpc.Schema) {
+	fmt.Fprintf(b, "\nexport interface %s %s\n", s.Name, g.fields(s, ""))
+}
+
+func (g *tsGenerator) fields(s xrpc.Schema, indent string) string {
+	if len(s.Fields) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, f := range s.Fields {
+		optional := "?"
+		if f.Required {
+			optional = ""
+		}
+		fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, tsName(f.Name), optional, g.ref(f.Type, indent+"  "))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// ref returns the type of s, inline unless it's a named struct.
+func (g *tsGenerator) ref(s xrpc.Schema, indent string) string {
+	switch {
+	case isTime(s) || isBytes(s) || s.Kind == "string":
+		return "string"
+	case isNumber(s.Kind):
+		return "number"
+	case s.Kind == "bool":
+		return "boolean"
+	case s.Kind == "slice" || s.Kind == "array":
+		elem := g.ref(*s.Elem, indent)
+		if strings.ContainsAny(elem, " |") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case s.Kind == "map":
+		return "Record<string, " + g.ref(*s.Elem, indent) + ">"
+	case s.Kind == "struct" && s.Name != "":
+		return s.Name
+	case s.Kind == "struct":
+		return g.fields(s, indent)
+	}
+	return "unknown"
+}
+
+func (g *tsGenerator) service(b *bytes.Buffer, svc service) {
+	fmt.Fprintf(b, "\nexport class %sClient {\n", svc.name)
+	b.WriteString("  constructor(private call: Call) {}\n")
+	for _, m := range svc.methods {
+		b.WriteString("\n")
+		if lines := docLines(m); len(lines) > 0 {
+			b.WriteString("  /**\n")
+			for _, line := range lines {
+				if strings.HasPrefix(line, "Deprecated") {
+					line = "@deprecated"
+				}
+				fmt.Fprintf(b, "   * %s\n", line)
+			}
+			b.WriteString("   */\n")
+		}
+		result := g.ref(m.Result, "  ")
+		params, args := "params: "+g.ref(m.Params, "  "), "params"
+		if m.Args != nil {
+			// the params of methods taking several args are positional
+			var names, decls []string
+			for i, arg := range m.Args {
+				names = append(names, fmt.Sprintf("arg%d", i))
+				decls = append(decls, fmt.Sprintf("arg%d: %s", i, g.ref(arg, "  ")))
+			}
+			params, args = strings.Join(decls, ", "), "["+strings.Join(names, ", ")+"]"
+		}
+		fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n", tsName(methodName(m)), params, result)
+		fmt.Fprintf(b, "    return this.call(%q, %s) as Promise<%s>;\n", m.Name, args, result)
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+}
+
+// tsName quotes name unless it's a valid identifier.
+func tsName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return name
+}
@@ -0,0 +1,96 @@
+package xrpc
+
+import (
+	"sort"
+	"strings"
+)
+
+const maxSuggestions = 3
+
+// NotFoundData is the Data of the MethodNotFound errors of methods with
+// registered ones of close names, e.g. "Int.Sum" for "Int.Sun".
+type NotFoundData struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+// notFound returns the MethodNotFound error of method, suggesting the
+// registered methods closest to it. msg is the message without them.
+func (s *Server) notFound(method, msg string) *Error {
+	err := &Error{ErrCode: MethodNotFound, ErrMsg: msg}
+	if suggestions := s.suggestMethods(method); len(suggestions) > 0 {
+		err.ErrMsg += ", did you mean " + strings.Join(suggestions, " or ") + "?"
+		err.Data = NotFoundData{Suggestions: suggestions}
+	}
+	return err
+}
+
+// suggestMethods returns the registered methods whose names are within a
+// few edits of method, closest first.
+func (s *Server) suggestMethods(method string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	maxDistance := len(method) / 4
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	lower := strings.ToLower(method)
+
+	var candidates []candidate
+	s.m.Range(func(key, value interface{}) bool {
+		srv := value.(*service)
+		if srv.internal {
+			return true
+		}
+		for name := range srv.method {
+			full := srv.name + "." + name
+			if d := editDistance(lower, strings.ToLower(full)); d <= maxDistance {
+				candidates = append(candidates, candidate{name: full, distance: d})
+			}
+		}
+		return true
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b, in bytes.
+func editDistance(a, b string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,40 @@
+package xrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("Int.Sum", "Int.Sum"))
+	assert.Equal(t, 1, editDistance("Int.Sun", "Int.Sum"))
+	assert.Equal(t, 2, editDistance("Itn.Sum", "Int.Sum"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, 3, editDistance("abc", ""))
+}
+
+func TestServer_suggestMethods(t *testing.T) {
+	codec := NewGobCodec()
+	s := NewServerWithCodec(codec)
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.Register(new(Whoami)))
+	assert.Nil(t, s.ExposeServiceMap()) // internal, never suggested
+
+	assert.Equal(t, []string{"Int.Sum"}, s.suggestMethods("Int.Sun"))
+	assert.Equal(t, []string{"Int.Sum"}, s.suggestMethods("int.sum"))
+	assert.Equal(t, []string{"Whoami.Get"}, s.suggestMethods("WhoAmI.Gte"))
+	assert.Empty(t, s.suggestMethods("Nothing.Close"))
+
+	err := s.notFound("Int.Sun", "rpc: can't find method Int.Sun")
+	assert.Equal(t, "rpc: can't find method Int.Sun, did you mean Int.Sum?", err.ErrMsg)
+	assert.Equal(t, NotFoundData{Suggestions: []string{"Int.Sum"}}, err.Data)
+
+	err = s.notFound("Nothing.Close", "rpc: can't find service Nothing")
+	assert.Equal(t, "rpc: can't find service Nothing", err.ErrMsg)
+	assert.Nil(t, err.Data)
+
+	resps := s.call(context.Background(), []Request{codec.NewRequest("Itn.Sum", &Args{})})
+	assert.Equal(t, "rpc: can't find service Itn, did you mean Int.Sum?", resps[0].Error().Error())
+}
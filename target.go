@@ -0,0 +1,158 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target is a parsed target string of the form scheme://authority/endpoint,
+// e.g. dns:///api.internal:8080 or unix:///run/app.sock.
+type Target struct {
+	Scheme    string
+	Authority string
+	Endpoint  string
+}
+
+// ParseTarget parses target. Strings without a scheme, such as
+// "host:port", are tcp targets.
+func ParseTarget(target string) (Target, error) {
+	i := strings.Index(target, "://")
+	if i < 0 {
+		return Target{Scheme: "tcp", Endpoint: target}, nil
+	}
+	t := Target{Scheme: target[:i]}
+	rest := target[i+3:]
+	if t.Scheme == "" {
+		return t, fmt.Errorf("rpc: target %q has no scheme", target)
+	}
+	if j := strings.Index(rest, "/"); j >= 0 {
+		t.Authority, t.Endpoint = rest[:j], rest[j+1:]
+	} else {
+		t.Endpoint = rest
+	}
+	if t.Endpoint == "" {
+		return t, fmt.Errorf("rpc: target %q has no endpoint", target)
+	}
+	return t, nil
+}
+
+// ResolverBuilder builds the resolver of a target.
+type ResolverBuilder func(t Target) (Resolver, error)
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = map[string]ResolverBuilder{
+		"tcp":    buildStaticResolver,
+		"static": buildStaticResolver,
+		"unix":   buildUnixResolver,
+		"dns":    buildDNSResolver,
+	}
+)
+
+// RegisterResolver makes targets of scheme resolve with b, replacing any
+// builder of the same scheme.
+func RegisterResolver(scheme string, b ResolverBuilder) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+
+	resolvers[scheme] = b
+}
+
+// NewResolver returns the resolver of target, built by the builder
+// registered for its scheme.
+func NewResolver(target string) (Resolver, error) {
+	t, err := ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	resolversMu.RLock()
+	b, ok := resolvers[t.Scheme]
+	resolversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("rpc: no resolver for scheme %q", t.Scheme)
+	}
+	return b(t)
+}
+
+// StaticResolver always resolves into the same addresses.
+type StaticResolver []string
+
+func (r StaticResolver) Resolve(ctx context.Context) ([]string, error) {
+	if len(r) == 0 {
+		return nil, errors.New("rpc: no address")
+	}
+	return append([]string(nil), r...), nil
+}
+
+// buildStaticResolver handles tcp://host:port and static://a,b,c, where
+// the addresses may also be the endpoint: static:///a,b,c.
+func buildStaticResolver(t Target) (Resolver, error) {
+	list := t.Endpoint
+	if t.Authority != "" {
+		list = t.Authority + "/" + t.Endpoint
+		list = strings.TrimSuffix(list, "/")
+	}
+	var addrs StaticResolver
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if t.Scheme == "tcp" && len(addrs) != 1 {
+		return nil, fmt.Errorf("rpc: tcp target needs one address, got %d", len(addrs))
+	}
+	return addrs, nil
+}
+
+// buildUnixResolver resolves unix:///path into an address clients dial
+// over a unix socket.
+func buildUnixResolver(t Target) (Resolver, error) {
+	if t.Authority != "" {
+		return nil, fmt.Errorf("rpc: unix target must be unix:///path")
+	}
+	return StaticResolver{"unix:///" + t.Endpoint}, nil
+}
+
+func buildDNSResolver(t Target) (Resolver, error) {
+	if t.Authority != "" {
+		return nil, fmt.Errorf("rpc: dns target with an authority is not supported")
+	}
+	return NewDNSResolver(t.Endpoint)
+}
+
+// splitNetwork returns the network to dial addr over: unix for
+// unix:///path addresses, tcp otherwise.
+func splitNetwork(addr string) (network, address string) {
+	if strings.HasPrefix(addr, "unix://") {
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	return "tcp", strings.TrimPrefix(addr, "tcp://")
+}
+
+// NewMultiClientForTarget resolves target, see NewResolver, and keeps
+// re-resolving it every interval until ctx is done if interval is positive.
+func NewMultiClientForTarget(ctx context.Context, codec ClientCodec, target string, interval time.Duration) (*MultiClient, error) {
+	return newMultiClientForTarget(ctx, ctx, codec, target, interval)
+}
+
+// newMultiClientForTarget resolves target within ctx and watches it until
+// watchCtx is done.
+func newMultiClientForTarget(ctx, watchCtx context.Context, codec ClientCodec, target string, interval time.Duration) (*MultiClient, error) {
+	r, err := NewResolver(target)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := r.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := NewMultiClientWithCodec(codec, addrs...)
+	if interval > 0 {
+		go m.Watch(watchCtx, r, interval)
+	}
+	return m, nil
+}
@@ -0,0 +1,85 @@
+package xrpc
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTarget(t *testing.T) {
+	for target, want := range map[string]Target{
+		"host:1":                  {Scheme: "tcp", Endpoint: "host:1"},
+		"tcp://host:1":            {Scheme: "tcp", Endpoint: "host:1"},
+		"unix:///run/app.sock":    {Scheme: "unix", Endpoint: "run/app.sock"},
+		"dns:///api.internal:80":  {Scheme: "dns", Endpoint: "api.internal:80"},
+		"dns://8.8.8.8/a.b:80":    {Scheme: "dns", Authority: "8.8.8.8", Endpoint: "a.b:80"},
+		"static://a:1,b:2":        {Scheme: "static", Endpoint: "a:1,b:2"},
+		"custom://auth/some/path": {Scheme: "custom", Authority: "auth", Endpoint: "some/path"},
+	} {
+		got, err := ParseTarget(target)
+		assert.Nil(t, err, target)
+		assert.Equal(t, want, got, target)
+	}
+	for _, target := range []string{"://x", "tcp://", "dns:///"} {
+		_, err := ParseTarget(target)
+		assert.NotNil(t, err, target)
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	ctx := context.Background()
+	for target, want := range map[string][]string{
+		"host:1":               {"host:1"},
+		"static://a:1, b:2":    {"a:1", "b:2"},
+		"static:///a:1,b:2":    {"a:1", "b:2"},
+		"unix:///run/app.sock": {"unix:///run/app.sock"},
+	} {
+		r, err := NewResolver(target)
+		if assert.Nil(t, err, target) {
+			addrs, err := r.Resolve(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, want, addrs, target)
+		}
+	}
+
+	_, err := NewResolver("nope://x")
+	assert.NotNil(t, err)
+	_, err = NewResolver("tcp://a:1,b:2")
+	assert.NotNil(t, err)
+	r, err := NewResolver("dns:///localhost:80")
+	assert.Nil(t, err)
+	assert.IsType(t, &DNSResolver{}, r)
+
+	RegisterResolver("test", func(t Target) (Resolver, error) {
+		return StaticResolver{t.Authority + "-" + t.Endpoint}, nil
+	})
+	r, err = NewResolver("test://a/b")
+	assert.Nil(t, err)
+	addrs, _ := r.Resolve(ctx)
+	assert.Equal(t, []string{"a-b"}, addrs)
+}
+
+func TestClient_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xrpc.sock")
+	s := NewServerWithCodec(nil, WithListener("unix", path))
+	assert.Nil(t, s.Register(new(Int)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Nil(t, s.Start(ctx))
+
+	m, err := NewMultiClientForTarget(ctx, nil, "unix://"+path, time.Minute)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer m.Close()
+
+	var sum int
+	assert.Nil(t, m.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.Equal(t, 3, sum)
+
+	_, err = NewMultiClientForTarget(ctx, nil, "static://", 0)
+	assert.NotNil(t, err)
+}
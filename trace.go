@@ -0,0 +1,83 @@
+package xrpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// TraceRecord describes one handled request.
+type TraceRecord struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Duration time.Duration `json:"duration"`
+	Code     Code          `json:"code"`
+	ReqSize  int           `json:"req_size"`
+	RespSize int           `json:"resp_size"`
+	Peer     string        `json:"peer"`
+}
+
+// traceRing keeps the latest records. Writers claim a slot with an atomic
+// counter, so recording never takes a lock.
+type traceRing struct {
+	next  uint64
+	slots []atomic.Value // *TraceRecord
+}
+
+func newTraceRing(n int) *traceRing {
+	return &traceRing{slots: make([]atomic.Value, n)}
+}
+
+func (r *traceRing) add(ctx context.Context, req Request, resp Response, d time.Duration) {
+	rec := &TraceRecord{
+		Time:     time.Now().Add(-d),
+		Method:   req.GetMethod(),
+		Duration: d,
+		Code:     InternalErr,
+		ReqSize:  len(req.GetParams()),
+		Peer:     peerFromContext(ctx),
+	}
+	if resp != nil {
+		rec.Code = resp.GetErrCode()
+		rec.RespSize = len(resp.GetReply())
+	}
+
+	idx := atomic.AddUint64(&r.next, 1) - 1
+	r.slots[idx%uint64(len(r.slots))].Store(rec)
+}
+
+// records returns the stored records, oldest first.
+func (r *traceRing) records() []TraceRecord {
+	next := atomic.LoadUint64(&r.next)
+	n := uint64(len(r.slots))
+	start := uint64(0)
+	if next > n {
+		start = next - n
+	}
+
+	out := make([]TraceRecord, 0, next-start)
+	for i := start; i < next; i++ {
+		if rec, ok := r.slots[i%n].Load().(*TraceRecord); ok {
+			out = append(out, *rec)
+		}
+	}
+	return out
+}
+
+// WithRequestTrace keeps the last n handled requests, see RecentRequests.
+func WithRequestTrace(n int) ServerOption {
+	return func(s *Server) {
+		if n > 0 {
+			s.trace = newTraceRing(n)
+		}
+	}
+}
+
+// RecentRequests returns the requests kept by WithRequestTrace, oldest
+// first.
+func (s *Server) RecentRequests() []TraceRecord {
+	if s.trace == nil {
+		return nil
+	}
+	return s.trace.records()
+}
@@ -0,0 +1,58 @@
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_RecentRequests(t *testing.T) {
+	s := NewServerWithCodec(nil, WithRequestTrace(2))
+	_ = s.Register(new(Int))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum))
+	assert.NotNil(t, c.Call("Int.Sub", &Args{A: 1, B: 2}, &sum))
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 2, B: 2}, &sum))
+
+	records := s.RecentRequests()
+	assert.Len(t, records, 2)
+	assert.Equal(t, "Int.Sub", records[0].Method)
+	assert.Equal(t, MethodNotFound, records[0].Code)
+	assert.Equal(t, "Int.Sum", records[1].Method)
+	assert.Equal(t, Success, records[1].Code)
+	assert.True(t, records[1].ReqSize > 0)
+	assert.True(t, records[1].RespSize > 0)
+	assert.Contains(t, records[1].Peer, "127.0.0.1:")
+
+	w := httptest.NewRecorder()
+	s.AdminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/requests", nil))
+	var dumped []TraceRecord
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &dumped))
+	assert.Len(t, dumped, 2)
+	assert.Equal(t, records[1].Method, dumped[1].Method)
+	assert.True(t, records[1].Time.Equal(dumped[1].Time))
+
+	assert.Nil(t, NewServerWithCodec(nil).RecentRequests())
+}
+
+func TestTraceRing_records(t *testing.T) {
+	r := newTraceRing(3)
+	assert.Empty(t, r.records())
+
+	codec := NewGobCodec()
+	for _, method := range []string{"A.A", "B.B", "C.C", "D.D"} {
+		r.add(withPeer(context.Background(), "peer"), codec.NewRequest(method, 1), nil, 0)
+	}
+	records := r.records()
+	assert.Len(t, records, 3)
+	assert.Equal(t, "B.B", records[0].Method)
+	assert.Equal(t, "D.D", records[2].Method)
+	assert.Equal(t, InternalErr, records[2].Code)
+	assert.Equal(t, "peer", records[2].Peer)
+}
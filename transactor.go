@@ -0,0 +1,60 @@
+package xrpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BatchTransactor runs the requests of a batch in a transaction, e.g. of a
+// database. Begin returns the context the methods get, which typically
+// carries the transaction.
+type BatchTransactor interface {
+	Begin(ctx context.Context) (context.Context, error)
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// WithBatchTransactor makes batches all-or-nothing: their requests run one
+// after the other within a transaction of t, which is committed if they
+// all succeed and rolled back otherwise, in which case every request of
+// the batch fails. Single requests run as usual.
+func WithBatchTransactor(t BatchTransactor) ServerOption {
+	return func(s *Server) {
+		s.transactor = t
+	}
+}
+
+func (s *Server) callInTx(ctx context.Context, reqs []Request) []Response {
+	replies := getResponses(len(reqs))
+	failAll := func(err error) []Response {
+		for i, req := range reqs {
+			if replies[i] != nil {
+				s.releaseResponse(replies[i])
+			}
+			replies[i] = s.codec.ErrResponse(InternalErr, err)
+			replies[i].SetReqId(req.GetId())
+		}
+		return replies
+	}
+
+	txCtx, err := s.transactor.Begin(ctx)
+	if err != nil {
+		return failAll(fmt.Errorf("rpc: could not begin batch transaction: %v", err))
+	}
+	for i, req := range reqs {
+		start := time.Now()
+		replies[i] = s.handleRequest(txCtx, req)
+		s.observe(txCtx, req, replies[i], time.Since(start))
+		if replyErr := replies[i].Error(); replyErr != nil {
+			if err := s.transactor.Rollback(txCtx); err != nil {
+				s.logger.Printf("rpc: could not roll back batch transaction: %v", err)
+			}
+			return failAll(fmt.Errorf("rpc: batch rolled back, request %d failed: %v", i, replyErr))
+		}
+	}
+	if err := s.transactor.Commit(txCtx); err != nil {
+		return failAll(fmt.Errorf("rpc: could not commit batch transaction: %v", err))
+	}
+	return replies
+}
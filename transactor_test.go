@@ -0,0 +1,81 @@
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type txKey struct{}
+
+// memTx buffers writes until commit.
+type memTx struct {
+	committed []int
+	ops       []string
+}
+
+func (m *memTx) Begin(ctx context.Context) (context.Context, error) {
+	m.ops = append(m.ops, "begin")
+	return context.WithValue(ctx, txKey{}, new([]int)), nil
+}
+
+func (m *memTx) Commit(ctx context.Context) error {
+	m.ops = append(m.ops, "commit")
+	m.committed = append(m.committed, *ctx.Value(txKey{}).(*[]int)...)
+	return nil
+}
+
+func (m *memTx) Rollback(ctx context.Context) error {
+	m.ops = append(m.ops, "rollback")
+	return nil
+}
+
+type Store struct{}
+
+func (s *Store) Put(ctx context.Context, args *int, reply *bool) error {
+	if *args < 0 {
+		return errors.New("negative")
+	}
+	if tx, ok := ctx.Value(txKey{}).(*[]int); ok {
+		*tx = append(*tx, *args)
+	}
+	*reply = true
+	return nil
+}
+
+func TestBatchTransactor(t *testing.T) {
+	codec := NewGobCodec()
+	tx := new(memTx)
+	s := NewServerWithCodec(codec, WithBatchTransactor(tx))
+	assert.Nil(t, s.Register(new(Store)))
+
+	batch := func(values ...int) []Request {
+		reqs := make([]Request, len(values))
+		for i, v := range values {
+			reqs[i] = codec.NewRequest("Store.Put", v)
+		}
+		return reqs
+	}
+
+	resps := s.call(context.Background(), batch(1, 2))
+	for _, resp := range resps {
+		assert.Nil(t, resp.Error())
+	}
+	assert.Equal(t, []int{1, 2}, tx.committed)
+
+	resps = s.call(context.Background(), batch(3, -1, 4))
+	assert.Len(t, resps, 3)
+	for _, resp := range resps {
+		assert.Equal(t, InternalErr, resp.GetErrCode())
+		assert.Contains(t, resp.Error().Error(), "request 1 failed")
+	}
+	assert.Equal(t, []int{1, 2}, tx.committed)
+	assert.Equal(t, []string{"begin", "commit", "begin", "rollback"}, tx.ops)
+
+	// single requests don't open transactions
+	resps = s.call(context.Background(), batch(5))
+	assert.Nil(t, resps[0].Error())
+	assert.Len(t, tx.ops, 4)
+}
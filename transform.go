@@ -0,0 +1,21 @@
+package xrpc
+
+// ResultTransformer rewrites the result of a method before it is encoded,
+// e.g. to wrap it in a standard envelope or emulate a legacy shape. Method
+// is the registered name, after aliases are resolved.
+type ResultTransformer func(method string, result interface{}) interface{}
+
+// WithResultTransformer applies fn to the results of successful calls.
+// Transformers run in the order they are given, after any field mask.
+func WithResultTransformer(fn ResultTransformer) ServerOption {
+	return func(s *Server) {
+		s.transformers = append(s.transformers, fn)
+	}
+}
+
+func (s *Server) transformResult(method string, result interface{}) interface{} {
+	for _, fn := range s.transformers {
+		result = fn(method, result)
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package xrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Envelope struct {
+	Method string
+	Data   int
+}
+
+func TestResultTransformer(t *testing.T) {
+	wrap := func(method string, result interface{}) interface{} {
+		return &Envelope{Method: method, Data: *result.(*int)}
+	}
+	double := func(method string, result interface{}) interface{} {
+		env := result.(*Envelope)
+		env.Data *= 2
+		return env
+	}
+	s := NewServerWithCodec(nil, WithResultTransformer(wrap), WithResultTransformer(double))
+	assert.Nil(t, s.Register(new(Int)))
+	assert.Nil(t, s.Alias("Int.Add", "Int.Sum"))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var env Envelope
+	assert.Nil(t, c.Call("Int.Add", &Args{A: 1, B: 2}, &env))
+	assert.Equal(t, Envelope{Method: "Int.Sum", Data: 6}, env)
+}
@@ -0,0 +1,364 @@
+package xrpc
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields of arg structs accept an xrpc tag with comma separated options:
+//
+//	required      the field must not be zero after decoding
+//	optional      the field may be omitted, which is the default
+//	default=V     the field is set to V when it is zero after decoding
+//	oneof=A B C   the field must be one of the space separated values
+//	min=N, max=N  bounds of a number, or of the length of a string, slice
+//	              or map
+//	regexp=RE     a string must match RE; it must be the last option
+//
+// Defaults and oneof apply to strings, bools, numbers, time.Duration and
+// pointers to them. Checks other than required skip nil pointers.
+type fieldRule struct {
+	index    int
+	name     string
+	required bool
+	def      reflect.Value   // zero Value if no default
+	oneof    []reflect.Value // allowed values
+	min, max *float64
+	re       *regexp.Regexp
+	nested   bool // struct field whose own fields have rules
+}
+
+func (r *fieldRule) checked() bool {
+	return r.required || r.def.IsValid() || r.oneof != nil || r.min != nil || r.max != nil || r.re != nil || r.nested
+}
+
+var argRules sync.Map // map[reflect.Type][]fieldRule
+
+// rulesFor returns the rules of struct type t, or of the struct it points
+// to.
+func rulesFor(t reflect.Type) ([]fieldRule, error) {
+	return rulesOf(t, make(map[reflect.Type]bool))
+}
+
+// rulesOf is rulesFor, visiting the struct types on the way to t.
+func rulesOf(t reflect.Type, visiting map[reflect.Type]bool) ([]fieldRule, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	if rules, ok := argRules.Load(t); ok {
+		return rules.([]fieldRule), nil
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var rules []fieldRule
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		rule := fieldRule{index: i, name: fieldName(f)}
+		if tag, ok := f.Tag.Lookup("xrpc"); ok {
+			if err := rule.parse(f, tag); err != nil {
+				return nil, err
+			}
+		}
+		if ft := indirectType(f.Type); ft.Kind() == reflect.Struct {
+			if visiting[ft] {
+				// recursive types: whether ft has rules is yet unknown
+				rule.nested = true
+			} else {
+				nested, err := rulesOf(ft, visiting)
+				if err != nil {
+					return nil, err
+				}
+				rule.nested = len(nested) > 0
+			}
+		}
+		if rule.checked() {
+			rules = append(rules, rule)
+		}
+	}
+	argRules.Store(t, rules)
+	return rules, nil
+}
+
+func (r *fieldRule) parse(f reflect.StructField, tag string) error {
+	for tag != "" {
+		opt := tag
+		if strings.HasPrefix(opt, "regexp=") {
+			tag = ""
+		} else if i := strings.Index(opt, ","); i >= 0 {
+			opt, tag = opt[:i], opt[i+1:]
+		} else {
+			tag = ""
+		}
+
+		key, value := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, value = opt[:i], opt[i+1:]
+		}
+		if err := r.parseOption(f, key, value); err != nil {
+			return fmt.Errorf("rpc: bad xrpc tag %s of field %s: %v", key, f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *fieldRule) parseOption(f reflect.StructField, key, value string) error {
+	switch key {
+	case "", "optional":
+	case "required":
+		r.required = true
+	case "default":
+		def, err := parseDefault(f.Type, value)
+		if err != nil {
+			return err
+		}
+		r.def = def
+	case "oneof":
+		for _, s := range strings.Fields(value) {
+			v, err := parseDefault(indirectType(f.Type), s)
+			if err != nil {
+				return err
+			}
+			r.oneof = append(r.oneof, v)
+		}
+		if len(r.oneof) == 0 {
+			return fmt.Errorf("no values")
+		}
+	case "min", "max":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		if key == "min" {
+			r.min = &n
+		} else {
+			r.max = &n
+		}
+	case "regexp":
+		if indirectType(f.Type).Kind() != reflect.String {
+			return fmt.Errorf("field is not a string")
+		}
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		r.re = re
+	default:
+		return fmt.Errorf("unknown option")
+	}
+	return nil
+}
+
+var typeOfDuration = reflect.TypeOf(time.Duration(0))
+
+func parseDefault(t reflect.Type, s string) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr {
+		elem, err := parseDefault(t.Elem(), s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	}
+
+	v := reflect.New(t).Elem()
+	switch {
+	case t == typeOfDuration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(int64(d))
+	case t.Kind() == reflect.String:
+		v.SetString(s)
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(n)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(n)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(n)
+	default:
+		return v, fmt.Errorf("unsupported type %s", t)
+	}
+	return v, nil
+}
+
+// FieldError describes an arg field which failed validation.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Validator is implemented by args which check themselves. Validate is
+// called after the xrpc tags are applied; an *Error keeps its code, other
+// errors are reported as InvalidParamErr.
+type Validator interface {
+	Validate() error
+}
+
+// prepareArgs fills the defaults of the decoded args v and validates them,
+// returning an InvalidParamErr *Error whose Data lists the failing fields.
+func prepareArgs(v reflect.Value) error {
+	var failed []FieldError
+	if err := applyRules(v, "", &failed); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		msgs := make([]string, len(failed))
+		for i, fe := range failed {
+			msgs[i] = fe.Field + " " + fe.Reason
+		}
+		return &Error{
+			ErrCode: InvalidParamErr,
+			ErrMsg:  "rpc: invalid params: " + strings.Join(msgs, "; "),
+			Data:    failed,
+		}
+	}
+
+	if validator, ok := v.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			if _, ok := err.(*Error); ok {
+				return err
+			}
+			return &Error{ErrCode: InvalidParamErr, ErrMsg: err.Error()}
+		}
+	}
+	return nil
+}
+
+func applyRules(v reflect.Value, prefix string, failed *[]FieldError) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	rules, err := rulesFor(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		f := v.Field(rule.index)
+		if f.IsZero() && rule.def.IsValid() {
+			if f.Kind() == reflect.Ptr {
+				// don't share the default between requests
+				f.Set(reflect.New(f.Type().Elem()))
+				f.Elem().Set(rule.def.Elem())
+			} else {
+				f.Set(rule.def)
+			}
+		}
+		if f.IsZero() && rule.required {
+			*failed = append(*failed, FieldError{Field: prefix + rule.name, Reason: "is required"})
+			continue
+		}
+		if reason := rule.check(f); reason != "" {
+			*failed = append(*failed, FieldError{Field: prefix + rule.name, Reason: reason})
+		}
+		if rule.nested {
+			if err := applyRules(f, prefix+rule.name+".", failed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check returns why f breaks the oneof, min, max and regexp rules, if it
+// does.
+func (r *fieldRule) check(f reflect.Value) string {
+	for f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return ""
+		}
+		f = f.Elem()
+	}
+
+	if r.oneof != nil {
+		found := false
+		for _, v := range r.oneof {
+			if v.Interface() == f.Interface() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed := make([]string, len(r.oneof))
+			for i, v := range r.oneof {
+				allowed[i] = fmt.Sprint(v.Interface())
+			}
+			return "must be one of " + strings.Join(allowed, " ")
+		}
+	}
+
+	if r.min != nil || r.max != nil {
+		n, what := 0.0, ""
+		switch f.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			n, what = float64(f.Len()), "length "
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(f.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(f.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = f.Float()
+		}
+		if r.min != nil && n < *r.min {
+			return fmt.Sprintf("%smust be at least %v", what, *r.min)
+		}
+		if r.max != nil && n > *r.max {
+			return fmt.Sprintf("%smust be at most %v", what, *r.max)
+		}
+	}
+
+	if r.re != nil && !r.re.MatchString(f.String()) {
+		return "must match " + r.re.String()
+	}
+	return ""
+}
+
+// fieldName returns the JSON name of f, which is what clients see.
+func fieldName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
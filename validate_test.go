@@ -0,0 +1,166 @@
+package xrpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Page struct {
+	Size int `xrpc:"default=20"`
+}
+
+type SearchArgs struct {
+	Query   string         `json:"q" xrpc:"required"`
+	Limit   int            `xrpc:"default=10"`
+	Timeout time.Duration  `xrpc:"default=1s"`
+	Exact   *bool          `xrpc:"default=true"`
+	Ratio   float64        `xrpc:"optional,default=0.5"`
+	Page    *Page          `xrpc:"required"`
+	Tags    map[string]int `xrpc:"optional"`
+}
+
+func TestPrepareArgs(t *testing.T) {
+	args := &SearchArgs{Query: "go", Limit: 3, Page: &Page{}}
+	assert.Nil(t, prepareArgs(reflect.ValueOf(args)))
+	assert.Equal(t, 3, args.Limit)
+	assert.Equal(t, time.Second, args.Timeout)
+	if assert.NotNil(t, args.Exact) {
+		assert.True(t, *args.Exact)
+	}
+	assert.Equal(t, 0.5, args.Ratio)
+	assert.Equal(t, 20, args.Page.Size)
+
+	other := &SearchArgs{}
+	err := prepareArgs(reflect.ValueOf(other))
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+	var rpcErr *Error
+	if assert.True(t, errors.As(err, &rpcErr)) {
+		assert.Equal(t, []FieldError{
+			{Field: "q", Reason: "is required"},
+			{Field: "Page", Reason: "is required"},
+		}, rpcErr.Data)
+	}
+	*other.Exact = false
+	assert.True(t, *args.Exact, "defaults must not be shared")
+}
+
+func TestRulesFor_BadTag(t *testing.T) {
+	type badDefault struct {
+		N int `xrpc:"default=x"`
+	}
+	type badOption struct {
+		N int `xrpc:"nope"`
+	}
+	_, err := rulesFor(reflect.TypeOf(badDefault{}))
+	assert.NotNil(t, err)
+	_, err = rulesFor(reflect.TypeOf(&badOption{}))
+	assert.NotNil(t, err)
+}
+
+type ListArgs struct {
+	Order  string   `xrpc:"default=asc,oneof=asc desc"`
+	Count  int      `xrpc:"min=1,max=100"`
+	IDs    []int    `xrpc:"max=3"`
+	Name   *string  `xrpc:"regexp=^[a-z]{1,3}$"`
+	Weight *float64 `xrpc:"min=0.5"`
+	Custom bool
+}
+
+func (a *ListArgs) Validate() error {
+	if a.Custom {
+		return errors.New("custom is not supported")
+	}
+	return nil
+}
+
+func TestPrepareArgs_Checks(t *testing.T) {
+	name, weight := "abcd", 0.1
+	args := &ListArgs{Order: "up", Count: 101, IDs: []int{1, 2, 3, 4}, Name: &name, Weight: &weight}
+	err := prepareArgs(reflect.ValueOf(args))
+	var rpcErr *Error
+	if assert.True(t, errors.As(err, &rpcErr)) {
+		assert.Equal(t, InvalidParamErr, rpcErr.ErrCode)
+		assert.Equal(t, []FieldError{
+			{Field: "Order", Reason: "must be one of asc desc"},
+			{Field: "Count", Reason: "must be at most 100"},
+			{Field: "IDs", Reason: "length must be at most 3"},
+			{Field: "Name", Reason: "must match ^[a-z]{1,3}$"},
+			{Field: "Weight", Reason: "must be at least 0.5"},
+		}, rpcErr.Data)
+	}
+
+	args = &ListArgs{Count: 1}
+	assert.Nil(t, prepareArgs(reflect.ValueOf(args)))
+	assert.Equal(t, "asc", args.Order)
+
+	args.Custom = true
+	err = prepareArgs(reflect.ValueOf(args))
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+	assert.Contains(t, err.Error(), "custom is not supported")
+}
+
+func TestRulesFor_RegexpWithComma(t *testing.T) {
+	type args struct {
+		S string `xrpc:"required,regexp=^a{1,2}$"`
+	}
+	rules, err := rulesFor(reflect.TypeOf(args{}))
+	if assert.Nil(t, err) && assert.Len(t, rules, 1) {
+		assert.True(t, rules[0].required)
+		assert.Equal(t, "^a{1,2}$", rules[0].re.String())
+	}
+}
+
+type Search int
+
+func (s *Search) Do(args *SearchArgs, reply *int) error {
+	*reply = args.Limit
+	return nil
+}
+
+func TestServer_ArgDefaults(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Search)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var limit int
+	assert.Nil(t, c.Call("Search.Do", &SearchArgs{Query: "go", Page: &Page{Size: 1}}, &limit))
+	assert.Equal(t, 10, limit)
+
+	err := c.Call("Search.Do", &SearchArgs{Page: &Page{Size: 1}}, &limit)
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+}
+
+type Dept struct {
+	Name string    `xrpc:"required"`
+	Head *Employee `json:"head"`
+}
+
+type Employee struct {
+	Name string `xrpc:"required"`
+	Dept *Dept  `json:"dept"`
+}
+
+type Org int
+
+func (o *Org) Hire(args *Employee, reply *string) error {
+	*reply = args.Dept.Name
+	return nil
+}
+
+func TestRulesFor_MutuallyRecursive(t *testing.T) {
+	s := NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Org)))
+
+	args := &Employee{Name: "ann", Dept: &Dept{Name: "ops", Head: &Employee{}}}
+	err := prepareArgs(reflect.ValueOf(args))
+	assert.True(t, errors.Is(err, ErrInvalidParams))
+	assert.Contains(t, err.Error(), "dept.head.Name is required")
+
+	args.Dept.Head.Name = "bob"
+	assert.Nil(t, prepareArgs(reflect.ValueOf(args)))
+}
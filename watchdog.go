@@ -0,0 +1,124 @@
+package xrpc
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// PriorityKey is the metadata key carrying the priority of a request, an
+// integer defaulting to 0. Lower priorities are shed first.
+const PriorityKey = "xrpc-priority"
+
+// WithPriority sets the priority of the call, see PriorityKey.
+func WithPriority(priority int) CallOption {
+	return WithMetadata(Metadata{PriorityKey: strconv.Itoa(priority)})
+}
+
+// Pressure is the load of the process as sampled by the watchdog. Level is
+// the highest ratio of a measure to its limit, so 1 means a limit is hit.
+type Pressure struct {
+	HeapBytes  uint64
+	Goroutines int
+	Level      float64
+}
+
+// ShedPolicy decides whether to reject a request under pressure.
+type ShedPolicy func(p Pressure, method string, priority int) bool
+
+// DefaultShedPolicy sheds negative priorities from level 0.8 and priority 0
+// from level 1. Positive priorities are never shed.
+func DefaultShedPolicy(p Pressure, method string, priority int) bool {
+	switch {
+	case priority < 0:
+		return p.Level >= 0.8
+	case priority == 0:
+		return p.Level >= 1
+	}
+	return false
+}
+
+// WatchdogConfig configures the shedding of requests under memory or
+// goroutine pressure. A zero limit is not checked.
+type WatchdogConfig struct {
+	MaxHeapBytes  uint64
+	MaxGoroutines int
+	// Interval between samples, taken while serving requests. Defaults to
+	// one second.
+	Interval time.Duration
+	// Policy defaults to DefaultShedPolicy.
+	Policy ShedPolicy
+}
+
+type watchdog struct {
+	cfg WatchdogConfig
+
+	sampled  int64 // unix nanos of the last sample
+	pressure atomic.Value
+	shed     uint64
+}
+
+// WithWatchdog rejects requests with Overloaded as the pressure rises,
+// according to the policy.
+func WithWatchdog(cfg WatchdogConfig) ServerOption {
+	return func(s *Server) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = time.Second
+		}
+		if cfg.Policy == nil {
+			cfg.Policy = DefaultShedPolicy
+		}
+		w := &watchdog{cfg: cfg}
+		w.pressure.Store(Pressure{})
+		s.watchdog = w
+	}
+}
+
+func (w *watchdog) current() Pressure {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&w.sampled)
+	if now-last >= int64(w.cfg.Interval) && atomic.CompareAndSwapInt64(&w.sampled, last, now) {
+		w.pressure.Store(w.sample())
+	}
+	return w.pressure.Load().(Pressure)
+}
+
+func (w *watchdog) sample() Pressure {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	p := Pressure{HeapBytes: ms.HeapAlloc, Goroutines: runtime.NumGoroutine()}
+	if w.cfg.MaxHeapBytes > 0 {
+		p.Level = float64(p.HeapBytes) / float64(w.cfg.MaxHeapBytes)
+	}
+	if w.cfg.MaxGoroutines > 0 {
+		if level := float64(p.Goroutines) / float64(w.cfg.MaxGoroutines); level > p.Level {
+			p.Level = level
+		}
+	}
+	return p
+}
+
+// admit returns an Overloaded *Error if req must be shed.
+func (w *watchdog) admit(req Request) error {
+	priority, _ := strconv.Atoi(req.GetMetadata().Get(PriorityKey))
+	p := w.current()
+	if !w.cfg.Policy(p, req.GetMethod(), priority) {
+		return nil
+	}
+	atomic.AddUint64(&w.shed, 1)
+	return &Error{
+		ErrCode: Overloaded,
+		ErrMsg:  fmt.Sprintf("rpc: %s shed at load %.2f", req.GetMethod(), p.Level),
+	}
+}
+
+// Pressure returns the last sample of the watchdog, see WithWatchdog, and
+// the number of requests shed so far.
+func (s *Server) Pressure() (p Pressure, shed uint64) {
+	if s.watchdog == nil {
+		return Pressure{}, 0
+	}
+	return s.watchdog.current(), atomic.LoadUint64(&s.watchdog.shed)
+}
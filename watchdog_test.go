@@ -0,0 +1,57 @@
+package xrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultShedPolicy(t *testing.T) {
+	assert.False(t, DefaultShedPolicy(Pressure{Level: 0.7}, "A.B", -1))
+	assert.True(t, DefaultShedPolicy(Pressure{Level: 0.8}, "A.B", -1))
+	assert.False(t, DefaultShedPolicy(Pressure{Level: 0.9}, "A.B", 0))
+	assert.True(t, DefaultShedPolicy(Pressure{Level: 1}, "A.B", 0))
+	assert.False(t, DefaultShedPolicy(Pressure{Level: 5}, "A.B", 1))
+}
+
+func TestServer_Watchdog(t *testing.T) {
+	// one goroutine is enough to be over the limit
+	s := NewServerWithCodec(nil, WithWatchdog(WatchdogConfig{MaxGoroutines: 1, Interval: time.Hour}))
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrOverloaded))
+	err = c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, WithPriority(-5))
+	assert.True(t, errors.Is(err, ErrOverloaded))
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum, WithPriority(1)))
+	assert.Equal(t, 3, sum)
+
+	p, shed := s.Pressure()
+	assert.True(t, p.Level > 1)
+	assert.True(t, p.HeapBytes > 0)
+	assert.Equal(t, uint64(2), shed)
+}
+
+func TestServer_WatchdogPolicy(t *testing.T) {
+	var seen []string
+	s := NewServerWithCodec(nil, WithWatchdog(WatchdogConfig{
+		MaxHeapBytes: 1 << 50,
+		Policy: func(p Pressure, method string, priority int) bool {
+			seen = append(seen, method)
+			return method == "Int.Sum" && p.Level < 1
+		},
+	}))
+	assert.Nil(t, s.Register(new(Int)))
+	c := NewClientWithCodec(nil, startServer(t, s))
+	defer c.Close()
+
+	var sum int
+	err := c.Call("Int.Sum", &Args{A: 1, B: 2}, &sum)
+	assert.True(t, errors.Is(err, ErrOverloaded))
+	assert.Equal(t, []string{"Int.Sum"}, seen)
+}
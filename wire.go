@@ -0,0 +1,44 @@
+package xrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+// WireStats describes how the frame carrying a request was received, so
+// interceptors can tell network time from serialization time.
+type WireStats struct {
+	Frame  proto.FrameInfo // the frame holding the request
+	Decode time.Duration   // decoding the frame body into requests
+}
+
+type wireStatsKey struct{}
+
+func withWireStats(ctx context.Context, ws WireStats) context.Context {
+	return context.WithValue(ctx, wireStatsKey{}, ws)
+}
+
+// WireStatsFromContext returns the wire stats of the request being served
+// over TCP.
+func WireStatsFromContext(ctx context.Context) (WireStats, bool) {
+	ws, ok := ctx.Value(wireStatsKey{}).(WireStats)
+	return ws, ok
+}
+
+// WithFrameHook calls hook for every TCP frame read or written by the
+// server. The hook runs on the connection goroutine and must not block.
+func WithFrameHook(hook proto.FrameHook) ServerOption {
+	return func(s *Server) {
+		s.frameHook = hook
+	}
+}
+
+// WithClientFrameHook calls hook for every TCP frame read or written by
+// the client.
+func WithClientFrameHook(hook proto.FrameHook) ClientOption {
+	return func(c *Client) {
+		c.frameHook = hook
+	}
+}
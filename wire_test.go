@@ -0,0 +1,50 @@
+package xrpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/dabao-zhao/xrpc/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameHooks(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		server []proto.FrameInfo
+		client []proto.FrameInfo
+		stats  WireStats
+	)
+	record := func(infos *[]proto.FrameInfo) proto.FrameHook {
+		return func(info proto.FrameInfo) {
+			mu.Lock()
+			*infos = append(*infos, info)
+			mu.Unlock()
+		}
+	}
+	capture := func(ctx context.Context, info *CallInfo, args, reply interface{}, next Invoker) error {
+		stats, _ = WireStatsFromContext(ctx)
+		return next(ctx, args, reply)
+	}
+
+	s := NewServerWithCodec(nil, WithFrameHook(record(&server)), WithInterceptors(capture))
+	assert.Nil(t, s.Register(new(Int)))
+	addr := startServer(t, s)
+	c := NewClientWithCodec(nil, addr, WithClientFrameHook(record(&client)))
+	defer c.Close()
+
+	var reply int
+	assert.Nil(t, c.Call("Int.Sum", &Args{A: 1, B: 2}, &reply))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, client, 2)
+	assert.Equal(t, proto.Write, client[0].Dir)
+	assert.Equal(t, proto.Read, client[1].Dir)
+	assert.Len(t, server, 2)
+	assert.Equal(t, proto.Read, server[0].Dir)
+	assert.Equal(t, client[0].Size, server[0].Size)
+	assert.Equal(t, server[0], stats.Frame)
+	assert.True(t, stats.Decode >= 0)
+}
@@ -0,0 +1,178 @@
+// Package xrpcproxy forwards xrpc requests to upstream servers chosen by a
+// Router, for building gateways in front of several services.
+package xrpcproxy
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+const defaultPoolSize = 4
+
+// Router picks the upstream address of a request. params are encoded by
+// the codec of the proxy.
+type Router interface {
+	Route(method string, params []byte) (addr string, err error)
+}
+
+// PrefixRouter routes a method to the upstream of its longest matching
+// prefix, e.g. "Int." or "Int.Sum".
+type PrefixRouter struct {
+	prefixes []string // longest first
+	routes   map[string]string
+}
+
+var _ Router = &PrefixRouter{}
+
+func NewPrefixRouter(routes map[string]string) *PrefixRouter {
+	r := &PrefixRouter{routes: make(map[string]string, len(routes))}
+	for prefix, addr := range routes {
+		r.routes[prefix] = addr
+		r.prefixes = append(r.prefixes, prefix)
+	}
+	sort.Slice(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i]) > len(r.prefixes[j])
+	})
+	return r
+}
+
+func (r *PrefixRouter) Route(method string, params []byte) (string, error) {
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(method, prefix) {
+			return r.routes[prefix], nil
+		}
+	}
+	return "", &xrpc.Error{ErrCode: xrpc.MethodNotFound, ErrMsg: "xrpcproxy: no route for " + method}
+}
+
+// Proxy is a server which forwards the requests for methods it doesn't
+// register itself to the upstream picked by its Router. Params and replies
+// are passed through without decoding them.
+type Proxy struct {
+	*xrpc.Server
+
+	codec      xrpc.Codec
+	router     Router
+	poolSize   int
+	clientOpts []xrpc.ClientOption
+	serverOpts []xrpc.ServerOption
+
+	mu    sync.Mutex
+	pools map[string]*pool
+}
+
+type Option func(*Proxy)
+
+// WithPoolSize sets the number of connections kept per upstream. Defaults
+// to 4.
+func WithPoolSize(n int) Option {
+	return func(p *Proxy) {
+		if n > 0 {
+			p.poolSize = n
+		}
+	}
+}
+
+// WithClientOptions configures the clients of the upstreams.
+func WithClientOptions(opts ...xrpc.ClientOption) Option {
+	return func(p *Proxy) {
+		p.clientOpts = append(p.clientOpts, opts...)
+	}
+}
+
+// WithMirror sends percent (0-100) of the forwarded requests to the shadow
+// server at addr as well, ignoring its responses.
+func WithMirror(addr string, percent float64) Option {
+	return WithClientOptions(xrpc.WithMirror(addr, percent))
+}
+
+// WithServerOptions configures the server of the proxy.
+func WithServerOptions(opts ...xrpc.ServerOption) Option {
+	return func(p *Proxy) {
+		p.serverOpts = append(p.serverOpts, opts...)
+	}
+}
+
+// New returns a proxy serving with codec, which is also used to talk to
+// the upstreams.
+func New(codec xrpc.Codec, router Router, opts ...Option) *Proxy {
+	if codec == nil {
+		codec = xrpc.NewGobCodec()
+	}
+	p := &Proxy{
+		codec:    codec,
+		router:   router,
+		poolSize: defaultPoolSize,
+		pools:    make(map[string]*pool),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.Server = xrpc.NewServerWithCodec(codec, p.serverOpts...)
+	p.Server.SetFallbackHandler(p.forward)
+	return p
+}
+
+func (p *Proxy) forward(ctx context.Context, method string, params []byte) (interface{}, error) {
+	var (
+		addr string
+		err  error
+	)
+	if cr, ok := p.router.(ContextRouter); ok {
+		addr, err = cr.RouteContext(ctx, method, params)
+	} else {
+		addr, err = p.router.Route(method, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reply xrpc.RawMessage
+	err = p.client(addr).CallContext(ctx, method, xrpc.RawMessage(params), &reply,
+		xrpc.WithMetadata(xrpc.MetadataFromContext(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// pool spreads calls to an upstream over several connections, since a
+// client serializes its round trips.
+type pool struct {
+	next    uint32
+	clients []*xrpc.Client
+}
+
+func (p *Proxy) client(addr string) *xrpc.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, ok := p.pools[addr]
+	if !ok {
+		pl = &pool{clients: make([]*xrpc.Client, p.poolSize)}
+		for i := range pl.clients {
+			pl.clients[i] = xrpc.NewClientWithCodec(p.codec, addr, p.clientOpts...)
+		}
+		p.pools[addr] = pl
+	}
+	n := atomic.AddUint32(&pl.next, 1)
+	return pl.clients[n%uint32(len(pl.clients))]
+}
+
+// Close closes the connections to the upstreams.
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for addr, pl := range p.pools {
+		for _, c := range pl.clients {
+			c.Close()
+		}
+		delete(p.pools, addr)
+	}
+}
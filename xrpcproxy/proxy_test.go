@@ -0,0 +1,130 @@
+package xrpcproxy
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/dabao-zhao/xrpc/jsonrpc"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Int int
+
+func (i *Int) Sum(args *Args, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+type Str int
+
+func (s *Str) Echo(args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+func (s *Str) Fail(args *string, reply *string) error {
+	return &xrpc.Error{ErrCode: xrpc.InvalidParamErr, ErrMsg: *args}
+}
+
+// serve serves s on a random local port until the test ends.
+func serve(t *testing.T, s interface{ Serve(net.Listener) error }) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = l.Close() })
+	return l.Addr().String()
+}
+
+func upstream(t *testing.T, codec xrpc.Codec, svc interface{}) string {
+	s := xrpc.NewServerWithCodec(codec)
+	assert.Nil(t, s.Register(svc))
+	return serve(t, s)
+}
+
+func TestPrefixRouter(t *testing.T) {
+	r := NewPrefixRouter(map[string]string{
+		"Int.":    "a",
+		"Int.Sum": "b",
+		"":        "c",
+	})
+	for method, want := range map[string]string{"Int.Sum": "b", "Int.Sub": "a", "Str.Echo": "c"} {
+		addr, err := r.Route(method, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, want, addr)
+	}
+
+	_, err := NewPrefixRouter(nil).Route("Int.Sum", nil)
+	assert.True(t, errors.Is(err, xrpc.ErrMethodNotFound))
+}
+
+func testProxy(t *testing.T, codec xrpc.Codec) {
+	router := NewPrefixRouter(map[string]string{
+		"Int.": upstream(t, codec, new(Int)),
+		"Str.": upstream(t, codec, new(Str)),
+	})
+	p := New(codec, router, WithPoolSize(2))
+	defer p.Close()
+
+	c := xrpc.NewClientWithCodec(codec, serve(t, p))
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		var sum int
+		assert.Nil(t, c.Call("Int.Sum", &Args{A: i, B: 2}, &sum))
+		assert.Equal(t, i+2, sum)
+	}
+
+	var echo string
+	msg := "hello"
+	assert.Nil(t, c.Call("Str.Echo", &msg, &echo))
+	assert.Equal(t, "hello", echo)
+
+	err := c.Call("Str.Fail", &msg, &echo)
+	assert.True(t, errors.Is(err, xrpc.ErrInvalidParams))
+
+	err = c.Call("Nope.Echo", &msg, &echo)
+	assert.True(t, errors.Is(err, xrpc.ErrMethodNotFound))
+}
+
+func TestProxy_Gob(t *testing.T) {
+	testProxy(t, xrpc.NewGobCodec())
+}
+
+func TestProxy_JSON(t *testing.T) {
+	testProxy(t, jsonrpc.NewJSONCodec())
+}
+
+type Counter struct {
+	calls chan string
+}
+
+func (c *Counter) Hit(args *string, reply *string) error {
+	c.calls <- *args
+	*reply = *args
+	return nil
+}
+
+func TestProxy_Mirror(t *testing.T) {
+	codec := xrpc.NewGobCodec()
+	primary, shadow := &Counter{calls: make(chan string, 1)}, &Counter{calls: make(chan string, 1)}
+	router := NewPrefixRouter(map[string]string{"": upstream(t, codec, primary)})
+	p := New(codec, router, WithPoolSize(1), WithMirror(upstream(t, codec, shadow), 100))
+	defer p.Close()
+	c := xrpc.NewClientWithCodec(codec, serve(t, p))
+	defer c.Close()
+
+	var reply string
+	msg := "hi"
+	assert.Nil(t, c.Call("Counter.Hit", &msg, &reply))
+	assert.Equal(t, "hi", <-primary.calls)
+	assert.Equal(t, "hi", <-shadow.calls)
+}
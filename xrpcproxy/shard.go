@@ -0,0 +1,102 @@
+package xrpcproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+const shardReplicas = 100
+
+// KeyFunc extracts the shard key of a request.
+type KeyFunc func(method string, params []byte) (string, error)
+
+// JSONPathKey extracts the key at a dotted path such as "$.user.id" from
+// JSON params.
+func JSONPathKey(path string) KeyFunc {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var fields []string
+	if path != "" {
+		fields = strings.Split(path, ".")
+	}
+	return func(method string, params []byte) (string, error) {
+		var v interface{}
+		if err := json.Unmarshal(params, &v); err != nil {
+			return "", err
+		}
+		for _, field := range fields {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("xrpcproxy: no %s in params of %s", path, method)
+			}
+			if v, ok = obj[field]; !ok {
+				return "", fmt.Errorf("xrpcproxy: no %s in params of %s", path, method)
+			}
+		}
+		switch key := v.(type) {
+		case string:
+			return key, nil
+		case float64:
+			return strconv.FormatFloat(key, 'f', -1, 64), nil
+		default:
+			return "", fmt.Errorf("xrpcproxy: %s of %s is not a string or a number", path, method)
+		}
+	}
+}
+
+// ShardRouter consistently hashes the key of each request onto the
+// upstreams, so requests for the same key keep going to the same upstream
+// and adding one moves only a share of the keys.
+type ShardRouter struct {
+	key    KeyFunc
+	hashes []uint32
+	addrs  map[uint32]string
+}
+
+var _ Router = &ShardRouter{}
+
+func NewShardRouter(key KeyFunc, addrs ...string) *ShardRouter {
+	r := &ShardRouter{key: key, addrs: make(map[uint32]string)}
+	for _, addr := range addrs {
+		for i := 0; i < shardReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(addr + "#" + strconv.Itoa(i)))
+			if _, dup := r.addrs[h]; dup {
+				continue
+			}
+			r.addrs[h] = addr
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Pick returns the upstream of key. Clients can shard their own calls by
+// passing it to xrpc.WithTarget.
+func (r *ShardRouter) Pick(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.addrs[r.hashes[i]]
+}
+
+func (r *ShardRouter) Route(method string, params []byte) (string, error) {
+	if len(r.hashes) == 0 {
+		return "", &xrpc.Error{ErrCode: xrpc.MethodNotFound, ErrMsg: "xrpcproxy: no upstream for " + method}
+	}
+	key, err := r.key(method, params)
+	if err != nil {
+		return "", &xrpc.Error{ErrCode: xrpc.InvalidParamErr, ErrMsg: err.Error()}
+	}
+	return r.Pick(key), nil
+}
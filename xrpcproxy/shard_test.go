@@ -0,0 +1,90 @@
+package xrpcproxy
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/dabao-zhao/xrpc/jsonrpc"
+)
+
+func TestJSONPathKey(t *testing.T) {
+	key := JSONPathKey("$.user.id")
+	k, err := key("M.N", []byte(`{"user":{"id":"u1"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "u1", k)
+
+	k, err = key("M.N", []byte(`{"user":{"id":42}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "42", k)
+
+	_, err = key("M.N", []byte(`{"user":"u1"}`))
+	assert.NotNil(t, err)
+	_, err = key("M.N", []byte(`{"user":{"id":true}}`))
+	assert.NotNil(t, err)
+
+	k, err = JSONPathKey("$")("M.N", []byte(`"top"`))
+	assert.Nil(t, err)
+	assert.Equal(t, "top", k)
+}
+
+func TestShardRouter_Consistent(t *testing.T) {
+	r := NewShardRouter(nil, "a", "b", "c")
+	grown := NewShardRouter(nil, "a", "b", "c", "d")
+
+	counts := map[string]int{}
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		addr := r.Pick(key)
+		assert.Equal(t, addr, r.Pick(key))
+		counts[addr]++
+		if to := grown.Pick(key); to != addr {
+			assert.Equal(t, "d", to)
+			moved++
+		}
+	}
+	assert.Len(t, counts, 3)
+	assert.True(t, moved > 100 && moved < 400, "moved %d keys", moved)
+
+	assert.Equal(t, "", NewShardRouter(nil).Pick("x"))
+}
+
+type Who string
+
+func (w *Who) Am(args *map[string]string, reply *string) error {
+	*reply = string(*w)
+	return nil
+}
+
+func TestProxy_Sharded(t *testing.T) {
+	codec := jsonrpc.NewJSONCodec()
+	var addrs []string
+	for _, name := range []string{"a", "b", "c"} {
+		who := Who(name)
+		addrs = append(addrs, upstream(t, codec, &who))
+	}
+	router := NewShardRouter(JSONPathKey("user"), addrs...)
+	p := New(codec, router)
+	defer p.Close()
+	c := xrpc.NewClientWithCodec(codec, serve(t, p))
+	defer c.Close()
+
+	seen := map[string]string{}
+	for i := 0; i < 20; i++ {
+		user := strconv.Itoa(i % 5)
+		var who string
+		assert.Nil(t, c.Call("Who.Am", &map[string]string{"user": user}, &who))
+		if prev, ok := seen[user]; ok {
+			assert.Equal(t, prev, who)
+		}
+		seen[user] = who
+	}
+
+	var who string
+	err := c.Call("Who.Am", &map[string]string{}, &who)
+	assert.True(t, errors.Is(err, xrpc.ErrInvalidParams))
+}
@@ -0,0 +1,192 @@
+package xrpcproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+const (
+	// GroupKey is the metadata key pinning a request to an upstream group
+	// of a SplitRouter, whatever the weights, e.g. to test a canary.
+	GroupKey = "xrpc-upstream-group"
+	// StickyKey is the metadata key of a session or user id. Requests
+	// carrying the same one go to the same group while the weights hold,
+	// and raising the weight of a group only moves sessions to it.
+	StickyKey = "xrpc-sticky"
+)
+
+// ContextRouter is a Router which also sees the context of the request,
+// e.g. its metadata. The proxy calls RouteContext instead of Route.
+type ContextRouter interface {
+	Router
+	RouteContext(ctx context.Context, method string, params []byte) (addr string, err error)
+}
+
+// SplitRouter splits the traffic between groups of upstreams by weight,
+// for blue/green deploys and canaries: each group is a version of the
+// backends behind its own Router. The weights can change at runtime, see
+// SetWeights and Handler.
+type SplitRouter struct {
+	groups map[string]Router
+
+	mu      sync.RWMutex
+	weights map[string]float64
+	names   []string // of the groups with a weight, sorted
+	total   float64
+}
+
+var _ ContextRouter = &SplitRouter{}
+
+// NewSplitRouter returns a router splitting the traffic between groups
+// according to weights, e.g. {"blue": 90, "green": 10}.
+func NewSplitRouter(groups map[string]Router, weights map[string]float64) (*SplitRouter, error) {
+	r := &SplitRouter{groups: groups}
+	if err := r.SetWeights(weights); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetWeights replaces the weights of the groups. Weights are relative,
+// groups left out get no traffic but those pinned to them with GroupKey.
+func (r *SplitRouter) SetWeights(weights map[string]float64) error {
+	var (
+		names []string
+		total float64
+	)
+	for name, w := range weights {
+		if _, ok := r.groups[name]; !ok {
+			return fmt.Errorf("xrpcproxy: no upstream group %s", name)
+		}
+		if w < 0 {
+			return fmt.Errorf("xrpcproxy: negative weight %v for group %s", w, name)
+		}
+		if w > 0 {
+			names = append(names, name)
+			total += w
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("xrpcproxy: no group has a weight")
+	}
+	sort.Strings(names)
+
+	copied := make(map[string]float64, len(weights))
+	for name, w := range weights {
+		copied[name] = w
+	}
+	r.mu.Lock()
+	r.weights, r.names, r.total = copied, names, total
+	r.mu.Unlock()
+	return nil
+}
+
+// Weights returns the current weights of the groups.
+func (r *SplitRouter) Weights() map[string]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	weights := make(map[string]float64, len(r.weights))
+	for name, w := range r.weights {
+		weights[name] = w
+	}
+	return weights
+}
+
+func (r *SplitRouter) Route(method string, params []byte) (string, error) {
+	return r.RouteContext(context.Background(), method, params)
+}
+
+func (r *SplitRouter) RouteContext(ctx context.Context, method string, params []byte) (string, error) {
+	return r.route(xrpc.MetadataFromContext(ctx), method, params)
+}
+
+func (r *SplitRouter) route(md xrpc.Metadata, method string, params []byte) (string, error) {
+	if name := md.Get(GroupKey); name != "" {
+		group, ok := r.groups[name]
+		if !ok {
+			return "", &xrpc.Error{ErrCode: xrpc.InvalidRequest, ErrMsg: "xrpcproxy: no upstream group " + name}
+		}
+		return group.Route(method, params)
+	}
+
+	if key := md.Get(StickyKey); key != "" {
+		return r.groups[r.pickSticky(key)].Route(method, params)
+	}
+	return r.groups[r.pick(rand.Float64())].Route(method, params)
+}
+
+// pick returns the group the point of the weight line falls in.
+func (r *SplitRouter) pick(point float64) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	at := point * r.total
+	for _, name := range r.names {
+		if at < r.weights[name] {
+			return name
+		}
+		at -= r.weights[name]
+	}
+	return r.names[len(r.names)-1]
+}
+
+// pickSticky returns the group of key by weighted rendezvous hashing: the
+// one of the lowest -ln(h)/weight, h hashing key with the group name in
+// (0, 1). Raising a weight only lowers the score of its group, so keys
+// move to it and nowhere else, however many groups there are.
+func (r *SplitRouter) pickSticky(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var (
+		picked string
+		best   = math.Inf(1)
+	)
+	for _, name := range r.names {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(name))
+		unit := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		if score := -math.Log(unit) / r.weights[name]; score < best {
+			picked, best = name, score
+		}
+	}
+	return picked
+}
+
+// Handler returns an HTTP handler reading the weights with GET and
+// replacing them with PUT, as a JSON object. Mount it on the admin
+// handler of the proxy, e.g.
+//
+//	New(codec, r, WithServerOptions(xrpc.WithAdminRoute("/split", r.Handler())))
+func (r *SplitRouter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var weights map[string]float64
+			if err := json.NewDecoder(req.Body).Decode(&weights); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := r.SetWeights(weights); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.Weights())
+	})
+}
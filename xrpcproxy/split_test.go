@@ -0,0 +1,191 @@
+package xrpcproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+func splitGroups() map[string]Router {
+	return map[string]Router{
+		"blue":  NewPrefixRouter(map[string]string{"": "blue:1"}),
+		"green": NewPrefixRouter(map[string]string{"": "green:1"}),
+	}
+}
+
+func TestSplitRouter(t *testing.T) {
+	r, err := NewSplitRouter(splitGroups(), map[string]float64{"blue": 90, "green": 10})
+	assert.Nil(t, err)
+	assert.Equal(t, "blue", r.pick(0))
+	assert.Equal(t, "blue", r.pick(0.89))
+	assert.Equal(t, "green", r.pick(0.9))
+	assert.Equal(t, "green", r.pick(0.999))
+
+	// the split holds roughly
+	green := 0
+	for i := 0; i < 1000; i++ {
+		addr, err := r.Route("Int.Sum", nil)
+		assert.Nil(t, err)
+		if addr == "green:1" {
+			green++
+		}
+	}
+	assert.InDelta(t, 100, green, 60)
+
+	// pinned
+	assert.Nil(t, r.SetWeights(map[string]float64{"blue": 1}))
+	addr, err := r.route(xrpc.Metadata{GroupKey: "green"}, "Int.Sum", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "green:1", addr)
+	_, err = r.route(xrpc.Metadata{GroupKey: "red"}, "Int.Sum", nil)
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, r.SetWeights(map[string]float64{"red": 1}))
+	assert.NotNil(t, r.SetWeights(map[string]float64{"blue": -1, "green": 2}))
+	assert.NotNil(t, r.SetWeights(map[string]float64{"blue": 0}))
+	assert.Equal(t, map[string]float64{"blue": 1}, r.Weights())
+}
+
+func TestSplitRouter_Sticky(t *testing.T) {
+	r, err := NewSplitRouter(splitGroups(), map[string]float64{"blue": 50, "green": 50})
+	assert.Nil(t, err)
+
+	route := func() map[string]string {
+		addrs := make(map[string]string)
+		for i := 0; i < 100; i++ {
+			user := "user" + strconv.Itoa(i)
+			addr, err := r.route(xrpc.Metadata{StickyKey: user}, "Int.Sum", nil)
+			assert.Nil(t, err)
+			addrs[user] = addr
+		}
+		return addrs
+	}
+	before := route()
+	assert.Equal(t, before, route())
+
+	// raising the weight of green only moves users to it
+	assert.Nil(t, r.SetWeights(map[string]float64{"blue": 50, "green": 100}))
+	for user, addr := range route() {
+		if before[user] == "green:1" {
+			assert.Equal(t, "green:1", addr, user)
+		}
+	}
+}
+
+func TestSplitRouter_StickyThreeGroups(t *testing.T) {
+	groups := splitGroups()
+	groups["red"] = NewPrefixRouter(map[string]string{"": "red:1"})
+	r, err := NewSplitRouter(groups, map[string]float64{"blue": 50, "green": 30, "red": 20})
+	assert.Nil(t, err)
+
+	route := func() map[string]string {
+		addrs := make(map[string]string)
+		for i := 0; i < 2000; i++ {
+			user := "user" + strconv.Itoa(i)
+			addr, err := r.route(xrpc.Metadata{StickyKey: user}, "Int.Sum", nil)
+			assert.Nil(t, err)
+			addrs[user] = addr
+		}
+		return addrs
+	}
+	count := func(addrs map[string]string, addr string) (n int) {
+		for _, a := range addrs {
+			if a == addr {
+				n++
+			}
+		}
+		return n
+	}
+	before := route()
+	assert.InDelta(t, 1000, count(before, "blue:1"), 150)
+	assert.InDelta(t, 600, count(before, "green:1"), 150)
+	assert.InDelta(t, 400, count(before, "red:1"), 150)
+
+	// raising the weight of green moves users from blue and red to green
+	// only, never between blue and red
+	assert.Nil(t, r.SetWeights(map[string]float64{"blue": 50, "green": 60, "red": 20}))
+	after := route()
+	moved := 0
+	for user, addr := range after {
+		if addr != before[user] {
+			assert.Equal(t, "green:1", addr, user)
+			moved++
+		}
+	}
+	assert.NotZero(t, moved)
+	assert.InDelta(t, 923, count(after, "green:1"), 150)
+}
+
+func TestSplitRouter_Handler(t *testing.T) {
+	r, err := NewSplitRouter(splitGroups(), map[string]float64{"blue": 100})
+	assert.Nil(t, err)
+	h := r.Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"blue": 95, "green": 5}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"blue": 95, "green": 5}`, w.Body.String())
+	assert.Equal(t, map[string]float64{"blue": 95, "green": 5}, r.Weights())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"red": 5}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.JSONEq(t, `{"blue": 95, "green": 5}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+type Version string
+
+func (v *Version) Get(args *int, reply *string) error {
+	*reply = string(*v)
+	return nil
+}
+
+func TestProxy_Split(t *testing.T) {
+	codec := xrpc.NewGobCodec()
+	blue, green := Version("blue"), Version("green")
+	r, err := NewSplitRouter(map[string]Router{
+		"blue":  NewPrefixRouter(map[string]string{"": upstream(t, codec, &blue)}),
+		"green": NewPrefixRouter(map[string]string{"": upstream(t, codec, &green)}),
+	}, map[string]float64{"blue": 1})
+	assert.Nil(t, err)
+	p := New(codec, r)
+	defer p.Close()
+	c := xrpc.NewClientWithCodec(codec, serve(t, p))
+	defer c.Close()
+
+	var reply string
+	assert.Nil(t, c.Call("Version.Get", new(int), &reply))
+	assert.Equal(t, "blue", reply)
+	assert.Nil(t, c.Call("Version.Get", new(int), &reply, xrpc.WithMetadata(xrpc.Metadata{GroupKey: "green"})))
+	assert.Equal(t, "green", reply)
+}
+
+func TestProxy_SplitAdminRoute(t *testing.T) {
+	r, err := NewSplitRouter(splitGroups(), map[string]float64{"blue": 100})
+	assert.Nil(t, err)
+	p := New(xrpc.NewGobCodec(), r, WithServerOptions(xrpc.WithAdminRoute("/split", r.Handler())))
+	defer p.Close()
+	h := p.AdminHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/split", strings.NewReader(`{"blue": 50, "green": 50}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]float64{"blue": 50, "green": 50}, r.Weights())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/methods", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,111 @@
+package xrpctest
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+)
+
+// leakTimeout bounds the wait for goroutines and connections to wind down
+// after a test.
+var leakTimeout = 2 * time.Second
+
+const xrpcPkg = "github.com/dabao-zhao/xrpc"
+
+// VerifyNoLeaks fails t if, once t and its cleanups registered before are
+// done, goroutines of the package started during t are still running or
+// connections opened during t are still open. Call it first:
+//
+//	func TestX(t *testing.T) {
+//		xrpctest.VerifyNoLeaks(t)
+//		...
+//	}
+//
+// Tests running in parallel with t are counted as leaks of t.
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+	before := goroutineIDs()
+	conns := xrpc.OpenConns()
+
+	t.Cleanup(func() {
+		var (
+			leaked []string
+			open   int
+		)
+		for deadline := time.Now().Add(leakTimeout); ; time.Sleep(10 * time.Millisecond) {
+			leaked, open = leakedGoroutines(before), xrpc.OpenConns()-conns
+			if len(leaked) == 0 && open <= 0 || time.Now().After(deadline) {
+				break
+			}
+		}
+		if open > 0 {
+			t.Errorf("xrpctest: %d connections left open", open)
+		}
+		if len(leaked) > 0 {
+			t.Errorf("xrpctest: %d goroutines leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	})
+}
+
+// leakedGoroutines returns the stacks of the goroutines of the package
+// missing from before.
+func leakedGoroutines(before map[string]bool) []string {
+	var leaked []string
+	for _, g := range goroutines() {
+		if !before[goroutineID(g)] && inPackage(g) {
+			leaked = append(leaked, g)
+		}
+	}
+	return leaked
+}
+
+func goroutineIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for _, g := range goroutines() {
+		ids[goroutineID(g)] = true
+	}
+	return ids
+}
+
+// goroutines returns the stacks of all goroutines.
+func goroutines() []string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return strings.Split(strings.TrimSpace(string(buf[:n])), "\n\n")
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineID returns the id of the goroutine of stack, from its
+// "goroutine 7 [running]:" header.
+func goroutineID(stack string) string {
+	header := strings.SplitN(stack, " ", 3)
+	if len(header) < 2 {
+		return ""
+	}
+	return header[1]
+}
+
+// inPackage reports whether a function of the package, but not of this
+// one or of tests, runs or started the goroutine of stack.
+func inPackage(stack string) bool {
+	for _, line := range strings.Split(stack, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			continue // file:line
+		}
+		fn := strings.TrimPrefix(line, "created by ")
+		if strings.HasPrefix(fn, xrpcPkg+"/xrpctest.") || strings.Contains(fn, ".Test") {
+			return false
+		}
+		if strings.HasPrefix(fn, xrpcPkg+".") || strings.HasPrefix(fn, xrpcPkg+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package xrpctest
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a testing.TB running its cleanups on demand.
+type recorder struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recorder) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	VerifyNoLeaks(t)
+
+	s := xrpc.NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Echo)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+
+	c := xrpc.NewClientWithCodec(nil, l.Addr().String())
+	var reply string
+	assert.Nil(t, c.Call("Echo.Say", &[]string{"hi"}[0], &reply))
+	c.Close()
+}
+
+func TestVerifyNoLeaks_Leak(t *testing.T) {
+	defer func(d time.Duration) { leakTimeout = d }(leakTimeout)
+	leakTimeout = 100 * time.Millisecond
+
+	s := xrpc.NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Echo)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	r := &recorder{TB: t}
+	VerifyNoLeaks(r)
+	go func() { _ = s.Serve(l) }()
+	c := xrpc.NewClientWithCodec(nil, l.Addr().String())
+	defer c.Close()
+	var reply string
+	assert.Nil(t, c.Call("Echo.Say", &[]string{"hi"}[0], &reply))
+
+	r.finish()
+	if assert.Len(t, r.errors, 2) {
+		assert.Equal(t, "xrpctest: 2 connections left open", r.errors[0])
+		assert.Contains(t, r.errors[1], "serveConn")
+	}
+}
@@ -0,0 +1,223 @@
+// Package xrpctest helps testing xrpc servers and clients.
+package xrpctest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/dabao-zhao/xrpc/proto"
+)
+
+// tapMagic starts a capture. Each record then is
+// conn(32bit):dir(8bit):time(64bit):len(32bit):data, the time in unix
+// nanoseconds.
+const tapMagic = "XRPCTAP1"
+
+const tapRecordHeader = 4 + 1 + 8 + 4
+
+// Tap records the bytes going through connections, to be decoded into
+// frames with ReadTap or the xrpc-tap tool.
+type Tap struct {
+	mu       sync.Mutex
+	w        io.Writer
+	started  bool
+	nextConn uint32
+	err      error
+}
+
+// NewTap returns a tap recording to w.
+func NewTap(w io.Writer) *Tap {
+	return &Tap{w: w}
+}
+
+// Err returns the first error writing the capture.
+func (t *Tap) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.err
+}
+
+// Conn returns conn recording its traffic.
+func (t *Tap) Conn(conn net.Conn) net.Conn {
+	t.mu.Lock()
+	t.nextConn++
+	id := t.nextConn
+	t.mu.Unlock()
+
+	return &tapConn{Conn: conn, tap: t, id: id}
+}
+
+// Listener returns l recording the traffic of the connections it accepts,
+// for Server.Serve.
+func (t *Tap) Listener(l net.Listener) net.Listener {
+	return &tapListener{Listener: l, tap: t}
+}
+
+// Dialer returns d recording the traffic of the connections it opens, for
+// xrpc.WithDialer. A nil d is a net.Dialer.
+func (t *Tap) Dialer(d xrpc.Dialer) xrpc.Dialer {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	return xrpc.DialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.Conn(conn), nil
+	})
+}
+
+func (t *Tap) record(id uint32, dir proto.Direction, data []byte) {
+	buf := make([]byte, tapRecordHeader, tapRecordHeader+len(data))
+	binary.BigEndian.PutUint32(buf, id)
+	buf[4] = byte(dir)
+	binary.BigEndian.PutUint64(buf[5:], uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint32(buf[13:], uint32(len(data)))
+	buf = append(buf, data...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return
+	}
+	if !t.started {
+		if _, t.err = io.WriteString(t.w, tapMagic); t.err != nil {
+			return
+		}
+		t.started = true
+	}
+	_, t.err = t.w.Write(buf)
+}
+
+type tapListener struct {
+	net.Listener
+	tap *Tap
+}
+
+func (l *tapListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.tap.Conn(conn), nil
+}
+
+type tapConn struct {
+	net.Conn
+	tap *Tap
+	id  uint32
+}
+
+func (c *tapConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.tap.record(c.id, proto.Read, b[:n])
+	}
+	return n, err
+}
+
+func (c *tapConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.tap.record(c.id, proto.Write, b[:n])
+	}
+	return n, err
+}
+
+// TapFrame is a frame of a capture. Dir tells whether the tapped side read
+// or wrote it.
+type TapFrame struct {
+	Conn  uint32
+	Dir   proto.Direction
+	Time  time.Time // when its first byte went through
+	Frame *proto.Proto
+}
+
+// ErrNotTap is returned by ReadTap for data which isn't a capture.
+var ErrNotTap = errors.New("xrpctest: not a tap capture")
+
+// ReadTap decodes the frames of a capture, ordered by time. Frames cut
+// short at the end of the capture are left out.
+func ReadTap(r io.Reader) ([]TapFrame, error) {
+	magic := make([]byte, len(tapMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, ErrNotTap
+	}
+	if string(magic) != tapMagic {
+		return nil, ErrNotTap
+	}
+
+	type stream struct {
+		conn  uint32
+		dir   proto.Direction
+		data  []byte
+		times []time.Time // of each byte range, by its start offset
+		start []int
+	}
+	var streams []*stream
+	byKey := make(map[[2]uint32]*stream)
+
+	header := make([]byte, tapRecordHeader)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		id := binary.BigEndian.Uint32(header)
+		dir := proto.Direction(header[4])
+		at := time.Unix(0, int64(binary.BigEndian.Uint64(header[5:])))
+		data := make([]byte, binary.BigEndian.Uint32(header[13:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+
+		key := [2]uint32{id, uint32(dir)}
+		s := byKey[key]
+		if s == nil {
+			s = &stream{conn: id, dir: dir}
+			byKey[key] = s
+			streams = append(streams, s)
+		}
+		s.times = append(s.times, at)
+		s.start = append(s.start, len(s.data))
+		s.data = append(s.data, data...)
+	}
+
+	var frames []TapFrame
+	for _, s := range streams {
+		for off := 0; off+4 <= len(s.data); {
+			packLen := int(binary.BigEndian.Uint32(s.data[off:]))
+			if packLen < 4 || off+packLen > len(s.data) {
+				break
+			}
+			p := proto.New()
+			if err := p.ReadTCP(bufio.NewReader(bytes.NewReader(s.data[off : off+packLen]))); err != nil {
+				return nil, err
+			}
+			i := sort.SearchInts(s.start, off+1) - 1
+			frames = append(frames, TapFrame{Conn: s.conn, Dir: s.dir, Time: s.times[i], Frame: p})
+			off += packLen
+		}
+	}
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].Time.Before(frames[j].Time)
+	})
+	return frames, nil
+}
@@ -0,0 +1,112 @@
+package xrpctest
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dabao-zhao/xrpc"
+	"github.com/dabao-zhao/xrpc/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+type Echo struct{}
+
+func (e *Echo) Say(args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+func TestTap(t *testing.T) {
+	s := xrpc.NewServerWithCodec(nil)
+	assert.Nil(t, s.Register(new(Echo)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var serverSide syncBuffer
+	var clientSide bytes.Buffer
+	go func() { _ = s.Serve(NewTap(&serverSide).Listener(l)) }()
+	tap := NewTap(&clientSide)
+	c := xrpc.NewClientWithCodec(nil, l.Addr().String(), xrpc.WithDialer(tap.Dialer(nil)))
+
+	var reply string
+	assert.Nil(t, c.Call("Echo.Say", &[]string{"hi"}[0], &reply))
+	assert.Nil(t, c.Call("Echo.Say", &[]string{"ho"}[0], &reply))
+	c.Close()
+	assert.Nil(t, tap.Err())
+
+	frames, err := ReadTap(&clientSide)
+	assert.Nil(t, err)
+	assert.Len(t, frames, 4)
+	for i, f := range frames {
+		assert.Equal(t, uint32(1), f.Conn)
+		if i%2 == 0 {
+			assert.Equal(t, proto.Write, f.Dir)
+			assert.Equal(t, proto.OpRequest, f.Frame.Op)
+			reqs, err := xrpc.NewGobCodec().ReadRequest(f.Frame.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, "Echo.Say", reqs[0].GetMethod())
+		} else {
+			assert.Equal(t, proto.Read, f.Dir)
+		}
+	}
+	assert.False(t, frames[1].Time.Before(frames[0].Time))
+
+	// the server side saw the same frames the other way round
+	deadline := time.Now().Add(time.Second)
+	for {
+		frames, err = ReadTap(bytes.NewReader(serverSide.Bytes()))
+		if len(frames) == 4 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, err)
+	assert.Len(t, frames, 4)
+	assert.Equal(t, proto.Read, frames[0].Dir)
+	assert.Equal(t, proto.OpRequest, frames[0].Frame.Op)
+	assert.Equal(t, proto.Write, frames[1].Dir)
+}
+
+// syncBuffer is a bytes.Buffer safe to read while written.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func TestReadTap(t *testing.T) {
+	frames, err := ReadTap(bytes.NewReader(nil))
+	assert.Nil(t, err)
+	assert.Empty(t, frames)
+
+	_, err = ReadTap(bytes.NewReader([]byte("GET / HTTP/1.1")))
+	assert.Equal(t, ErrNotTap, err)
+
+	// a frame cut short is left out
+	var buf bytes.Buffer
+	tap := NewTap(&buf)
+	server, client := net.Pipe()
+	conn := tap.Conn(client)
+	go func() { _, _ = server.Read(make([]byte, 100)) }()
+	_, _ = conn.Write([]byte{0, 0, 0, 20, 0, 12})
+	frames, err = ReadTap(&buf)
+	assert.Nil(t, err)
+	assert.Empty(t, frames)
+}